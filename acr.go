@@ -0,0 +1,62 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Authentication context claims, see OIDC Core §2 and RFC 8176
+const (
+	ACRClaim = "acr"
+	AMRClaim = "amr"
+)
+
+// AuthenticationContextVerifier defines the optional hook reporting how the user authenticated with the password grant
+type AuthenticationContextVerifier interface {
+	// AuthenticationContext returns the achieved authentication context class and methods (e.g. "pwd", "otp")
+	// of the user validated by ValidateUser
+	AuthenticationContext(username string, r *http.Request) (acr string, amr []string)
+}
+
+// ACRValues returns the requested authentication context classes of the acr_values parameter
+// of the authorization request, by order of preference
+func ACRValues(r *http.Request) []string {
+	return strings.Fields(r.FormValue("acr_values"))
+}
+
+// setAuthenticationContext adds the acr and amr claims reported for the grant to the tokens
+func setAuthenticationContext(token *Token, refresh *RefreshToken, acr string, amr []string) {
+	if acr == "" && len(amr) == 0 {
+		return
+	}
+	if token.Claims == nil {
+		token.Claims = Claims{}
+		refresh.Claims = token.Claims
+	}
+	if acr != "" {
+		token.Claims[ACRClaim] = acr
+	}
+	if len(amr) > 0 {
+		token.Claims[AMRClaim] = amr
+	}
+}
+
+// RequireACR is the resource server middleware, after Authorize, rejecting the tokens whose acr claim is not one of
+// the acceptable values with the RFC 9470 insufficient_user_authentication challenge
+func RequireACR(acceptable ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(ClaimsContext).(Claims)
+			acr, _ := claims.GetString(ACRClaim)
+			for _, a := range acceptable {
+				if acr == a {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="A different authentication level is required", acr_values="%s"`, strings.Join(acceptable, " ")))
+			renderJSON(w, "Not authorized: insufficient user authentication", true, http.StatusUnauthorized)
+		})
+	}
+}
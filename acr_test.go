@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type acrVerifier struct {
+	TestUserVerifier
+}
+
+func (acrVerifier) AuthenticationContext(username string, r *http.Request) (string, []string) {
+	return "silver", []string{"pwd", "otp"}
+}
+
+func TestAuthenticationContextClaims(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(acrVerifier), nil)
+	resp, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if amr, _ := token.Claims.GetStrings(AMRClaim); token.Claims[ACRClaim] != "silver" || len(amr) != 2 {
+		t.Fatalf("Error claims = %v", token.Claims)
+	}
+
+	sut.RefreshClaims = true
+	resp, status = sut.generateTokenResponse(RefreshTokenGrant, "", "", resp.(*TokenResponse).RefreshToken, "", "", "", new(http.Request))
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, _ = sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if amr, _ := token.Claims.GetStrings(AMRClaim); token.Claims[ACRClaim] != "silver" || len(amr) != 2 || token.Claims["customer_id"] != "1001" {
+		t.Fatalf("Error refreshed claims = %v", token.Claims)
+	}
+
+	sut = NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.AuthCodeStore = NewMemoryAuthCodeStore()
+	code, _ := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111", ACR: "gold", AMR: []string{"hwk"}})
+	resp, status = sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", &http.Request{Form: url.Values{}})
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, _ = sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if token.Claims[ACRClaim] != "gold" {
+		t.Fatalf("Error claims = %v", token.Claims)
+	}
+
+	if values := ACRValues(httptest.NewRequest("GET", "/authorize?acr_values=gold+silver", nil)); len(values) != 2 || values[0] != "gold" {
+		t.Fatalf("Error acr_values = %v", values)
+	}
+}
+
+func TestRequireACR(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(acrVerifier), nil)
+	resp, _ := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	ba := NewBearerAuthentication("mySecretKey-10101", nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	call := func(acceptable ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+resp.(*TokenResponse).Token)
+		w := httptest.NewRecorder()
+		ba.Authorize(RequireACR(acceptable...)(ok)).ServeHTTP(w, req)
+		return w
+	}
+	if w := call("gold", "silver"); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	w := call("gold")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), `acr_values="gold"`) {
+		t.Fatalf("Error StatusCode = %d, WWW-Authenticate = %s", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,261 @@
+package oauth
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// DefaultAdminScope is the scope of the access tokens accepted by the AdminHandler when the server AdminScope is empty.
+const DefaultAdminScope = "oauth:admin"
+
+// AdminVerifier defines the interface authorizing the callers of the AdminHandler, required by the AdminHandler
+type AdminVerifier interface {
+	// ValidateAdmin returns an error if the credential of the access token is not an administrator
+	ValidateAdmin(credential string, r *http.Request) error
+}
+
+// ClientSecretResponse is the client registration returned by the admin API with the plaintext secret,
+// only set when the secret is created or rotated.
+type ClientSecretResponse struct {
+	*Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AdminHandler returns the client administration API backed by the ClientStore, which must implement ClientAdminStore.
+// Mount it under a prefix with http.StripPrefix, it serves:
+//
+//	GET    /clients              list the clients
+//	POST   /clients              create a client, a secret is generated for the confidential clients
+//	GET    /clients/{id}         get a client
+//	PUT    /clients/{id}         update a client, keeping its secret
+//	DELETE /clients/{id}         delete a client
+//	POST   /clients/{id}/secret  rotate the client secret
+//	GET    /clients/{id}/stats   get the UsageTracker counters of the client
+//	GET    /usage/{kind}         list a page of the UsageTracker counters of the clients or the users (?after=&limit=)
+//	GET    /usage/{kind}/{id}    get the UsageTracker counters of a client or a user
+//
+// The requests must carry an access token issued by the server with the AdminScope, and its credential must be
+// authorized by the verifier, which must implement AdminVerifier: the scope alone can be requested by any user.
+func (bs *BearerServer) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = bs.withRequestID(w, r)
+		bs.setSecurityHeaders(w)
+		w = trackResponse(w)
+		defer bs.recoverPanic(w, r)
+		if !bs.authorizeAdmin(w, r) {
+			return
+		}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if parts[0] == "usage" && r.Method == http.MethodGet {
+			bs.usageStats(w, r, parts[1:])
+			return
+		}
+		if parts[0] != "clients" || len(parts) > 3 {
+			bs.renderError(w, r, TokenInvalidRequest, "unknown admin resource", "", http.StatusNotFound)
+			return
+		}
+		store, ok := bs.ClientStore.(ClientAdminStore)
+		if !ok {
+			bs.renderError(w, r, TokenInvalidRequest, "client administration is not enabled", "", http.StatusNotFound)
+			return
+		}
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			bs.listClients(w, r, store)
+		case len(parts) == 1 && r.Method == http.MethodPost:
+			bs.createClient(w, r, store)
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			if c, found := bs.adminClient(w, r, store, parts[1]); found {
+				renderJSON(w, c, true, http.StatusOK)
+			}
+		case len(parts) == 2 && r.Method == http.MethodPut:
+			bs.updateClient(w, r, store, parts[1])
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			if _, found := bs.adminClient(w, r, store, parts[1]); !found {
+				return
+			}
+			if err := store.DeleteClient(parts[1]); err != nil {
+				bs.renderError(w, r, TokenServerError, "deleting client failed: "+err.Error(), "", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case len(parts) == 3 && parts[2] == "secret" && r.Method == http.MethodPost:
+			bs.rotateClientSecret(w, r, store, parts[1])
+		case len(parts) == 3 && parts[2] == "stats" && r.Method == http.MethodGet:
+			bs.clientStats(w, r, store, parts[1])
+		default:
+			bs.renderError(w, r, TokenInvalidRequest, "unknown admin resource", "", http.StatusNotFound)
+		}
+	})
+}
+
+// authorizeAdmin validates the bearer token of the admin request, its AdminScope and its credential with the AdminVerifier
+func (bs *BearerServer) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	ba := &BearerAuthentication{secretKey: bs.secret(), provider: bs.provider, Denylist: bs.Denylist, ReferenceTokens: bs.ReferenceTokens}
+	token, err := ba.checkAuthorizationHeader(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		renderJSON(w, "Not authorized: "+err.Error(), true, http.StatusUnauthorized)
+		return false
+	}
+	scope := bs.AdminScope
+	if scope == "" {
+		scope = DefaultAdminScope
+	}
+	if !token.HasScopes(scope) {
+		renderInsufficientScope(w, scope)
+		return false
+	}
+	v, ok := optionalVerifier(bs.verifier).(AdminVerifier)
+	if !ok {
+		bs.renderError(w, r, TokenInvalidRequest, "client administration requires an AdminVerifier", "", http.StatusNotFound)
+		return false
+	}
+	if err := v.ValidateAdmin(token.Credential, r); err != nil {
+		if resp, ok := overloaded(err); ok {
+			bs.renderResponse(w, r, resp, false, http.StatusServiceUnavailable)
+			return false
+		}
+		renderJSON(w, "Forbidden: "+err.Error(), true, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// adminClient loads the client, rendering 404 when it is not registered
+func (bs *BearerServer) adminClient(w http.ResponseWriter, r *http.Request, store ClientAdminStore, clientID string) (*Client, bool) {
+	c, err := store.GetClient(clientID)
+	if err == ErrClientNotFound {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		bs.renderError(w, r, TokenServerError, "loading client failed: "+err.Error(), "", http.StatusInternalServerError)
+		return nil, false
+	}
+	return c, true
+}
+
+func (bs *BearerServer) listClients(w http.ResponseWriter, r *http.Request, store ClientAdminStore) {
+	clients, err := store.ListClients()
+	if err != nil {
+		bs.renderError(w, r, TokenServerError, "listing clients failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	if clients == nil {
+		clients = []*Client{}
+	}
+	renderJSON(w, clients, true, http.StatusOK)
+}
+
+func (bs *BearerServer) createClient(w http.ResponseWriter, r *http.Request, store ClientAdminStore) {
+	c := new(Client)
+	if !bs.decodeJSON(w, r, c) {
+		return
+	}
+	if c.ID == "" {
+		c.ID = uuid.Must(uuid.NewV4()).String()
+	} else if _, err := store.GetClient(c.ID); err != ErrClientNotFound {
+		if err == nil {
+			bs.renderError(w, r, TokenInvalidRequest, "client already exists", "", http.StatusConflict)
+			return
+		}
+		bs.renderError(w, r, TokenServerError, "loading client failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	if err := DefaultRedirectURIPolicy.ValidateClient(c); err != nil {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusBadRequest)
+		return
+	}
+	resp := ClientSecretResponse{Client: c}
+	if !c.Public {
+		var err error
+		if resp.ClientSecret, c.SecretHash, err = GenerateClientSecret(); err != nil {
+			bs.renderError(w, r, TokenServerError, "generating client secret failed: "+err.Error(), "", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := store.SaveClient(c); err != nil {
+		bs.renderError(w, r, TokenServerError, "saving client failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, resp, true, http.StatusCreated)
+}
+
+func (bs *BearerServer) updateClient(w http.ResponseWriter, r *http.Request, store ClientAdminStore, clientID string) {
+	current, found := bs.adminClient(w, r, store, clientID)
+	if !found {
+		return
+	}
+	c := new(Client)
+	if !bs.decodeJSON(w, r, c) {
+		return
+	}
+	c.ID, c.SecretHash = clientID, current.SecretHash
+	if err := DefaultRedirectURIPolicy.ValidateClient(c); err != nil {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusBadRequest)
+		return
+	}
+	if err := store.SaveClient(c); err != nil {
+		bs.renderError(w, r, TokenServerError, "saving client failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, c, true, http.StatusOK)
+}
+
+func (bs *BearerServer) rotateClientSecret(w http.ResponseWriter, r *http.Request, store ClientAdminStore, clientID string) {
+	current, found := bs.adminClient(w, r, store, clientID)
+	if !found {
+		return
+	}
+	if current.Public {
+		bs.renderError(w, r, TokenInvalidRequest, "public clients have no secret", "", http.StatusBadRequest)
+		return
+	}
+	c := *current
+	secret, hash, err := GenerateClientSecret()
+	if err != nil {
+		bs.renderError(w, r, TokenServerError, "generating client secret failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	c.SecretHash = hash
+	if err = store.SaveClient(&c); err != nil {
+		bs.renderError(w, r, TokenServerError, "saving client failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, ClientSecretResponse{Client: &c, ClientSecret: secret}, true, http.StatusOK)
+}
+
+func (bs *BearerServer) clientStats(w http.ResponseWriter, r *http.Request, store ClientAdminStore, clientID string) {
+	if _, found := bs.adminClient(w, r, store, clientID); !found {
+		return
+	}
+	bs.usageStats(w, r, []string{string(ClientUsage), clientID})
+}
+
+// usageStats renders the UsageTracker counters of the {kind}[/{id}] path
+func (bs *BearerServer) usageStats(w http.ResponseWriter, r *http.Request, path []string) {
+	if bs.UsageTracker == nil {
+		bs.renderError(w, r, TokenInvalidRequest, "usage tracking is not enabled", "", http.StatusNotFound)
+		return
+	}
+	if len(path) == 0 || len(path) > 2 || (UsageKind(path[0]) != ClientUsage && UsageKind(path[0]) != UserUsage) {
+		bs.renderError(w, r, TokenInvalidRequest, "unknown admin resource", "", http.StatusNotFound)
+		return
+	}
+	var usage interface{}
+	var err error
+	if len(path) == 2 {
+		usage, err = bs.UsageTracker.Usage(UsageKind(path[0]), path[1])
+	} else {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		usage, err = bs.UsageTracker.ListUsage(UsageKind(path[0]), r.URL.Query().Get("after"), limit)
+	}
+	if err != nil {
+		bs.renderError(w, r, TokenServerError, "loading usage failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, usage, true, http.StatusOK)
+}
@@ -0,0 +1,120 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type adminVerifier struct {
+	TestUserVerifier
+}
+
+func (adminVerifier) ValidateAdmin(credential string, r *http.Request) error {
+	if credential != "admin" {
+		return errors.New("not an administrator")
+	}
+	return nil
+}
+
+func TestAdminHandler(t *testing.T) {
+	store := NewMemoryClientStore()
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(adminVerifier), nil)
+	sut.ClientStore = store
+	sut.UsageTracker = NewMemoryUsageTracker()
+	admin, _ := sut.IssueToken(context.Background(), UserToken, "admin", "oauth:admin", nil)
+	user, _ := sut.IssueToken(context.Background(), UserToken, "user111", "read", nil)
+	escalated, _ := sut.IssueToken(context.Background(), UserToken, "user111", "oauth:admin", nil)
+	handler := http.StripPrefix("/admin", sut.AdminHandler())
+
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin"+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call("GET", "/clients", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w := call("GET", "/clients", user.Token, ""); w.Code != http.StatusForbidden || !strings.Contains(w.Header().Get("WWW-Authenticate"), "insufficient_scope") {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w := call("GET", "/clients", escalated.Token, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("Error self-requested admin scope StatusCode = %d", w.Code)
+	}
+
+	w := call("POST", "/clients", admin.Token, `{"client_id":"abcdef","grant_types":["client_credentials"]}`)
+	var created ClientSecretResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated || created.ClientSecret == "" {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "argon2id") {
+		t.Fatalf("Error secret hash exposed: %s", w.Body.String())
+	}
+	c, _ := store.GetClient("abcdef")
+	if VerifyClientSecret(c.SecretHash, created.ClientSecret) != nil {
+		t.Fatalf("Error stored secret hash does not match")
+	}
+	if w = call("POST", "/clients", admin.Token, `{"client_id":"abcdef"}`); w.Code != http.StatusConflict {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w = call("POST", "/clients", admin.Token, `{"redirect_uris":["javascript:alert(1)"]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+
+	if w = call("PUT", "/clients/abcdef", admin.Token, `{"grant_types":["client_credentials","password"]}`); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if c, _ = store.GetClient("abcdef"); !c.AllowsGrantType(PasswordGrant) || VerifyClientSecret(c.SecretHash, created.ClientSecret) != nil {
+		t.Fatalf("Error updated client = %+v", c)
+	}
+
+	var rotated ClientSecretResponse
+	w = call("POST", "/clients/abcdef/secret", admin.Token, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &rotated); err != nil || rotated.ClientSecret == "" || rotated.ClientSecret == created.ClientSecret {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if c, _ = store.GetClient("abcdef"); VerifyClientSecret(c.SecretHash, created.ClientSecret) == nil {
+		t.Fatalf("Error old secret still valid")
+	}
+
+	if _, status := sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", created.ClientSecret, "", "", "", "", new(http.Request)); status != http.StatusUnauthorized {
+		t.Fatalf("Error old secret StatusCode = %d", status)
+	}
+	if _, status := sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", rotated.ClientSecret, "", "", "", "", new(http.Request)); status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	var usage Usage
+	w = call("GET", "/clients/abcdef/stats", admin.Token, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil || usage.Issued != 1 || usage.LastIssued.IsZero() {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var clients []*Client
+	w = call("GET", "/clients", admin.Token, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &clients); err != nil || len(clients) != 1 || clients[0].ID != "abcdef" {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w = call("DELETE", "/clients/abcdef", admin.Token, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w = call("GET", "/clients/abcdef", admin.Token, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+
+	sut = NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.ClientStore = store
+	handler = http.StripPrefix("/admin", sut.AdminHandler())
+	if w = call("GET", "/clients", admin.Token, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Error admin API without AdminVerifier StatusCode = %d", w.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"net/http"
+)
+
+// Assertion grant types, see https://datatracker.ietf.org/doc/html/rfc7522 and https://datatracker.ietf.org/doc/html/rfc7523
+const (
+	JWTBearerGrant   GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	SAML2BearerGrant GrantType = "urn:ietf:params:oauth:grant-type:saml2-bearer"
+)
+
+// AssertionGrantHandler validates the assertions of an assertion grant type, see https://datatracker.ietf.org/doc/html/rfc7521
+type AssertionGrantHandler interface {
+	// ValidateAssertion validates the assertion returning the type of the token to issue and the credential it is issued to
+	ValidateAssertion(assertion, clientID, scope string, r *http.Request) (TokenType, string, error)
+}
+
+// AssertionGrantHandlerFunc is an adapter to use ordinary functions as AssertionGrantHandler.
+type AssertionGrantHandlerFunc func(assertion, clientID, scope string, r *http.Request) (TokenType, string, error)
+
+// ValidateAssertion calls f(assertion, clientID, scope, r).
+func (f AssertionGrantHandlerFunc) ValidateAssertion(assertion, clientID, scope string, r *http.Request) (TokenType, string, error) {
+	return f(assertion, clientID, scope, r)
+}
+
+// RegisterAssertionGrant registers the handler validating the assertions of the grant type.
+// Assertion grants must be registered before serving requests.
+func (bs *BearerServer) RegisterAssertionGrant(grantType GrantType, handler AssertionGrantHandler) {
+	if bs.assertionGrants == nil {
+		bs.assertionGrants = make(map[GrantType]AssertionGrantHandler)
+	}
+	bs.assertionGrants[grantType] = handler
+}
+
+// assertionGrant parses the assertion grant request (RFC 7521 §4.1), authenticates the client when credentials are
+// provided or a ClientStore is configured and issues the tokens to the credential asserted by the handler
+func (bs *BearerServer) assertionGrant(gc *GrantContext, handler AssertionGrantHandler) (interface{}, int) {
+	scope, r := gc.Scope, gc.Request
+	assertion := r.FormValue("assertion")
+	if assertion == "" {
+		return ErrorResponse{Error: TokenInvalidRequest, Description: "assertion is required", URI: ""}, http.StatusBadRequest
+	}
+
+	clientID, clientSecret, err := bs.clientCredentials(r)
+	if err == errClientSecretInBody {
+		return ErrorResponse{Error: TokenInvalidRequest, Description: err.Error(), URI: ""}, http.StatusBadRequest
+	}
+	if err != nil {
+		return ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+	}
+	gc.ClientID = clientID
+	if clientSecret != "" {
+		// the verifier of the client is selected for the client authenticated by its secret
+		bs.selectVerifier(gc, clientID)
+	}
+	if bs.ClientStore != nil {
+		if _, resp, status := bs.authenticateClient(gc, clientID, clientSecret); resp != nil {
+			return resp, status
+		}
+	} else if clientSecret != "" {
+		if resp, status := bs.validateClient(gc, nil, clientID, clientSecret); resp != nil {
+			return resp, status
+		}
+	}
+	r = gc.Request
+
+	tokenType, credential, err := handler.ValidateAssertion(assertion, clientID, scope, r)
+	if err != nil || credential == "" {
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid assertion", URI: ""}, http.StatusBadRequest
+	}
+	return bs.issueTokens(gc, tokenType, credential)
+}
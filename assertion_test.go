@@ -0,0 +1,41 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAssertionGrant(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RegisterAssertionGrant(JWTBearerGrant, AssertionGrantHandlerFunc(func(assertion, clientID, scope string, r *http.Request) (TokenType, string, error) {
+		if assertion != "valid-assertion" {
+			return "", "", errors.New("invalid assertion")
+		}
+		return UserToken, "user111", nil
+	}))
+
+	r := &http.Request{Form: url.Values{"assertion": {"valid-assertion"}}}
+	resp, code := sut.generateTokenResponse(JWTBearerGrant, "", "", "", "", "", "", r)
+	if code != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, err := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if err != nil || token.Credential != "user111" {
+		t.Fatalf("Error token = %v, %v", token, err)
+	}
+
+	r = &http.Request{Form: url.Values{"assertion": {"forged"}}}
+	resp, code = sut.generateTokenResponse(JWTBearerGrant, "", "", "", "", "", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidGrant {
+		t.Fatalf("Error response = %v", resp)
+	}
+
+	r = &http.Request{Form: url.Values{}}
+	resp, code = sut.generateTokenResponse(SAML2BearerGrant, "", "", "", "", "", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenUnsupportedGrantType {
+		t.Fatalf("Error response = %v", resp)
+	}
+}
@@ -0,0 +1,138 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Event chain verification errors
+var (
+	ErrEventChainBroken  = errors.New("event chain broken")
+	ErrEventHashMismatch = errors.New("event hash mismatch")
+	ErrEventSignature    = errors.New("invalid event signature")
+	ErrEventSequenceGap  = errors.New("event sequence gap")
+)
+
+// EventChainError locates the first event failing the verification of VerifyEventChain
+type EventChainError struct {
+	Sequence uint64
+	Err      error
+}
+
+func (e *EventChainError) Error() string {
+	return fmt.Sprintf("event %d: %v", e.Sequence, e.Err)
+}
+
+// Unwrap returns the verification error
+func (e *EventChainError) Unwrap() error {
+	return e.Err
+}
+
+// SigningSink is the EventSink decorator making the audit log tamper-evident: each delivered event gets the next
+// Sequence, the PrevHash of the previous event, its Hash covering both and its Ed25519 Signature of the hash, so the
+// modification, removal or reordering of the stored events breaks the chain checked by VerifyEventChain.
+// The chain advances only when the decorated sink delivers the batch.
+type SigningSink struct {
+	Sink EventSink
+	Key  ed25519.PrivateKey
+
+	mu       sync.Mutex
+	sequence uint64
+	prevHash string
+}
+
+// NewSigningSink creates the SigningSink of the sink starting a new chain
+func NewSigningSink(sink EventSink, key ed25519.PrivateKey) *SigningSink {
+	return &SigningSink{Sink: sink, Key: key}
+}
+
+// Resume continues the chain after the last event delivered by a previous run, read back from the audit log
+func (s *SigningSink) Resume(sequence uint64, hash string) {
+	s.mu.Lock()
+	s.sequence, s.prevHash = sequence, hash
+	s.mu.Unlock()
+}
+
+// Head returns the sequence and hash of the last delivered event
+func (s *SigningSink) Head() (uint64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sequence, s.prevHash
+}
+
+// PublishEvents chains and signs copies of the events, then delivers them to the decorated sink. The events of the
+// caller are left unchanged, so they can be shared with other sinks and the undelivered batches don't keep the
+// sequences and hashes the chain didn't advance to.
+func (s *SigningSink) PublishEvents(ctx context.Context, events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sequence, prevHash := s.sequence, s.prevHash
+	signed := make([]*Event, len(events))
+	for i, e := range events {
+		event := *e
+		sequence++
+		event.Sequence, event.PrevHash = sequence, prevHash
+		digest, err := eventDigest(&event)
+		if err != nil {
+			return err
+		}
+		event.Hash = base64.RawURLEncoding.EncodeToString(digest)
+		event.Signature = base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.Key, digest))
+		prevHash = event.Hash
+		signed[i] = &event
+	}
+	if err := s.Sink.PublishEvents(ctx, signed); err != nil {
+		return err
+	}
+	s.sequence, s.prevHash = sequence, prevHash
+	return nil
+}
+
+// eventDigest is the SHA-256 of the JSON of the event without its Hash and Signature
+func eventDigest(event *Event) ([]byte, error) {
+	e := *event
+	e.Hash, e.Signature = "", ""
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// VerifyEventChain verifies the hashes, the signatures with the public key and the contiguity of the events signed by
+// a SigningSink, in sequence order. prevHash is the hash of the event preceding the first one, empty at the start
+// of the chain whose first event must then have the Sequence 1, so the removal of the first events is detected.
+// It returns an *EventChainError locating the first invalid event.
+func VerifyEventChain(key ed25519.PublicKey, prevHash string, events []*Event) error {
+	for i, event := range events {
+		if i == 0 && prevHash == "" && event.Sequence != 1 {
+			return &EventChainError{Sequence: event.Sequence, Err: ErrEventSequenceGap}
+		}
+		if i > 0 && event.Sequence != events[i-1].Sequence+1 {
+			return &EventChainError{Sequence: event.Sequence, Err: ErrEventSequenceGap}
+		}
+		if event.PrevHash != prevHash {
+			return &EventChainError{Sequence: event.Sequence, Err: ErrEventChainBroken}
+		}
+		digest, err := eventDigest(event)
+		if err != nil {
+			return &EventChainError{Sequence: event.Sequence, Err: err}
+		}
+		if event.Hash != base64.RawURLEncoding.EncodeToString(digest) {
+			return &EventChainError{Sequence: event.Sequence, Err: ErrEventHashMismatch}
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(event.Signature)
+		if err != nil || !ed25519.Verify(key, digest, signature) {
+			return &EventChainError{Sequence: event.Sequence, Err: ErrEventSignature}
+		}
+		prevHash = event.Hash
+	}
+	return nil
+}
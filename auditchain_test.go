@@ -0,0 +1,85 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	events []*Event
+	err    error
+}
+
+func (s *memorySink) PublishEvents(_ context.Context, events []*Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestSigningSink(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	inner := &memorySink{}
+	sut := NewSigningSink(inner, key)
+	event := func(credential string) *Event {
+		return &Event{ID: credential, Type: TokenIssuedEvent, Time: time.Now().UTC(), Credential: credential}
+	}
+
+	published := []*Event{event("a"), event("b")}
+	if err := sut.PublishEvents(context.Background(), published); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if published[0].Sequence != 0 || published[0].Hash != "" || inner.events[0] == published[0] {
+		t.Fatalf("Error the published events should not be modified: %+v", published[0])
+	}
+	inner.err = errors.New("broker down")
+	lost := event("lost")
+	if err := sut.PublishEvents(context.Background(), []*Event{lost}); err == nil || lost.Sequence != 0 || lost.Signature != "" {
+		t.Fatalf("Error the sink error should be returned: %v, %+v", err, lost)
+	}
+	inner.err = nil
+	if err := sut.PublishEvents(context.Background(), []*Event{event("c")}); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if seq, hash := sut.Head(); seq != 3 || hash != inner.events[2].Hash {
+		t.Fatalf("Error Head = %d, %s", seq, hash)
+	}
+	if err := VerifyEventChain(pub, "", inner.events); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if err := VerifyEventChain(pub, inner.events[0].Hash, inner.events[1:]); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+
+	tampered := *inner.events[1]
+	tampered.Credential = "mallory"
+	var chainErr *EventChainError
+	err := VerifyEventChain(pub, "", []*Event{inner.events[0], &tampered, inner.events[2]})
+	if !errors.As(err, &chainErr) || chainErr.Sequence != 2 || !errors.Is(err, ErrEventHashMismatch) {
+		t.Fatalf("Error %v", err)
+	}
+	if err = VerifyEventChain(pub, "", []*Event{inner.events[0], inner.events[2]}); !errors.Is(err, ErrEventSequenceGap) {
+		t.Fatalf("Error the removed event should be detected: %v", err)
+	}
+	if err = VerifyEventChain(pub, "", inner.events[1:]); !errors.As(err, &chainErr) || chainErr.Sequence != 2 || !errors.Is(err, ErrEventSequenceGap) {
+		t.Fatalf("Error the removed first event should be detected: %v", err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err = VerifyEventChain(otherPub, "", inner.events); !errors.Is(err, ErrEventSignature) {
+		t.Fatalf("Error %v", err)
+	}
+
+	resumed := NewSigningSink(inner, key)
+	resumed.Resume(sut.Head())
+	if err = resumed.PublishEvents(context.Background(), []*Event{event("d")}); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if err = VerifyEventChain(pub, "", inner.events); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+}
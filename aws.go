@@ -0,0 +1,185 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AWSIdentityGrant is the assertion grant type exchanging a signed sts:GetCallerIdentity request for tokens
+const AWSIdentityGrant GrantType = "urn:ietf:params:oauth:grant-type:aws-sigv4"
+
+const (
+	// DefaultSTSEndpoint is the STS endpoint the signed requests are sent to when the AWSIdentity STSEndpoints are empty.
+	DefaultSTSEndpoint = "https://sts.amazonaws.com/"
+	// AWSServerIDHeader is the signed header binding the GetCallerIdentity request to the AWSIdentity ServerID.
+	AWSServerIDHeader = "X-OAuth-AWS-Server-ID"
+)
+
+var (
+	// ErrInvalidAWSRequest is returned by the AWSIdentity when the assertion is not a signed GetCallerIdentity request
+	// of an allowed STS endpoint.
+	ErrInvalidAWSRequest = errors.New("invalid GetCallerIdentity request")
+	// ErrAWSIdentityNotAllowed is returned by the AWSIdentity when the caller ARN is not mapped.
+	ErrAWSIdentityNotAllowed = errors.New("AWS identity not allowed")
+)
+
+// AWSSignedRequest is the sts:GetCallerIdentity request signed with SigV4 by the client, sent base64url encoded
+// as JSON in the assertion of the AWSIdentityGrant. The body is "Action=GetCallerIdentity&Version=2011-06-15".
+type AWSSignedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// AWSIdentity is the AssertionGrantHandler of the AWSIdentityGrant, issuing client tokens to the callers proving their
+// AWS identity: the server forwards the signed GetCallerIdentity request to STS, which verifies the signature and
+// returns the caller ARN, so machines bootstrap their credentials from their instance profile or role without secrets.
+type AWSIdentity struct {
+	// STSEndpoints are the STS URLs the signed requests may target, DefaultSTSEndpoint when empty
+	STSEndpoints []string
+	// ServerID must be signed in the AWSServerIDHeader, required so the requests signed for another server are not
+	// replayed
+	ServerID string
+	// ARNs maps the allowed IAM user and role ARNs to the credential of their tokens, the assumed-role sessions are
+	// matched by their role ARN. Required so the other identities of every AWS account get no tokens.
+	ARNs map[string]string
+	// Client sends the requests to STS, http.DefaultClient when nil
+	Client *http.Client
+}
+
+type getCallerIdentityResponse struct {
+	Result struct {
+		Arn     string `xml:"Arn"`
+		UserID  string `xml:"UserId"`
+		Account string `xml:"Account"`
+	} `xml:"GetCallerIdentityResult"`
+}
+
+// ValidateAssertion sends the signed GetCallerIdentity request of the assertion to STS and returns the ClientToken type
+// and the credential mapped to the caller ARN
+func (a *AWSIdentity) ValidateAssertion(assertion, _, _ string, r *http.Request) (TokenType, string, error) {
+	if a.ServerID == "" {
+		return "", "", errors.New("aws: ServerID is required")
+	}
+	if len(a.ARNs) == 0 {
+		return "", "", errors.New("aws: ARNs is required")
+	}
+	signed, err := a.parseRequest(assertion)
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, signed.URL, strings.NewReader(signed.Body))
+	if err != nil {
+		return "", "", err
+	}
+	for name, values := range signed.Headers {
+		if strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		req.Header[name] = values
+	}
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("sts: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%w: sts status %d", ErrInvalidAWSRequest, resp.StatusCode)
+	}
+	var identity getCallerIdentityResponse
+	if err = xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&identity); err != nil {
+		return "", "", fmt.Errorf("sts: %w", err)
+	}
+	arn := canonicalARN(identity.Result.Arn)
+	if arn == "" {
+		return "", "", fmt.Errorf("%w: missing caller ARN", ErrInvalidAWSRequest)
+	}
+	credential, ok := a.ARNs[arn]
+	if !ok || credential == "" {
+		return "", "", ErrAWSIdentityNotAllowed
+	}
+	return ClientToken, credential, nil
+}
+
+// parseRequest decodes the signed request and checks it is a GetCallerIdentity POST to an allowed endpoint,
+// signed with the ServerID
+func (a *AWSIdentity) parseRequest(assertion string) (*AWSSignedRequest, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(assertion, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAWSRequest, err)
+	}
+	var signed AWSSignedRequest
+	if err = json.Unmarshal(b, &signed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAWSRequest, err)
+	}
+	headers := make(http.Header, len(signed.Headers))
+	for name, values := range signed.Headers {
+		headers[http.CanonicalHeaderKey(name)] = values
+	}
+	signed.Headers = headers
+	if signed.Method != http.MethodPost || !a.allowsEndpoint(signed.URL) {
+		return nil, fmt.Errorf("%w: untrusted endpoint %s %s", ErrInvalidAWSRequest, signed.Method, signed.URL)
+	}
+	body, err := url.ParseQuery(signed.Body)
+	if err != nil || len(body) != 2 || body.Get("Action") != "GetCallerIdentity" || body.Get("Version") != "2011-06-15" {
+		return nil, fmt.Errorf("%w: not a GetCallerIdentity request", ErrInvalidAWSRequest)
+	}
+	if signed.Headers.Get(AWSServerIDHeader) != a.ServerID || !signedHeader(signed.Headers.Get("Authorization"), AWSServerIDHeader) {
+		return nil, fmt.Errorf("%w: %s not signed", ErrInvalidAWSRequest, AWSServerIDHeader)
+	}
+	return &signed, nil
+}
+
+func (a *AWSIdentity) allowsEndpoint(endpoint string) bool {
+	endpoints := a.STSEndpoints
+	if len(endpoints) == 0 {
+		endpoints = []string{DefaultSTSEndpoint}
+	}
+	for _, e := range endpoints {
+		if strings.TrimSuffix(e, "/") == strings.TrimSuffix(endpoint, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// signedHeader reports whether the header is in the SignedHeaders of the SigV4 Authorization header
+func signedHeader(authorization, header string) bool {
+	for _, part := range strings.Split(authorization, ",") {
+		part = strings.TrimSpace(part)
+		if i := strings.LastIndex(part, "SignedHeaders="); i >= 0 {
+			for _, name := range strings.Split(part[i+len("SignedHeaders="):], ";") {
+				if strings.EqualFold(name, header) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// canonicalARN returns the IAM role ARN of an assumed-role session ARN,
+// "arn:aws:sts::<account>:assumed-role/<role>/<session>" becoming "arn:aws:iam::<account>:role/<role>"
+func canonicalARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn
+	}
+	resource := strings.Split(parts[5], "/")
+	if len(resource) < 3 {
+		return arn
+	}
+	return strings.Join([]string{parts[0], parts[1], "iam", parts[3], parts[4], "role/" + resource[1]}, ":")
+}
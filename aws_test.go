@@ -0,0 +1,85 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAWSIdentityGrant(t *testing.T) {
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "AWS4-HMAC-SHA256 Credential=AKIA/20260101/us-east-1/sts/aws4_request, SignedHeaders=host;x-amz-date;x-oauth-aws-server-id, Signature=valid" ||
+			string(body) != "Action=GetCallerIdentity&Version=2011-06-15" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>` +
+			`<Arn>arn:aws:sts::123456789012:assumed-role/worker/i-0abc</Arn><UserId>AROA:i-0abc</UserId><Account>123456789012</Account>` +
+			`</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}))
+	defer sts.Close()
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	handler := &AWSIdentity{STSEndpoints: []string{sts.URL}}
+	sut.RegisterAssertionGrant(AWSIdentityGrant, handler)
+	assertion := func(signature, serverID, endpoint, body string) string {
+		b, _ := json.Marshal(&AWSSignedRequest{Method: "POST", URL: endpoint, Body: body, Headers: http.Header{
+			"Authorization":   {"AWS4-HMAC-SHA256 Credential=AKIA/20260101/us-east-1/sts/aws4_request, SignedHeaders=host;x-amz-date;x-oauth-aws-server-id, Signature=" + signature},
+			"X-Amz-Date":      {"20260101T000000Z"},
+			AWSServerIDHeader: {serverID},
+		}})
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	grant := func(assertion string) (interface{}, int) {
+		r := &http.Request{Form: url.Values{"assertion": {assertion}}}
+		return sut.generateTokenResponse(AWSIdentityGrant, "", "", "", "", "", "", r)
+	}
+
+	valid := assertion("valid", "oauth.example.com", sts.URL, "Action=GetCallerIdentity&Version=2011-06-15")
+	if resp, code := grant(valid); code == http.StatusOK {
+		t.Fatalf("Error the handler without ServerID and ARNs should be rejected: %v", resp)
+	}
+	handler.ServerID = "oauth.example.com"
+	if resp, code := grant(valid); code == http.StatusOK {
+		t.Fatalf("Error the handler without ARNs should be rejected: %v", resp)
+	}
+	handler.ARNs = map[string]string{"arn:aws:iam::123456789012:role/worker": "worker-client"}
+	resp, code := grant(valid)
+	if code != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, err := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if err != nil || token.Credential != "worker-client" || token.TokenType != ClientToken {
+		t.Fatalf("Error token = %v, %v", token, err)
+	}
+
+	for _, forged := range []string{
+		assertion("forged", "oauth.example.com", sts.URL, "Action=GetCallerIdentity&Version=2011-06-15"),
+		assertion("valid", "other.example.com", sts.URL, "Action=GetCallerIdentity&Version=2011-06-15"),
+		assertion("valid", "oauth.example.com", "https://attacker.example.com/", "Action=GetCallerIdentity&Version=2011-06-15"),
+		assertion("valid", "oauth.example.com", sts.URL, "Action=AssumeRole&Version=2011-06-15"),
+		"not-base64!",
+	} {
+		if resp, code = grant(forged); code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidGrant {
+			t.Fatalf("Error response = %v", resp)
+		}
+	}
+}
+
+func TestCanonicalARN(t *testing.T) {
+	for arn, expected := range map[string]string{
+		"arn:aws:sts::123456789012:assumed-role/worker/i-0abc": "arn:aws:iam::123456789012:role/worker",
+		"arn:aws:iam::123456789012:user/alice":                 "arn:aws:iam::123456789012:user/alice",
+		"arn:aws-cn:sts::123456789012:assumed-role/a/b":        "arn:aws-cn:iam::123456789012:role/a",
+	} {
+		if actual := canonicalARN(arn); actual != expected {
+			t.Fatalf("Error canonicalARN(%s) = %s", arn, actual)
+		}
+	}
+}
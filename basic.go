@@ -4,12 +4,13 @@ import (
 	"encoding/base64"
 	"errors"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
 // GetBasicAuthentication get username and password from Authorization header
 func GetBasicAuthentication(r *http.Request) (username, password string, err error) {
-	if header := r.Header.Get("Authorization"); header != "" {
+	if header := r.Header.Get("Authorization"); len(header) > 6 {
 		if strings.ToLower(header[:6]) == "basic " {
 			// decode header value
 			value, err := base64.StdEncoding.DecodeString(header[6:])
@@ -25,13 +26,29 @@ func GetBasicAuthentication(r *http.Request) (username, password string, err err
 	return "", "", nil
 }
 
+// GetClientBasicAuthentication get client_id and client_secret from Authorization header, decoding them
+// using the application/x-www-form-urlencoded algorithm as required by RFC 6749 §2.3.1
+func GetClientBasicAuthentication(r *http.Request) (clientID, clientSecret string, err error) {
+	id, secret, err := GetBasicAuthentication(r)
+	if err != nil || id == "" {
+		return "", "", err
+	}
+	if clientID, err = url.QueryUnescape(id); err != nil {
+		return "", "", err
+	}
+	if clientSecret, err = url.QueryUnescape(secret); err != nil {
+		return "", "", err
+	}
+	return clientID, clientSecret, nil
+}
+
 // CheckBasicAuthentication checks Basic Authorization header credentials
 func CheckBasicAuthentication(username, password string, r *http.Request) error {
 	u, p, err := GetBasicAuthentication(r)
 	if err != nil {
 		return err
 	}
-	if u != "" && p != "" && u != username && p != password {
+	if u != "" && p != "" && !(constantTimeEqual(u, username) && constantTimeEqual(p, password)) {
 		return errors.New("invalid credentials")
 	}
 	return nil
@@ -51,3 +51,34 @@ func TestCheckBasicAuthentication(t *testing.T) {
 		t.Log("Credentials are OK")
 	}
 }
+
+func TestGetClientBasicAuthentication(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/token", nil)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("my%3Aclient:p%40ss+word")))
+
+	clientID, clientSecret, err := GetClientBasicAuthentication(req)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if clientID != "my:client" || clientSecret != "p@ss word" {
+		t.Fatalf("Wrong client credentials = %s, %s", clientID, clientSecret)
+	}
+}
+
+func TestShortBasicAuthentication(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/token", nil)
+	req.Header.Set("Authorization", "Bas")
+
+	if username, _, err := GetBasicAuthentication(req); err != nil || username != "" {
+		t.Fatalf("Error %v, username = %s", err, username)
+	}
+}
+
+func TestCheckBasicAuthenticationWrongPassword(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/token", nil)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:wrongpassword")))
+
+	if err := CheckBasicAuthentication("admin", "password123456", req); err == nil {
+		t.Fatalf("Error should have occurred")
+	}
+}
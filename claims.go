@@ -0,0 +1,162 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrReservedClaim is returned when setting or merging a registered claim managed by the server.
+var ErrReservedClaim = errors.New("reserved claim")
+
+// ReservedClaims are the registered claims (RFC 7519 §4.1) that cannot be overwritten through the Claims setters.
+var ReservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+// IsReservedClaim returns true if the name is one of the ReservedClaims.
+func IsReservedClaim(name string) bool {
+	for _, c := range ReservedClaims {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Set sets the claim, returning ErrReservedClaim for the reserved claims. The nil claims are initialized.
+func (c *Claims) Set(name string, value interface{}) error {
+	if IsReservedClaim(name) {
+		return ErrReservedClaim
+	}
+	if *c == nil {
+		*c = make(Claims)
+	}
+	(*c)[name] = value
+	return nil
+}
+
+// GetString returns the claim if it is a string.
+func (c Claims) GetString(name string) (string, bool) {
+	v, ok := c[name].(string)
+	return v, ok
+}
+
+// GetBool returns the claim if it is a boolean.
+func (c Claims) GetBool(name string) (bool, bool) {
+	v, ok := c[name].(bool)
+	return v, ok
+}
+
+// GetInt64 returns the claim if it is an integer number, decoded tokens hold numbers as float64.
+func (c Claims) GetInt64(name string) (int64, bool) {
+	switch v := c[name].(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// GetStrings returns the claim if it is a list of strings, or a single string.
+func (c Claims) GetStrings(name string) ([]string, bool) {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}, true
+	case []string:
+		return v, true
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, s)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// Lookup returns the nested claim at the dotted path, list elements are addressed by index
+// (e.g. "address.country" or "groups.0.name").
+func (c Claims) Lookup(path string) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(c)
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case Claims:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Merge deep-merges other into the claims: nested objects are merged recursively, other values are replaced.
+// Merge returns ErrReservedClaim, leaving the claims unchanged, if other contains a reserved claim. The nil claims are
+// initialized.
+func (c *Claims) Merge(other Claims) error {
+	for name := range other {
+		if IsReservedClaim(name) {
+			return ErrReservedClaim
+		}
+	}
+	if *c == nil {
+		*c = make(Claims)
+	}
+	mergeMaps(*c, other)
+	return nil
+}
+
+func mergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := asMap(v)
+		dstMap, dstIsMap := asMap(dst[k])
+		if srcIsMap && dstIsMap {
+			merged := make(map[string]interface{}, len(dstMap))
+			for dk, dv := range dstMap {
+				merged[dk] = dv
+			}
+			mergeMaps(merged, srcMap)
+			dst[k] = merged
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Claims:
+		return m, true
+	}
+	return nil, false
+}
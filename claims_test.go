@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestClaimsGetters(t *testing.T) {
+	var claims Claims
+	if err := json.Unmarshal([]byte(`{"sub":"user111","admin":true,"level":3,"roles":["a","b"],"address":{"country":"IT"},"groups":[{"name":"dev"}]}`), &claims); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if v, ok := claims.GetString("sub"); !ok || v != "user111" {
+		t.Fatalf("Error GetString = %v", v)
+	}
+	if v, ok := claims.GetBool("admin"); !ok || !v {
+		t.Fatalf("Error GetBool = %v", v)
+	}
+	if v, ok := claims.GetInt64("level"); !ok || v != 3 {
+		t.Fatalf("Error GetInt64 = %v", v)
+	}
+	if v, ok := claims.GetStrings("roles"); !ok || len(v) != 2 || v[1] != "b" {
+		t.Fatalf("Error GetStrings = %v", v)
+	}
+	if v, ok := claims.Lookup("address.country"); !ok || v != "IT" {
+		t.Fatalf("Error Lookup = %v", v)
+	}
+	if v, ok := claims.Lookup("groups.0.name"); !ok || v != "dev" {
+		t.Fatalf("Error Lookup = %v", v)
+	}
+	if _, ok := claims.Lookup("address.city"); ok {
+		t.Fatalf("Error Lookup should fail")
+	}
+}
+
+func TestClaimsReserved(t *testing.T) {
+	claims := Claims{"sub": "user111"}
+	if err := claims.Set("exp", 0); err != ErrReservedClaim {
+		t.Fatalf("Error should be ErrReservedClaim: %v", err)
+	}
+	if err := claims.Merge(Claims{"sub": "admin", "role": "admin"}); err != ErrReservedClaim {
+		t.Fatalf("Error should be ErrReservedClaim: %v", err)
+	}
+	if claims["sub"] != "user111" || claims["role"] != nil {
+		t.Fatalf("Error claims modified = %v", claims)
+	}
+}
+
+func TestClaimsMerge(t *testing.T) {
+	claims := Claims{"address": map[string]interface{}{"country": "IT", "city": "Rome"}, "role": "user"}
+	if err := claims.Merge(Claims{"address": Claims{"city": "Milan"}, "role": "admin"}); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if v, _ := claims.Lookup("address.country"); v != "IT" {
+		t.Fatalf("Error nested claim lost = %v", claims)
+	}
+	if v, _ := claims.Lookup("address.city"); v != "Milan" || claims["role"] != "admin" {
+		t.Fatalf("Error claims not merged = %v", claims)
+	}
+}
+
+func TestClaimsNil(t *testing.T) {
+	var claims Claims
+	if err := claims.Set("role", "admin"); err != nil || claims["role"] != "admin" {
+		t.Fatalf("Error Set on nil claims = %v, %v", claims, err)
+	}
+	var merged Claims
+	if err := merged.Merge(Claims{"address": map[string]interface{}{"country": "IT"}}); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if v, _ := merged.Lookup("address.country"); v != "IT" {
+		t.Fatalf("Error Merge on nil claims = %v", merged)
+	}
+}
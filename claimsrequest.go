@@ -0,0 +1,91 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ClaimsRequestContext is the context key of the ClaimsRequest of the authorization code being exchanged.
+const ClaimsRequestContext contextKey = "oauth.claimsrequest"
+
+// ClaimRequest is the request of an individual claim, see OIDC Core §5.5.1, a nil ClaimRequest requests the claim
+// in the default manner
+type ClaimRequest struct {
+	Essential bool          `json:"essential,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// ClaimsRequest is the "claims" authorization request parameter, see OIDC Core §5.5
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"`
+}
+
+// ParseClaimsRequest parses the "claims" parameter of the authorization request, nil when the parameter is absent
+func ParseClaimsRequest(r *http.Request) (*ClaimsRequest, error) {
+	raw := r.FormValue("claims")
+	if raw == "" {
+		return nil, nil
+	}
+	cr := new(ClaimsRequest)
+	if err := json.Unmarshal([]byte(raw), cr); err != nil {
+		return nil, fmt.Errorf("invalid claims parameter: %v", err)
+	}
+	return cr, nil
+}
+
+// ClaimsRequestFromContext returns the ClaimsRequest bound to the grant request, for the verifier AddClaims
+func ClaimsRequestFromContext(ctx context.Context) *ClaimsRequest {
+	cr, _ := ctx.Value(ClaimsRequestContext).(*ClaimsRequest)
+	return cr
+}
+
+// Restrict drops the requested claims which are not supported, as the unsupported claims are ignored (OIDC Core §5.5)
+func (cr *ClaimsRequest) Restrict(supported []string) {
+	allowed := make(map[string]bool, len(supported))
+	for _, name := range supported {
+		allowed[name] = true
+	}
+	for _, member := range []map[string]*ClaimRequest{cr.UserInfo, cr.IDToken} {
+		for name := range member {
+			if !allowed[name] {
+				delete(member, name)
+			}
+		}
+	}
+}
+
+// MissingEssential returns the essential claims of the member (UserInfo or IDToken) absent from the claims or whose
+// value is not one of the requested values, for the ID token and userinfo builders deciding how to proceed
+func MissingEssential(member map[string]*ClaimRequest, claims Claims) []string {
+	var missing []string
+	for name, req := range member {
+		if req == nil || !req.Essential {
+			continue
+		}
+		value, ok := claims[name]
+		if !ok || !req.accepts(value) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// accepts returns true if the value matches the requested value or values, when requested
+func (req *ClaimRequest) accepts(value interface{}) bool {
+	if req.Value == nil && len(req.Values) == 0 {
+		return true
+	}
+	if req.Value != nil && fmt.Sprint(req.Value) == fmt.Sprint(value) {
+		return true
+	}
+	for _, v := range req.Values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
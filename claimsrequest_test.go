@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type claimsRequestVerifier struct {
+	TestUserVerifier
+}
+
+func (v claimsRequestVerifier) AddClaims(tokenType TokenType, credential, tokenID, scope string, r *http.Request) (Claims, error) {
+	claims := Claims{}
+	if cr := ClaimsRequestFromContext(r.Context()); cr != nil {
+		for name := range cr.IDToken {
+			claims[name] = "requested"
+		}
+	}
+	return claims, nil
+}
+
+func TestClaimsRequest(t *testing.T) {
+	form := url.Values{"claims": {`{"id_token":{"email":{"essential":true},"acr":{"values":["gold","silver"]},"picture":null},"userinfo":{"phone_number":null}}`}}
+	cr, err := ParseClaimsRequest(httptest.NewRequest("GET", "/authorize?"+form.Encode(), nil))
+	if err != nil || len(cr.IDToken) != 3 || !cr.IDToken["email"].Essential || cr.IDToken["picture"] != nil {
+		t.Fatalf("Error claims request = %+v, %v", cr, err)
+	}
+	if _, err = ParseClaimsRequest(httptest.NewRequest("GET", "/authorize?claims=%7B", nil)); err == nil {
+		t.Fatalf("Error invalid claims parameter accepted")
+	}
+
+	if missing := MissingEssential(cr.IDToken, Claims{"acr": "bronze"}); len(missing) != 1 || missing[0] != "email" {
+		t.Fatalf("Error missing = %v", missing)
+	}
+	cr.IDToken["acr"].Essential = true
+	if missing := MissingEssential(cr.IDToken, Claims{"email": "a@b.c", "acr": "silver"}); len(missing) != 0 {
+		t.Fatalf("Error missing = %v", missing)
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(claimsRequestVerifier), nil)
+	sut.AuthCodeStore = NewMemoryAuthCodeStore()
+	sut.SupportedClaims = []string{"email", "phone_number"}
+	code, _ := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111", Claims: cr})
+	if len(cr.IDToken) != 1 || len(cr.UserInfo) != 1 {
+		t.Fatalf("Error restricted claims request = %+v", cr)
+	}
+	resp, status := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", &http.Request{Form: url.Values{}})
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if token.Claims["email"] != "requested" || token.Claims["picture"] != nil {
+		t.Fatalf("Error claims = %v", token.Claims)
+	}
+}
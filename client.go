@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrClientNotFound is returned by the ClientStore when the client is not registered.
+	ErrClientNotFound = errors.New("client not found")
+	// ErrGrantTypeNotAllowed is returned when the client is not registered for the grant type.
+	ErrGrantTypeNotAllowed = errors.New("grant type not allowed for the client")
+)
+
+// Client is the registration of an OAuth client.
+type Client struct {
+	ID string `json:"client_id"`
+	// AllowedGrantTypes lists the grant types the client is registered for, an empty list allows none.
+	AllowedGrantTypes []GrantType `json:"grant_types"`
+	// Public clients cannot keep a secret: they authenticate with the client_id only and must use PKCE.
+	Public bool `json:"public"`
+	// RedirectURIs are the registered redirect URIs, required by IssueAuthorizationCode
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	// SecretHash is the HashClientSecret hash of the client secret, never marshaled. When set, the grants verify the
+	// client secret against it instead of calling the verifier ValidateClient.
+	SecretHash string `json:"-"`
+}
+
+// AllowsGrantType returns true if the client is registered for the grant type,
+// public clients are never allowed to use the client_credentials grant.
+func (c *Client) AllowsGrantType(grantType GrantType) bool {
+	if c.Public && grantType == ClientCredentialsGrant {
+		return false
+	}
+	for _, g := range c.AllowedGrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientStore provides the registered clients to the authorization server.
+type ClientStore interface {
+	// GetClient returns the client registration or ErrClientNotFound
+	GetClient(clientID string) (*Client, error)
+}
+
+// ClientAdminStore is implemented by the ClientStores managed by the admin API.
+type ClientAdminStore interface {
+	ClientStore
+	// ListClients returns the client registrations sorted by id
+	ListClients() ([]*Client, error)
+	// SaveClient creates or replaces the client registration
+	SaveClient(c *Client) error
+	// DeleteClient removes the client registration
+	DeleteClient(clientID string) error
+}
+
+// MemoryClientStore is an in-memory ClientStore safe for concurrent use.
+type MemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+	// configured are the ids of the clients set by the last ReplaceClients
+	configured map[string]bool
+}
+
+// NewMemoryClientStore creates a MemoryClientStore containing the clients.
+func NewMemoryClientStore(clients ...*Client) *MemoryClientStore {
+	s := &MemoryClientStore{clients: make(map[string]*Client)}
+	for _, c := range clients {
+		s.clients[c.ID] = c
+	}
+	return s
+}
+
+// GetClient returns the client registration or ErrClientNotFound
+func (s *MemoryClientStore) GetClient(clientID string) (*Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.clients[clientID]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return c, nil
+}
+
+// ListClients returns the client registrations sorted by id
+func (s *MemoryClientStore) ListClients() ([]*Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clients := make([]*Client, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, nil
+}
+
+// SaveClient creates or replaces the client registration
+func (s *MemoryClientStore) SaveClient(c *Client) error {
+	s.mu.Lock()
+	s.clients[c.ID] = c
+	delete(s.configured, c.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteClient removes the client registration
+func (s *MemoryClientStore) DeleteClient(clientID string) error {
+	s.mu.Lock()
+	delete(s.clients, clientID)
+	delete(s.configured, clientID)
+	s.mu.Unlock()
+	return nil
+}
+
+// ReplaceClients replaces the clients set by the previous ReplaceClients, the clients created with
+// NewMemoryClientStore or saved with SaveClient (e.g. by the AdminHandler) are kept unless they have the id of a
+// replacing client
+func (s *MemoryClientStore) ReplaceClients(clients []*Client) error {
+	configured := make(map[string]bool, len(clients))
+	for _, c := range clients {
+		configured[c.ID] = true
+	}
+	s.mu.Lock()
+	for id := range s.configured {
+		delete(s.clients, id)
+	}
+	for _, c := range clients {
+		s.clients[c.ID] = c
+	}
+	s.configured = configured
+	s.mu.Unlock()
+	return nil
+}
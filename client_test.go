@@ -0,0 +1,20 @@
+package oauth
+
+import (
+	"testing"
+)
+
+func TestMemoryClientStore(t *testing.T) {
+	store := NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{ClientCredentialsGrant}})
+	client, err := store.GetClient("abcdef")
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if !client.AllowsGrantType(ClientCredentialsGrant) || client.AllowsGrantType(PasswordGrant) {
+		t.Fatalf("Error AllowedGrantTypes = %v", client.AllowedGrantTypes)
+	}
+	_ = store.DeleteClient("abcdef")
+	if _, err = store.GetClient("abcdef"); err != ErrClientNotFound {
+		t.Fatalf("Error should be ErrClientNotFound: %v", err)
+	}
+}
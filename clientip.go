@@ -0,0 +1,124 @@
+package oauth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const clientIPContext contextKey = "oauth.clientip"
+
+// ClientIPResolver resolves the IP address of the client behind the trusted proxies from the Forwarded (RFC 7239)
+// or X-Forwarded-For headers, IPv4 and IPv6 addresses are supported. Headers set by untrusted peers are ignored.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver creates a ClientIPResolver trusting the proxies in the CIDRs or IP addresses.
+func NewClientIPResolver(trustedProxies ...string) (*ClientIPResolver, error) {
+	c := &ClientIPResolver{}
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: p}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			c.trusted = append(c.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		c.trusted = append(c.trusted, network)
+	}
+	return c, nil
+}
+
+// ClientIP returns the address of the client: the rightmost untrusted address of the forwarding chain when the
+// peer is a trusted proxy, otherwise the peer address. A nil resolver returns the peer address.
+func (c *ClientIPResolver) ClientIP(r *http.Request) net.IP {
+	peer := parseHostIP(r.RemoteAddr)
+	if c == nil || peer == nil || !c.isTrusted(peer) {
+		return peer
+	}
+	chain := forwardedFor(r.Header)
+	if len(chain) == 0 {
+		chain = xForwardedFor(r.Header)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseHostIP(chain[i])
+		if ip == nil {
+			// obfuscated or unknown identifiers end the trusted chain
+			return peer
+		}
+		if !c.isTrusted(ip) {
+			return ip
+		}
+		peer = ip
+	}
+	return peer
+}
+
+// ClientIPFromContext returns the client address of the token request resolved by the server ClientIPResolver, e.g. for
+// AddClaims to bind the tokens to the address, nil outside of the token requests
+func ClientIPFromContext(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(clientIPContext).(net.IP)
+	return ip
+}
+
+func (c *ClientIPResolver) isTrusted(ip net.IP) bool {
+	for _, n := range c.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for= nodes of the Forwarded headers
+func forwardedFor(h http.Header) []string {
+	var nodes []string
+	for _, header := range h.Values("Forwarded") {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				pair = strings.TrimSpace(pair)
+				if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+					nodes = append(nodes, strings.Trim(pair[4:], `"`))
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// xForwardedFor returns the addresses of the X-Forwarded-For headers
+func xForwardedFor(h http.Header) []string {
+	var nodes []string
+	for _, header := range h.Values("X-Forwarded-For") {
+		for _, node := range strings.Split(header, ",") {
+			if node = strings.TrimSpace(node); node != "" {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+// parseHostIP parses an address with or without port, IPv6 addresses may be bracketed
+func parseHostIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		// IPv6 zone
+		addr = addr[:i]
+	}
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewClientIPResolver("10.0.0.0/8", "fd00::/8", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	tests := []struct {
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"203.0.113.7:4711", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.7"},
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"198.51.100.1, 10.0.0.2"}}, "198.51.100.1"},
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1"}}, "198.51.100.1"},
+		{"[fd00::1]:4711", http.Header{"Forwarded": {`for="[2001:db8::17]:4711";proto=https, for=192.0.2.1`}}, "2001:db8::17"},
+		{"192.0.2.1:80", http.Header{"Forwarded": {"for=_hidden"}}, "192.0.2.1"},
+		{"10.0.0.1:4711", nil, "10.0.0.1"},
+		{"[2001:db8::1%eth0]:4711", nil, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		r := &http.Request{RemoteAddr: tt.remoteAddr, Header: tt.header}
+		if got := resolver.ClientIP(r); got.String() != tt.want {
+			t.Fatalf("Error ClientIP(%s, %v) = %s, want %s", tt.remoteAddr, tt.header, got, tt.want)
+		}
+	}
+
+	var untrusted *ClientIPResolver
+	r := &http.Request{RemoteAddr: "10.0.0.1:4711", Header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}}
+	if got := untrusted.ClientIP(r); got.String() != "10.0.0.1" {
+		t.Fatalf("Error ClientIP = %s", got)
+	}
+	if _, err = NewClientIPResolver("not-an-ip"); err == nil {
+		t.Fatalf("Error invalid proxy accepted")
+	}
+}
+
+type clientIPVerifier struct {
+	TestUserVerifier
+}
+
+func (clientIPVerifier) AddClaims(tokenType TokenType, credential, tokenID, scope string, r *http.Request) (Claims, error) {
+	return Claims{"ip": ClientIPFromContext(r.Context()).String()}, nil
+}
+
+func TestClientIPFromContext(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(clientIPVerifier), nil)
+	sut.ClientIPResolver, _ = NewClientIPResolver("10.0.0.0/8")
+	events := new(recordingPublisher)
+	sut.Events = events
+
+	r := httptest.NewRequest("POST", "/token", nil)
+	r.RemoteAddr = "10.0.0.1:4711"
+	r.Header.Set("X-Forwarded-For", "2001:db8::1")
+	resp, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", r)
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if token.Claims["ip"] != "2001:db8::1" {
+		t.Fatalf("Error claims = %v", token.Claims)
+	}
+	if len(events.events) != 1 || events.events[0].ClientIP != "2001:db8::1" {
+		t.Fatalf("Error events = %v", events.events)
+	}
+	if ip := ClientIPFromContext(context.Background()); ip != nil {
+		t.Fatalf("Error client ip = %v", ip)
+	}
+}
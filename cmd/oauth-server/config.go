@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the reference server configuration loaded from YAML.
+type Config struct {
+	Addr            string        `yaml:"addr"`
+	SecretKey       string        `yaml:"secret_key"`
+	TokenTTL        time.Duration `yaml:"token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	CodeTTL         time.Duration `yaml:"code_ttl"`
+	Users           []User        `yaml:"users"`
+	Clients         []Client      `yaml:"clients"`
+}
+
+// User is a static resource owner.
+type User struct {
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	Claims   map[string]string `yaml:"claims"`
+}
+
+// Client is a static OAuth client.
+type Client struct {
+	ID           string   `yaml:"id"`
+	Secret       string   `yaml:"secret"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+}
+
+// LoadConfig reads and validates the YAML configuration at path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(b)
+}
+
+// ParseConfig parses a YAML configuration, applying defaults for the missing values.
+func ParseConfig(b []byte) (*Config, error) {
+	cfg := &Config{
+		Addr:            ":8080",
+		TokenTTL:        time.Minute * 10,
+		RefreshTokenTTL: time.Hour * 24,
+		CodeTTL:         time.Minute,
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.SecretKey == "" {
+		return nil, errors.New("secret_key is required")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+/*
+Reference Authorization Server
+
+Runs the authorization server and a protected resource with all the grant types enabled,
+using the users and clients declared in the YAML configuration (see config.yaml).
+
+	go run ./cmd/oauth-server -config cmd/oauth-server/config.yaml
+
+Endpoints
+
+	POST /token      password and refresh_token grants
+	POST /auth       client_credentials and refresh_token grants
+	GET  /authorize  issues an authorization code to the user authenticated with Basic authentication
+	POST /code       authorization_code grant
+	GET  /me         protected resource returning the token credential and claims
+	GET  /healthz    health of the authorization server
+*/
+func main() {
+	path := flag.String("config", "config.yaml", "path of the YAML configuration file")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*path)
+	if err != nil {
+		log.Fatalf("loading configuration: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	if err = registerAPI(r, cfg); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("listening on %s", cfg.Addr)
+	log.Fatal(http.ListenAndServe(cfg.Addr, r))
+}
+
+func registerAPI(r chi.Router, cfg *Config) error {
+	verifier := NewStaticVerifier(cfg)
+	s := oauth.NewBearerServer(
+		cfg.SecretKey,
+		cfg.TokenTTL,
+		cfg.RefreshTokenTTL,
+		verifier,
+		nil)
+	if err := s.Validate(oauth.PasswordGrant, oauth.ClientCredentialsGrant, oauth.AuthCodeGrant, oauth.RefreshTokenGrant); err != nil {
+		return err
+	}
+	r.Post("/token", s.UserCredentials)
+	r.Post("/auth", s.ClientCredentials)
+	r.Post("/code", s.AuthorizationCode)
+	r.Get("/authorize", authorize(verifier))
+	r.Get("/healthz", s.Healthz)
+	r.Group(func(r chi.Router) {
+		r.Use(oauth.Authorize(cfg.SecretKey, nil))
+		r.Get("/me", me)
+	})
+	return nil
+}
+
+// authorize is a minimal authorization endpoint: the user authenticates with Basic authentication
+// and is redirected back to the client with the authorization code.
+func authorize(verifier *StaticVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("response_type") != "code" {
+			http.Error(w, "unsupported_response_type", http.StatusBadRequest)
+			return
+		}
+		username, password, err := oauth.GetBasicAuthentication(r)
+		if err != nil || verifier.ValidateUser(username, password, "", r) != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth-server"`)
+			http.Error(w, "access_denied", http.StatusUnauthorized)
+			return
+		}
+		redirectURI := r.FormValue("redirect_uri")
+		code, err := verifier.IssueCode(r.FormValue("client_id"), redirectURI, username)
+		if err != nil {
+			http.Error(w, "invalid_request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			http.Error(w, "invalid_request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := u.Query()
+		q.Set("code", code)
+		if state := r.FormValue("state"); state != "" {
+			q.Set("state", state)
+		}
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusFound)
+	}
+}
+
+func me(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"credential": r.Context().Value(oauth.CredentialContext),
+		"token_type": r.Context().Value(oauth.TokenTypeContext),
+		"scope":      r.Context().Value(oauth.ScopeContext),
+		"claims":     r.Context().Value(oauth.ClaimsContext),
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	cfg, err := LoadConfig("config.yaml")
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	r := chi.NewRouter()
+	if err = registerAPI(r, cfg); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postForm(t *testing.T, u string, form url.Values) map[string]interface{} {
+	resp, err := http.PostForm(u, form)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %v", resp.StatusCode, body)
+	}
+	return body
+}
+
+func getMe(t *testing.T, ts *httptest.Server, token string) map[string]interface{} {
+	req, _ := http.NewRequest("GET", ts.URL+"/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", resp.StatusCode)
+	}
+	var body map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return body
+}
+
+func TestPasswordAndRefreshFlow(t *testing.T) {
+	ts := newTestServer(t)
+	tokens := postForm(t, ts.URL+"/token", url.Values{"grant_type": {"password"}, "username": {"user01"}, "password": {"12345"}})
+	me := getMe(t, ts, tokens["access_token"].(string))
+	if me["credential"] != "user01" {
+		t.Fatalf("Error credential = %v", me["credential"])
+	}
+
+	refreshed := postForm(t, ts.URL+"/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens["refresh_token"].(string)}})
+	getMe(t, ts, refreshed["access_token"].(string))
+
+	// the refresh token has been rotated
+	resp, _ := http.PostForm(ts.URL+"/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens["refresh_token"].(string)}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", resp.StatusCode)
+	}
+}
+
+func TestClientCredentialsFlow(t *testing.T) {
+	ts := newTestServer(t)
+	tokens := postForm(t, ts.URL+"/auth", url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}})
+	me := getMe(t, ts, tokens["access_token"].(string))
+	if me["credential"] != "abcdef" {
+		t.Fatalf("Error credential = %v", me["credential"])
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	ts := newTestServer(t)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest("GET", ts.URL+"/authorize?response_type=code&client_id=abcdef&state=xyz&redirect_uri="+url.QueryEscape("http://localhost:3000/callback"), nil)
+	req.SetBasicAuth("user01", "12345")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Error StatusCode = %d", resp.StatusCode)
+	}
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	if !strings.HasPrefix(location.String(), "http://localhost:3000/callback") || location.Query().Get("state") != "xyz" {
+		t.Fatalf("Error Location = %s", location)
+	}
+
+	tokens := postForm(t, ts.URL+"/code", url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"abcdef"},
+		"client_secret": {"12345"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {"http://localhost:3000/callback"},
+	})
+	me := getMe(t, ts, tokens["access_token"].(string))
+	if me["credential"] != "user01" {
+		t.Fatalf("Error credential = %v", me["credential"])
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+type authCode struct {
+	clientID    string
+	redirectURI string
+	username    string
+	expires     time.Time
+}
+
+// StaticVerifier validates users and clients declared in the configuration
+// and keeps the issued authorization codes and token ids in memory.
+type StaticVerifier struct {
+	cfg *Config
+
+	mu     sync.Mutex
+	codes  map[string]authCode
+	tokens map[string]string // refresh token id -> token id
+}
+
+// NewStaticVerifier creates a verifier backed by the configuration.
+func NewStaticVerifier(cfg *Config) *StaticVerifier {
+	return &StaticVerifier{cfg: cfg, codes: make(map[string]authCode), tokens: make(map[string]string)}
+}
+
+func (v *StaticVerifier) user(username string) *User {
+	for i := range v.cfg.Users {
+		if v.cfg.Users[i].Username == username {
+			return &v.cfg.Users[i]
+		}
+	}
+	return nil
+}
+
+func (v *StaticVerifier) client(clientID string) *Client {
+	for i := range v.cfg.Clients {
+		if v.cfg.Clients[i].ID == clientID {
+			return &v.cfg.Clients[i]
+		}
+	}
+	return nil
+}
+
+// ValidateUser validates username and password returning an error if the user credentials are wrong
+func (v *StaticVerifier) ValidateUser(username, password, scope string, r *http.Request) error {
+	if u := v.user(username); u != nil && u.Password == password {
+		return nil
+	}
+	return errors.New("wrong user")
+}
+
+// ValidateClient validates clientID and secret returning an error if the client credentials are wrong
+func (v *StaticVerifier) ValidateClient(clientID, clientSecret, scope string, r *http.Request) error {
+	if c := v.client(clientID); c != nil && c.Secret == clientSecret {
+		return nil
+	}
+	return errors.New("wrong client")
+}
+
+// IssueCode creates a single use authorization code for the user.
+func (v *StaticVerifier) IssueCode(clientID, redirectURI, username string) (string, error) {
+	c := v.client(clientID)
+	if c == nil {
+		return "", errors.New("unknown client")
+	}
+	if !contains(c.RedirectURIs, redirectURI) {
+		return "", errors.New("redirect_uri is not registered")
+	}
+	code := uuid.Must(uuid.NewV4()).String()
+	v.mu.Lock()
+	v.codes[code] = authCode{clientID: clientID, redirectURI: redirectURI, username: username, expires: time.Now().Add(v.cfg.CodeTTL)}
+	v.mu.Unlock()
+	return code, nil
+}
+
+// ValidateCode checks the authorization code and returns the user credential
+func (v *StaticVerifier) ValidateCode(clientID, clientSecret, code, redirectURI string, r *http.Request) (string, error) {
+	if err := v.ValidateClient(clientID, clientSecret, "", r); err != nil {
+		return "", err
+	}
+	v.mu.Lock()
+	ac, ok := v.codes[code]
+	delete(v.codes, code)
+	v.mu.Unlock()
+	if !ok || time.Now().After(ac.expires) {
+		return "", errors.New("invalid code")
+	}
+	if ac.clientID != clientID || ac.redirectURI != redirectURI {
+		return "", errors.New("code was issued to another client")
+	}
+	return ac.username, nil
+}
+
+// AddClaims provides the configured user claims to the token
+func (v *StaticVerifier) AddClaims(tokenType oauth.TokenType, credential, tokenID, scope string, r *http.Request) (oauth.Claims, error) {
+	claims := make(oauth.Claims)
+	if u := v.user(credential); u != nil {
+		for k, val := range u.Claims {
+			claims[k] = val
+		}
+	}
+	return claims, nil
+}
+
+// AddProperties provides additional information to the token response
+func (v *StaticVerifier) AddProperties(tokenType oauth.TokenType, credential, tokenID, scope string, r *http.Request) (oauth.Properties, error) {
+	return nil, nil
+}
+
+// ValidateTokenID checks that the refresh token is the latest issued for the token
+func (v *StaticVerifier) ValidateTokenID(tokenType oauth.TokenType, credential, tokenID, refreshTokenID string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.tokens[refreshTokenID] != tokenID {
+		return errors.New("refresh token was revoked")
+	}
+	delete(v.tokens, refreshTokenID)
+	return nil
+}
+
+// StoreTokenID saves the token id generated for the user
+func (v *StaticVerifier) StoreTokenID(tokenType oauth.TokenType, credential, tokenID, refreshTokenID string) error {
+	v.mu.Lock()
+	v.tokens[refreshTokenID] = tokenID
+	v.mu.Unlock()
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
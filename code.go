@@ -0,0 +1,241 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// AuthorizationCodeContext is the context key of the AuthorizationCode being exchanged.
+const AuthorizationCodeContext contextKey = "oauth.authorizationcode"
+
+// DefaultCodeTTL is the lifetime of the authorization codes when BearerServer.CodeTTL is not set.
+const DefaultCodeTTL = time.Minute
+
+// ErrCodeNotFound is returned by the AuthCodeStore when the code is unknown or already consumed.
+var ErrCodeNotFound = errors.New("authorization code not found")
+
+// ErrCodeReplayCacheRequired is returned by IssueAuthorizationCode when StatelessAuthorizationCodes is enabled without
+// CodeReplayCache: the sealed codes could be redeemed repeatedly until they expire.
+var ErrCodeReplayCacheRequired = errors.New("stateless authorization codes require a CodeReplayCache")
+
+// codePrefix separates the sealed authorization codes from the tokens crypted with the same formatter
+var codePrefix = []byte("code:")
+
+// AuthorizationCode is the content of an authorization code, self-contained or persisted by the AuthCodeStore.
+type AuthorizationCode struct {
+	ID                  string              `json:"code_id"`
+	ClientID            string              `json:"client_id"`
+	RedirectURI         string              `json:"redirect_uri"`
+	Credential          string              `json:"credential"`
+	Scope               string              `json:"scope"`
+	CodeChallenge       string              `json:"code_challenge,omitempty"`
+	CodeChallengeMethod CodeChallengeMethod `json:"code_challenge_method,omitempty"`
+	// ACR and AMR are the authentication context class and methods achieved by the user, embedded in the tokens
+	ACR string   `json:"acr,omitempty"`
+	AMR []string `json:"amr,omitempty"`
+	// Claims is the "claims" parameter of the authorization request, exposed to AddClaims by ClaimsRequestFromContext
+	Claims *ClaimsRequest `json:"claims_request,omitempty"`
+	// Nonce is the nonce of the authorization request, for the ID token built by AddClaims
+	Nonce string `json:"nonce,omitempty"`
+	// AuthTime is when the user authenticated, the absolute lifetime of the refresh tokens starts from it when set
+	AuthTime     time.Time     `json:"auth_time,omitempty"`
+	CreationDate time.Time     `json:"date"`
+	ExpiresIn    time.Duration `json:"expires_in"`
+}
+
+// IsExpired checks the creation date to the expiry and returns true if the code is expired.
+func (c *AuthorizationCode) IsExpired() bool {
+	return time.Now().UTC().After(c.CreationDate.Add(c.ExpiresIn))
+}
+
+// CryptAuthorizationCode serializes and crypts the authorization code.
+func (tp *TokenProvider) CryptAuthorizationCode(c *AuthorizationCode) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return tp.crypt(append(append([]byte{}, codePrefix...), b...))
+}
+
+// DecryptAuthorizationCode decrypts the authorization code, errors wrap ErrMalformedToken.
+func (tp *TokenProvider) DecryptAuthorizationCode(code string) (*AuthorizationCode, error) {
+	b, err := tp.decrypt(code)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, codePrefix) {
+		return nil, ErrMalformedToken
+	}
+	var c *AuthorizationCode
+	if err = json.Unmarshal(b[len(codePrefix):], &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if c == nil {
+		return nil, ErrMalformedToken
+	}
+	return c, nil
+}
+
+// AuthCodeStore persists the authorization codes exchanged by the authorization_code grant.
+type AuthCodeStore interface {
+	// SaveCode records the authorization code
+	SaveCode(c *AuthorizationCode) error
+	// ConsumeCode returns and removes the authorization code, ErrCodeNotFound if unknown or already consumed
+	ConsumeCode(id string) (*AuthorizationCode, error)
+}
+
+// MemoryAuthCodeStore is an in-memory AuthCodeStore safe for concurrent use.
+type MemoryAuthCodeStore struct {
+	mu    sync.Mutex
+	codes map[string]*AuthorizationCode
+}
+
+// NewMemoryAuthCodeStore creates an empty MemoryAuthCodeStore.
+func NewMemoryAuthCodeStore() *MemoryAuthCodeStore {
+	return &MemoryAuthCodeStore{codes: make(map[string]*AuthorizationCode)}
+}
+
+// SaveCode records the authorization code
+func (s *MemoryAuthCodeStore) SaveCode(c *AuthorizationCode) error {
+	cp := *c
+	s.mu.Lock()
+	s.codes[c.ID] = &cp
+	s.mu.Unlock()
+	return nil
+}
+
+// ConsumeCode returns and removes the authorization code, ErrCodeNotFound if unknown or already consumed
+func (s *MemoryAuthCodeStore) ConsumeCode(id string) (*AuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.codes[id]
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+	delete(s.codes, id)
+	return c, nil
+}
+
+// PurgeExpired removes the codes expired before now, returning how many were removed
+func (s *MemoryAuthCodeStore) PurgeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, c := range s.codes {
+		if now.After(c.CreationDate.Add(c.ExpiresIn)) {
+			delete(s.codes, id)
+			n++
+		}
+	}
+	return n
+}
+
+// IssueAuthorizationCode returns the authorization code the authorization endpoint redirects to the client:
+// the code sealed with the server formatter when StatelessAuthorizationCodes is enabled,
+// otherwise the code id of the code saved in the AuthCodeStore.
+// With a ClientStore the redirect URI must be registered for the client, ErrRedirectURIMismatch otherwise: the clients
+// without registered redirect URIs cannot get authorization codes.
+// ID, CreationDate and ExpiresIn are set when empty.
+func (bs *BearerServer) IssueAuthorizationCode(c *AuthorizationCode) (string, error) {
+	if bs.StatelessAuthorizationCodes && bs.CodeReplayCache == nil {
+		return "", ErrCodeReplayCacheRequired
+	}
+	if c.ID == "" {
+		c.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	if c.CreationDate.IsZero() {
+		c.CreationDate = time.Now().UTC()
+	}
+	if c.ExpiresIn == 0 {
+		c.ExpiresIn = bs.CodeTTL
+		if c.ExpiresIn == 0 {
+			c.ExpiresIn = DefaultCodeTTL
+		}
+	}
+	if bs.ClientStore != nil {
+		client, err := bs.ClientStore.GetClient(c.ClientID)
+		if err != nil {
+			return "", err
+		}
+		if !client.MatchRedirectURI(c.RedirectURI) {
+			return "", ErrRedirectURIMismatch
+		}
+	}
+	if c.Claims != nil && bs.SupportedClaims != nil {
+		c.Claims.Restrict(bs.SupportedClaims)
+	}
+	if !bs.StatelessAuthorizationCodes && bs.AuthCodeStore != nil {
+		if err := bs.AuthCodeStore.SaveCode(c); err != nil {
+			return "", err
+		}
+		return c.ID, nil
+	}
+	return bs.provider.CryptAuthorizationCode(c)
+}
+
+// codeGrant exchanges a sealed or stored authorization code: the client and redirect_uri must match the code,
+// confidential clients are authenticated with ValidateClient and the code_verifier is checked against the challenge
+func (bs *BearerServer) codeGrant(gc *GrantContext) (interface{}, int) {
+	clientID, secret, r := gc.ClientID, gc.secret, gc.Request
+	if bs.StatelessAuthorizationCodes && bs.CodeReplayCache == nil {
+		return ErrorResponse{Error: TokenServerError, Description: ErrCodeReplayCacheRequired.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	client, err := bs.checkClientGrant(clientID, AuthCodeGrant)
+	if err != nil {
+		return clientGrantError(err)
+	}
+	public := client != nil && client.Public
+	if !public {
+		if secret == "" || bs.checkClientSecret(client, clientID, secret, "", r) != nil {
+			return ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+		}
+	}
+
+	ac, err := bs.loadAuthorizationCode(gc.code)
+	if err != nil || ac.IsExpired() {
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "authorization code is invalid or expired", URI: ""}, http.StatusBadRequest
+	}
+	if ac.ClientID != clientID || ac.RedirectURI != gc.RedirectURI {
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "authorization code was issued to another client or redirect_uri", URI: ""}, http.StatusBadRequest
+	}
+	if ac.CodeChallenge == "" && public {
+		return ErrorResponse{Error: TokenInvalidRequest, Description: "PKCE is required for public clients", URI: ""}, http.StatusBadRequest
+	}
+	if ac.CodeChallenge != "" && !VerifyCodeChallenge(ac.CodeChallenge, ac.CodeChallengeMethod, r.FormValue("code_verifier")) {
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid code_verifier", URI: ""}, http.StatusBadRequest
+	}
+	if bs.StatelessAuthorizationCodes {
+		if err = bs.CodeReplayCache.Consume(ac.ID, time.Until(ac.CreationDate.Add(ac.ExpiresIn))); err != nil {
+			return ErrorResponse{Error: TokenInvalidGrant, Description: "authorization code is invalid or expired", URI: ""}, http.StatusBadRequest
+		}
+	}
+	gc.Scope, gc.acr, gc.amr = ac.Scope, ac.ACR, ac.AMR
+	ctx := context.WithValue(r.Context(), AuthorizationCodeContext, ac)
+	if ac.Claims != nil {
+		ctx = context.WithValue(ctx, ClaimsRequestContext, ac.Claims)
+	}
+	gc.Request, gc.authTime = r.WithContext(ctx), ac.AuthTime
+	return bs.issueTokens(gc, AuthToken, ac.Credential)
+}
+
+// AuthorizationCodeFromContext returns the AuthorizationCode exchanged by the grant request, for the verifier AddClaims
+func AuthorizationCodeFromContext(ctx context.Context) *AuthorizationCode {
+	ac, _ := ctx.Value(AuthorizationCodeContext).(*AuthorizationCode)
+	return ac
+}
+
+// loadAuthorizationCode decrypts the sealed code or consumes the stored one
+func (bs *BearerServer) loadAuthorizationCode(code string) (*AuthorizationCode, error) {
+	if bs.StatelessAuthorizationCodes {
+		return bs.provider.DecryptAuthorizationCode(code)
+	}
+	return bs.AuthCodeStore.ConsumeCode(code)
+}
@@ -0,0 +1,118 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatelessAuthorizationCode(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.StatelessAuthorizationCodes = true
+	sut.CodeReplayCache = NewMemoryReplayCache()
+
+	code, err := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111", Scope: "read",
+		CodeChallenge: S256Challenge(testCodeVerifierValue), CodeChallengeMethod: S256CodeChallenge})
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if _, err = _mut.ValidateToken(code); err == nil {
+		t.Fatalf("Error authorization code accepted as access token")
+	}
+
+	r := &http.Request{Form: url.Values{"code_verifier": {testCodeVerifierValue}}}
+	resp, status := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/other", r)
+	if status != http.StatusBadRequest {
+		t.Fatalf("Error response = %v", resp)
+	}
+	resp, status = sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", r)
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, err := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if err != nil || token.Credential != "user111" || token.Scope != "read" || token.TokenType != AuthToken {
+		t.Fatalf("Error token = %v, %v", token, err)
+	}
+
+	resp, status = sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", r)
+	if status != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidGrant {
+		t.Fatalf("Error replayed code accepted = %v", resp)
+	}
+}
+
+func TestStatelessAuthorizationCodeClientAuth(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.StatelessAuthorizationCodes = true
+	if _, err := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", Credential: "user111"}); err != ErrCodeReplayCacheRequired {
+		t.Fatalf("Error should be ErrCodeReplayCacheRequired: %v", err)
+	}
+	if err := sut.Validate(AuthCodeGrant); err == nil || !strings.Contains(err.Error(), "CodeReplayCache") {
+		t.Fatalf("Error Validate = %v", err)
+	}
+	sut.CodeReplayCache = NewMemoryReplayCache()
+
+	code, _ := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", Credential: "user111", ExpiresIn: time.Minute})
+	r := &http.Request{Form: url.Values{}}
+	if _, status := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "wrong", "", "", code, "", r); status != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+
+	expired, _ := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", Credential: "user111", CreationDate: time.Now().UTC().Add(-time.Hour)})
+	if _, status := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", expired, "", r); status != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+}
+
+func TestStoredAuthorizationCode(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.AuthCodeStore = NewMemoryAuthCodeStore()
+
+	code, err := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111", Scope: "read"})
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	r := &http.Request{Form: url.Values{}}
+	resp, status := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", r)
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	if _, status = sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", r); status != http.StatusBadRequest {
+		t.Fatalf("Error consumed code accepted, StatusCode = %d", status)
+	}
+}
+
+type nonceVerifier struct {
+	TestUserVerifier
+}
+
+func (nonceVerifier) AddClaims(tokenType TokenType, credential, tokenID, scope string, r *http.Request) (Claims, error) {
+	if ac := AuthorizationCodeFromContext(r.Context()); ac != nil {
+		return Claims{"nonce": ac.Nonce}, nil
+	}
+	return nil, nil
+}
+
+func TestAuthorizationCodeContext(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(nonceVerifier), nil)
+	sut.RefreshTokenMaxLifetime = time.Hour
+	sut.AuthCodeStore = NewMemoryAuthCodeStore()
+	authTime := time.Now().UTC().Add(-time.Minute * 59).Truncate(time.Second)
+
+	code, _ := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111", Nonce: "n-0S6", AuthTime: authTime})
+	resp, status := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", &http.Request{Form: url.Values{}})
+	if status != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	refresh, _ := sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	if token.Claims["nonce"] != "n-0S6" || !refresh.AuthTime.Equal(authTime) || refresh.ExpiresIn > time.Minute {
+		t.Fatalf("Error claims = %v, auth_time = %s, refresh lifetime = %s", token.Claims, refresh.AuthTime, refresh.ExpiresIn)
+	}
+
+	code, _ = sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111", AuthTime: authTime.Add(-time.Hour)})
+	if _, status = sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", code, "https://client/cb", &http.Request{Form: url.Values{}}); status != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+}
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Compression is the algorithm compressing the token payloads before encryption
+type Compression byte
+
+// Compression algorithms, the value is the flag byte prefixing the payload
+const (
+	NoCompression      Compression = 0
+	DeflateCompression Compression = 1
+	GzipCompression    Compression = 2
+)
+
+// DefaultMinCompressSize is the payload size below which the compression is not worth it
+const DefaultMinCompressSize = 256
+
+// DefaultMaxDecompressedSize bounds the decompressed payloads
+const DefaultMaxDecompressedSize = 1 << 20
+
+// CompressingFormatter compresses the token payloads before crypting them with the wrapped formatter, the compressed
+// size leaks through the token length so do not use it when the claims mix secrets with attacker controlled values.
+// Payloads crypted before the compression was enabled are still decrypted.
+type CompressingFormatter struct {
+	inner       TokenSecureFormatter
+	compression Compression
+	// MinSize is the payload size from which the payloads are compressed, DefaultMinCompressSize when 0
+	MinSize int
+	// MaxDecompressedSize bounds the decompressed payloads, DefaultMaxDecompressedSize when 0
+	MaxDecompressedSize int64
+}
+
+// NewCompressingFormatter wraps the formatter compressing the payloads with the algorithm
+func NewCompressingFormatter(inner TokenSecureFormatter, compression Compression) *CompressingFormatter {
+	return &CompressingFormatter{inner: inner, compression: compression}
+}
+
+// CompactTokens reports whether the tokens of the wrapped formatter are emitted as is
+func (f *CompressingFormatter) CompactTokens() bool {
+	inner, ok := f.inner.(CompactTokenFormatter)
+	return ok && inner.CompactTokens()
+}
+
+// CryptToken compresses the payload, prefixed by the compression flag byte, then crypts it
+func (f *CompressingFormatter) CryptToken(source []byte) ([]byte, error) {
+	minSize := f.MinSize
+	if minSize <= 0 {
+		minSize = DefaultMinCompressSize
+	}
+	compression := f.compression
+	if len(source) < minSize {
+		compression = NoCompression
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(byte(compression))
+	var w io.WriteCloser
+	switch compression {
+	case NoCompression:
+		buf.Write(source)
+		return f.inner.CryptToken(buf.Bytes())
+	case DeflateCompression:
+		w, _ = flate.NewWriter(&buf, flate.BestCompression)
+	case GzipCompression:
+		w, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	default:
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+	if _, err := w.Write(source); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return f.inner.CryptToken(buf.Bytes())
+}
+
+// DecryptToken decrypts the payload then decompresses it according to its flag byte
+func (f *CompressingFormatter) DecryptToken(source []byte) ([]byte, error) {
+	payload, err := f.inner.DecryptToken(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, ErrMalformedToken
+	}
+	var r io.Reader
+	switch Compression(payload[0]) {
+	case NoCompression:
+		return payload[1:], nil
+	case DeflateCompression:
+		r = flate.NewReader(bytes.NewReader(payload[1:]))
+	case GzipCompression:
+		if r, err = gzip.NewReader(bytes.NewReader(payload[1:])); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+		}
+	default:
+		// payload crypted without compression
+		return payload, nil
+	}
+	limit := f.MaxDecompressedSize
+	if limit <= 0 {
+		limit = DefaultMaxDecompressedSize
+	}
+	dest, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if int64(len(dest)) > limit {
+		return nil, fmt.Errorf("%w: decompressed payload exceeds %d bytes", ErrMalformedToken, limit)
+	}
+	return dest, nil
+}
@@ -0,0 +1,48 @@
+package oauth
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompressingFormatter(t *testing.T) {
+	inner := NewSHA256RC4TokenSecurityProvider([]byte("mySecretKey-10101"))
+	payload := []byte(`{"claims":{"groups":"` + strings.Repeat("engineering,", 100) + `"}}`)
+	for _, compression := range []Compression{DeflateCompression, GzipCompression} {
+		f := NewCompressingFormatter(inner, compression)
+		crypted, err := f.CryptToken(payload)
+		if err != nil {
+			t.Fatalf("Error %v", err)
+		}
+		if len(crypted) >= len(payload) {
+			t.Fatalf("Error compression %d: %d bytes for a %d bytes payload", compression, len(crypted), len(payload))
+		}
+		decrypted, err := f.DecryptToken(crypted)
+		if err != nil || !bytes.Equal(decrypted, payload) {
+			t.Fatalf("Error compression %d: %s, %v", compression, decrypted, err)
+		}
+
+		f.MaxDecompressedSize = 100
+		if _, err = f.DecryptToken(crypted); !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("Error %v", err)
+		}
+	}
+
+	f := NewCompressingFormatter(inner, GzipCompression)
+	small := []byte(`{"token_id":"1"}`)
+	crypted, _ := f.CryptToken(small)
+	if decrypted, err := f.DecryptToken(crypted); err != nil || !bytes.Equal(decrypted, small) {
+		t.Fatalf("Error %s, %v", decrypted, err)
+	}
+	legacy, _ := inner.CryptToken(small)
+	if decrypted, err := f.DecryptToken(legacy); err != nil || !bytes.Equal(decrypted, small) {
+		t.Fatalf("Error legacy %s, %v", decrypted, err)
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", 0, 0, new(TestUserVerifier), f)
+	if err := sut.Validate(PasswordGrant); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+}
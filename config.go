@@ -0,0 +1,221 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultConfigPollInterval is the interval at which WatchConfigFile checks the configuration file when 0
+const DefaultConfigPollInterval = 5 * time.Second
+
+// ConfigDuration is a time.Duration unmarshaled from a duration string ("15m") or a number of seconds.
+type ConfigDuration time.Duration
+
+// MarshalJSON marshals the duration as a duration string
+func (d ConfigDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON unmarshals a duration string or a number of seconds
+func (d *ConfigDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = ConfigDuration(v)
+		return nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(b, &seconds); err != nil {
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	*d = ConfigDuration(seconds * float64(time.Second))
+	return nil
+}
+
+// ConfigClient is the static registration of a client in the ServerConfig, with its secret hash.
+type ConfigClient struct {
+	Client
+	// SecretHash is the HashClientSecret hash of the client secret
+	SecretHash string `json:"secret_hash,omitempty"`
+}
+
+// ServerConfig is the part of the server configuration reloaded at runtime by Reload, e.g. from the JSON file watched
+// by WatchConfigFile. It is combined with the BearerServer fields: the non-zero TTLs override TokenTTL, RefreshTokenTTL
+// and RefreshTokenMaxLifetime, the disabled grants and CORS origins are added to DisabledGrants and CORSOrigins.
+//
+//	{"token_ttl": "15m", "disabled_grants": ["password"], "cors_origins": ["https://app.example.com"],
+//	 "clients": [{"client_id": "spa", "grant_types": ["authorization_code"], "public": true}]}
+type ServerConfig struct {
+	TokenTTL                ConfigDuration `json:"token_ttl,omitempty"`
+	RefreshTokenTTL         ConfigDuration `json:"refresh_token_ttl,omitempty"`
+	RefreshTokenMaxLifetime ConfigDuration `json:"refresh_token_max_lifetime,omitempty"`
+	DisabledGrants          []GrantType    `json:"disabled_grants,omitempty"`
+	CORSOrigins             []string       `json:"cors_origins,omitempty"`
+	// Clients, when not nil, replace the clients of the previous configuration in the ClientStore, which must
+	// implement ClientReplacer. They cannot be empty, RemoveAllClients removes the configured clients.
+	Clients []*ConfigClient `json:"clients,omitempty"`
+	// RemoveAllClients removes the clients of the previous configuration, without Clients
+	RemoveAllClients bool `json:"remove_all_clients,omitempty"`
+}
+
+// ClientReplacer is implemented by the ClientStores whose clients are replaced by the Clients of the ServerConfig.
+type ClientReplacer interface {
+	// ReplaceClients atomically replaces the client registrations of the previous ReplaceClients, keeping the
+	// clients registered otherwise, e.g. with the AdminHandler
+	ReplaceClients(clients []*Client) error
+}
+
+// LoadConfigFile reads the JSON ServerConfig of the file, the unknown fields are rejected
+func LoadConfigFile(path string) (*ServerConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	cfg := new(ServerConfig)
+	if err = dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Reload applies the configuration to the server without restarting it: the requests being served keep the settings
+// read when they started and the next requests get the new ones. The configuration is checked like Validate and the
+// current one is kept when it is invalid or its clients cannot be replaced. OnConfigReload is called with the result.
+func (bs *BearerServer) Reload(cfg *ServerConfig) error {
+	bs.configMu.Lock()
+	defer bs.configMu.Unlock()
+	err := bs.applyConfig(cfg)
+	if bs.OnConfigReload != nil {
+		bs.OnConfigReload(cfg, err)
+	}
+	return err
+}
+
+func (bs *BearerServer) applyConfig(cfg *ServerConfig) error {
+	if cfg == nil {
+		return errors.New("invalid server configuration: nil config")
+	}
+	var problems []string
+	if cfg.TokenTTL < 0 || cfg.RefreshTokenTTL < 0 || cfg.RefreshTokenMaxLifetime < 0 {
+		problems = append(problems, "token lifetimes cannot be negative")
+	}
+	ttl, idle, absolute := bs.ttls(cfg)
+	if ttl > 0 && idle > 0 && ttl > idle {
+		problems = append(problems, fmt.Sprintf("TokenTTL (%s) exceeds RefreshTokenTTL (%s)", ttl, idle))
+	}
+	if absolute > 0 && idle > absolute {
+		problems = append(problems, fmt.Sprintf("RefreshTokenTTL (%s) exceeds RefreshTokenMaxLifetime (%s)", idle, absolute))
+	}
+	for _, c := range cfg.Clients {
+		if c == nil || c.ID == "" {
+			problems = append(problems, "a client has no client_id")
+		}
+	}
+	if cfg.Clients != nil && len(cfg.Clients) == 0 && !cfg.RemoveAllClients {
+		problems = append(problems, "clients is empty, set remove_all_clients to remove the configured clients")
+	}
+	if cfg.RemoveAllClients && len(cfg.Clients) > 0 {
+		problems = append(problems, "remove_all_clients cannot be combined with clients")
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid server configuration: " + strings.Join(problems, "; "))
+	}
+	if cfg.Clients != nil || cfg.RemoveAllClients {
+		replacer, ok := bs.ClientStore.(ClientReplacer)
+		if !ok {
+			return errors.New("invalid server configuration: the ClientStore does not implement ClientReplacer")
+		}
+		clients := make([]*Client, len(cfg.Clients))
+		for i, c := range cfg.Clients {
+			client := c.Client
+			client.SecretHash = c.SecretHash
+			clients[i] = &client
+		}
+		if err := replacer.ReplaceClients(clients); err != nil {
+			return err
+		}
+	}
+	bs.config.Store(cfg)
+	return nil
+}
+
+// Config returns the configuration applied by the last successful Reload, nil before
+func (bs *BearerServer) Config() *ServerConfig {
+	cfg, _ := bs.config.Load().(*ServerConfig)
+	return cfg
+}
+
+// ttls returns the token TTL and the refresh token idle and absolute lifetimes of the fields overridden by the config
+func (bs *BearerServer) ttls(cfg *ServerConfig) (ttl, idle, absolute time.Duration) {
+	ttl, idle, absolute = bs.TokenTTL, bs.RefreshTokenTTL, bs.RefreshTokenMaxLifetime
+	if cfg == nil {
+		return
+	}
+	if cfg.TokenTTL > 0 {
+		ttl = time.Duration(cfg.TokenTTL)
+	}
+	if cfg.RefreshTokenTTL > 0 {
+		idle = time.Duration(cfg.RefreshTokenTTL)
+	}
+	if cfg.RefreshTokenMaxLifetime > 0 {
+		absolute = time.Duration(cfg.RefreshTokenMaxLifetime)
+	}
+	return
+}
+
+// tokenTTL returns the current access token lifetime
+func (bs *BearerServer) tokenTTL() time.Duration {
+	ttl, _, _ := bs.ttls(bs.Config())
+	return ttl
+}
+
+// WatchConfigFile returns the BackgroundTask reloading the JSON ServerConfig of the file when it starts and then each
+// time the modification time or size of the file changes, checked every interval (DefaultConfigPollInterval when 0).
+// The invalid files are reported to OnConfigReload and the current configuration is kept.
+//
+//	bs.AddBackgroundTask(bs.WatchConfigFile("/etc/oauth/config.json", 0))
+func (bs *BearerServer) WatchConfigFile(path string, interval time.Duration) BackgroundTask {
+	if interval <= 0 {
+		interval = DefaultConfigPollInterval
+	}
+	return func(ctx context.Context) error {
+		var last os.FileInfo
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			info, err := os.Stat(path)
+			if err != nil {
+				last = nil
+				if bs.OnConfigReload != nil {
+					bs.OnConfigReload(nil, err)
+				}
+			} else if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				if cfg, err := LoadConfigFile(path); err != nil {
+					if bs.OnConfigReload != nil {
+						bs.OnConfigReload(nil, err)
+					}
+				} else {
+					_ = bs.Reload(cfg)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+}
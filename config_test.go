@@ -0,0 +1,150 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{ClientCredentialsGrant}})
+	var resp TokenResponse
+	var reloads []error
+	sut.OnConfigReload = func(_ *ServerConfig, err error) { reloads = append(reloads, err) }
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+	clientCredentials := func(clientID string) *httptest.ResponseRecorder {
+		return post(url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "client_secret": {"12345"}})
+	}
+
+	if w := clientCredentials("abcdef"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.ExpiresIn != 10 {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	cfg := &ServerConfig{
+		TokenTTL:       ConfigDuration(30 * time.Second),
+		DisabledGrants: []GrantType{PasswordGrant},
+		Clients:        []*ConfigClient{{Client: Client{ID: "abcdef", AllowedGrantTypes: []GrantType{ClientCredentialsGrant}}}},
+	}
+	if err := sut.Reload(cfg); err != nil || sut.Config() != cfg {
+		t.Fatalf("Error Reload: %v", err)
+	}
+	if w := clientCredentials("abcdef"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.ExpiresIn != 30 {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	w := post(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), string(TokenUnsupportedGrantType)) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	if err := sut.Reload(&ServerConfig{TokenTTL: ConfigDuration(2 * time.Minute)}); err == nil || !strings.Contains(err.Error(), "exceeds RefreshTokenTTL") {
+		t.Fatalf("Error invalid config accepted: %v", err)
+	}
+	if sut.Config() != cfg || sut.tokenTTL() != 30*time.Second {
+		t.Fatalf("Error the invalid config replaced the current one")
+	}
+	if len(reloads) != 2 || reloads[0] != nil || reloads[1] == nil {
+		t.Fatalf("Error OnConfigReload results = %v", reloads)
+	}
+
+	store := sut.ClientStore.(*MemoryClientStore)
+	store.SaveClient(&Client{ID: "admin", AllowedGrantTypes: []GrantType{ClientCredentialsGrant}})
+	if err := sut.Reload(cfg); err != nil {
+		t.Fatalf("Error Reload: %v", err)
+	}
+	if _, err := store.GetClient("admin"); err != nil {
+		t.Fatalf("Error the client saved by the admin was removed: %v", err)
+	}
+	if err := sut.Reload(&ServerConfig{Clients: []*ConfigClient{}}); err == nil {
+		t.Fatalf("Error empty clients accepted")
+	}
+	if w := clientCredentials("abcdef"); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if err := sut.Reload(&ServerConfig{RemoveAllClients: true}); err != nil {
+		t.Fatalf("Error Reload: %v", err)
+	}
+	if w := clientCredentials("abcdef"); w.Code == http.StatusOK {
+		t.Fatalf("Error the removed client got a token")
+	}
+	if _, err := store.GetClient("admin"); err != nil {
+		t.Fatalf("Error the client saved by the admin was removed: %v", err)
+	}
+
+	sut.ClientStore = nil
+	if err := sut.Reload(&ServerConfig{RemoveAllClients: true}); err == nil {
+		t.Fatalf("Error clients replaced without ClientReplacer")
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"token_ttl": "15m", "refresh_token_ttl": 3600, "clients": [{"client_id": "spa", "public": true, "secret_hash": "h"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfigFile(path)
+	if err != nil || time.Duration(cfg.TokenTTL) != 15*time.Minute || time.Duration(cfg.RefreshTokenTTL) != time.Hour {
+		t.Fatalf("Error LoadConfigFile = %+v, %v", cfg, err)
+	}
+	if len(cfg.Clients) != 1 || cfg.Clients[0].ID != "spa" || !cfg.Clients[0].Public || cfg.Clients[0].SecretHash != "h" {
+		t.Fatalf("Error Clients = %+v", cfg.Clients)
+	}
+	if err = ioutil.WriteFile(path, []byte(`{"token_tll": "15m"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadConfigFile(path); err == nil {
+		t.Fatalf("Error unknown field accepted")
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	reloaded := make(chan *ServerConfig, 4)
+	sut.OnConfigReload = func(cfg *ServerConfig, err error) {
+		if err == nil {
+			reloaded <- cfg
+		}
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"token_ttl": "20s"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sut.WatchConfigFile(path, 10*time.Millisecond)(ctx) }()
+
+	wait := func(expected time.Duration) {
+		select {
+		case cfg := <-reloaded:
+			if time.Duration(cfg.TokenTTL) != expected || sut.tokenTTL() != expected {
+				t.Fatalf("Error TokenTTL = %s", time.Duration(cfg.TokenTTL))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Error config not reloaded")
+		}
+	}
+	wait(20 * time.Second)
+	if err := ioutil.WriteFile(path, []byte(`{"token_ttl": "40s"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wait(40 * time.Second)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Error WatchConfigFile = %v", err)
+	}
+}
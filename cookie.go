@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+const (
+	// DefaultRefreshCookieName is the name of the refresh token cookie when the RefreshCookie Name is empty.
+	DefaultRefreshCookieName = "refresh_token"
+	// DefaultCSRFHeader is the header carrying the CSRF token when the RefreshCookie CSRFHeader is empty.
+	DefaultCSRFHeader = "X-CSRF-Token"
+)
+
+// errCSRFMismatch is returned when the refresh from the cookie lacks the CSRF token of the cookie
+var errCSRFMismatch = errors.New("missing or invalid CSRF token")
+
+// RefreshCookie configures the delivery of the refresh tokens in a Secure HttpOnly cookie instead of the response
+// body, for the browser-based applications served by a backend for frontend (BFF).
+type RefreshCookie struct {
+	// Name is the cookie name, DefaultRefreshCookieName when empty
+	Name string
+	// Path restricts the cookie to the token endpoint path, e.g. "/oauth2/token"
+	Path   string
+	Domain string
+	// SameSite is http.SameSiteStrictMode when zero
+	SameSite http.SameSite
+	// CSRFHeader is the request header echoing the CSRF cookie on refresh, DefaultCSRFHeader when empty
+	CSRFHeader string
+}
+
+func (c *RefreshCookie) name() string {
+	if c.Name == "" {
+		return DefaultRefreshCookieName
+	}
+	return c.Name
+}
+
+// csrfName is the name of the cookie readable by the scripts carrying the CSRF token
+func (c *RefreshCookie) csrfName() string {
+	return c.name() + "_csrf"
+}
+
+func (c *RefreshCookie) csrfHeader() string {
+	if c.CSRFHeader == "" {
+		return DefaultCSRFHeader
+	}
+	return c.CSRFHeader
+}
+
+// csrfToken returns the CSRF token bound to the refresh token, signed with the secret key
+func csrfToken(secret, refreshToken string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("csrf." + refreshToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setRefreshCookie moves the refresh token of the response to the RefreshCookie and sets the CSRF cookie
+// the scripts echo in the CSRFHeader of the refresh requests (signed double-submit)
+func (bs *BearerServer) setRefreshCookie(w http.ResponseWriter, resp *TokenResponse) {
+	if resp.RefreshToken == "" {
+		return
+	}
+	c := bs.RefreshCookie
+	sameSite := c.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteStrictMode
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.name(),
+		Value:    resp.RefreshToken,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		MaxAge:   int(resp.RefreshTokenExpiresIn),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.csrfName(),
+		Value:    csrfToken(bs.secret(), resp.RefreshToken),
+		Path:     "/",
+		Domain:   c.Domain,
+		MaxAge:   int(resp.RefreshTokenExpiresIn),
+		Secure:   true,
+		SameSite: sameSite,
+	})
+	resp.RefreshToken = ""
+}
+
+// refreshTokenParam returns the refresh_token parameter, read from the RefreshCookie when it is set and the parameter
+// is missing. The refresh from the cookie must carry the CSRF token in the CSRFHeader, signed with the current
+// secret or, after a reload of the WithSecret secret, with the previous one.
+func (bs *BearerServer) refreshTokenParam(r *http.Request) (string, error) {
+	refreshToken := r.FormValue("refresh_token")
+	if refreshToken != "" || bs.RefreshCookie == nil || GrantType(r.FormValue("grant_type")) != RefreshTokenGrant {
+		return refreshToken, nil
+	}
+	c, err := r.Cookie(bs.RefreshCookie.name())
+	if err != nil {
+		return "", nil
+	}
+	header := []byte(r.Header.Get(bs.RefreshCookie.csrfHeader()))
+	for _, secret := range bs.verificationSecrets() {
+		if hmac.Equal(header, []byte(csrfToken(secret, c.Value))) {
+			return c.Value, nil
+		}
+	}
+	return "", errCSRFMismatch
+}
@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshCookie(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RefreshCookie = &RefreshCookie{Path: "/token"}
+
+	post := func(form url.Values, cookie *http.Cookie, csrf string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if csrf != "" {
+			req.Header.Set(DefaultCSRFHeader, csrf)
+		}
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+
+	w := post(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}, nil, "")
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusOK || len(cookies) != 2 || strings.Contains(w.Body.String(), `"refresh_token"`) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	c := cookies[0]
+	if c.Name != DefaultRefreshCookieName || !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode || c.Path != "/token" || c.MaxAge != 60 {
+		t.Fatalf("Error cookie = %+v", c)
+	}
+	csrf := cookies[1]
+	if csrf.Name != DefaultRefreshCookieName+"_csrf" || csrf.HttpOnly || csrf.Value == "" {
+		t.Fatalf("Error csrf cookie = %+v", csrf)
+	}
+
+	refresh := &http.Cookie{Name: c.Name, Value: c.Value}
+	if w = post(url.Values{"grant_type": {"refresh_token"}}, refresh, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w = post(url.Values{"grant_type": {"refresh_token"}}, refresh, "forged"); w.Code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	w = post(url.Values{"grant_type": {"refresh_token"}}, refresh, csrf.Value)
+	if w.Code != http.StatusOK || len(w.Result().Cookies()) != 2 || w.Result().Cookies()[0].Value == c.Value {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w = post(url.Values{"grant_type": {"refresh_token"}}, nil, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package oauth
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCORSMaxAge is how long, in seconds, the browsers cache the answers to the CORS preflight requests
+const DefaultCORSMaxAge = 600
+
+// corsAllowedHeaders are the request headers the browser clients may send to the endpoints
+var corsAllowedHeaders = []string{"Authorization", "Content-Type", "DPoP", IdempotencyKeyHeader, RequestIDHeader, WrapTTLHeader, DefaultCSRFHeader}
+
+// corsExposedHeaders are the response headers readable by the browser clients
+var corsExposedHeaders = []string{RequestIDHeader, "Retry-After", "Deprecation", "Sunset"}
+
+// CORS is the middleware answering the CORS requests of the browser clients (single-page applications) sent from the
+// CORSOrigins or the origins of the reloaded ServerConfig, the requests of the other origins are served without CORS
+// headers so the browsers block their responses. The credentials (the RefreshCookie) are allowed when the origin is
+// listed, not when it is only allowed by "*". RegisterHandlers wraps the endpoints with it.
+func (bs *BearerServer) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed, listed := bs.corsOrigin(origin)
+		if !allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if listed {
+			h.Set("Access-Control-Allow-Origin", origin)
+			if bs.RefreshCookie != nil {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST")
+			h.Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			h.Set("Access-Control-Max-Age", strconv.Itoa(DefaultCORSMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsOrigin returns whether the origin is allowed and whether it is listed rather than allowed by "*"
+func (bs *BearerServer) corsOrigin(origin string) (allowed, listed bool) {
+	origins := bs.CORSOrigins
+	if cfg := bs.Config(); cfg != nil {
+		origins = append(origins[:len(origins):len(origins)], cfg.CORSOrigins...)
+	}
+	for _, o := range origins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true, true
+		}
+		if o == "*" {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
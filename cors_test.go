@@ -0,0 +1,60 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.CORSOrigins = []string{"https://app.example.com"}
+	mux := http.NewServeMux()
+	sut.RegisterHandlers(mux)
+
+	send := func(method, origin string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}}
+		req := httptest.NewRequest(method, "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodOptions, "https://app.example.com")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		!strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("Error preflight StatusCode = %d, headers = %v", w.Code, w.Header())
+	}
+	w = send(http.MethodPost, "https://app.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		!strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), RequestIDHeader) {
+		t.Fatalf("Error StatusCode = %d, headers = %v", w.Code, w.Header())
+	}
+
+	w = send(http.MethodOptions, "https://evil.example.com")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Error preflight of another origin StatusCode = %d, headers = %v", w.Code, w.Header())
+	}
+
+	if err := sut.Reload(&ServerConfig{CORSOrigins: []string{"https://evil.example.com"}}); err != nil {
+		t.Fatalf("Error Reload: %v", err)
+	}
+	if w = send(http.MethodOptions, "https://evil.example.com"); w.Code != http.StatusNoContent {
+		t.Fatalf("Error reloaded origin not allowed: StatusCode = %d", w.Code)
+	}
+
+	sut.CORSOrigins = []string{"*"}
+	sut.RefreshCookie = &RefreshCookie{}
+	w = send(http.MethodPost, "https://other.example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("Error wildcard headers = %v", w.Header())
+	}
+}
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// Denylist records the revoked access token ids (jti) until their expiry.
+// A bloom filter answers the lookups of the tokens never revoked without touching the exact-match map,
+// the expired entries are pruned by PurgeExpired, called automatically when the capacity is exceeded.
+// Denylist is safe for concurrent use.
+type Denylist struct {
+	mu       sync.RWMutex
+	capacity int
+	rate     float64
+	bits     []uint64
+	hashes   uint32
+	entries  map[string]time.Time
+}
+
+// NewDenylist creates a Denylist sized for the expected number of revoked tokens and the bloom filter false positive rate.
+func NewDenylist(capacity int, falsePositiveRate float64) *Denylist {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+	d := &Denylist{capacity: capacity, rate: falsePositiveRate, entries: make(map[string]time.Time)}
+	d.reset()
+	return d
+}
+
+// reset sizes the bloom filter for the capacity
+func (d *Denylist) reset() {
+	m := math.Ceil(-float64(d.capacity) * math.Log(d.rate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(d.capacity) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	d.bits = make([]uint64, (int(m)+63)/64)
+	d.hashes = uint32(k)
+}
+
+// Add denies the token id until its expiry
+func (d *Denylist) Add(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.entries) >= d.capacity {
+		d.purge(time.Now())
+	}
+	d.entries[jti] = expiresAt
+	d.setBits(jti)
+}
+
+// Contains returns true if the token id is denied and not yet expired
+func (d *Denylist) Contains(jti string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.mayContain(jti) {
+		return false
+	}
+	expiresAt, ok := d.entries[jti]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// Len returns the number of denied token ids, expired ones included until pruned
+func (d *Denylist) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.entries)
+}
+
+// PurgeExpired removes the token ids expired before now and rebuilds the bloom filter, returning how many were removed
+func (d *Denylist) PurgeExpired(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.purge(now)
+}
+
+func (d *Denylist) purge(now time.Time) int {
+	n := 0
+	for jti, expiresAt := range d.entries {
+		if !now.Before(expiresAt) {
+			delete(d.entries, jti)
+			n++
+		}
+	}
+	if len(d.entries) >= d.capacity {
+		d.capacity *= 2
+	}
+	d.reset()
+	for jti := range d.entries {
+		d.setBits(jti)
+	}
+	return n
+}
+
+// locations returns the bloom filter positions of the token id using double hashing
+func (d *Denylist) locations(jti string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(jti))
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+func (d *Denylist) setBits(jti string) {
+	h1, h2 := d.locations(jti)
+	m := uint64(len(d.bits) * 64)
+	for i := uint64(0); i < uint64(d.hashes); i++ {
+		pos := (h1 + i*h2) % m
+		d.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (d *Denylist) mayContain(jti string) bool {
+	h1, h2 := d.locations(jti)
+	m := uint64(len(d.bits) * 64)
+	for i := uint64(0); i < uint64(d.hashes); i++ {
+		pos := (h1 + i*h2) % m
+		if d.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
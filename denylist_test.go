@@ -0,0 +1,54 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDenylist(t *testing.T) {
+	d := NewDenylist(100, 0.01)
+	now := time.Now()
+	d.Add("revoked", now.Add(time.Minute))
+	d.Add("expired", now.Add(-time.Minute))
+	if !d.Contains("revoked") {
+		t.Fatalf("Error revoked token not denied")
+	}
+	if d.Contains("expired") || d.Contains("valid") {
+		t.Fatalf("Error token denied")
+	}
+	if n := d.PurgeExpired(now); n != 1 || d.Len() != 1 || !d.Contains("revoked") {
+		t.Fatalf("Error purged = %d, len = %d", n, d.Len())
+	}
+}
+
+func TestDenylistGrowsBeyondCapacity(t *testing.T) {
+	d := NewDenylist(8, 0.01)
+	for i := 0; i < 100; i++ {
+		d.Add(fmt.Sprintf("jti-%d", i), time.Now().Add(time.Minute))
+	}
+	for i := 0; i < 100; i++ {
+		if !d.Contains(fmt.Sprintf("jti-%d", i)) {
+			t.Fatalf("Error jti-%d not denied", i)
+		}
+	}
+}
+
+func TestValidateTokenDenylist(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.Denylist = NewDenylist(100, 0.01)
+	token, err := mut.ValidateToken(resp.(*TokenResponse).Token)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	mut.Denylist.Add(token.ID, token.CreationDate.Add(token.ExpiresIn))
+	if _, err = mut.ValidateToken(resp.(*TokenResponse).Token); !errors.Is(err, ErrRevokedToken) {
+		t.Fatalf("Error should be ErrRevokedToken: %v", err)
+	}
+}
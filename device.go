@@ -0,0 +1,140 @@
+package oauth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// MultiFactorMethod is the RFC 8176 amr value of the multiple-factor authentications
+const MultiFactorMethod = "mfa"
+
+// DeviceRegistry remembers the devices on which the users completed a multi-factor authentication,
+// the RiskEvaluator MFA requirement is skipped on these devices.
+type DeviceRegistry interface {
+	// TrustDevice remembers the device of the credential until expiresAt, never when zero
+	TrustDevice(credential, deviceID string, expiresAt time.Time) error
+	// IsTrusted returns true when the device of the credential is remembered and not expired
+	IsTrusted(credential, deviceID string) (bool, error)
+	// RevokeDevice forgets the device of the credential
+	RevokeDevice(credential, deviceID string) error
+	// RevokeDevices forgets all the devices of the credential
+	RevokeDevices(credential string) error
+}
+
+// isMultiFactor returns true when the authentication methods include several factors
+func isMultiFactor(amr []string) bool {
+	methods := make(map[string]bool, len(amr))
+	for _, m := range amr {
+		if m == MultiFactorMethod {
+			return true
+		}
+		methods[m] = true
+	}
+	return len(methods) > 1
+}
+
+// checkDevice resolves the "device_id" parameter of the password grant against the DeviceRegistry
+func (bs *BearerServer) checkDevice(gc *GrantContext) (interface{}, int) {
+	if bs.DeviceRegistry == nil || gc.Request == nil {
+		return nil, 0
+	}
+	if gc.deviceID = gc.Request.FormValue(DeviceClaim); gc.deviceID == "" {
+		return nil, 0
+	}
+	trusted, err := bs.DeviceRegistry.IsTrusted(gc.Credential, gc.deviceID)
+	if err != nil {
+		return ErrorResponse{Error: TokenServerError, Description: "loading trusted device failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	gc.trustedDevice = trusted
+	return nil, 0
+}
+
+// rememberDevice trusts the device of a multi-factor authentication, a device identifier is created when the request
+// has none. The identifier is kept in the refresh token only: it is returned in the token response, never in the access
+// token claims read by the resource servers.
+func (bs *BearerServer) rememberDevice(gc *GrantContext, refresh *RefreshToken) error {
+	if bs.DeviceRegistry == nil || !isMultiFactor(gc.amr) {
+		return nil
+	}
+	if gc.deviceID == "" {
+		gc.deviceID = uuid.Must(uuid.NewV4()).String()
+	}
+	var expiresAt time.Time
+	if bs.TrustedDeviceTTL > 0 {
+		expiresAt = time.Now().UTC().Add(bs.TrustedDeviceTTL)
+	}
+	if err := bs.DeviceRegistry.TrustDevice(gc.Credential, gc.deviceID, expiresAt); err != nil {
+		return err
+	}
+	refresh.DeviceID = gc.deviceID
+	gc.trustedDevice = true
+	return nil
+}
+
+// MemoryDeviceRegistry is an in-memory DeviceRegistry safe for concurrent use.
+type MemoryDeviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[string]map[string]time.Time
+}
+
+// NewMemoryDeviceRegistry creates an empty MemoryDeviceRegistry
+func NewMemoryDeviceRegistry() *MemoryDeviceRegistry {
+	return &MemoryDeviceRegistry{devices: make(map[string]map[string]time.Time)}
+}
+
+// TrustDevice remembers the device of the credential until expiresAt, never when zero
+func (m *MemoryDeviceRegistry) TrustDevice(credential, deviceID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.devices[credential] == nil {
+		m.devices[credential] = make(map[string]time.Time)
+	}
+	m.devices[credential][deviceID] = expiresAt
+	return nil
+}
+
+// IsTrusted returns true when the device of the credential is remembered and not expired
+func (m *MemoryDeviceRegistry) IsTrusted(credential, deviceID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	expiresAt, ok := m.devices[credential][deviceID]
+	return ok && (expiresAt.IsZero() || time.Now().Before(expiresAt)), nil
+}
+
+// RevokeDevice forgets the device of the credential
+func (m *MemoryDeviceRegistry) RevokeDevice(credential, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.devices[credential], deviceID)
+	return nil
+}
+
+// RevokeDevices forgets all the devices of the credential
+func (m *MemoryDeviceRegistry) RevokeDevices(credential string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.devices, credential)
+	return nil
+}
+
+// PurgeExpired removes the expired devices and returns the number of devices removed
+func (m *MemoryDeviceRegistry) PurgeExpired(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for credential, devices := range m.devices {
+		for id, expiresAt := range devices {
+			if !expiresAt.IsZero() && !now.Before(expiresAt) {
+				delete(devices, id)
+				n++
+			}
+		}
+		if len(devices) == 0 {
+			delete(m.devices, credential)
+		}
+	}
+	return n
+}
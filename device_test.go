@@ -0,0 +1,100 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type otpVerifier struct {
+	TestUserVerifier
+}
+
+func (otpVerifier) AuthenticationContext(username string, r *http.Request) (string, []string) {
+	if r.FormValue("otp") != "" {
+		return "", []string{"pwd", "otp"}
+	}
+	return "", []string{"pwd"}
+}
+
+type mfaRiskEvaluator struct{}
+
+func (mfaRiskEvaluator) EvaluateRisk(rc *RiskContext) (RiskDecision, error) {
+	if rc.Grant.Form.Get("otp") == "" {
+		return RiskDecision{RequireMFA: true, Reason: "new device"}, nil
+	}
+	return RiskDecision{}, nil
+}
+
+func TestTrustedDevice(t *testing.T) {
+	registry := NewMemoryDeviceRegistry()
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(otpVerifier), nil)
+	sut.RiskEvaluator = mfaRiskEvaluator{}
+	sut.DeviceRegistry = registry
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		form.Set("grant_type", "password")
+		form.Set("username", "user111")
+		form.Set("password", "password111")
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		return w
+	}
+
+	w := post(url.Values{"otp": {"123456"}})
+	var resp TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	device, _ := resp.Extensions[DeviceClaim].(string)
+	token, _ := sut.provider.DecryptToken(resp.Token)
+	refresh, _ := sut.provider.DecryptRefreshTokens(resp.RefreshToken)
+	if _, ok := token.Claims[DeviceClaim]; device == "" || ok || refresh.DeviceID != device {
+		t.Fatalf("Error device = %q, claims = %v", device, token.Claims)
+	}
+
+	if w = post(url.Values{DeviceClaim: {device}}); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w = post(url.Values{DeviceClaim: {"unknown"}}); w.Code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	_ = registry.RevokeDevice("user111", device)
+	if w = post(url.Values{DeviceClaim: {device}}); w.Code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMemoryDeviceRegistry(t *testing.T) {
+	registry := NewMemoryDeviceRegistry()
+	now := time.Now()
+	_ = registry.TrustDevice("user111", "laptop", time.Time{})
+	_ = registry.TrustDevice("user111", "phone", now.Add(-time.Second))
+	_ = registry.TrustDevice("user222", "tablet", now.Add(time.Hour))
+
+	if ok, _ := registry.IsTrusted("user111", "laptop"); !ok {
+		t.Fatalf("Error laptop not trusted")
+	}
+	if ok, _ := registry.IsTrusted("user111", "phone"); ok {
+		t.Fatalf("Error expired phone trusted")
+	}
+	if ok, _ := registry.IsTrusted("user222", "laptop"); ok {
+		t.Fatalf("Error laptop trusted for another user")
+	}
+	if n := registry.PurgeExpired(now); n != 1 {
+		t.Fatalf("Error purged = %d", n)
+	}
+	_ = registry.RevokeDevices("user111")
+	if ok, _ := registry.IsTrusted("user111", "laptop"); ok {
+		t.Fatalf("Error revoked laptop trusted")
+	}
+	if !isMultiFactor([]string{"mfa"}) || !isMultiFactor([]string{"pwd", "otp"}) || isMultiFactor([]string{"pwd", "pwd"}) {
+		t.Fatalf("Error isMultiFactor")
+	}
+}
@@ -0,0 +1,63 @@
+package oauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDocumentMaxAge is the Cache-Control max-age of the documents served by ServeDocument when maxAge is zero.
+const DefaultDocumentMaxAge = time.Hour
+
+// ServeDocument serves a rarely changing JSON document, such as a JWKS or a discovery document, with a strong ETag
+// computed from its content and the Cache-Control max-age, a negative maxAge sending no-cache. The conditional
+// requests whose If-None-Match matches the ETag get a 304 Not Modified without body.
+func ServeDocument(w http.ResponseWriter, r *http.Request, doc interface{}, maxAge time.Duration) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(true)
+	if err := enc.Encode(doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	switch {
+	case maxAge < 0:
+		w.Header().Set("Cache-Control", "no-cache")
+	case maxAge == 0:
+		maxAge = DefaultDocumentMaxAge
+		fallthrough
+	default:
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// etagMatches returns true when the If-None-Match header lists the ETag or is "*", using the weak comparison
+// of RFC 9110 §13.1.2
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
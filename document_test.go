@@ -0,0 +1,45 @@
+package oauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeDocument(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	f := NewEd25519TokenSecurityProvider(priv)
+	f.JWKSMaxAge = 10 * time.Minute
+
+	w := httptest.NewRecorder()
+	f.ServeJWKS(w, httptest.NewRequest("GET", "/jwks", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != "public, max-age=600" || w.Body.Len() == 0 {
+		t.Fatalf("Error StatusCode = %d, headers = %v", w.Code, w.Header())
+	}
+
+	req := httptest.NewRequest("GET", "/jwks", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	w = httptest.NewRecorder()
+	f.ServeJWKS(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	f.AddVerificationKey(pub)
+	w = httptest.NewRecorder()
+	f.ServeJWKS(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("Error the rotated JWKS should be served: StatusCode = %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ServeDocument(w, httptest.NewRequest("HEAD", "/.well-known/openid-configuration", nil), map[string]string{"issuer": "https://issuer"}, -1)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Cache-Control") != "no-cache" || w.Header().Get("Content-Length") == "" {
+		t.Fatalf("Error StatusCode = %d, headers = %v", w.Code, w.Header())
+	}
+}
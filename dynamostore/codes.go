@@ -0,0 +1,86 @@
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+var _ oauth.AuthCodeStore = (*Store)(nil)
+
+const codeKind = "code"
+
+// SaveCode records the authorization code
+func (s *Store) SaveCode(c *oauth.AuthorizationCode) error {
+	expiresAt := c.CreationDate.Add(c.ExpiresIn)
+	item := key(codeKind, c.ID)
+	item["id"] = str(c.ID)
+	item["client_id"] = str(c.ClientID)
+	item["redirect_uri"] = str(c.RedirectURI)
+	item["credential"] = str(c.Credential)
+	item["scope"] = str(c.Scope)
+	item["code_challenge"] = str(c.CodeChallenge)
+	item["code_challenge_method"] = str(string(c.CodeChallengeMethod))
+	item["acr"] = str(c.ACR)
+	item["amr"] = str(strings.Join(c.AMR, " "))
+	item["nonce"] = str(c.Nonce)
+	if !c.AuthTime.IsZero() {
+		item["auth_time"] = timestamp(c.AuthTime)
+	}
+	if c.Claims != nil {
+		b, err := json.Marshal(c.Claims)
+		if err != nil {
+			return err
+		}
+		item["claims_request"] = str(string(b))
+	}
+	item["created_at"] = timestamp(c.CreationDate)
+	item["expires_at"] = timestamp(expiresAt)
+	item[TTLAttribute] = ttl(expiresAt)
+	_, err := s.client.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item})
+	return err
+}
+
+// ConsumeCode returns and removes the authorization code, oauth.ErrCodeNotFound if unknown or already consumed.
+// The code is deleted atomically, concurrent exchanges of the same code get oauth.ErrCodeNotFound.
+func (s *Store) ConsumeCode(id string) (*oauth.AuthorizationCode, error) {
+	out, err := s.client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+		TableName:    aws.String(s.table),
+		Key:          key(codeKind, id),
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return nil, err
+	}
+	item := out.Attributes
+	if len(item) == 0 {
+		return nil, oauth.ErrCodeNotFound
+	}
+	c := &oauth.AuthorizationCode{
+		ID:                  getString(item, "id"),
+		ClientID:            getString(item, "client_id"),
+		RedirectURI:         getString(item, "redirect_uri"),
+		Credential:          getString(item, "credential"),
+		Scope:               getString(item, "scope"),
+		CodeChallenge:       getString(item, "code_challenge"),
+		CodeChallengeMethod: oauth.CodeChallengeMethod(getString(item, "code_challenge_method")),
+		ACR:                 getString(item, "acr"),
+		AMR:                 strings.Fields(getString(item, "amr")),
+		Nonce:               getString(item, "nonce"),
+		AuthTime:            getTime(item, "auth_time"),
+		CreationDate:        getTime(item, "created_at"),
+	}
+	c.ExpiresIn = getTime(item, "expires_at").Sub(c.CreationDate)
+	if claims := getString(item, "claims_request"); claims != "" {
+		c.Claims = new(oauth.ClaimsRequest)
+		if err = json.Unmarshal([]byte(claims), c.Claims); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,88 @@
+// Package dynamostore implements the oauth TokenStore and AuthCodeStore over a single DynamoDB table,
+// for serverless deployments without Redis or SQL databases.
+//
+// The table has the string partition key "pk" and the expiry of the items is stored in the numeric "ttl" attribute:
+// enable the DynamoDB Time to Live on it so the expired tokens and codes are removed by DynamoDB.
+package dynamostore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// KeyAttribute is the partition key of the table
+	KeyAttribute = "pk"
+	// TTLAttribute is the Time to Live attribute of the table, in Unix seconds
+	TTLAttribute = "ttl"
+)
+
+// API is the subset of the DynamoDB client used by the Store, implemented by *dynamodb.Client.
+type API interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// Store is the DynamoDB store.
+type Store struct {
+	client API
+	table  string
+}
+
+// New creates a Store over the table.
+func New(client API, table string) *Store {
+	return &Store{client: client, table: table}
+}
+
+// key returns the primary key of the item
+func key(kind, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{KeyAttribute: str(kind + "#" + id)}
+}
+
+func str(v string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: v}
+}
+
+func boolean(v bool) types.AttributeValue {
+	return &types.AttributeValueMemberBOOL{Value: v}
+}
+
+func timestamp(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: t.UTC().Format(time.RFC3339Nano)}
+}
+
+func ttl(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+func getString(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func getBool(item map[string]types.AttributeValue, name string) bool {
+	if v, ok := item[name].(*types.AttributeValueMemberBOOL); ok {
+		return v.Value
+	}
+	return false
+}
+
+func getTime(item map[string]types.AttributeValue, name string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, getString(item, name))
+	return t
+}
+
+func getStrings(item map[string]types.AttributeValue, name string) []string {
+	if v, ok := item[name].(*types.AttributeValueMemberSS); ok {
+		return v.Value
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package dynamostore
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+// fakeDynamoDB implements the API for the update expressions used by the Store
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func pk(k map[string]types.AttributeValue) string {
+	return k[KeyAttribute].(*types.AttributeValueMemberS).Value
+}
+
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	c := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		c[k] = v
+	}
+	return c
+}
+
+func (f *fakeDynamoDB) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[pk(in.Item)] = copyItem(in.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item, ok := f.items[pk(in.Key)]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: copyItem(item)}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item := f.items[pk(in.Key)]
+	delete(f.items, pk(in.Key))
+	return &dynamodb.DeleteItemOutput{Attributes: item}, nil
+}
+
+func (f *fakeDynamoDB) UpdateItem(_ context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item, ok := f.items[pk(in.Key)]
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	if in.ConditionExpression != nil && strings.Contains(*in.ConditionExpression, "revoked = :unrevoked") && getBool(item, "revoked") {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	old := copyItem(item)
+	switch *in.UpdateExpression {
+	case "ADD children :child":
+		children := append(getStrings(item, "children"), in.ExpressionAttributeValues[":child"].(*types.AttributeValueMemberSS).Value...)
+		item["children"] = &types.AttributeValueMemberSS{Value: children}
+	case "SET revoked = :revoked":
+		item["revoked"] = in.ExpressionAttributeValues[":revoked"]
+	}
+	return &dynamodb.UpdateItemOutput{Attributes: old}, nil
+}
+
+func TestTokenStore(t *testing.T) {
+	s := New(newFakeDynamoDB(), "oauth")
+	now := time.Now().UTC().Truncate(time.Second)
+	_ = s.SaveToken(&oauth.TokenRecord{ID: "r1", TokenID: "t1", FamilyID: "r1", TokenType: oauth.UserToken, Credential: "user111", CreationDate: now, ExpiresAt: now.Add(time.Hour)})
+	_ = s.SaveToken(&oauth.TokenRecord{ID: "r2", TokenID: "t2", ParentID: "r1", FamilyID: "r1", TokenType: oauth.UserToken, Credential: "user111", CreationDate: now, ExpiresAt: now.Add(time.Hour), TokenExpiresAt: now.Add(time.Minute)})
+	_ = s.SaveToken(&oauth.TokenRecord{ID: "r3", TokenID: "t3", ParentID: "r2", FamilyID: "r1", TokenType: oauth.UserToken, Credential: "user111", CreationDate: now, ExpiresAt: now.Add(time.Hour)})
+
+	rec, err := s.GetToken("r2")
+	if err != nil || rec.ParentID != "r1" || rec.Credential != "user111" || !rec.ExpiresAt.Equal(now.Add(time.Hour)) || !rec.TokenExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Error record = %+v, %v", rec, err)
+	}
+	revoked, err := s.RevokeFamily("r2")
+	if err != nil || len(revoked) != 2 {
+		t.Fatalf("Error revoked = %v, %v", revoked, err)
+	}
+	if rec, _ = s.GetToken("r3"); !rec.Revoked {
+		t.Fatalf("Error descendant not revoked")
+	}
+	if rec, _ = s.GetToken("r1"); rec.Revoked {
+		t.Fatalf("Error ancestor revoked")
+	}
+	if _, err = s.GetToken("unknown"); err != oauth.ErrTokenNotFound {
+		t.Fatalf("Error %v", err)
+	}
+	if err = s.RotateToken("r1"); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if rec, _ = s.GetToken("r1"); !rec.Revoked {
+		t.Fatalf("Error rotated token not revoked")
+	}
+	if err = s.RotateToken("r1"); err != oauth.ErrTokenRotated {
+		t.Fatalf("Error the second rotation should fail: %v", err)
+	}
+	if err = s.RotateToken("unknown"); err != oauth.ErrTokenNotFound {
+		t.Fatalf("Error %v", err)
+	}
+
+	fake := s.client.(*fakeDynamoDB)
+	_ = s.SaveToken(&oauth.TokenRecord{ID: "r4", TokenID: "t4", FamilyID: "r4", TokenType: oauth.UserToken, Credential: "user111", CreationDate: now})
+	if _, ok := fake.items[tokenKind+"#r4"][TTLAttribute]; ok {
+		t.Fatalf("Error the non-expiring token should not be removed by the Time to Live")
+	}
+}
+
+func TestAuthCodeStore(t *testing.T) {
+	s := New(newFakeDynamoDB(), "oauth")
+	now := time.Now().UTC()
+	_ = s.SaveCode(&oauth.AuthorizationCode{ID: "c1", ClientID: "abcdef", Credential: "user111", CodeChallengeMethod: oauth.S256CodeChallenge, ACR: "urn:mace:incommon:iap:silver", AMR: []string{"pwd", "otp"}, Claims: &oauth.ClaimsRequest{IDToken: map[string]*oauth.ClaimRequest{"email": {Essential: true}}}, Nonce: "n-0S6", AuthTime: now.Add(-time.Minute), CreationDate: now, ExpiresIn: time.Minute})
+
+	c, err := s.ConsumeCode("c1")
+	if err != nil || c.ClientID != "abcdef" || c.CodeChallengeMethod != oauth.S256CodeChallenge || c.ExpiresIn != time.Minute || c.ACR != "urn:mace:incommon:iap:silver" || len(c.AMR) != 2 || !c.Claims.IDToken["email"].Essential || c.Nonce != "n-0S6" || !c.AuthTime.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("Error code = %+v, %v", c, err)
+	}
+	if _, err = s.ConsumeCode("c1"); err != oauth.ErrCodeNotFound {
+		t.Fatalf("Error consumed code returned: %v", err)
+	}
+}
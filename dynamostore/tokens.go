@@ -0,0 +1,141 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+var _ oauth.TokenStore = (*Store)(nil)
+
+const tokenKind = "token"
+
+// SaveToken records the tokens, the rotated refresh token is linked to its parent
+func (s *Store) SaveToken(rec *oauth.TokenRecord) error {
+	ctx := context.Background()
+	item := key(tokenKind, rec.ID)
+	item["id"] = str(rec.ID)
+	item["token_id"] = str(rec.TokenID)
+	item["parent_id"] = str(rec.ParentID)
+	item["family_id"] = str(rec.FamilyID)
+	item["token_type"] = str(string(rec.TokenType))
+	item["credential"] = str(rec.Credential)
+	item["scope"] = str(rec.Scope)
+	item["created_at"] = timestamp(rec.CreationDate)
+	item["expires_at"] = timestamp(rec.ExpiresAt)
+	item["token_expires_at"] = timestamp(rec.TokenExpiresAt)
+	item["revoked"] = boolean(rec.Revoked)
+	if !rec.ExpiresAt.IsZero() {
+		item[TTLAttribute] = ttl(rec.ExpiresAt)
+	}
+	if _, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item}); err != nil {
+		return err
+	}
+	if rec.ParentID == "" {
+		return nil
+	}
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       key(tokenKind, rec.ParentID),
+		UpdateExpression:          aws.String("ADD children :child"),
+		ConditionExpression:       aws.String("attribute_exists(" + KeyAttribute + ")"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":child": &types.AttributeValueMemberSS{Value: []string{rec.ID}}},
+	})
+	var notFound *types.ConditionalCheckFailedException
+	if errors.As(err, &notFound) {
+		// the parent expired, there is no lineage to keep
+		return nil
+	}
+	return err
+}
+
+// GetToken returns the record of the refresh token or oauth.ErrTokenNotFound, the records saved before the access
+// token expiry was recorded have no TokenExpiresAt
+func (s *Store) GetToken(refreshTokenID string) (*oauth.TokenRecord, error) {
+	item, err := s.getToken(context.Background(), refreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth.TokenRecord{
+		ID:             getString(item, "id"),
+		TokenID:        getString(item, "token_id"),
+		ParentID:       getString(item, "parent_id"),
+		FamilyID:       getString(item, "family_id"),
+		TokenType:      oauth.TokenType(getString(item, "token_type")),
+		Credential:     getString(item, "credential"),
+		Scope:          getString(item, "scope"),
+		CreationDate:   getTime(item, "created_at"),
+		ExpiresAt:      getTime(item, "expires_at"),
+		TokenExpiresAt: getTime(item, "token_expires_at"),
+		Revoked:        getBool(item, "revoked"),
+	}, nil
+}
+
+func (s *Store) getToken(ctx context.Context, id string) (map[string]types.AttributeValue, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(s.table), Key: key(tokenKind, id), ConsistentRead: aws.Bool(true)})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, oauth.ErrTokenNotFound
+	}
+	return out.Item, nil
+}
+
+// RotateToken revokes the rotated refresh token unless it is already revoked, with a conditional update
+func (s *Store) RotateToken(refreshTokenID string) error {
+	ctx := context.Background()
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       key(tokenKind, refreshTokenID),
+		UpdateExpression:          aws.String("SET revoked = :revoked"),
+		ConditionExpression:       aws.String("attribute_exists(" + KeyAttribute + ") AND revoked = :unrevoked"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":revoked": boolean(true), ":unrevoked": boolean(false)},
+	})
+	var failed *types.ConditionalCheckFailedException
+	if !errors.As(err, &failed) {
+		return err
+	}
+	if _, err = s.getToken(ctx, refreshTokenID); err != nil {
+		return err
+	}
+	return oauth.ErrTokenRotated
+}
+
+// RevokeFamily revokes the refresh token and all the refresh tokens rotated from it
+func (s *Store) RevokeFamily(refreshTokenID string) ([]string, error) {
+	ctx := context.Background()
+	if _, err := s.getToken(ctx, refreshTokenID); err != nil {
+		return nil, err
+	}
+	var revoked []string
+	pending := []string{refreshTokenID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		out, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(s.table),
+			Key:                       key(tokenKind, id),
+			UpdateExpression:          aws.String("SET revoked = :revoked"),
+			ConditionExpression:       aws.String("attribute_exists(" + KeyAttribute + ")"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":revoked": boolean(true)},
+			ReturnValues:              types.ReturnValueAllOld,
+		})
+		var notFound *types.ConditionalCheckFailedException
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return revoked, err
+		}
+		if !getBool(out.Attributes, "revoked") {
+			revoked = append(revoked, id)
+		}
+		pending = append(pending, getStrings(out.Attributes, "children")...)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,236 @@
+package oauth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EdDSA is the JWS algorithm of the Ed25519 signatures, see https://datatracker.ietf.org/doc/html/rfc8037
+const EdDSA = "EdDSA"
+
+// AccessTokenJWTType is the JWS "typ" of the JWT access tokens, see https://datatracker.ietf.org/doc/html/rfc9068
+const AccessTokenJWTType = "at+jwt"
+
+// InternalJWTType is the JWS "typ" of the refresh tokens and the authorization codes signed by the
+// Ed25519TokenSecureFormatter, so the validators of JWT access tokens reject them
+const InternalJWTType = "oauth-internal+jwt"
+
+// tokenTypeClaim is the private claim carrying the TokenType of the JWT access tokens
+const tokenTypeClaim = "token_kind"
+
+// ErrVerifyOnly is returned by the formatters created without private key when asked to sign.
+var ErrVerifyOnly = errors.New("formatter has no signing key")
+
+// Ed25519TokenSecureFormatter signs the tokens as compact JWS with Ed25519, the tokens are not encrypted:
+// the claims are readable by the token holders. The access tokens are RFC 9068 JWTs ("typ": "at+jwt"), the refresh
+// tokens and the authorization codes are signed with the InternalJWTType and are never accepted as access tokens.
+// The verification accepts all the added public keys so the signing key can be rotated.
+// The formatter is safe for concurrent use, the verification keys are swapped copy-on-write.
+type Ed25519TokenSecureFormatter struct {
+	kid  string
+	key  ed25519.PrivateKey
+	mu   sync.Mutex   // serializes AddVerificationKey
+	keys atomic.Value // map[string]ed25519.PublicKey, read without lock
+	// JWKSMaxAge is the Cache-Control max-age of ServeJWKS, DefaultDocumentMaxAge when zero
+	JWKSMaxAge time.Duration
+	// Issuer is the "iss" claim of the access tokens
+	Issuer string
+}
+
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// NewEd25519TokenSecurityProvider creates a formatter signing with the private key, identified by its JWK thumbprint
+func NewEd25519TokenSecurityProvider(key ed25519.PrivateKey) *Ed25519TokenSecureFormatter {
+	f := &Ed25519TokenSecureFormatter{key: key}
+	f.kid = f.AddVerificationKey(key.Public().(ed25519.PublicKey))
+	return f
+}
+
+// NewEd25519TokenVerifier creates a formatter verifying the tokens signed by the public keys, for the resource servers
+func NewEd25519TokenVerifier(keys ...ed25519.PublicKey) *Ed25519TokenSecureFormatter {
+	f := new(Ed25519TokenSecureFormatter)
+	for _, key := range keys {
+		f.AddVerificationKey(key)
+	}
+	return f
+}
+
+// AddVerificationKey accepts the tokens signed by the public key and returns its key id.
+// Keys can be added while serving requests.
+func (f *Ed25519TokenSecureFormatter) AddVerificationKey(key ed25519.PublicKey) string {
+	kid := okpJWK(key).Thumbprint()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current := f.verificationKeys()
+	keys := make(map[string]ed25519.PublicKey, len(current)+1)
+	for k, v := range current {
+		keys[k] = v
+	}
+	keys[kid] = key
+	f.keys.Store(keys)
+	return kid
+}
+
+// verificationKeys returns the current verification keys, not to be modified
+func (f *Ed25519TokenSecureFormatter) verificationKeys() map[string]ed25519.PublicKey {
+	keys, _ := f.keys.Load().(map[string]ed25519.PublicKey)
+	return keys
+}
+
+// CryptToken signs the payload of the refresh tokens and the authorization codes with the InternalJWTType
+func (f *Ed25519TokenSecureFormatter) CryptToken(source []byte) ([]byte, error) {
+	return f.sign(source, InternalJWTType)
+}
+
+// CompactTokens reports that the compact JWS are emitted as is
+func (f *Ed25519TokenSecureFormatter) CompactTokens() bool {
+	return true
+}
+
+// DecryptToken verifies the signature of the InternalJWTType token and returns the payload
+func (f *Ed25519TokenSecureFormatter) DecryptToken(source []byte) ([]byte, error) {
+	return f.verify(source, InternalJWTType)
+}
+
+// CryptAccessToken signs the RFC 9068 claim set of the access token: the claims of the token with the iss, sub
+// (the credential), iat, exp (omitted when the token does not expire), jti and scope claims
+func (f *Ed25519TokenSecureFormatter) CryptAccessToken(t *Token) ([]byte, error) {
+	claims := make(map[string]interface{}, len(t.Claims)+7)
+	for k, v := range t.Claims {
+		claims[k] = v
+	}
+	if f.Issuer != "" {
+		claims["iss"] = f.Issuer
+	}
+	claims["sub"] = t.Credential
+	claims["iat"] = t.CreationDate.Unix()
+	if t.ExpiresIn > 0 {
+		claims["exp"] = t.CreationDate.Add(t.ExpiresIn).Unix()
+	}
+	claims["jti"] = t.ID
+	claims["scope"] = t.Scope
+	claims[tokenTypeClaim] = t.TokenType
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	return f.sign(payload, AccessTokenJWTType)
+}
+
+// DecryptAccessToken verifies the signature of the "at+jwt" token and returns the access token of its claims
+func (f *Ed25519TokenSecureFormatter) DecryptAccessToken(source []byte) (*Token, error) {
+	payload, err := f.verify(source, AccessTokenJWTType)
+	if err != nil {
+		return nil, err
+	}
+	var registered struct {
+		Sub   string    `json:"sub"`
+		Iat   int64     `json:"iat"`
+		Exp   int64     `json:"exp"`
+		Jti   string    `json:"jti"`
+		Scope string    `json:"scope"`
+		Type  TokenType `json:"token_kind"`
+	}
+	var claims Claims
+	if err = json.Unmarshal(payload, &registered); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	delete(claims, tokenTypeClaim)
+	token := &Token{ID: registered.Jti, Credential: registered.Sub, CreationDate: time.Unix(registered.Iat, 0).UTC(),
+		TokenType: registered.Type, Scope: registered.Scope, Claims: claims}
+	if registered.Exp > 0 {
+		token.ExpiresIn = time.Unix(registered.Exp, 0).Sub(time.Unix(registered.Iat, 0))
+	}
+	return token, nil
+}
+
+// sign signs the payload as a compact JWS of the type
+func (f *Ed25519TokenSecureFormatter) sign(payload []byte, typ string) ([]byte, error) {
+	if f.key == nil {
+		return nil, ErrVerifyOnly
+	}
+	header, err := json.Marshal(jwsHeader{Alg: EdDSA, Kid: f.kid, Typ: typ})
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	signature := ed25519.Sign(f.key, []byte(signingInput))
+	return []byte(signingInput + "." + enc.EncodeToString(signature)), nil
+}
+
+// verify verifies the signature of the compact JWS, which must be of the type, and returns the payload
+func (f *Ed25519TokenSecureFormatter) verify(source []byte, typ string) ([]byte, error) {
+	parts := bytes.Split(source, []byte("."))
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	enc := base64.RawURLEncoding
+	rawHeader, err := enc.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	var header jwsHeader
+	if err = json.Unmarshal(rawHeader, &header); err != nil || header.Alg != EdDSA {
+		return nil, fmt.Errorf("%w: unsupported JWS header", ErrMalformedToken)
+	}
+	if header.Typ != typ {
+		return nil, fmt.Errorf("%w: unexpected JWS type %q", ErrMalformedToken, header.Typ)
+	}
+	key, ok := f.verificationKeys()[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrMalformedToken, header.Kid)
+	}
+	signature, err := enc.DecodeString(string(parts[2]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	signingInput := source[:len(parts[0])+1+len(parts[1])]
+	if !ed25519.Verify(key, signingInput, signature) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrMalformedToken)
+	}
+	payload, err := enc.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return payload, nil
+}
+
+// JWKS returns the verification keys as OKP JSON Web Keys
+func (f *Ed25519TokenSecureFormatter) JWKS() JWKS {
+	keys := f.verificationKeys()
+	jwks := JWKS{Keys: make([]JWK, 0, len(keys))}
+	for kid, key := range keys {
+		jwk := okpJWK(key)
+		jwk.Kid = kid
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
+
+// ServeJWKS renders the JWKS with ServeDocument, the clients revalidate it with its ETag
+func (f *Ed25519TokenSecureFormatter) ServeJWKS(w http.ResponseWriter, r *http.Request) {
+	ServeDocument(w, r, f.JWKS(), f.JWKSMaxAge)
+}
+
+// okpJWK returns the JWK of the Ed25519 public key
+func okpJWK(key ed25519.PublicKey) JWK {
+	return JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(key), Use: "sig", Alg: EdDSA}
+}
@@ -0,0 +1,138 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 8037 Appendix A.3
+	jwk := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	if tp := jwk.Thumbprint(); tp != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Fatalf("Error thumbprint = %s", tp)
+	}
+}
+
+func TestEd25519TokenSecureFormatter(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer := NewEd25519TokenSecurityProvider(priv)
+	signer.Issuer = "https://auth.example.com"
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), signer)
+	if err := sut.Validate(PasswordGrant); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "read", nil)
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+
+	if parts := strings.Split(resp.Token, "."); len(parts) != 3 || !strings.HasPrefix(parts[0], "eyJ") {
+		t.Fatalf("Error token is not a compact JWS: %s", resp.Token)
+	}
+
+	ba := NewBearerAuthentication("", NewEd25519TokenVerifier(pub))
+	token, err := ba.ValidateToken(resp.Token)
+	if err != nil || token.Credential != "user111" || token.TokenType != UserToken || token.ExpiresIn != time.Second*10 {
+		t.Fatalf("Error token = %+v, %v", token, err)
+	}
+	if header, claims := jwsParts(t, resp.Token); header["typ"] != AccessTokenJWTType || claims["iss"] != "https://auth.example.com" ||
+		claims["sub"] != "user111" || claims["jti"] != token.ID || claims["exp"] == nil || claims["iat"] == nil || claims["expires_in"] != nil {
+		t.Fatalf("Error access token header = %v, claims = %v", header, claims)
+	}
+	if header, _ := jwsParts(t, resp.RefreshToken); header["typ"] != InternalJWTType {
+		t.Fatalf("Error refresh token header = %v", header)
+	}
+	if _, err = ba.ValidateToken(resp.RefreshToken); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error refresh token accepted as access token: %v", err)
+	}
+	if _, err = sut.provider.DecryptRefreshTokens(resp.Token); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error access token accepted as refresh token: %v", err)
+	}
+	if _, err = NewEd25519TokenVerifier(pub).CryptToken([]byte("{}")); err != ErrVerifyOnly {
+		t.Fatalf("Error %v", err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err = NewBearerAuthentication("", NewEd25519TokenVerifier(otherPub)).ValidateToken(resp.Token); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error %v", err)
+	}
+	crypted, _ := signer.CryptToken([]byte(`{"credential":"user111"}`))
+	crypted[len(crypted)-2] ^= 1
+	if _, err = signer.DecryptToken(crypted); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	signer.ServeJWKS(w, httptest.NewRequest("GET", "/jwks", nil))
+	var jwks JWKS
+	if err = json.Unmarshal(w.Body.Bytes(), &jwks); err != nil || len(jwks.Keys) != 1 {
+		t.Fatalf("Error jwks = %s, %v", w.Body.String(), err)
+	}
+	if k := jwks.Keys[0]; k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != EdDSA || k.Kid != k.Thumbprint() {
+		t.Fatalf("Error jwk = %+v", k)
+	}
+}
+
+// jwsParts returns the decoded header and payload of the compact JWS
+func jwsParts(t *testing.T, jws string) (map[string]interface{}, map[string]interface{}) {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Error not a compact JWS: %s", jws)
+	}
+	var header, payload map[string]interface{}
+	for i, v := range []*map[string]interface{}{&header, &payload} {
+		b, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil || json.Unmarshal(b, v) != nil {
+			t.Fatalf("Error invalid JWS part %d: %s", i, jws)
+		}
+	}
+	return header, payload
+}
+
+func TestEd25519AddVerificationKeyConcurrent(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer := NewEd25519TokenSecurityProvider(priv)
+	crypted, _ := signer.CryptToken([]byte(`{"credential":"user111"}`))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			pub, _, _ := ed25519.GenerateKey(rand.Reader)
+			signer.AddVerificationKey(pub)
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if _, err := signer.DecryptToken(crypted); err != nil {
+			t.Fatalf("Error %v", err)
+		}
+	}
+	<-done
+	if n := len(signer.JWKS().Keys); n != 21 {
+		t.Fatalf("Error keys = %d", n)
+	}
+}
+
+func TestEd25519StablePayload(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	provider := NewTokenProvider(NewEd25519TokenSecurityProvider(priv))
+	token := &Token{ID: "id", Credential: "user111", CreationDate: time.Unix(1700000000, 0).UTC(), Claims: Claims{
+		"roles": []string{"admin"}, "z": 1, "a": map[string]interface{}{"y": true, "b": "c"}, "m": "n",
+	}}
+	first, err := provider.CryptToken(token)
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if crypted, _ := provider.CryptToken(token); crypted != first {
+			t.Fatalf("Error unstable token %s != %s", crypted, first)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Media types of the response encoders
+const (
+	JSONMediaType = "application/json"
+	FormMediaType = "application/x-www-form-urlencoded"
+	XMLMediaType  = "application/xml"
+)
+
+// ResponseEncoder encodes the fields of the responses in another media type than JSON, for the legacy clients
+// negotiating it with the Accept header. The fields are the ones of the JSON response, numbers being json.Number.
+type ResponseEncoder interface {
+	Encode(fields map[string]interface{}) ([]byte, error)
+}
+
+// ResponseEncoderFunc is an adapter to use ordinary functions as ResponseEncoder.
+type ResponseEncoderFunc func(fields map[string]interface{}) ([]byte, error)
+
+// Encode calls f(fields).
+func (f ResponseEncoderFunc) Encode(fields map[string]interface{}) ([]byte, error) {
+	return f(fields)
+}
+
+// FormEncoder encodes the responses as application/x-www-form-urlencoded, e.g. "access_token=...&expires_in=3600"
+// as the pre-RFC 6749 servers did. The nested values are encoded as JSON.
+type FormEncoder struct{}
+
+// Encode encodes the fields as form values sorted by name, the null fields are omitted
+func (FormEncoder) Encode(fields map[string]interface{}) ([]byte, error) {
+	values := make(url.Values, len(fields))
+	for name, value := range fields {
+		if value == nil {
+			continue
+		}
+		text, err := fieldText(value)
+		if err != nil {
+			return nil, err
+		}
+		values.Set(name, text)
+	}
+	return []byte(values.Encode()), nil
+}
+
+// XMLEncoder encodes the responses as an XML document with an element per field,
+// e.g. "<response><access_token>...</access_token></response>". The fields whose name is not a valid element name
+// (e.g. the custom claims "https://example.com/roles" or "1st") are encoded as "<field name="...">...</field>".
+type XMLEncoder struct {
+	// Root is the name of the document element, "response" when empty. It must be a valid element name.
+	Root string
+}
+
+// Encode encodes the fields as the elements of the Root element sorted by name, the null fields are omitted
+func (e XMLEncoder) Encode(fields map[string]interface{}) ([]byte, error) {
+	root := e.Root
+	if root == "" {
+		root = "response"
+	}
+	if !isXMLName(root) {
+		return nil, fmt.Errorf("invalid XML root element name %q", root)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := writeXMLElement(&buf, root, fields); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeXMLElement writes the value as the element, the objects as nested elements and the arrays as repeated elements
+func writeXMLElement(buf *bytes.Buffer, name string, value interface{}) error {
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			if err := writeXMLElement(buf, name, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	open, end := "<"+name+">", "</"+name+">"
+	if !isXMLName(name) {
+		var attr bytes.Buffer
+		if err := xml.EscapeText(&attr, []byte(name)); err != nil {
+			return err
+		}
+		open, end = `<field name="`+attr.String()+`">`, "</field>"
+	}
+	buf.WriteString(open)
+	if object, ok := value.(map[string]interface{}); ok {
+		names := make([]string, 0, len(object))
+		for n, v := range object {
+			if v != nil {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			if err := writeXMLElement(buf, n, object[n]); err != nil {
+				return err
+			}
+		}
+	} else {
+		text, err := fieldText(value)
+		if err != nil {
+			return err
+		}
+		if err = xml.EscapeText(buf, []byte(text)); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(end)
+	return nil
+}
+
+// isXMLName reports whether the name is an XML NCName (a name without colon) not reserved by the "xml" prefix
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || unicode.IsLetter(c):
+		case i > 0 && (c == '-' || c == '.' || unicode.IsDigit(c) || unicode.Is(unicode.Mn, c)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// fieldText returns the text of the scalar field values, the JSON of the others
+func fieldText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// RegisterEncoder registers the encoder of the responses negotiated with the media type in the Accept header,
+// e.g. RegisterEncoder(FormMediaType, FormEncoder{}). JSON stays the default and the preferred type at equal quality.
+// Encoders must be registered before serving requests.
+func (bs *BearerServer) RegisterEncoder(mediaType string, encoder ResponseEncoder) {
+	if bs.encoders == nil {
+		bs.encoders = make(map[string]ResponseEncoder)
+	}
+	bs.encoders[strings.ToLower(mediaType)] = encoder
+}
+
+// negotiateEncoder returns the registered encoder of the media type preferred by the Accept header of the request,
+// nil when it prefers JSON or no registered media type
+func (bs *BearerServer) negotiateEncoder(r *http.Request) (string, ResponseEncoder) {
+	if len(bs.encoders) == 0 || r == nil {
+		return "", nil
+	}
+	for _, mediaType := range acceptedValues(r.Header.Get("Accept")) {
+		switch mediaType {
+		case JSONMediaType, "application/*", "*/*":
+			return "", nil
+		}
+		if encoder, ok := bs.encoders[mediaType]; ok {
+			return mediaType, encoder
+		}
+	}
+	return "", nil
+}
+
+// renderEncoded renders the JSON object fields of v with the encoder, falling back to JSON for the other values
+func renderEncoded(w http.ResponseWriter, mediaType string, encoder ResponseEncoder, v interface{}, noStore bool, statusCode int) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	var fields map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&fields); err != nil {
+		renderJSON(w, v, noStore, statusCode)
+		return
+	}
+	body, err := encoder.Encode(fields)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	if noStore {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,94 @@
+package oauth
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseEncoders(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RegisterEncoder(FormMediaType, FormEncoder{})
+	sut.RegisterEncoder(XMLMediaType, XMLEncoder{Root: "OAuth"})
+
+	token := func(password, accept string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+
+	w := token("password111", "application/x-www-form-urlencoded")
+	values, err := url.ParseQuery(w.Body.String())
+	if w.Code != http.StatusOK || err != nil || values.Get("access_token") == "" || values.Get("expires_in") != "10" || !strings.HasPrefix(values.Get("properties"), "{") {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != FormMediaType+"; charset=utf-8" || w.Header().Get("Vary") != "Accept" {
+		t.Fatalf("Error headers = %v", w.Header())
+	}
+
+	w = token("forged", "text/html, application/xml;q=0.9, application/json;q=0.5")
+	var e struct {
+		XMLName xml.Name `xml:"OAuth"`
+		Error   string   `xml:"error"`
+	}
+	if w.Code != http.StatusUnauthorized || xml.Unmarshal(w.Body.Bytes(), &e) != nil || e.Error != string(TokenInvalidGrant) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != XMLMediaType+"; charset=utf-8" {
+		t.Fatalf("Error Content-Type = %s", w.Header().Get("Content-Type"))
+	}
+
+	for _, accept := range []string{"", "*/*", "application/json, application/xml", "application/xml;q=0.1, application/json"} {
+		var resp TokenResponse
+		if w = token("password111", accept); json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Token == "" {
+			t.Fatalf("Error Accept %q: body = %s", accept, w.Body.String())
+		}
+	}
+}
+
+func TestXMLEncoderNested(t *testing.T) {
+	b, err := XMLEncoder{}.Encode(map[string]interface{}{
+		"access_token": "a<b",
+		"properties":   map[string]interface{}{"tenant": "acme"},
+		"aud":          []interface{}{"x", "y"},
+		"refresh":      nil,
+		"active":       true,
+		"expires_in":   json.Number("10"),
+	})
+	expected := xml.Header + "<response><access_token>a&lt;b</access_token><active>true</active><aud>x</aud><aud>y</aud>" +
+		"<expires_in>10</expires_in><properties><tenant>acme</tenant></properties></response>"
+	if err != nil || string(b) != expected {
+		t.Fatalf("Error Encode = %s, %v", b, err)
+	}
+
+	b, err = XMLEncoder{}.Encode(map[string]interface{}{
+		"https://example.com/roles": []interface{}{"admin"},
+		"1st":                       "a",
+		`x"><evil/><y a="`:          "b",
+		"xmlns":                     "c",
+	})
+	expected = xml.Header + `<response><field name="1st">a</field><field name="https://example.com/roles">admin</field>` +
+		`<field name="x&#34;&gt;&lt;evil/&gt;&lt;y a=&#34;">b</field><field name="xmlns">c</field></response>`
+	if err != nil || string(b) != expected {
+		t.Fatalf("Error Encode = %s, %v", b, err)
+	}
+	var doc struct {
+		XMLName xml.Name
+		Fields  []string `xml:"field"`
+	}
+	if err = xml.Unmarshal(b, &doc); err != nil || len(doc.Fields) != 4 {
+		t.Fatalf("Error document = %+v, %v", doc, err)
+	}
+	if _, err = (XMLEncoder{Root: "a b"}).Encode(nil); err == nil {
+		t.Fatalf("Error the invalid root should be rejected")
+	}
+}
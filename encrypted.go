@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// encryptedPrefix marks the values encrypted by the EncryptedStore
+const encryptedPrefix = "enc1:"
+
+// EncryptedStore is a TokenStore decorator encrypting the credential and the scope of the records before they reach
+// the inner store, so database dumps don't expose them. Each value is sealed with AES-256-GCM under a random data key,
+// crypted in turn with the formatter (envelope encryption) and bound to the record id.
+// Values stored before the encryption was enabled are returned as they are.
+type EncryptedStore struct {
+	inner     TokenStore
+	formatter TokenSecureFormatter
+}
+
+// NewEncryptedStore creates an EncryptedStore wrapping the inner store.
+func NewEncryptedStore(inner TokenStore, formatter TokenSecureFormatter) *EncryptedStore {
+	return &EncryptedStore{inner: inner, formatter: formatter}
+}
+
+// SaveToken encrypts the record and saves it in the inner store
+func (s *EncryptedStore) SaveToken(rec *TokenRecord) error {
+	c := *rec
+	var err error
+	if c.Credential, err = s.seal(c.Credential, c.ID, "credential"); err != nil {
+		return err
+	}
+	if c.Scope, err = s.seal(c.Scope, c.ID, "scope"); err != nil {
+		return err
+	}
+	return s.inner.SaveToken(&c)
+}
+
+// GetToken returns the decrypted record of the refresh token or ErrTokenNotFound
+func (s *EncryptedStore) GetToken(refreshTokenID string) (*TokenRecord, error) {
+	rec, err := s.inner.GetToken(refreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Credential, err = s.open(rec.Credential, rec.ID, "credential"); err != nil {
+		return nil, err
+	}
+	if rec.Scope, err = s.open(rec.Scope, rec.ID, "scope"); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// RotateToken revokes the rotated refresh token in the inner store unless it is already revoked
+func (s *EncryptedStore) RotateToken(refreshTokenID string) error {
+	return s.inner.RotateToken(refreshTokenID)
+}
+
+// RevokeFamily revokes the refresh token and all the refresh tokens rotated from it
+func (s *EncryptedStore) RevokeFamily(refreshTokenID string) ([]string, error) {
+	return s.inner.RevokeFamily(refreshTokenID)
+}
+
+// PurgeExpired purges the inner store when it implements Purger
+func (s *EncryptedStore) PurgeExpired(now time.Time) int {
+	if p, ok := s.inner.(Purger); ok {
+		return p.PurgeExpired(now)
+	}
+	return 0
+}
+
+// seal encrypts the value, the envelope is the length of the crypted data key, the crypted data key, the nonce
+// and the AES-GCM ciphertext
+func (s *EncryptedStore) seal(value, recordID, field string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	wrapped, err := s.formatter.CryptToken(key)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	envelope := make([]byte, 2, 2+len(wrapped)+len(nonce)+len(value)+aead.Overhead())
+	binary.BigEndian.PutUint16(envelope, uint16(len(wrapped)))
+	envelope = append(envelope, wrapped...)
+	envelope = append(envelope, nonce...)
+	envelope = aead.Seal(envelope, nonce, []byte(value), []byte(recordID+"/"+field))
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(envelope), nil
+}
+
+// open decrypts the value sealed by seal
+func (s *EncryptedStore) open(value, recordID, field string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	envelope, err := base64.RawURLEncoding.DecodeString(value[len(encryptedPrefix):])
+	if err != nil || len(envelope) < 2 {
+		return "", fmt.Errorf("%w: invalid %s envelope", ErrMalformedToken, field)
+	}
+	n := int(binary.BigEndian.Uint16(envelope))
+	if len(envelope) < 2+n {
+		return "", fmt.Errorf("%w: invalid %s envelope", ErrMalformedToken, field)
+	}
+	key, err := s.formatter.DecryptToken(envelope[2 : 2+n])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	sealed := envelope[2+n:]
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: invalid %s envelope", ErrMalformedToken, field)
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(recordID+"/"+field))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,43 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	inner := NewMemoryTokenStore()
+	s := NewEncryptedStore(inner, NewSHA256RC4TokenSecurityProvider([]byte("mySecretKey-10101")))
+	rec := &TokenRecord{ID: "r1", TokenID: "t1", FamilyID: "r1", Credential: "user111", Scope: "read write", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.SaveToken(rec); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if rec.Credential != "user111" {
+		t.Fatalf("Error record modified = %+v", rec)
+	}
+
+	stored, _ := inner.GetToken("r1")
+	if !strings.HasPrefix(stored.Credential, encryptedPrefix) || strings.Contains(stored.Credential, "user111") || strings.Contains(stored.Scope, "read") {
+		t.Fatalf("Error stored in clear = %+v", stored)
+	}
+
+	got, err := s.GetToken("r1")
+	if err != nil || got.Credential != "user111" || got.Scope != "read write" {
+		t.Fatalf("Error record = %+v, %v", got, err)
+	}
+
+	// the values are bound to their record
+	stored.ID = "r2"
+	_ = inner.SaveToken(stored)
+	if _, err = s.GetToken("r2"); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error swapped record accepted: %v", err)
+	}
+
+	// plaintext records stored before the encryption are readable
+	_ = inner.SaveToken(&TokenRecord{ID: "legacy", Credential: "user222"})
+	if got, err = s.GetToken("legacy"); err != nil || got.Credential != "user222" {
+		t.Fatalf("Error record = %+v, %v", got, err)
+	}
+}
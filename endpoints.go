@@ -0,0 +1,112 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Endpoint identifies an endpoint mounted by RegisterHandlers.
+type Endpoint string
+
+const (
+	// TokenEndpoint serves all the grant types, mounted on /token
+	TokenEndpoint Endpoint = "token"
+	// HealthEndpoint serves Healthz, mounted on /healthz
+	HealthEndpoint Endpoint = "healthz"
+)
+
+// Router is implemented by http.ServeMux and chi.Router.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// EndpointOption configures the endpoints mounted by RegisterHandlers.
+type EndpointOption func(*endpointConfig)
+
+type endpointConfig struct {
+	prefix   string
+	paths    map[Endpoint]string
+	disabled map[Endpoint]bool
+}
+
+// WithEndpointPath mounts the endpoint on the path instead of its standard path
+func WithEndpointPath(e Endpoint, path string) EndpointOption {
+	return func(c *endpointConfig) { c.paths[e] = path }
+}
+
+// WithoutEndpoint disables the endpoint
+func WithoutEndpoint(e Endpoint) EndpointOption {
+	return func(c *endpointConfig) { c.disabled[e] = true }
+}
+
+// WithPathPrefix mounts the endpoints under the prefix, e.g. /oauth2
+func WithPathPrefix(prefix string) EndpointOption {
+	return func(c *endpointConfig) { c.prefix = strings.TrimSuffix(prefix, "/") }
+}
+
+// RegisterHandlers mounts the enabled endpoints on their standard paths, each endpoint answers
+// 405 Method Not Allowed to the methods it doesn't serve and the CORS requests of the allowed origins.
+func (bs *BearerServer) RegisterHandlers(mux Router, opts ...EndpointOption) {
+	c := &endpointConfig{
+		paths: map[Endpoint]string{
+			TokenEndpoint:  "/token",
+			HealthEndpoint: "/healthz",
+		},
+		disabled: make(map[Endpoint]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	endpoints := []struct {
+		endpoint Endpoint
+		handler  http.HandlerFunc
+		methods  []string
+	}{
+		{TokenEndpoint, bs.Token, []string{http.MethodPost}},
+		{HealthEndpoint, bs.Healthz, []string{http.MethodGet, http.MethodHead}},
+	}
+	for _, e := range endpoints {
+		if c.disabled[e.endpoint] {
+			continue
+		}
+		mux.Handle(c.prefix+c.paths[e.endpoint], bs.CORS(allowMethods(e.handler, e.methods...)))
+	}
+}
+
+// Token is the token endpoint serving all the grant types, it dispatches the request on the grant_type parameter
+func (bs *BearerServer) Token(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if !bs.checkHTTPS(w, r) || !bs.parseForm(w, r) {
+		return
+	}
+	switch GrantType(r.FormValue("grant_type")) {
+	case PasswordGrant:
+		bs.UserCredentials(w, r)
+	case AuthCodeGrant:
+		bs.AuthorizationCode(w, r)
+	default:
+		bs.ClientCredentials(w, r)
+	}
+}
+
+// allowMethods answers 405 Method Not Allowed to the requests with other methods, the bodies of the HEAD responses
+// are discarded
+func allowMethods(h http.Handler, methods ...string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range methods {
+			if r.Method == m {
+				if r.Method == http.MethodHead {
+					w = headResponseWriter{w}
+				}
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		renderJSON(w, ErrorResponse{Error: TokenInvalidRequest, Description: "method not allowed", URI: ""}, false, http.StatusMethodNotAllowed)
+	})
+}
@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRegisterHandlers(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	mux := http.NewServeMux()
+	sut.RegisterHandlers(mux, WithPathPrefix("/oauth2/"), WithoutEndpoint(HealthEndpoint))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, form := range []url.Values{
+		{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}},
+		{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}},
+	} {
+		resp, err := http.PostForm(ts.URL+"/oauth2/token", form)
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Error %s StatusCode = %d", form.Get("grant_type"), resp.StatusCode)
+		}
+	}
+
+	resp, _ := http.Get(ts.URL + "/oauth2/token")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "POST" {
+		t.Fatalf("Error StatusCode = %d", resp.StatusCode)
+	}
+	resp, _ = http.Get(ts.URL + "/oauth2/healthz")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Error disabled endpoint StatusCode = %d", resp.StatusCode)
+	}
+}
+
+func TestRegisterHandlersChi(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	r := chi.NewRouter()
+	sut.RegisterHandlers(r, WithEndpointPath(HealthEndpoint, "/ready"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader("grant_type=password&username=user111&password=wrong"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
+
+func TestRegisterHandlersHead(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	mux := http.NewServeMux()
+	sut.RegisterHandlers(mux)
+
+	get := httptest.NewRecorder()
+	mux.ServeHTTP(get, httptest.NewRequest("GET", "/healthz", nil))
+	head := httptest.NewRecorder()
+	mux.ServeHTTP(head, httptest.NewRequest("HEAD", "/healthz", nil))
+	if head.Code != get.Code || head.Body.Len() != 0 || head.Header().Get("Content-Length") != strconv.Itoa(get.Body.Len()) {
+		t.Fatalf("Error StatusCode = %d, body = %q, headers = %v", head.Code, head.Body.String(), head.Header())
+	}
+}
@@ -0,0 +1,32 @@
+package oauth
+
+import "net/url"
+
+// ErrorURICatalog links the error responses without error_uri to the documentation of their error code.
+type ErrorURICatalog struct {
+	// BaseURL is the URL the documentation paths are resolved against, e.g. "https://docs.example.com/oauth/errors/"
+	BaseURL string
+	// Paths maps the error codes to their documentation path, relative to BaseURL (e.g. "grants#invalid-grant") or
+	// absolute. The error codes not in Paths link to their code, e.g. "invalid_grant".
+	Paths map[ErrorResponseType]string
+}
+
+// URI returns the documentation URI of the error code, empty when BaseURL is not a valid URL
+func (c *ErrorURICatalog) URI(code ErrorResponseType) string {
+	if c == nil || code == "" {
+		return ""
+	}
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return ""
+	}
+	path, ok := c.Paths[code]
+	if !ok {
+		path = url.PathEscape(string(code))
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
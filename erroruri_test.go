@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorURICatalog(t *testing.T) {
+	catalog := &ErrorURICatalog{
+		BaseURL: "https://docs.example.com/oauth/errors/",
+		Paths:   map[ErrorResponseType]string{TokenInvalidClient: "clients#authentication", TokenServerError: "https://status.example.com/"},
+	}
+	for code, expected := range map[ErrorResponseType]string{
+		TokenInvalidGrant:  "https://docs.example.com/oauth/errors/invalid_grant",
+		TokenInvalidClient: "https://docs.example.com/oauth/errors/clients#authentication",
+		TokenServerError:   "https://status.example.com/",
+	} {
+		if uri := catalog.URI(code); uri != expected {
+			t.Fatalf("Error URI(%s) = %s", code, uri)
+		}
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.ErrorURIs = catalog
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"forged"}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.Token(w, req)
+	var e ErrorResponse
+	if json.Unmarshal(w.Body.Bytes(), &e) != nil || e.URI != "https://docs.example.com/oauth/errors/invalid_grant" {
+		t.Fatalf("Error body = %s", w.Body.String())
+	}
+}
@@ -0,0 +1,157 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// EventType is the type of the token lifecycle events
+type EventType string
+
+// Token lifecycle events
+const (
+	TokenIssuedEvent    EventType = "token.issued"
+	TokenRefreshedEvent EventType = "token.refreshed"
+	TokenRevokedEvent   EventType = "token.revoked"
+	// TokenStoreFailedEvent audits the tokens issued although the verifier StoreTokenID failed, see StorePolicy
+	TokenStoreFailedEvent EventType = "token.store_failed"
+)
+
+// ErrTokenStoreRequired is returned by RevokeRefreshToken when the server has no TokenStore.
+var ErrTokenStoreRequired = errors.New("token revocation requires a TokenStore")
+
+// ErrCredentialRevocationUnsupported is returned by RevokeCredential when the TokenStore is not a CredentialTokenStore.
+var ErrCredentialRevocationUnsupported = errors.New("the TokenStore cannot revoke the tokens of a credential")
+
+// Event is a token lifecycle event, it never carries the tokens themselves.
+type Event struct {
+	ID             string    `json:"id"`
+	Type           EventType `json:"type"`
+	Time           time.Time `json:"time"`
+	TokenID        string    `json:"token_id,omitempty"`
+	RefreshTokenID string    `json:"refresh_token_id,omitempty"`
+	TokenType      TokenType `json:"token_type,omitempty"`
+	Credential     string    `json:"credential,omitempty"`
+	Scope          string    `json:"scope,omitempty"`
+	RequestID      string    `json:"request_id,omitempty"`
+	// ClientIP is the client address of the token request, resolved by the server ClientIPResolver
+	ClientIP string `json:"client_ip,omitempty"`
+	// Error is the failure audited by the TokenStoreFailedEvent
+	Error string `json:"error,omitempty"`
+	// Sequence, PrevHash, Hash and Signature chain and sign the events delivered by the SigningSink
+	Sequence  uint64 `json:"seq,omitempty"`
+	PrevHash  string `json:"prev_hash,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// EventPublisher receives the token lifecycle events, Publish is called on the request path and must not block.
+type EventPublisher interface {
+	Publish(event *Event)
+}
+
+// RevokeRefreshToken revokes the refresh token and all the refresh tokens rotated from it,
+// publishing a TokenRevokedEvent for each, and returns the revoked refresh token ids
+func (bs *BearerServer) RevokeRefreshToken(refreshTokenID string) ([]string, error) {
+	if bs.TokenStore == nil {
+		return nil, ErrTokenStoreRequired
+	}
+	revoked, err := bs.TokenStore.RevokeFamily(refreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	bs.tokensRevoked(revoked)
+	return revoked, nil
+}
+
+// revokeFamily revokes all the refresh tokens of the family of the record, from the refresh token of the original grant
+func (bs *BearerServer) revokeFamily(rec *TokenRecord) {
+	familyID := rec.FamilyID
+	if familyID == "" {
+		familyID = rec.ID
+	}
+	if revoked, err := bs.TokenStore.RevokeFamily(familyID); err == nil {
+		bs.tokensRevoked(revoked)
+	}
+}
+
+// RevokeCredential revokes all the refresh tokens issued to the credential, publishing a TokenRevokedEvent for each,
+// and returns the revoked refresh token ids. The TokenStore must implement CredentialTokenStore.
+func (bs *BearerServer) RevokeCredential(credential string) ([]string, error) {
+	if bs.TokenStore == nil {
+		return nil, ErrTokenStoreRequired
+	}
+	store, ok := bs.TokenStore.(CredentialTokenStore)
+	if !ok {
+		return nil, ErrCredentialRevocationUnsupported
+	}
+	revoked, err := store.RevokeCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+	bs.tokensRevoked(revoked)
+	return revoked, nil
+}
+
+// tokensRevoked denies the access tokens issued with the revoked refresh tokens and publishes their revocation
+func (bs *BearerServer) tokensRevoked(revoked []string) {
+	if bs.Events == nil && bs.Denylist == nil {
+		return
+	}
+	for _, id := range revoked {
+		event := &Event{Type: TokenRevokedEvent, RefreshTokenID: id}
+		if rec, err := bs.TokenStore.GetToken(id); err == nil {
+			event.TokenID, event.TokenType, event.Credential, event.Scope = rec.TokenID, rec.TokenType, rec.Credential, rec.Scope
+			if bs.Denylist != nil {
+				bs.Denylist.Add(rec.TokenID, denyUntil(rec.TokenExpiresAt))
+			}
+		}
+		if bs.Events != nil {
+			bs.publish(event, nil)
+		}
+	}
+}
+
+// denyUntil returns until when the revoked access token of the expiry is denied, the tokens that do not expire
+// are denied for good
+func denyUntil(tokenExpiresAt time.Time) time.Time {
+	if tokenExpiresAt.IsZero() {
+		return time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+	}
+	return tokenExpiresAt
+}
+
+// publishTokens publishes the issuance of the tokens, rotated refresh tokens are published as TokenRefreshedEvent
+func (bs *BearerServer) publishTokens(token *Token, refresh *RefreshToken, r *http.Request) {
+	if bs.Events == nil {
+		return
+	}
+	eventType := TokenIssuedEvent
+	if refresh.ParentID != "" {
+		eventType = TokenRefreshedEvent
+	}
+	bs.publish(&Event{
+		Type:           eventType,
+		TokenID:        token.ID,
+		RefreshTokenID: refresh.ID,
+		TokenType:      token.TokenType,
+		Credential:     token.Credential,
+		Scope:          token.Scope,
+	}, r)
+}
+
+// publish completes the event and hands it to the EventPublisher
+func (bs *BearerServer) publish(event *Event, r *http.Request) {
+	event.ID = uuid.Must(uuid.NewV4()).String()
+	event.Time = time.Now().UTC()
+	if r != nil {
+		event.RequestID = RequestIDFromContext(r.Context())
+		if ip := ClientIPFromContext(r.Context()); ip != nil {
+			event.ClientIP = ip.String()
+		}
+	}
+	bs.Events.Publish(event)
+}
@@ -0,0 +1,94 @@
+package oauth
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (p *recordingPublisher) Publish(event *Event) {
+	p.mu.Lock()
+	p.events = append(p.events, event)
+	p.mu.Unlock()
+}
+
+func TestTokenEvents(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.TokenStore = NewMemoryTokenStore()
+	events := new(recordingPublisher)
+	sut.Events = events
+
+	resp, code := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	resp, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", resp.(*TokenResponse).RefreshToken, "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	refresh, _ := sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	// the rotated refresh token was revoked by the rotation
+	revoked, err := sut.RevokeRefreshToken(refresh.FamilyID)
+	if err != nil || len(revoked) != 1 || revoked[0] != refresh.ID {
+		t.Fatalf("Error revoked = %v, %v", revoked, err)
+	}
+
+	want := []EventType{TokenIssuedEvent, TokenRefreshedEvent, TokenRevokedEvent}
+	if len(events.events) != len(want) {
+		t.Fatalf("Error events = %d", len(events.events))
+	}
+	for i, event := range events.events {
+		if event.Type != want[i] || event.ID == "" || event.Credential != "user111" || event.RefreshTokenID == "" {
+			t.Fatalf("Error event %d = %+v", i, event)
+		}
+	}
+	if events.events[1].RefreshTokenID != refresh.ID {
+		t.Fatalf("Error refreshed event = %+v", events.events[1])
+	}
+
+	sut.TokenStore = nil
+	if _, err = sut.RevokeRefreshToken(refresh.ID); err != ErrTokenStoreRequired {
+		t.Fatalf("Error %v", err)
+	}
+}
+
+func TestTokensRevokedDenylist(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.TokenStore = NewMemoryTokenStore()
+	sut.Denylist = NewDenylist(10, 0.01)
+
+	resp, code := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	refresh, _ := sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	// the TTL changed by a reload does not apply to the tokens issued before
+	sut.TokenTTL = time.Second
+	if _, err := sut.RevokeRefreshToken(refresh.ID); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if expiresAt := sut.Denylist.entries[token.ID]; !expiresAt.Equal(token.CreationDate.Add(10 * time.Second)) {
+		t.Fatalf("Error denied until %v", expiresAt)
+	}
+
+	sut.TokenTTL = 0
+	resp, code = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	token, _ = sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	refresh, _ = sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	if _, err := sut.RevokeRefreshToken(refresh.ID); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if !sut.Denylist.Contains(token.ID) || sut.Denylist.entries[token.ID].Before(time.Now().AddDate(100, 0, 0)) {
+		t.Fatalf("Error the non-expiring token is denied until %v", sut.Denylist.entries[token.ID])
+	}
+}
@@ -0,0 +1,90 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Token response extension fields registered by OIDC and token exchange.
+const (
+	IDTokenExtension         = "id_token"
+	DeviceSecretExtension    = "device_secret"
+	IssuedTokenTypeExtension = "issued_token_type"
+)
+
+// ErrStandardField is returned when an extension field collides with a standard token response field.
+var ErrStandardField = errors.New("extension collides with a standard token response field")
+
+var tokenResponseFields = []string{"access_token", "refresh_token", "token_type", "expires_in", "refresh_token_expires_in", "properties"}
+
+func isTokenResponseField(name string) bool {
+	for _, f := range tokenResponseFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtensionsVerifier defines the optional interface providing the extension fields of the token response
+type ExtensionsVerifier interface {
+	// AddExtensions provides fields marshaled at the top level of the authorization server response
+	AddExtensions(tokenType TokenType, credential, tokenID, scope string, r *http.Request) (map[string]interface{}, error)
+}
+
+// SetExtension sets a field marshaled at the top level of the response, standard fields cannot be overwritten.
+func (t *TokenResponse) SetExtension(name string, value interface{}) error {
+	if isTokenResponseField(name) {
+		return ErrStandardField
+	}
+	if t.Extensions == nil {
+		t.Extensions = make(map[string]interface{})
+	}
+	t.Extensions[name] = value
+	return nil
+}
+
+// MarshalJSON marshals the standard fields followed by the extension fields.
+func (t TokenResponse) MarshalJSON() ([]byte, error) {
+	type tokenResponse TokenResponse
+	b, err := json.Marshal(tokenResponse(t))
+	if err != nil || len(t.Extensions) == 0 {
+		return b, err
+	}
+	for name := range t.Extensions {
+		if isTokenResponseField(name) {
+			return nil, ErrStandardField
+		}
+	}
+	ext, err := json.Marshal(t.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(b[:len(b)-1])
+	buf.WriteByte(',')
+	buf.Write(ext[1:])
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON unmarshals the standard fields collecting the others in Extensions.
+func (t *TokenResponse) UnmarshalJSON(b []byte) error {
+	type tokenResponse TokenResponse
+	if err := json.Unmarshal(b, (*tokenResponse)(t)); err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for name, value := range fields {
+		if !isTokenResponseField(name) {
+			if t.Extensions == nil {
+				t.Extensions = make(map[string]interface{})
+			}
+			t.Extensions[name] = value
+		}
+	}
+	return nil
+}
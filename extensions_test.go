@@ -0,0 +1,37 @@
+package oauth
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTokenResponseExtensions(t *testing.T) {
+	resp := &TokenResponse{Token: "access", TokenType: BearerToken, ExpiresIn: 10}
+	if err := resp.SetExtension("access_token", "forged"); err != ErrStandardField {
+		t.Fatalf("Error should be ErrStandardField: %v", err)
+	}
+	if err := resp.SetExtension(IssuedTokenTypeExtension, "urn:ietf:params:oauth:token-type:access_token"); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if !strings.Contains(string(b), `"access_token":"access"`) || !strings.Contains(string(b), `"issued_token_type":"urn:ietf:params:oauth:token-type:access_token"`) {
+		t.Fatalf("Error JSON = %s", b)
+	}
+
+	var decoded TokenResponse
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if decoded.Token != "access" || decoded.Extensions[IssuedTokenTypeExtension] != "urn:ietf:params:oauth:token-type:access_token" {
+		t.Fatalf("Error decoded = %v", decoded)
+	}
+
+	resp.Extensions["token_type"] = "mac"
+	if _, err = json.Marshal(resp); err == nil {
+		t.Fatalf("Error should have occurred")
+	}
+}
@@ -0,0 +1,53 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ResponseFieldMapping renames the JSON fields of the token and error responses for the clients of a legacy
+// authorization server, e.g. during a migration:
+//
+//	bs.ResponseFields = &oauth.ResponseFieldMapping{Names: map[string]string{"access_token": "accessToken", "expires_in": "expires"}, KeepStandard: true}
+type ResponseFieldMapping struct {
+	// Names maps the standard field names to the legacy ones, the fields not in Names keep their standard name
+	Names map[string]string
+	// KeepStandard renders the standard fields along the legacy ones, so the migrated and the legacy clients share the responses
+	KeepStandard bool
+	// Applies, when set, selects the requests whose responses are renamed (e.g. by client id or a header), all of them when nil
+	Applies func(r *http.Request) bool
+}
+
+// rename returns the token, error or problem response with the legacy field names, resp itself when the mapping
+// doesn't apply. The OAuth error fields embedded in a Problem are renamed like the ones of an ErrorResponse.
+func (m *ResponseFieldMapping) rename(r *http.Request, resp interface{}) interface{} {
+	if m == nil || len(m.Names) == 0 || r == nil || (m.Applies != nil && !m.Applies(r)) {
+		return resp
+	}
+	switch resp.(type) {
+	case *TokenResponse, ErrorResponse, *Problem:
+	default:
+		return resp
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return resp
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return resp
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for name, value := range fields {
+		legacy, ok := m.Names[name]
+		if !ok || legacy == "" {
+			renamed[name] = value
+			continue
+		}
+		renamed[legacy] = value
+		if m.KeepStandard {
+			renamed[name] = value
+		}
+	}
+	return renamed
+}
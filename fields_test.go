@@ -0,0 +1,50 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseFieldMapping(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.ResponseFields = &ResponseFieldMapping{
+		Names:   map[string]string{"access_token": "accessToken", "expires_in": "expires", "error": "errorCode"},
+		Applies: func(r *http.Request) bool { return r.Header.Get("X-Legacy-Client") != "" },
+	}
+	token := func(password string, legacy bool) map[string]interface{} {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if legacy {
+			req.Header.Set("X-Legacy-Client", "1")
+		}
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		var fields map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+			t.Fatalf("Error %v", err)
+		}
+		return fields
+	}
+
+	fields := token("password111", true)
+	if fields["accessToken"] == nil || fields["expires"] != float64(10) || fields["access_token"] != nil || fields["refresh_token"] == nil {
+		t.Fatalf("Error fields = %v", fields)
+	}
+	if fields = token("password111", false); fields["access_token"] == nil || fields["accessToken"] != nil {
+		t.Fatalf("Error fields = %v", fields)
+	}
+	if fields = token("forged", true); fields["errorCode"] != string(TokenInvalidGrant) || fields["error"] != nil {
+		t.Fatalf("Error fields = %v", fields)
+	}
+
+	sut.ResponseFields.KeepStandard = true
+	if fields = token("password111", true); fields["accessToken"] == nil || fields["accessToken"] != fields["access_token"] {
+		t.Fatalf("Error fields = %v", fields)
+	}
+}
@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const verifierContext contextKey = "oauth.verifier"
+
+// GrantContext describes the token request being processed. It is passed to the new-style verifier hooks
+// so that future request parameters don't change their signatures.
+type GrantContext struct {
+	GrantType GrantType
+	// ClientID is the client requesting the token, empty when the request does not identify it
+	ClientID string
+	// ClientAuthenticated is set once the grant authenticated ClientID, until then ClientID is the client_id form parameter
+	ClientAuthenticated bool
+	// Credential is the user or client the token is issued to, resolved by the grant before the tokens generation
+	Credential  string
+	Scope       string
+	RedirectURI string
+	// Audience and Resources are the requested "audience" (RFC 8693) and "resource" (RFC 8707) parameters
+	Audience   []string
+	Resources  []string
+	Form       url.Values
+	RemoteAddr string
+	// ClientIP is the address of the client resolved by the server ClientIPResolver
+	ClientIP net.IP
+	Request  *http.Request
+
+	secret       string
+	refreshToken string
+	code         string
+	deviceID     string
+	maxTTL       time.Duration
+	acr          string
+	amr          []string
+	authTime     time.Time
+	// trustedDevice is set when deviceID is remembered by the DeviceRegistry
+	trustedDevice bool
+}
+
+// GrantContextVerifier defines the optional new-style verifier hooks receiving the GrantContext
+type GrantContextVerifier interface {
+	// ValidateGrant is called after the grant validation and before the tokens generation, an error denies the grant
+	ValidateGrant(gc *GrantContext) error
+}
+
+// authenticatedClient returns the client authenticated by the grant, empty until it is authenticated
+func (gc *GrantContext) authenticatedClient() string {
+	if gc.ClientAuthenticated {
+		return gc.ClientID
+	}
+	return ""
+}
+
+func newGrantContext(grantType GrantType, credential, secret, refreshToken, scope, code, redirectURI string, r *http.Request) *GrantContext {
+	gc := &GrantContext{
+		GrantType:    grantType,
+		Credential:   credential,
+		Scope:        scope,
+		RedirectURI:  redirectURI,
+		Request:      r,
+		secret:       secret,
+		refreshToken: refreshToken,
+		code:         code,
+	}
+	if r != nil {
+		gc.ClientID = r.FormValue("client_id")
+		gc.Form = r.Form
+		gc.Audience = r.Form["audience"]
+		gc.Resources = r.Form["resource"]
+		gc.RemoteAddr = r.RemoteAddr
+	}
+	return gc
+}
+
+// GrantHandler processes the token request described by the GrantContext and returns the response and its HTTP status
+type GrantHandler func(gc *GrantContext) (interface{}, int)
+
+// GrantMiddleware wraps the GrantHandler of all the grant types, it can act before and after the grant
+type GrantMiddleware func(next GrantHandler) GrantHandler
+
+// Use appends the middlewares to the grant chain, the first registered middleware is the outermost one.
+// Use must be called before serving the requests.
+func (bs *BearerServer) Use(middlewares ...GrantMiddleware) {
+	bs.middlewares = append(bs.middlewares, middlewares...)
+}
+
+// grantHandler returns the grant handler wrapped by the middlewares. The idempotent responses are replayed inside the
+// middlewares and the rate limiter, so the replays are checked and throttled like the grants.
+func (bs *BearerServer) grantHandler() GrantHandler {
+	handler := GrantHandler(bs.grant)
+	if bs.IdempotencyCache != nil {
+		handler = bs.idempotent(handler)
+	}
+	for i := len(bs.middlewares) - 1; i >= 0; i-- {
+		handler = bs.middlewares[i](handler)
+	}
+	if bs.RateLimiter != nil {
+		handler = bs.rateLimited(handler)
+	}
+	return handler
+}
+
+// validateGrant calls the GrantContextVerifier hook then the RiskEvaluator
+func (bs *BearerServer) validateGrant(gc *GrantContext) (interface{}, int) {
+	if v, ok := optionalVerifier(bs.verifierFor(gc.Request)).(GrantContextVerifier); ok {
+		if err := v.ValidateGrant(gc); err != nil {
+			return ErrorResponse{Error: TokenInvalidGrant, Description: "grant denied: " + err.Error(), URI: ""}, http.StatusBadRequest
+		}
+	}
+	if bs.RiskEvaluator != nil {
+		return bs.evaluateRisk(gc)
+	}
+	return nil, 0
+}
+
+// selectVerifier binds the verifier returned by the VerifierSelector for the client to the grant request, returning
+// false when the server verifier is kept. The client must be authenticated by the grant with the selected verifier
+// (its secret or its authorization code) or be the client the refresh token was issued to, never a form parameter alone.
+func (bs *BearerServer) selectVerifier(gc *GrantContext, clientID string) bool {
+	if bs.VerifierSelector == nil || gc.Request == nil || clientID == "" {
+		return false
+	}
+	v := bs.VerifierSelector(clientID)
+	if v == nil {
+		return false
+	}
+	gc.Request = gc.Request.WithContext(context.WithValue(gc.Request.Context(), verifierContext, v))
+	return true
+}
+
+// verifierFor returns the verifier selected for the request, the server verifier by default
+func (bs *BearerServer) verifierFor(r *http.Request) CredentialsVerifier {
+	if r != nil {
+		if v, ok := r.Context().Value(verifierContext).(CredentialsVerifier); ok {
+			return v
+		}
+	}
+	return bs.verifier
+}
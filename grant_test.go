@@ -0,0 +1,153 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testGrantVerifier struct {
+	TestUserVerifier
+	grants []*GrantContext
+}
+
+func (v *testGrantVerifier) ValidateGrant(gc *GrantContext) error {
+	v.grants = append(v.grants, gc)
+	if len(gc.Audience) > 0 && gc.Audience[0] != "api" {
+		return errors.New("audience not allowed")
+	}
+	return nil
+}
+
+func TestGrantContextVerifier(t *testing.T) {
+	verifier := new(testGrantVerifier)
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, verifier, nil)
+
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}, "client_id": {"abcdef"}, "audience": {"api"}, "resource": {"https://api.example.com"}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if len(verifier.grants) != 1 {
+		t.Fatalf("Error ValidateGrant calls = %d", len(verifier.grants))
+	}
+	gc := verifier.grants[0]
+	if gc.GrantType != PasswordGrant || gc.Credential != "user111" || gc.ClientID != "abcdef" || gc.Resources[0] != "https://api.example.com" || gc.Request == nil {
+		t.Fatalf("Error grant context = %+v", gc)
+	}
+
+	form.Set("audience", "other")
+	req = httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
+
+func TestGrantMiddleware(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	var calls []string
+	sut.Use(func(next GrantHandler) GrantHandler {
+		return func(gc *GrantContext) (interface{}, int) {
+			calls = append(calls, "outer")
+			resp, status := next(gc)
+			calls = append(calls, "outer:"+http.StatusText(status))
+			return resp, status
+		}
+	}, func(next GrantHandler) GrantHandler {
+		return func(gc *GrantContext) (interface{}, int) {
+			calls = append(calls, "quota")
+			if gc.Credential == "user222" {
+				return ErrorResponse{Error: TokenInvalidGrant, Description: "quota exceeded"}, http.StatusTooManyRequests
+			}
+			return next(gc)
+		}
+	})
+
+	_, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	resp, status := sut.generateTokenResponse(PasswordGrant, "user222", "password222", "", "", "", "", new(http.Request))
+	if status != http.StatusTooManyRequests || resp.(ErrorResponse).Description != "quota exceeded" {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if strings.Join(calls, ",") != "outer,quota,outer:OK,outer,quota,outer:Too Many Requests" {
+		t.Fatalf("Error calls = %v", calls)
+	}
+}
+
+type firstPartyVerifier struct {
+	TestUserVerifier
+}
+
+func (firstPartyVerifier) ValidateUser(username, password, scope string, r *http.Request) error {
+	if username == "employee" && password == "secret" {
+		return nil
+	}
+	return errors.New("wrong user")
+}
+
+func (firstPartyVerifier) ValidateClient(clientID, clientSecret, scope string, r *http.Request) error {
+	if clientID == "intranet" && clientSecret == "s3cr3t" {
+		return nil
+	}
+	return errors.New("wrong client")
+}
+
+func (firstPartyVerifier) AddClaims(tokenType TokenType, credential, tokenID, scope string, r *http.Request) (Claims, error) {
+	return Claims{"first_party": true}, nil
+}
+
+func TestVerifierSelector(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.VerifierSelector = func(clientID string) CredentialsVerifier {
+		if clientID == "intranet" {
+			return firstPartyVerifier{}
+		}
+		return nil
+	}
+
+	r, _ := http.NewRequest("POST", "/token?client_id=intranet&client_secret=s3cr3t", nil)
+	resp, status := sut.generateTokenResponse(PasswordGrant, "employee", "secret", "", "", "", "", r)
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, response = %v", status, resp)
+	}
+	token, err := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if err != nil || token.Claims["first_party"] != true {
+		t.Fatalf("Error claims = %v, %v", token, err)
+	}
+	refresh, err := sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	if err != nil || refresh.ClientID != "intranet" {
+		t.Fatalf("Error refresh token client = %v, %v", refresh, err)
+	}
+
+	r, _ = http.NewRequest("POST", "/token?client_id=intranet&client_secret=s3cr3t", nil)
+	if _, status = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", r); status != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+
+	// the unauthenticated client_id does not select the verifier
+	r, _ = http.NewRequest("POST", "/token?client_id=intranet", nil)
+	if _, status = sut.generateTokenResponse(PasswordGrant, "employee", "secret", "", "", "", "", r); status != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	r, _ = http.NewRequest("POST", "/token?client_id=intranet&client_secret=wrong", nil)
+	if resp, status = sut.generateTokenResponse(PasswordGrant, "employee", "secret", "", "", "", "", r); status != http.StatusUnauthorized || resp.(ErrorResponse).Error != TokenInvalidClient {
+		t.Fatalf("Error response = %v", resp)
+	}
+
+	r, _ = http.NewRequest("POST", "/token", nil)
+	if _, status = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", r); status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+}
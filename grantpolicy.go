@@ -0,0 +1,68 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ServerOption configures the BearerServer created by NewBearerServer.
+type ServerOption func(*BearerServer)
+
+// GrantDeprecation announces the removal of a grant type to the clients still using it.
+type GrantDeprecation struct {
+	// Since is the date of the deprecation, rendered in the Deprecation header (RFC 9745)
+	Since time.Time
+	// Sunset is the date the grant type will be removed, rendered in the Sunset header (RFC 8594) when set
+	Sunset time.Time
+	// Link is the URL of the migration documentation, rendered as a Link header with the "deprecation" relation when set
+	Link string
+}
+
+// WithDisabledGrants makes the grant types answer unsupported_grant_type before any processing
+func WithDisabledGrants(grants ...GrantType) ServerOption {
+	return func(bs *BearerServer) { bs.DisabledGrants = append(bs.DisabledGrants, grants...) }
+}
+
+// WithDeprecatedGrant adds the deprecation headers to the responses of the grant type
+func WithDeprecatedGrant(grantType GrantType, deprecation GrantDeprecation) ServerOption {
+	return func(bs *BearerServer) {
+		if bs.DeprecatedGrants == nil {
+			bs.DeprecatedGrants = make(map[GrantType]GrantDeprecation)
+		}
+		bs.DeprecatedGrants[grantType] = deprecation
+	}
+}
+
+// grantDisabled returns true when the grant type is in DisabledGrants or in the disabled grants of the reloaded config
+func (bs *BearerServer) grantDisabled(grantType GrantType) bool {
+	disabled := bs.DisabledGrants
+	if cfg := bs.Config(); cfg != nil {
+		disabled = append(disabled[:len(disabled):len(disabled)], cfg.DisabledGrants...)
+	}
+	for _, g := range disabled {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// setDeprecationHeaders adds the deprecation headers of the grant type of the request
+func (bs *BearerServer) setDeprecationHeaders(w http.ResponseWriter, r *http.Request) {
+	d, ok := bs.DeprecatedGrants[GrantType(r.FormValue("grant_type"))]
+	if !ok {
+		return
+	}
+	since := d.Since
+	if since.IsZero() {
+		since = time.Now()
+	}
+	w.Header().Set("Deprecation", fmt.Sprintf("@%d", since.Unix()))
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, d.Link))
+	}
+}
@@ -0,0 +1,42 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrantPolicy(t *testing.T) {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil,
+		WithDisabledGrants(PasswordGrant),
+		WithDeprecatedGrant(ClientCredentialsGrant, GrantDeprecation{Since: time.Unix(1700000000, 0), Sunset: sunset, Link: "https://example.com/migrate"}))
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+
+	w := post(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), string(TokenUnsupportedGrantType)) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Deprecation") != "" {
+		t.Fatalf("Error Deprecation header on a non deprecated grant")
+	}
+
+	w = post(url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Deprecation") != "@1700000000" || w.Header().Get("Sunset") != "Fri, 01 Jan 2027 00:00:00 GMT" ||
+		w.Header().Get("Link") != `<https://example.com/migrate>; rel="deprecation"; type="text/html"` {
+		t.Fatalf("Error headers = %v", w.Header())
+	}
+}
@@ -0,0 +1,45 @@
+package oauth
+
+import "net/http"
+
+// DefaultSecurityHeaders are set on the responses of the handlers, see BearerServer.SecurityHeaders for the overrides.
+// The responses are JSON documents never rendered nor framed by the browsers.
+var DefaultSecurityHeaders = http.Header{
+	"X-Content-Type-Options":  {"nosniff"},
+	"Referrer-Policy":         {"no-referrer"},
+	"X-Frame-Options":         {"DENY"},
+	"Content-Security-Policy": {"default-src 'none'; frame-ancestors 'none'"},
+}
+
+// setSecurityHeaders sets the DefaultSecurityHeaders with the overrides, an override with an empty value removes the header
+func setSecurityHeaders(w http.ResponseWriter, overrides http.Header) {
+	h := w.Header()
+	for name, values := range DefaultSecurityHeaders {
+		h[name] = values
+	}
+	for name, values := range overrides {
+		name = http.CanonicalHeaderKey(name)
+		if len(values) == 0 || values[0] == "" {
+			h.Del(name)
+			continue
+		}
+		h[name] = values
+	}
+}
+
+// setSecurityHeaders sets the DefaultSecurityHeaders overridden by the SecurityHeaders on the response
+func (bs *BearerServer) setSecurityHeaders(w http.ResponseWriter) {
+	setSecurityHeaders(w, bs.SecurityHeaders)
+}
+
+// SecureHeaders is the middleware setting the DefaultSecurityHeaders with the overrides on the responses, e.g. of the
+// HTML login and consent pages served by the application, which override the Content-Security-Policy to load their
+// own resources while X-Frame-Options still prevents their framing (clickjacking).
+func SecureHeaders(overrides http.Header) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			setSecurityHeaders(w, overrides)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
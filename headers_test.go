@@ -0,0 +1,35 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	w := httptest.NewRecorder()
+	sut.Token(w, httptest.NewRequest("POST", "/token", nil))
+	for name, values := range DefaultSecurityHeaders {
+		if w.Header().Get(name) != values[0] {
+			t.Fatalf("Error %s = %q", name, w.Header().Get(name))
+		}
+	}
+
+	sut.SecurityHeaders = http.Header{"Referrer-Policy": {"strict-origin"}, "x-frame-options": {""}}
+	w = httptest.NewRecorder()
+	sut.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Header().Get("Referrer-Policy") != "strict-origin" || w.Header().Get("X-Frame-Options") != "" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("Error headers = %v", w.Header())
+	}
+
+	page := SecureHeaders(http.Header{"Content-Security-Policy": {"default-src 'self'"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	w = httptest.NewRecorder()
+	page.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	if w.Header().Get("Content-Security-Policy") != "default-src 'self'" || w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Fatalf("Error headers = %v", w.Header())
+	}
+}
@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthTimeout bounds the store connectivity checks of Healthz.
+const DefaultHealthTimeout = 2 * time.Second
+
+// Pinger is implemented by the stores able to check their connectivity.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthResponse is the body of the Healthz response, Checks maps each component to "ok" or its error.
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Healthz reports whether the authorization server is functional: the secret key is available, the formatter
+// round-trips the tokens and the configured stores implementing Pinger are reachable.
+// It responds 200 when all the checks pass, 503 otherwise, and can gate the traffic as readiness probe.
+func (bs *BearerServer) Healthz(w http.ResponseWriter, r *http.Request) {
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	ctx, cancel := context.WithTimeout(r.Context(), DefaultHealthTimeout)
+	defer cancel()
+	resp := bs.health(ctx)
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	renderJSON(w, resp, true, status)
+}
+
+// health runs the checks of Healthz
+func (bs *BearerServer) health(ctx context.Context) HealthResponse {
+	checks := map[string]error{"formatter": bs.checkFormatter()}
+	if bs.secret() == "" {
+		checks["secret_key"] = errors.New("secret key not configured")
+	} else {
+		checks["secret_key"] = nil
+	}
+	stores := map[string]interface{}{
+		"token_store":       bs.TokenStore,
+		"client_store":      bs.ClientStore,
+		"auth_code_store":   bs.AuthCodeStore,
+		"code_replay_cache": bs.CodeReplayCache,
+		"idempotency_cache": bs.IdempotencyCache,
+	}
+	for name, store := range stores {
+		if p, ok := store.(Pinger); ok && p != nil {
+			checks[name] = p.Ping(ctx)
+		}
+	}
+
+	resp := HealthResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+	for name, err := range checks {
+		if err != nil {
+			resp.Status = "unavailable"
+			resp.Checks[name] = err.Error()
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+	return resp
+}
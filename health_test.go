@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type unreachableStore struct {
+	*MemoryTokenStore
+}
+
+func (unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthz(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	w := httptest.NewRecorder()
+	sut.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	sut.TokenStore = unreachableStore{NewMemoryTokenStore()}
+	sut.provider = NewTokenProvider(brokenFormatter{})
+	w = httptest.NewRecorder()
+	sut.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	var resp HealthResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusServiceUnavailable || resp.Checks["token_store"] != "connection refused" || resp.Checks["formatter"] == "ok" || resp.Checks["secret_key"] != "ok" {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,63 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IsSecure reports whether the request reached the server over TLS: directly, or through a trusted proxy declaring
+// the https scheme in the Forwarded proto= parameter (RFC 7239) or the X-Forwarded-Proto header.
+// A nil resolver trusts no proxy.
+func (c *ClientIPResolver) IsSecure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	peer := parseHostIP(r.RemoteAddr)
+	if c == nil || peer == nil || !c.isTrusted(peer) {
+		return false
+	}
+	if proto := forwardedProto(r.Header); proto != "" {
+		return strings.EqualFold(proto, "https")
+	}
+	values := strings.Split(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","), ",")
+	return strings.EqualFold(strings.TrimSpace(values[len(values)-1]), "https")
+}
+
+// forwardedProto returns the proto= parameter of the last element of the Forwarded headers, set by the nearest proxy
+func forwardedProto(h http.Header) string {
+	headers := h.Values("Forwarded")
+	if len(headers) == 0 {
+		return ""
+	}
+	elements := strings.Split(headers[len(headers)-1], ",")
+	for _, pair := range strings.Split(elements[len(elements)-1], ";") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) > 6 && strings.EqualFold(pair[:6], "proto=") {
+			return strings.Trim(pair[6:], `"`)
+		}
+	}
+	return ""
+}
+
+// RequireHTTPS is the middleware rejecting the requests not received over TLS with an invalid_request error,
+// as required for the token endpoint by RFC 6749 §3.2. The resolver trusts the scheme forwarded by its proxies.
+func RequireHTTPS(resolver *ClientIPResolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !resolver.IsSecure(r) {
+				renderJSON(w, ErrorResponse{Error: TokenInvalidRequest, Description: "HTTPS is required"}, true, http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkHTTPS renders invalid_request and returns false when RequireHTTPS is set and the request is not secure
+func (bs *BearerServer) checkHTTPS(w http.ResponseWriter, r *http.Request) bool {
+	if bs.RequireHTTPS && !bs.ClientIPResolver.IsSecure(r) {
+		bs.renderError(w, r, TokenInvalidRequest, "HTTPS is required", "", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsSecure(t *testing.T) {
+	resolver, _ := NewClientIPResolver("10.0.0.0/8")
+	tests := []struct {
+		remote  string
+		headers map[string]string
+		tls     bool
+		secure  bool
+	}{
+		{"203.0.113.7:1234", nil, true, true},
+		{"203.0.113.7:1234", map[string]string{"X-Forwarded-Proto": "https"}, false, false},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "https"}, false, true},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "https, http"}, false, false},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=203.0.113.7;proto=https`}, false, true},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=203.0.113.7;proto=https, for=10.0.0.2;proto=http`, "X-Forwarded-Proto": "https"}, false, false},
+		{"10.0.0.1:1234", nil, false, false},
+	}
+	for i, test := range tests {
+		r := httptest.NewRequest("POST", "/token", nil)
+		r.RemoteAddr = test.remote
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		if !test.tls {
+			r.TLS = nil
+		} else {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if secure := resolver.IsSecure(r); secure != test.secure {
+			t.Fatalf("Error test %d secure = %v", i, secure)
+		}
+	}
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RequireHTTPS = true
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+	post := func(secure bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secure {
+			req.TLS = &tls.ConnectionState{}
+		}
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+	if w := post(false); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "HTTPS is required") {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := post(true); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	handler := RequireHTTPS(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/resource", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
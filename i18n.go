@@ -0,0 +1,93 @@
+package oauth
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Translator localizes the error description to the first supported language, languages are the lowercased tags of
+// the Accept-Language header by decreasing preference. It returns the description unchanged when no language is supported.
+type Translator func(description string, languages []string) string
+
+// MessageCatalog maps the English error descriptions to their translation
+type MessageCatalog map[string]string
+
+// RegisterCatalog registers the translations of the error descriptions to the language tag (e.g. "fr" or "pt-br").
+// Catalogs must be registered before serving requests.
+func (bs *BearerServer) RegisterCatalog(language string, catalog MessageCatalog) {
+	if bs.catalogs == nil {
+		bs.catalogs = make(map[string]MessageCatalog)
+	}
+	bs.catalogs[strings.ToLower(language)] = catalog
+}
+
+// translate localizes the description with the Translator or the registered catalogs, falling back to English
+func (bs *BearerServer) translate(description, acceptLanguage string) string {
+	if description == "" || acceptLanguage == "" || (bs.Translator == nil && len(bs.catalogs) == 0) {
+		return description
+	}
+	languages := acceptedLanguages(acceptLanguage)
+	if bs.Translator != nil {
+		return bs.Translator(description, languages)
+	}
+	for _, language := range languages {
+		if language == "en" || strings.HasPrefix(language, "en-") {
+			return description
+		}
+		if translated, ok := bs.catalogs[language][description]; ok {
+			return translated
+		}
+		if i := strings.IndexByte(language, '-'); i > 0 {
+			if translated, ok := bs.catalogs[language[:i]][description]; ok {
+				return translated
+			}
+		}
+	}
+	return description
+}
+
+// acceptedLanguages parses the Accept-Language header returning the language tags by decreasing quality
+func acceptedLanguages(header string) []string {
+	var languages []string
+	for _, tag := range acceptedValues(header) {
+		if tag != "*" {
+			languages = append(languages, tag)
+		}
+	}
+	return languages
+}
+
+// acceptedValues parses an Accept or Accept-Language header returning its lowercased values by decreasing quality
+func acceptedValues(header string) []string {
+	type weighted struct {
+		value string
+		q     float64
+	}
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, weighted{value: value, q: q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	values := make([]string, len(accepted))
+	for i, a := range accepted {
+		values[i] = a.value
+	}
+	return values
+}
@@ -0,0 +1,51 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	got := acceptedLanguages("en;q=0.5, fr-CA, *;q=0.1, de;q=0, pt-BR;q=0.8")
+	if want := []string{"fr-ca", "pt-br", "en"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Error languages = %v, want %v", got, want)
+	}
+}
+
+func TestLocalizedErrorDescription(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RegisterCatalog("fr", MessageCatalog{"invalid username or password": "nom d'utilisateur ou mot de passe invalide"})
+
+	post := func(acceptLanguage string) string {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"wrong"}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Error StatusCode = %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	if body := post("fr-CA, en;q=0.8"); !strings.Contains(body, "mot de passe invalide") {
+		t.Fatalf("Error body = %s", body)
+	}
+	if body := post("en, fr;q=0.8"); !strings.Contains(body, "invalid username or password") {
+		t.Fatalf("Error body = %s", body)
+	}
+	if body := post("ja"); !strings.Contains(body, "invalid username or password") {
+		t.Fatalf("Error body = %s", body)
+	}
+
+	sut.Translator = func(description string, languages []string) string { return languages[0] + ":" + description }
+	if body := post("ja"); !strings.Contains(body, "ja:invalid username or password") {
+		t.Fatalf("Error body = %s", body)
+	}
+}
@@ -0,0 +1,126 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyWindow is the replay window of the token responses when BearerServer.IdempotencyWindow is not set.
+const DefaultIdempotencyWindow = 10 * time.Minute
+
+// IdempotencyKeyHeader is the request header identifying the retries of a token request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentResponse is the token response cached for an Idempotency-Key.
+type IdempotentResponse struct {
+	// Fingerprint identifies the request parameters and credentials the response was issued to
+	Fingerprint string
+	Response    *TokenResponse
+}
+
+// IdempotencyCache stores the token responses replayed to the retried requests.
+type IdempotencyCache interface {
+	// Get returns the response cached for the key
+	Get(key string) (*IdempotentResponse, bool)
+	// Set caches the response for the ttl, keeping the response already cached for the key
+	Set(key string, resp *IdempotentResponse, ttl time.Duration)
+}
+
+// MemoryIdempotencyCache is an in-memory IdempotencyCache safe for concurrent use.
+type MemoryIdempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	resp      *IdempotentResponse
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyCache creates an empty MemoryIdempotencyCache.
+func NewMemoryIdempotencyCache() *MemoryIdempotencyCache {
+	return &MemoryIdempotencyCache{entries: make(map[string]idempotencyEntry)}
+}
+
+// Get returns the response cached for the key
+func (c *MemoryIdempotencyCache) Get(key string) (*IdempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !time.Now().Before(e.expiresAt) {
+		return nil, false
+	}
+	return e.resp, true
+}
+
+// Set caches the response for the ttl, keeping the response already cached for the key
+func (c *MemoryIdempotencyCache) Set(key string, resp *IdempotentResponse, ttl time.Duration) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && now.Before(e.expiresAt) {
+		return
+	}
+	c.entries[key] = idempotencyEntry{resp: resp, expiresAt: now.Add(ttl)}
+}
+
+// PurgeExpired removes the responses expired before now, returning how many were removed
+func (c *MemoryIdempotencyCache) PurgeExpired(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// idempotent replays the successful token response cached for the Idempotency-Key of the request.
+// The response is replayed only to a request with the same parameters and credentials,
+// a key reused with a different request is rejected.
+func (bs *BearerServer) idempotent(next GrantHandler) GrantHandler {
+	return func(gc *GrantContext) (interface{}, int) {
+		if gc.Request == nil || gc.Request.Header.Get(IdempotencyKeyHeader) == "" {
+			return next(gc)
+		}
+		client := gc.ClientID
+		if client == "" {
+			client = gc.Credential
+		}
+		key := string(gc.GrantType) + "|" + client + "|" + gc.Request.Header.Get(IdempotencyKeyHeader)
+		fingerprint := bs.fingerprint(gc)
+		if cached, ok := bs.IdempotencyCache.Get(key); ok {
+			if !hmac.Equal([]byte(cached.Fingerprint), []byte(fingerprint)) {
+				return ErrorResponse{Error: TokenInvalidRequest, Description: "Idempotency-Key reused with a different request", URI: ""}, http.StatusUnprocessableEntity
+			}
+			return cached.Response, http.StatusOK
+		}
+
+		resp, status := next(gc)
+		if tr, ok := resp.(*TokenResponse); ok && status == http.StatusOK {
+			window := bs.IdempotencyWindow
+			if window == 0 {
+				window = DefaultIdempotencyWindow
+			}
+			bs.IdempotencyCache.Set(key, &IdempotentResponse{Fingerprint: fingerprint, Response: tr}, window)
+		}
+		return resp, status
+	}
+}
+
+// fingerprint returns the HMAC of the request parameters and credentials keyed by the server secret
+func (bs *BearerServer) fingerprint(gc *GrantContext) string {
+	mac := hmac.New(sha256.New, []byte(bs.secret()))
+	for _, v := range []string{string(gc.GrantType), gc.Credential, gc.secret, gc.refreshToken, gc.code, gc.RedirectURI, gc.Scope, gc.Form.Encode()} {
+		mac.Write([]byte(v))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
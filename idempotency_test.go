@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.IdempotencyCache = NewMemoryIdempotencyCache()
+
+	post := func(form url.Values, key string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		var body map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+	status, first := post(form, "k1")
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	status, retry := post(form, "k1")
+	if status != http.StatusOK || retry["access_token"] != first["access_token"] {
+		t.Fatalf("Error response not replayed = %v", retry)
+	}
+	if _, other := post(form, "k2"); other["access_token"] == first["access_token"] {
+		t.Fatalf("Error response replayed for another key")
+	}
+
+	form.Set("scope", "admin")
+	if status, _ = post(form, "k1"); status != http.StatusUnprocessableEntity {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+}
+
+func TestIdempotencyKeyMiddlewares(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.IdempotencyCache = NewMemoryIdempotencyCache()
+	denied := false
+	sut.Use(func(next GrantHandler) GrantHandler {
+		return func(gc *GrantContext) (interface{}, int) {
+			if denied {
+				return ErrorResponse{Error: TokenInvalidGrant, Description: "denied", URI: ""}, http.StatusForbidden
+			}
+			return next(gc)
+		}
+	})
+
+	post := func() int {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		return w.Code
+	}
+	if status := post(); status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	denied = true
+	if status := post(); status != http.StatusForbidden {
+		t.Fatalf("Error replay bypassed the middleware, StatusCode = %d", status)
+	}
+}
@@ -0,0 +1,97 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ActorClaim is the claim identifying the party acting on behalf of the subject (RFC 8693 §4.1).
+const ActorClaim = "act"
+
+// serverClaims are the claims set by the server rather than by the verifier, kept when RefreshClaims replaces the claims
+var serverClaims = []string{ActorClaim, ACRClaim, AMRClaim}
+
+// keepServerClaims returns a copy of the claims with the server claims of old, the verifier claims are not modified
+func keepServerClaims(claims, old Claims) Claims {
+	kept := Claims{}
+	for k, v := range claims {
+		kept[k] = v
+	}
+	for _, k := range serverClaims {
+		if v, ok := old[k]; ok {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// ImpersonationVerifier defines the optional interface authorizing the impersonations
+type ImpersonationVerifier interface {
+	// ValidateImpersonation returns an error if the admin is not allowed to act as the target with the scope
+	ValidateImpersonation(adminCredential, targetCredential, scope string) error
+}
+
+// ErrImpersonationNotAllowed is returned by ImpersonateToken when the verifier does not implement ImpersonationVerifier.
+var ErrImpersonationNotAllowed = errors.New("impersonation requires an ImpersonationVerifier")
+
+// IssueError is returned by IssueToken and ImpersonateToken when the token pipeline fails with an error response,
+// e.g. a failing StoreTokenID or TokenStore, keeping the response and the HTTP status the grants would render.
+type IssueError struct {
+	Response   ErrorResponse
+	StatusCode int
+}
+
+func (e *IssueError) Error() string {
+	return e.Response.Description
+}
+
+// IssueToken mints the tokens of the credential outside of an HTTP request (background jobs, migrations), running
+// the same pipeline as the grants: the verifier AddClaims, the claims merged over them, StoreTokenID, the TokenStore
+// and the formatter. The verifier methods receive an empty request carrying the context.
+func (bs *BearerServer) IssueToken(ctx context.Context, tokenType TokenType, credential, scope string, claims Claims) (*TokenResponse, error) {
+	return bs.mintTokens(new(http.Request).WithContext(ctx), tokenType, credential, scope, func(c Claims) error {
+		return c.Merge(claims)
+	})
+}
+
+// ImpersonateToken mints the user tokens of the target credential for the admin: the claims carry the admin
+// as actor ({"act": {"sub": admin}}, nesting the actor of the target claims) so the actions remain attributable.
+// The impersonation must be authorized by the verifier, which must implement ImpersonationVerifier.
+func (bs *BearerServer) ImpersonateToken(adminCredential, targetCredential, scope string) (*TokenResponse, error) {
+	v, ok := optionalVerifier(bs.verifier).(ImpersonationVerifier)
+	if !ok {
+		return nil, ErrImpersonationNotAllowed
+	}
+	if err := v.ValidateImpersonation(adminCredential, targetCredential, scope); err != nil {
+		return nil, err
+	}
+	return bs.mintTokens(new(http.Request), UserToken, targetCredential, scope, func(c Claims) error {
+		act := map[string]interface{}{"sub": adminCredential}
+		if previous, ok := c[ActorClaim]; ok {
+			act[ActorClaim] = previous
+		}
+		c[ActorClaim] = act
+		return nil
+	})
+}
+
+// mintTokens generates the tokens, lets edit update the claims, then stores and crypts the tokens
+func (bs *BearerServer) mintTokens(r *http.Request, tokenType TokenType, credential, scope string, edit func(Claims) error) (*TokenResponse, error) {
+	token, refresh, err := bs.generateTokens(tokenType, credential, "", scope, r)
+	if err != nil {
+		return nil, err
+	}
+	if token.Claims == nil {
+		token.Claims = Claims{}
+	}
+	if err = edit(token.Claims); err != nil {
+		return nil, err
+	}
+	refresh.Claims = token.Claims
+	resp, status := bs.storeAndCryptTokens(token, refresh, r, nil)
+	if e, ok := resp.(ErrorResponse); ok {
+		return nil, &IssueError{Response: e, StatusCode: status}
+	}
+	return resp.(*TokenResponse), nil
+}
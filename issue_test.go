@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIssueToken(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "read", Claims{"job": "migration", "customer_id": "2002"})
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	token, err := _mut.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if token.Credential != "user111" || token.Scope != "read" || token.Claims["job"] != "migration" || token.Claims["customer_id"] != "2002" {
+		t.Fatalf("Error token = %+v", token)
+	}
+	refresh, err := sut.provider.DecryptRefreshTokens(resp.RefreshToken)
+	if err != nil || refresh.Claims["job"] != "migration" {
+		t.Fatalf("Error refresh token = %+v, %v", refresh, err)
+	}
+
+	if _, err = sut.IssueToken(context.Background(), UserToken, "user111", "", Claims{"exp": 0}); err != ErrReservedClaim {
+		t.Fatalf("Error should be ErrReservedClaim: %v", err)
+	}
+
+	failing := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, &flakyVerifier{failures: 1}, nil)
+	var ie *IssueError
+	if _, err = failing.IssueToken(context.Background(), UserToken, "user111", "read", nil); !errors.As(err, &ie) ||
+		ie.StatusCode != http.StatusInternalServerError || ie.Response.Error != TokenServerError {
+		t.Fatalf("Error should be an IssueError: %v", err)
+	}
+}
+
+type impersonationVerifier struct {
+	TestUserVerifier
+}
+
+func (impersonationVerifier) ValidateImpersonation(adminCredential, targetCredential, scope string) error {
+	if adminCredential != "support01" {
+		return errors.New("not a support agent")
+	}
+	return nil
+}
+
+func TestImpersonateToken(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(impersonationVerifier), nil)
+	resp, err := sut.ImpersonateToken("support01", "user111", "read")
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	token, err := _mut.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if actor, _ := token.Claims.Lookup("act.sub"); token.Credential != "user111" || actor != "support01" {
+		t.Fatalf("Error token = %+v", token)
+	}
+
+	if _, err = sut.ImpersonateToken("user222", "user111", "read"); err == nil {
+		t.Fatalf("Error impersonation not authorized")
+	}
+
+	sut = NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	if _, err = sut.ImpersonateToken("support01", "user111", "read"); err != ErrImpersonationNotAllowed {
+		t.Fatalf("Error should be ErrImpersonationNotAllowed: %v", err)
+	}
+}
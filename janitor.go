@@ -0,0 +1,111 @@
+package oauth
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultJanitorInterval is the default interval between two Janitor runs.
+const DefaultJanitorInterval = time.Minute
+
+// Purger is implemented by the stores removing their expired entries
+// (MemoryTokenStore, MemoryReplayCache, Denylist).
+type Purger interface {
+	// PurgeExpired removes the entries expired before now, returning how many were removed
+	PurgeExpired(now time.Time) int
+}
+
+// JanitorStats reports the activity of the Janitor.
+type JanitorStats struct {
+	Runs    int64
+	Purged  int64
+	LastRun time.Time
+}
+
+// Janitor periodically purges the expired entries of the registered stores.
+type Janitor struct {
+	// Interval between two runs, DefaultJanitorInterval when 0
+	Interval time.Duration
+	// Jitter randomizes each interval by up to the fraction of Interval, so the janitors of a cluster don't run in lockstep
+	Jitter float64
+	// OnPurge, when set, is called after each store purge with the store name and the number of entries removed
+	OnPurge func(name string, purged int)
+
+	mu      sync.Mutex
+	names   []string
+	purgers []Purger
+	runs    int64
+	purged  int64
+	lastRun int64
+}
+
+// NewJanitor creates a Janitor running at the interval with a 10% jitter.
+func NewJanitor(interval time.Duration) *Janitor {
+	return &Janitor{Interval: interval, Jitter: 0.1}
+}
+
+// Add registers the store under the name used by OnPurge
+func (j *Janitor) Add(name string, p Purger) {
+	j.mu.Lock()
+	j.names = append(j.names, name)
+	j.purgers = append(j.purgers, p)
+	j.mu.Unlock()
+}
+
+// PurgeOnce purges all the registered stores, returning the number of entries removed
+func (j *Janitor) PurgeOnce(now time.Time) int {
+	j.mu.Lock()
+	names, purgers := j.names, j.purgers
+	j.mu.Unlock()
+	total := 0
+	for i, p := range purgers {
+		n := p.PurgeExpired(now)
+		total += n
+		if j.OnPurge != nil {
+			j.OnPurge(names[i], n)
+		}
+	}
+	atomic.AddInt64(&j.runs, 1)
+	atomic.AddInt64(&j.purged, int64(total))
+	atomic.StoreInt64(&j.lastRun, now.UnixNano())
+	return total
+}
+
+// Run purges the stores at each interval until the context is done, returning the context error
+func (j *Janitor) Run(ctx context.Context) error {
+	for {
+		timer := time.NewTimer(j.nextInterval())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case now := <-timer.C:
+			j.PurgeOnce(now)
+		}
+	}
+}
+
+// Stats returns the activity of the Janitor
+func (j *Janitor) Stats() JanitorStats {
+	stats := JanitorStats{Runs: atomic.LoadInt64(&j.runs), Purged: atomic.LoadInt64(&j.purged)}
+	if last := atomic.LoadInt64(&j.lastRun); last != 0 {
+		stats.LastRun = time.Unix(0, last)
+	}
+	return stats
+}
+
+// nextInterval returns the interval randomized by the jitter
+func (j *Janitor) nextInterval() time.Duration {
+	interval := j.Interval
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+	if j.Jitter > 0 {
+		delta := float64(interval) * j.Jitter
+		interval += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	return interval
+}
@@ -0,0 +1,49 @@
+package oauth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJanitorPurgeOnce(t *testing.T) {
+	tokens := NewMemoryTokenStore()
+	replay := NewMemoryReplayCache()
+	_ = tokens.SaveToken(&TokenRecord{ID: "r1", ExpiresAt: time.Now().Add(-time.Minute)})
+	_ = replay.Consume("jti", -time.Minute)
+
+	j := NewJanitor(time.Minute)
+	j.Add("tokens", tokens)
+	j.Add("replay", replay)
+	purged := map[string]int{}
+	j.OnPurge = func(name string, n int) { purged[name] = n }
+
+	if n := j.PurgeOnce(time.Now()); n != 2 || purged["tokens"] != 1 || purged["replay"] != 1 {
+		t.Fatalf("Error purged = %d, %v", n, purged)
+	}
+	if stats := j.Stats(); stats.Runs != 1 || stats.Purged != 2 || stats.LastRun.IsZero() {
+		t.Fatalf("Error stats = %+v", stats)
+	}
+}
+
+func TestJanitorRun(t *testing.T) {
+	d := NewDenylist(10, 0.01)
+	d.Add("jti", time.Now().Add(-time.Minute))
+	j := NewJanitor(5 * time.Millisecond)
+	j.Add("denylist", d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- j.Run(ctx) }()
+	deadline := time.Now().Add(time.Second)
+	for d.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Error %v", err)
+	}
+	if d.Len() != 0 {
+		t.Fatalf("Error denylist not purged")
+	}
+}
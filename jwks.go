@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrUnsupportedKey is returned by JWK.PublicKey for the key types and curves not supported.
+var ErrUnsupportedKey = errors.New("unsupported JSON web key")
+
+// JWK is a public JSON Web Key, see https://datatracker.ietf.org/doc/html/rfc7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set, served to the resource servers verifying the signed tokens
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key, base64url encoded
+func (k JWK) Thumbprint() string {
+	// the required members in lexicographic order
+	var b []byte
+	switch k.Kty {
+	case "RSA":
+		b, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N})
+	case "EC":
+		b, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y})
+	default:
+		b, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X})
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicKey returns the ed25519.PublicKey, *rsa.PublicKey or *ecdsa.PublicKey of the key
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+	switch k.Kty {
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key", ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: invalid RSA exponent", ErrUnsupportedKey)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < MinRSAKeyBits {
+			return nil, fmt.Errorf("%w: RSA key of %d bits", ErrUnsupportedKey, key.N.BitLen())
+		}
+		return key, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		y, err := enc.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("%w: point not on curve", ErrUnsupportedKey)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: key type %q", ErrUnsupportedKey, k.Kty)
+}
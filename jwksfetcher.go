@@ -0,0 +1,240 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JWKSFetcher defaults
+const (
+	DefaultJWKSRefreshInterval = time.Hour
+	DefaultJWKSMinRefetch      = time.Minute
+	DefaultJWKSTimeout         = 10 * time.Second
+	DefaultMaxJWKSSize         = 1 << 20
+)
+
+// ErrUnknownKey is returned by the JWKSFetcher when the key id is not in the JWKS, even after a refetch.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKSFetcher caches the JWKS of an external issuer to verify the JWTs it signs. Run refreshes the keys in the
+// background, a key id missing from the cache triggers a refetch rate limited to one per MinRefetchInterval, so the
+// key rotations of the issuer are picked up without outage, and the failed fetches keep the stale keys.
+// The concurrent fetches are deduplicated and run without blocking the callers of the cached keys.
+type JWKSFetcher struct {
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Timeout bounds each fetch, DefaultJWKSTimeout when 0. The fetches are shared by the concurrent callers and not
+	// cancelled by their contexts, the callers stop waiting when their context is done.
+	Timeout time.Duration
+	// MaxSize bounds the size of the JWKS document, DefaultMaxJWKSSize when 0
+	MaxSize int64
+	// RefreshInterval is the period of the background refresh of Run, DefaultJWKSRefreshInterval when 0
+	RefreshInterval time.Duration
+	// MinRefetchInterval is the minimum delay between the refetches triggered by unknown key ids, DefaultJWKSMinRefetch when 0
+	MinRefetchInterval time.Duration
+	// OnError, when set, is called when a fetch fails
+	OnError func(err error)
+
+	keys atomic.Value // map[string]crypto.PublicKey, read without lock
+
+	mu          sync.Mutex
+	call        *jwksCall // the fetch in flight
+	etag        string
+	lastAttempt time.Time
+	fetchedAt   time.Time
+}
+
+// jwksCall is a fetch in flight the concurrent callers wait for
+type jwksCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKSFetcher creates a JWKSFetcher of the JWKS URL, the keys are fetched on first use or by Run
+func NewJWKSFetcher(url string) *JWKSFetcher {
+	return &JWKSFetcher{URL: url}
+}
+
+// Key returns the public key of the key id, refetching the JWKS when the key id is unknown
+func (f *JWKSFetcher) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := f.cached()[kid]; ok {
+		return key, nil
+	}
+	minRefetch := f.MinRefetchInterval
+	if minRefetch <= 0 {
+		minRefetch = DefaultJWKSMinRefetch
+	}
+	f.mu.Lock()
+	// another caller may have fetched the key while this one waited
+	if key, ok := f.cached()[kid]; ok {
+		f.mu.Unlock()
+		return key, nil
+	}
+	if f.call == nil && time.Since(f.lastAttempt) < minRefetch {
+		f.mu.Unlock()
+		return nil, ErrUnknownKey
+	}
+	f.mu.Unlock()
+	if err := f.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := f.cached()[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Refresh fetches the JWKS, the current keys are kept when it fails
+func (f *JWKSFetcher) Refresh(ctx context.Context) error {
+	return f.fetch(ctx)
+}
+
+// FetchedAt returns the time of the last successful fetch, zero before the first one
+func (f *JWKSFetcher) FetchedAt() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetchedAt
+}
+
+// Run refreshes the keys every RefreshInterval until the context is done, e.g. with AddBackgroundTask
+func (f *JWKSFetcher) Run(ctx context.Context) error {
+	interval := f.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultJWKSRefreshInterval
+	}
+	_ = f.Refresh(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_ = f.Refresh(ctx)
+		}
+	}
+}
+
+func (f *JWKSFetcher) cached() map[string]crypto.PublicKey {
+	keys, _ := f.keys.Load().(map[string]crypto.PublicKey)
+	return keys
+}
+
+// fetch replaces the keys with the ones of the JWKS, or waits for the fetch in flight. The lock is not held during
+// the download.
+func (f *JWKSFetcher) fetch(ctx context.Context) error {
+	f.mu.Lock()
+	call := f.call
+	if call == nil {
+		call = &jwksCall{done: make(chan struct{})}
+		f.call = call
+		f.lastAttempt = time.Now()
+		etag := f.etag
+		f.mu.Unlock()
+		timeout := f.Timeout
+		if timeout <= 0 {
+			timeout = DefaultJWKSTimeout
+		}
+		go func() {
+			fetchCtx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			err := f.download(fetchCtx, etag)
+			if err != nil && f.OnError != nil {
+				f.OnError(err)
+			}
+			f.mu.Lock()
+			call.err, f.call = err, nil
+			f.mu.Unlock()
+			close(call.done)
+		}()
+	} else {
+		f.mu.Unlock()
+	}
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// download fetches the JWKS, revalidated with the ETag of the previous fetch
+func (f *JWKSFetcher) download(ctx context.Context, etag string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" && f.cached() != nil {
+		req.Header.Set("If-None-Match", etag)
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		f.mu.Lock()
+		f.fetchedAt = time.Now()
+		f.mu.Unlock()
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("jwks: unexpected status %d", resp.StatusCode)
+	}
+	maxSize := f.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxJWKSSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	if int64(len(b)) > maxSize {
+		return fmt.Errorf("jwks: document exceeds %d bytes", maxSize)
+	}
+	var jwks JWKS
+	if err = json.Unmarshal(b, &jwks); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// the keys of the unsupported types are skipped
+			continue
+		}
+		kid := jwk.Kid
+		if kid == "" {
+			kid = jwk.Thumbprint()
+		}
+		keys[kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks: no supported signing key")
+	}
+	f.mu.Lock()
+	f.keys.Store(keys)
+	f.etag = resp.Header.Get("ETag")
+	f.fetchedAt = time.Now()
+	f.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,147 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJWKPublicKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	enc := base64.RawURLEncoding
+	rsaJWK := JWK{Kty: "RSA", N: enc.EncodeToString(rsaKey.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())}
+	if key, err := rsaJWK.PublicKey(); err != nil || !rsaKey.PublicKey.Equal(key) {
+		t.Fatalf("Error RSA key = %v, %v", key, err)
+	}
+	ecJWK := JWK{Kty: "EC", Crv: "P-256", X: enc.EncodeToString(ecKey.X.Bytes()), Y: enc.EncodeToString(ecKey.Y.Bytes())}
+	if key, err := ecJWK.PublicKey(); err != nil || !ecKey.PublicKey.Equal(key) {
+		t.Fatalf("Error EC key = %v, %v", key, err)
+	}
+	if _, err := (JWK{Kty: "oct"}).PublicKey(); err == nil {
+		t.Fatalf("Error should have occurred")
+	}
+	weakKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	weakJWK := JWK{Kty: "RSA", N: enc.EncodeToString(weakKey.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(weakKey.E)).Bytes())}
+	if _, err := weakJWK.PublicKey(); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("Error the 1024 bits RSA key should be rejected: %v", err)
+	}
+}
+
+func TestJWKSFetcher(t *testing.T) {
+	pub1, _, _ := ed25519.GenerateKey(rand.Reader)
+	issuer := NewEd25519TokenVerifier(pub1)
+	kid1 := okpJWK(pub1).Thumbprint()
+	var fetches, failing int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		issuer.ServeJWKS(w, r)
+	}))
+	defer ts.Close()
+
+	f := NewJWKSFetcher(ts.URL)
+	f.MinRefetchInterval = 50 * time.Millisecond
+	ctx := context.Background()
+	if key, err := f.Key(ctx, kid1); err != nil || !pub1.Equal(key) {
+		t.Fatalf("Error key = %v, %v", key, err)
+	}
+	if _, err := f.Key(ctx, "unknown"); err != ErrUnknownKey || atomic.LoadInt32(&fetches) != 1 {
+		t.Fatalf("Error the refetch should be rate limited: %v, fetches = %d", err, fetches)
+	}
+
+	pub2, _, _ := ed25519.GenerateKey(rand.Reader)
+	kid2 := issuer.AddVerificationKey(pub2)
+	time.Sleep(60 * time.Millisecond)
+	if key, err := f.Key(ctx, kid2); err != nil || !pub2.Equal(key) {
+		t.Fatalf("Error the rotated key should be fetched: %v", err)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	if err := f.Refresh(ctx); err == nil {
+		t.Fatalf("Error should have occurred")
+	}
+	if _, err := f.Key(ctx, kid1); err != nil {
+		t.Fatalf("Error the stale keys should be kept: %v", err)
+	}
+	atomic.StoreInt32(&failing, 0)
+	before := f.FetchedAt()
+	if err := f.Refresh(ctx); err != nil || !f.FetchedAt().After(before) {
+		t.Fatalf("Error the not modified JWKS should refresh: %v", err)
+	}
+}
+
+func TestJWKSFetcherConcurrent(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	issuer := NewEd25519TokenVerifier(pub)
+	kid := okpJWK(pub).Thumbprint()
+	var fetches int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		issuer.ServeJWKS(w, r)
+	}))
+	defer ts.Close()
+
+	f := NewJWKSFetcher(ts.URL)
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := f.Key(context.Background(), kid)
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		_ = f.FetchedAt()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Error the fetcher is locked during the fetch")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.Key(ctx, kid); err != context.DeadlineExceeded {
+		t.Fatalf("Error the caller should stop waiting at its deadline: %v", err)
+	}
+	close(release)
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("Error fetches = %d", n)
+	}
+}
+
+func TestJWKSFetcherMaxSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys": [` + strings.Repeat(" ", 2048) + `]}`))
+	}))
+	defer ts.Close()
+	f := NewJWKSFetcher(ts.URL)
+	f.MaxSize = 1024
+	if err := f.Refresh(context.Background()); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("Error the oversized JWKS should be rejected: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers the hashes of the RS256, PS256 and ES256 algorithms
+	_ "crypto/sha512" // registers the hashes of the 384 and 512 algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DefaultJWTAlgorithms are the JWS algorithms accepted from a TrustedIssuer when its Algorithms are empty,
+// the symmetric and "none" algorithms are never accepted.
+var DefaultJWTAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", EdDSA}
+
+// DefaultKeyTimeout bounds the key resolution of a TrustedIssuer token when its KeyTimeout is 0
+const DefaultKeyTimeout = 5 * time.Second
+
+// MinRSAKeyBits is the minimum size of the RSA keys verifying the JWTs, the smaller keys are rejected
+const MinRSAKeyBits = 2048
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// KeyResolver returns the verification key of a key id, implemented by the JWKSFetcher and StaticKeys
+type KeyResolver interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeys is the KeyResolver of a fixed set of keys by key id, the single key also verifies the tokens without kid
+type StaticKeys map[string]crypto.PublicKey
+
+// Key returns the key of the key id
+func (k StaticKeys) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(k) == 1 {
+		for _, key := range k {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// TrustedIssuer configures the acceptance by the BearerAuthentication middleware of the JWT access tokens signed by
+// an external issuer, e.g. during a migration from another identity provider. The accepted tokens have the
+// ExternalCredential of their "iss" and "sub" claims as credential, so the subjects of an issuer cannot impersonate the
+// users of this server or of another issuer, the "scope" (or "scp") claim as scope and the UserToken type.
+type TrustedIssuer struct {
+	// Issuer is the "iss" claim of the tokens
+	Issuer string
+	// Keys resolves the verification keys, a JWKSFetcher of the issuer JWKS URI or StaticKeys
+	Keys KeyResolver
+	// Audience must be contained in the "aud" claim, required so the tokens issued for other services are not replayed
+	Audience string
+	// Algorithms are the accepted JWS algorithms, DefaultJWTAlgorithms when empty
+	Algorithms []string
+	// Leeway tolerates the clock skew with the issuer on the "exp" and "nbf" claims
+	Leeway time.Duration
+	// KeyTimeout bounds the resolution of the verification key, e.g. the JWKS refetch of an unknown key id,
+	// DefaultKeyTimeout when 0
+	KeyTimeout time.Duration
+}
+
+// ExternalCredential returns the credential of the tokens of a TrustedIssuer: "{iss}#{sub}"
+func ExternalCredential(issuer, subject string) string {
+	return issuer + "#" + subject
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// validateJWT verifies the JWT of a trusted issuer and returns it as a Token with the ExternalCredential
+func (ba *BearerAuthentication) validateJWT(ctx context.Context, raw string) (*Token, error) {
+	token, err := verifyJWT(ctx, raw, ba.trustedIssuer)
+	if err != nil {
+		return nil, err
+	}
+	iss, _ := token.Claims.GetString("iss")
+	token.Credential = ExternalCredential(iss, token.Credential)
+	return token, nil
+}
+
+// trustedJWT reports whether the JWT is to be verified as the token of a TrustedIssuer: the tokens signed with a key of
+// the provider, such as the compact JWS of the Ed25519TokenSecureFormatter, and the tokens of the other issuers are
+// decrypted by the provider
+func (ba *BearerAuthentication) trustedJWT(raw string) bool {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	enc := base64.RawURLEncoding
+	var header jwtHeader
+	if b, err := enc.DecodeString(parts[0]); err == nil && json.Unmarshal(b, &header) == nil && header.Kid != "" {
+		if f, ok := ba.provider.secureFormatter.(*Ed25519TokenSecureFormatter); ok {
+			if _, own := f.verificationKeys()[header.Kid]; own {
+				return false
+			}
+		}
+	}
+	var claims struct {
+		Iss string `json:"iss"`
+	}
+	b, err := enc.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(b, &claims) != nil {
+		return false
+	}
+	return ba.trustedIssuer(claims.Iss) != nil
+}
+
+// verifyJWT verifies the JWT of the TrustedIssuer returned by issuerOf for its iss claim and returns it as a Token
+func verifyJWT(ctx context.Context, raw string, issuerOf func(iss string) *TrustedIssuer) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	enc := base64.RawURLEncoding
+	var header jwtHeader
+	if b, err := enc.DecodeString(parts[0]); err != nil || json.Unmarshal(b, &header) != nil {
+		return nil, fmt.Errorf("%w: invalid JWT header", ErrMalformedToken)
+	}
+	payload, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	var claims Claims
+	d := json.NewDecoder(strings.NewReader(string(payload)))
+	d.UseNumber()
+	if err = d.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	iss, _ := claims.GetString("iss")
+	issuer := issuerOf(iss)
+	if issuer == nil {
+		return nil, fmt.Errorf("%w: untrusted issuer %q", ErrMalformedToken, iss)
+	}
+	if !issuer.allows(header.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed", ErrMalformedToken, header.Alg)
+	}
+	if issuer.Audience == "" {
+		return nil, fmt.Errorf("%w: the TrustedIssuer %q has no Audience", ErrInvalidAudience, iss)
+	}
+	timeout := issuer.KeyTimeout
+	if timeout <= 0 {
+		timeout = DefaultKeyTimeout
+	}
+	keyCtx, cancel := context.WithTimeout(ctx, timeout)
+	key, err := issuer.Keys.Key(keyCtx, header.Kid)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	signature, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if err = verifyJWS(header.Alg, key, []byte(raw[:len(parts[0])+1+len(parts[1])]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+
+	now := time.Now().UTC()
+	exp, hasExp := claims.GetInt64("exp")
+	if !hasExp {
+		return nil, fmt.Errorf("%w: missing exp claim", ErrMalformedToken)
+	}
+	expiresAt := time.Unix(exp, 0).Add(issuer.Leeway)
+	if nbf, ok := claims.GetInt64("nbf"); ok && now.Add(issuer.Leeway).Before(time.Unix(nbf, 0)) {
+		return nil, fmt.Errorf("%w: token not yet valid", ErrMalformedToken)
+	}
+	if !now.Before(expiresAt) {
+		return nil, ErrExpiredToken
+	}
+	issuedAt := now
+	if iat, ok := claims.GetInt64("iat"); ok && time.Unix(iat, 0).Before(expiresAt) {
+		issuedAt = time.Unix(iat, 0).UTC()
+	}
+	token := &Token{TokenType: UserToken, CreationDate: issuedAt, ExpiresIn: expiresAt.Sub(issuedAt), Claims: claims}
+	token.ID, _ = claims.GetString("jti")
+	token.Credential, _ = claims.GetString("sub")
+	if scope, ok := claims.GetString("scope"); ok {
+		token.Scope = scope
+	} else if scp, ok := claims.GetStrings("scp"); ok {
+		token.Scope = strings.Join(scp, " ")
+	}
+	if !token.HasAudience(issuer.Audience) {
+		return nil, ErrInvalidAudience
+	}
+	return token, nil
+}
+
+// trustedIssuer returns the TrustedIssuer of the iss claim, nil when it is not trusted
+func (ba *BearerAuthentication) trustedIssuer(iss string) *TrustedIssuer {
+	for _, issuer := range ba.TrustedIssuers {
+		if iss != "" && issuer.Issuer == iss {
+			return issuer
+		}
+	}
+	return nil
+}
+
+func (i *TrustedIssuer) allows(alg string) bool {
+	algorithms := i.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultJWTAlgorithms
+	}
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyJWS verifies the JWS signature of the signing input with the key of the algorithm
+func verifyJWS(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	if len(alg) < 5 {
+		return errInvalidSignature
+	}
+	var hash crypto.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		if alg != EdDSA || !ed25519.Verify(k, signingInput, signature) {
+			return errInvalidSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		if hash == 0 || k.N.BitLen() < MinRSAKeyBits {
+			return errInvalidSignature
+		}
+		h := hash.New()
+		h.Write(signingInput)
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), signature)
+		case "PS":
+			return rsa.VerifyPSS(k, hash, h.Sum(nil), signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if hash == 0 || alg[:2] != "ES" || len(signature) != 2*size {
+			return errInvalidSignature
+		}
+		h := hash.New()
+		h.Write(signingInput)
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, h.Sum(nil), r, s) {
+			return errInvalidSignature
+		}
+		return nil
+	}
+	return errInvalidSignature
+}
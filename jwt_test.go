@@ -0,0 +1,141 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signJWT signs the claims with the key of the algorithm
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims Claims) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(input))
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		r, s, e := ecdsa.Sign(rand.Reader, k, sum[:])
+		signature, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), e
+	}
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	return input + "." + enc.EncodeToString(signature)
+}
+
+func TestTrustedIssuers(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	jwks := httptest.NewServer(http.HandlerFunc(NewEd25519TokenVerifier(edPub).ServeJWKS))
+	defer jwks.Close()
+
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.TrustedIssuers = []*TrustedIssuer{
+		{Issuer: "https://legacy", Keys: StaticKeys{"rsa": rsaKey.Public(), "ec": ecKey.Public()}, Audience: "orders", Algorithms: []string{"RS256", "ES256"}},
+		{Issuer: "https://idp", Keys: NewJWKSFetcher(jwks.URL), Audience: "orders", Leeway: time.Minute},
+		{Issuer: "https://open", Keys: StaticKeys{"rsa": rsaKey.Public()}},
+	}
+	now := time.Now().Unix()
+	claims := func(iss string, exp int64) Claims {
+		return Claims{"iss": iss, "sub": "user111", "aud": []string{"orders"}, "scope": "read write", "jti": "j1", "iat": now, "exp": exp}
+	}
+
+	for raw, iss := range map[string]string{
+		signJWT(t, "RS256", "rsa", rsaKey, claims("https://legacy", now+60)):                "https://legacy",
+		signJWT(t, "ES256", "ec", ecKey, claims("https://legacy", now+60)):                  "https://legacy",
+		signJWT(t, EdDSA, okpJWK(edPub).Thumbprint(), edKey, claims("https://idp", now-30)): "https://idp",
+	} {
+		token, err := mut.ValidateToken(raw)
+		if err != nil || token.Credential != iss+"#user111" || !token.HasScopes("read", "write") || token.ID != "j1" {
+			t.Fatalf("Error token = %+v, %v", token, err)
+		}
+	}
+
+	for name, c := range map[string]struct {
+		raw      string
+		expected error
+	}{
+		"expired":     {signJWT(t, "RS256", "rsa", rsaKey, claims("https://legacy", now-10)), ErrExpiredToken},
+		"untrusted":   {signJWT(t, "RS256", "rsa", rsaKey, claims("https://other", now+60)), ErrMalformedToken},
+		"algorithm":   {signJWT(t, EdDSA, "rsa", edKey, claims("https://legacy", now+60)), ErrMalformedToken},
+		"key":         {signJWT(t, "RS256", "ec", rsaKey, claims("https://legacy", now+60)), ErrMalformedToken},
+		"audience":    {signJWT(t, "RS256", "rsa", rsaKey, Claims{"iss": "https://legacy", "aud": "billing", "exp": now + 60}), ErrInvalidAudience},
+		"no audience": {signJWT(t, "RS256", "rsa", rsaKey, claims("https://open", now+60)), ErrInvalidAudience},
+	} {
+		if _, err := mut.ValidateToken(c.raw); !errors.Is(err, c.expected) {
+			t.Fatalf("Error %s: %v", name, err)
+		}
+	}
+}
+
+func TestTrustedIssuersWithSignedTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	formatter := NewEd25519TokenSecurityProvider(edKey)
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), formatter)
+	mut := NewBearerAuthentication("mySecretKey-10101", formatter)
+	mut.TrustedIssuers = []*TrustedIssuer{{Issuer: "https://legacy", Keys: StaticKeys{"rsa": rsaKey.Public()}, Audience: "orders"}}
+
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "read", nil)
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if token, err := mut.ValidateToken(resp.Token); err != nil || token.Credential != "user111" {
+		t.Fatalf("Error the server token should be accepted along the TrustedIssuers: %+v, %v", token, err)
+	}
+	now := time.Now().Unix()
+	external := signJWT(t, "RS256", "rsa", rsaKey, Claims{"iss": "https://legacy", "sub": "user111", "aud": "orders", "exp": now + 60})
+	if token, err := mut.ValidateToken(external); err != nil || token.Credential != "https://legacy#user111" {
+		t.Fatalf("Error token = %+v, %v", token, err)
+	}
+	untrusted := signJWT(t, "RS256", "rsa", rsaKey, Claims{"iss": "https://other", "sub": "user111", "aud": "orders", "exp": now + 60})
+	if _, err = mut.ValidateToken(untrusted); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error the token of an untrusted issuer should be rejected: %v", err)
+	}
+}
+
+// blockingKeys is a KeyResolver waiting for the end of the context
+type blockingKeys struct{}
+
+func (blockingKeys) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTrustedIssuerKeyTimeout(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.TrustedIssuers = []*TrustedIssuer{{Issuer: "https://idp", Keys: blockingKeys{}, Audience: "orders", KeyTimeout: 10 * time.Millisecond}}
+	raw := signJWT(t, EdDSA, "k", edKey, Claims{"iss": "https://idp", "sub": "user111", "aud": "orders", "exp": time.Now().Add(time.Minute).Unix()})
+	start := time.Now()
+	if _, err := mut.ValidateToken(raw); !errors.Is(err, ErrMalformedToken) || time.Since(start) > time.Second {
+		t.Fatalf("Error the key resolution should time out: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mut.TrustedIssuers[0].KeyTimeout = time.Hour
+	if _, err := mut.ValidateTokenContext(ctx, raw); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error the key resolution should stop with the request: %v", err)
+	}
+}
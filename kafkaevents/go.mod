@@ -0,0 +1,18 @@
+module github.com/jeffreydwalter/oauth-1/kafkaevents
+
+go 1.17
+
+require (
+	github.com/jeffreydwalter/oauth-1 v0.0.0
+	github.com/segmentio/kafka-go v0.4.38
+)
+
+require (
+	github.com/gofrs/uuid v4.2.0+incompatible // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
+
+replace github.com/jeffreydwalter/oauth-1 => ../
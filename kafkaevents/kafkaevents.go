@@ -0,0 +1,54 @@
+// Package kafkaevents delivers the oauth token lifecycle events to a Kafka topic.
+//
+// The events are JSON encoded, keyed by credential so the events of a user or client keep their order,
+// and carry their type in the "event-type" header.
+package kafkaevents
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jeffreydwalter/oauth-1"
+	"github.com/segmentio/kafka-go"
+)
+
+// TypeHeader is the header carrying the event type
+const TypeHeader = "event-type"
+
+// Writer is the subset of the Kafka writer used by the Sink, implemented by *kafka.Writer.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Sink is an oauth.EventSink writing the events to Kafka.
+type Sink struct {
+	writer Writer
+}
+
+// New creates a Sink over the writer, the topic is the one configured in the writer.
+func New(writer Writer) *Sink {
+	return &Sink{writer: writer}
+}
+
+// NewPublisher creates an oauth.AsyncPublisher buffering up to bufferSize events for the writer.
+func NewPublisher(writer Writer, bufferSize int) *oauth.AsyncPublisher {
+	return oauth.NewAsyncPublisher(New(writer), bufferSize)
+}
+
+// PublishEvents writes the events in a single call
+func (s *Sink) PublishEvents(ctx context.Context, events []*oauth.Event) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:     []byte(event.Credential),
+			Value:   value,
+			Headers: []kafka.Header{{Key: TypeHeader, Value: []byte(event.Type)}},
+			Time:    event.Time,
+		})
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
@@ -0,0 +1,31 @@
+package kafkaevents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jeffreydwalter/oauth-1"
+	"github.com/segmentio/kafka-go"
+)
+
+var _ Writer = (*kafka.Writer)(nil)
+
+type fakeWriter struct {
+	msgs []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestPublishEvents(t *testing.T) {
+	writer := new(fakeWriter)
+	events := []*oauth.Event{{ID: "e1", Type: oauth.TokenIssuedEvent, Credential: "user111"}}
+	if err := New(writer).PublishEvents(context.Background(), events); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if len(writer.msgs) != 1 || string(writer.msgs[0].Key) != "user111" || string(writer.msgs[0].Headers[0].Value) != "token.issued" {
+		t.Fatalf("Error messages = %+v", writer.msgs)
+	}
+}
@@ -0,0 +1,270 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// KubernetesTokenGrant is the assertion grant type exchanging a projected Kubernetes service account token
+const KubernetesTokenGrant GrantType = "urn:ietf:params:oauth:grant-type:kubernetes-service-account"
+
+// Kubernetes in-cluster defaults
+const (
+	DefaultKubernetesAPIServer = "https://kubernetes.default.svc"
+	DefaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// maxTokenReviewSize bounds the TokenReview responses read from the API server
+const maxTokenReviewSize = 1 << 16
+
+var (
+	// ErrUnauthenticatedServiceAccount is returned by the TokenReviewer when the cluster rejects the token.
+	ErrUnauthenticatedServiceAccount = errors.New("service account token not authenticated")
+	// ErrServiceAccountNotAllowed is returned by the KubernetesAssertion when the service account is not mapped.
+	ErrServiceAccountNotAllowed = errors.New("service account not allowed")
+)
+
+// ServiceAccount identifies the Kubernetes service account of a token
+type ServiceAccount struct {
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// String returns the "system:serviceaccount:<namespace>:<name>" user name of the service account
+func (sa *ServiceAccount) String() string {
+	return serviceAccountPrefix + sa.Namespace + ":" + sa.Name
+}
+
+// parseServiceAccount parses the "system:serviceaccount:<namespace>:<name>" user name
+func parseServiceAccount(username string) (*ServiceAccount, bool) {
+	if !strings.HasPrefix(username, serviceAccountPrefix) {
+		return nil, false
+	}
+	parts := strings.Split(username[len(serviceAccountPrefix):], ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, false
+	}
+	return &ServiceAccount{Namespace: parts[0], Name: parts[1]}, true
+}
+
+// TokenReviewer authenticates the service account tokens with the cluster, implemented by KubernetesTokenReview
+type TokenReviewer interface {
+	// ReviewToken returns the service account of the token issued for one of the audiences
+	ReviewToken(ctx context.Context, token string, audiences []string) (*ServiceAccount, error)
+}
+
+// KubernetesTokenReview is the TokenReviewer posting TokenReviews to the Kubernetes API server. The server's own
+// service account needs the system:auth-delegator cluster role (create tokenreviews).
+type KubernetesTokenReview struct {
+	// URL is the API server URL, DefaultKubernetesAPIServer when empty
+	URL string
+	// TokenFile is the file of the token authenticating the reviews, read at each review as the kubelet rotates it,
+	// DefaultKubernetesTokenFile when empty
+	TokenFile string
+	// Client sends the requests, trusting the cluster CA. http.DefaultClient when nil
+	Client *http.Client
+}
+
+type tokenReview struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Spec       tokenReviewSpec   `json:"spec"`
+	Status     tokenReviewStatus `json:"status,omitempty"`
+}
+
+type tokenReviewSpec struct {
+	Token     string   `json:"token"`
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+type tokenReviewStatus struct {
+	Authenticated bool     `json:"authenticated"`
+	Audiences     []string `json:"audiences,omitempty"`
+	User          struct {
+		Username string `json:"username"`
+		UID      string `json:"uid"`
+	} `json:"user"`
+	Error string `json:"error,omitempty"`
+}
+
+// ReviewToken posts the TokenReview of the token and returns its authenticated service account. The audiences the
+// API server reports for the token must contain one of the audiences, an API server ignoring them is rejected.
+func (k *KubernetesTokenReview) ReviewToken(ctx context.Context, token string, audiences []string) (*ServiceAccount, error) {
+	tokenFile := k.TokenFile
+	if tokenFile == "" {
+		tokenFile = DefaultKubernetesTokenFile
+	}
+	bearer, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("tokenreview: %w", err)
+	}
+	body, err := json.Marshal(&tokenReview{
+		APIVersion: "authentication.k8s.io/v1",
+		Kind:       "TokenReview",
+		Spec:       tokenReviewSpec{Token: token, Audiences: audiences},
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := k.URL
+	if url == "" {
+		url = DefaultKubernetesAPIServer
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+"/apis/authentication.k8s.io/v1/tokenreviews", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(bearer)))
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tokenreview: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("tokenreview: unexpected status %d", resp.StatusCode)
+	}
+	var review tokenReview
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxTokenReviewSize)).Decode(&review); err != nil {
+		return nil, fmt.Errorf("tokenreview: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("%w: %s", ErrUnauthenticatedServiceAccount, review.Status.Error)
+		}
+		return nil, ErrUnauthenticatedServiceAccount
+	}
+	sa, ok := parseServiceAccount(review.Status.User.Username)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a service account", ErrUnauthenticatedServiceAccount, review.Status.User.Username)
+	}
+	if len(audiences) > 0 && !containsAny(review.Status.Audiences, audiences) {
+		return nil, fmt.Errorf("%w: the token is not valid for %v", ErrInvalidAudience, audiences)
+	}
+	sa.UID = review.Status.User.UID
+	return sa, nil
+}
+
+// containsAny returns true when the values contain one of the wanted values
+func containsAny(values, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DiscoverIssuer reads the OIDC discovery document of the issuer, e.g. the service account issuer of the cluster,
+// and returns the TrustedIssuer verifying its tokens with the keys of its JWKS URI. client is http.DefaultClient when nil.
+// Set the Audience of the TrustedIssuer before adding it to the TrustedIssuers of a BearerAuthentication.
+func DiscoverIssuer(ctx context.Context, client *http.Client, issuer string) (*TrustedIssuer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery: unexpected status %d", resp.StatusCode)
+	}
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", doc.Issuer, issuer)
+	}
+	if doc.JWKSURI == "" {
+		return nil, errors.New("discovery: missing jwks_uri")
+	}
+	return &TrustedIssuer{Issuer: doc.Issuer, Keys: &JWKSFetcher{URL: doc.JWKSURI, Client: client}}, nil
+}
+
+// KubernetesAssertion is the AssertionGrantHandler of the KubernetesTokenGrant, issuing client tokens to the in-cluster
+// workloads presenting their projected service account token as assertion, without client secret:
+//
+//	bs.RegisterAssertionGrant(oauth.KubernetesTokenGrant, &oauth.KubernetesAssertion{
+//		Reviewer:        &oauth.KubernetesTokenReview{},
+//		Audience:        "oauth-server",
+//		ServiceAccounts: map[string]string{"payments/worker": "payments-client"},
+//	})
+type KubernetesAssertion struct {
+	// Reviewer authenticates the tokens with the TokenReview API, which also rejects the tokens of deleted pods
+	Reviewer TokenReviewer
+	// Issuer verifies the tokens locally with the keys of the cluster issuer (see DiscoverIssuer) when Reviewer is nil
+	Issuer *TrustedIssuer
+	// Audience is the audience the tokens must be projected for, required so the tokens of other services are not replayed
+	Audience string
+	// ServiceAccounts maps the "<namespace>/<name>" of the allowed service accounts to the credential of their tokens,
+	// required so the workloads of the other namespaces get no tokens
+	ServiceAccounts map[string]string
+}
+
+// ValidateAssertion authenticates the service account token and returns the ClientToken type and the mapped credential
+func (k *KubernetesAssertion) ValidateAssertion(assertion, _, _ string, r *http.Request) (TokenType, string, error) {
+	if k.Audience == "" {
+		return "", "", errors.New("kubernetes: audience is required")
+	}
+	if len(k.ServiceAccounts) == 0 {
+		return "", "", errors.New("kubernetes: ServiceAccounts is required")
+	}
+	var sa *ServiceAccount
+	switch {
+	case k.Reviewer != nil:
+		var err error
+		if sa, err = k.Reviewer.ReviewToken(r.Context(), assertion, []string{k.Audience}); err != nil {
+			return "", "", err
+		}
+	case k.Issuer != nil:
+		issuer := *k.Issuer
+		issuer.Audience = k.Audience
+		token, err := verifyJWT(r.Context(), assertion, func(iss string) *TrustedIssuer {
+			if iss != "" && iss == issuer.Issuer {
+				return &issuer
+			}
+			return nil
+		})
+		if err != nil {
+			return "", "", err
+		}
+		var ok bool
+		if sa, ok = parseServiceAccount(token.Credential); !ok {
+			return "", "", fmt.Errorf("%w: %q is not a service account", ErrUnauthenticatedServiceAccount, token.Credential)
+		}
+	default:
+		return "", "", errors.New("kubernetes: Reviewer or Issuer is required")
+	}
+	credential, ok := k.ServiceAccounts[sa.Namespace+"/"+sa.Name]
+	if !ok || credential == "" {
+		return "", "", ErrServiceAccountNotAllowed
+	}
+	return ClientToken, credential, nil
+}
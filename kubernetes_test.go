@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKubernetesTokenReview(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review tokenReview
+		if r.URL.Path != "/apis/authentication.k8s.io/v1/tokenreviews" || r.Header.Get("Authorization") != "Bearer server-token" ||
+			json.NewDecoder(r.Body).Decode(&review) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if review.Spec.Token == "workload-token" && len(review.Spec.Audiences) == 1 && review.Spec.Audiences[0] == "oauth-server" {
+			review.Status.Authenticated = true
+			review.Status.Audiences = review.Spec.Audiences
+			review.Status.User.Username = "system:serviceaccount:payments:worker"
+			review.Status.User.UID = "uid-1"
+		} else if review.Spec.Token == "legacy-token" {
+			// an API server ignoring the audiences
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:payments:worker"
+		} else if review.Spec.Token == "large-token" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"kind": "TokenReview", "padding": "` + strings.Repeat("x", 1<<17) + `"}`))
+			return
+		} else {
+			review.Status.Error = "invalid bearer token"
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(review)
+	}))
+	defer apiServer.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("server-token\n"), 0600); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RegisterAssertionGrant(KubernetesTokenGrant, &KubernetesAssertion{
+		Reviewer:        &KubernetesTokenReview{URL: apiServer.URL, TokenFile: tokenFile},
+		Audience:        "oauth-server",
+		ServiceAccounts: map[string]string{"payments/worker": "payments-client"},
+	})
+	r := &http.Request{Form: url.Values{"assertion": {"workload-token"}}}
+	resp, code := sut.generateTokenResponse(KubernetesTokenGrant, "", "", "", "", "", "", r)
+	if code != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+	token, err := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+	if err != nil || token.Credential != "payments-client" || token.TokenType != ClientToken {
+		t.Fatalf("Error token = %v, %v", token, err)
+	}
+
+	for _, assertion := range []string{"stolen-token", "legacy-token", "large-token"} {
+		r = &http.Request{Form: url.Values{"assertion": {assertion}}}
+		resp, code = sut.generateTokenResponse(KubernetesTokenGrant, "", "", "", "", "", "", r)
+		if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidGrant {
+			t.Fatalf("Error %s response = %v", assertion, resp)
+		}
+	}
+}
+
+func TestKubernetesIssuerDiscovery(t *testing.T) {
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	verifier := NewEd25519TokenVerifier(edPub)
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/openid/v1/jwks"})
+	})
+	mux.HandleFunc("/openid/v1/jwks", verifier.ServeJWKS)
+	cluster := httptest.NewServer(mux)
+	defer cluster.Close()
+	issuer = cluster.URL
+
+	trusted, err := DiscoverIssuer(context.Background(), nil, issuer)
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if _, err = DiscoverIssuer(context.Background(), nil, issuer+"/other"); err == nil {
+		t.Fatalf("Error the mismatched issuer should fail")
+	}
+	handler := &KubernetesAssertion{Issuer: trusted, Audience: "oauth-server"}
+	r := httptest.NewRequest("POST", "/token", nil)
+	if _, _, err = handler.ValidateAssertion("token", "", "", r); err == nil {
+		t.Fatalf("Error the handler without ServiceAccounts should be rejected")
+	}
+	handler.ServiceAccounts = map[string]string{"payments/worker": "payments-client"}
+	kid := verifier.JWKS().Keys[0].Kid
+	serviceAccountToken := func(aud, sub string) string {
+		return signJWT(t, EdDSA, kid, edKey, Claims{"iss": issuer, "sub": sub, "aud": []string{aud}, "exp": time.Now().Add(time.Minute).Unix()})
+	}
+
+	tokenType, credential, err := handler.ValidateAssertion(serviceAccountToken("oauth-server", "system:serviceaccount:payments:worker"), "", "", r)
+	if err != nil || tokenType != ClientToken || credential != "payments-client" {
+		t.Fatalf("Error ValidateAssertion = %s, %s, %v", tokenType, credential, err)
+	}
+	if _, _, err = handler.ValidateAssertion(serviceAccountToken("vault", "system:serviceaccount:payments:worker"), "", "", r); err != ErrInvalidAudience {
+		t.Fatalf("Error the token of another audience should be rejected: %v", err)
+	}
+	if _, _, err = handler.ValidateAssertion(serviceAccountToken("oauth-server", "alice"), "", "", r); err == nil {
+		t.Fatalf("Error the token of a user should be rejected")
+	}
+	handler.ServiceAccounts = map[string]string{"billing/worker": "billing-client"}
+	if _, _, err = handler.ValidateAssertion(serviceAccountToken("oauth-server", "system:serviceaccount:payments:worker"), "", "", r); err != ErrServiceAccountNotAllowed {
+		t.Fatalf("Error the unmapped service account should be rejected: %v", err)
+	}
+}
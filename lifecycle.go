@@ -0,0 +1,87 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrServerStarted is returned by Start when the background tasks are already running.
+var ErrServerStarted = errors.New("server already started")
+
+// BackgroundTask is a long running component of the server (janitor, key rotation loop),
+// it must return when the context is done.
+type BackgroundTask func(ctx context.Context) error
+
+// lifecycle holds the background tasks of the server
+type lifecycle struct {
+	mu     sync.Mutex
+	tasks  []BackgroundTask
+	cancel context.CancelFunc
+	done   chan struct{}
+	errs   []error
+}
+
+// AddBackgroundTask registers a task run by Start, tasks added after Start run at the next Start
+func (bs *BearerServer) AddBackgroundTask(task BackgroundTask) {
+	bs.lifecycle.mu.Lock()
+	bs.lifecycle.tasks = append(bs.lifecycle.tasks, task)
+	bs.lifecycle.mu.Unlock()
+}
+
+// Start runs the Janitor, when set, and the registered background tasks until Shutdown or the context cancellation
+func (bs *BearerServer) Start(ctx context.Context) error {
+	lc := &bs.lifecycle
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.cancel != nil {
+		return ErrServerStarted
+	}
+	tasks := append([]BackgroundTask(nil), lc.tasks...)
+	if bs.Janitor != nil {
+		tasks = append(tasks, bs.Janitor.Run)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	lc.cancel, lc.done, lc.errs = cancel, make(chan struct{}), nil
+	var wg sync.WaitGroup
+	var errsMu sync.Mutex
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task BackgroundTask) {
+			defer wg.Done()
+			if err := task(ctx); err != nil && err != context.Canceled {
+				errsMu.Lock()
+				lc.errs = append(lc.errs, err)
+				errsMu.Unlock()
+			}
+		}(task)
+	}
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(lc.done)
+	return nil
+}
+
+// Shutdown stops the background tasks and waits for them until the context is done,
+// returning the first error reported by a task or the context error
+func (bs *BearerServer) Shutdown(ctx context.Context) error {
+	lc := &bs.lifecycle
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.cancel == nil {
+		return nil
+	}
+	lc.cancel()
+	select {
+	case <-lc.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	lc.cancel = nil
+	if len(lc.errs) > 0 {
+		return lc.errs[0]
+	}
+	return nil
+}
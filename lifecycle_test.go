@@ -0,0 +1,51 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestServerLifecycle(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.Janitor = NewJanitor(time.Millisecond)
+	stopped := make(chan struct{})
+	sut.AddBackgroundTask(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+
+	if err := sut.Start(context.Background()); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if err := sut.Start(context.Background()); err != ErrServerStarted {
+		t.Fatalf("Error should be ErrServerStarted: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sut.Shutdown(ctx); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatalf("Error background task not stopped")
+	}
+	if err := sut.Shutdown(ctx); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+}
+
+func TestServerShutdownReportsTaskError(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	failure := errors.New("rotation failed")
+	sut.AddBackgroundTask(func(ctx context.Context) error { return failure })
+	if err := sut.Start(context.Background()); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if err := sut.Shutdown(context.Background()); err != failure {
+		t.Fatalf("Error should be the task error: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultMaxRequestBodySize is the default size limit of the token requests body.
+	DefaultMaxRequestBodySize = 64 * 1024
+	// DefaultMaxFormParams is the default limit of the number of parameters of the token requests.
+	DefaultMaxFormParams = 100
+)
+
+// parseForm parses the request form within the size and parameters limits,
+// rendering invalid_request and returning false when they are exceeded
+func (bs *BearerServer) parseForm(w http.ResponseWriter, r *http.Request) bool {
+	if r.Form == nil {
+		maxSize := bs.MaxRequestBodySize
+		if maxSize == 0 {
+			maxSize = DefaultMaxRequestBodySize
+		}
+		if maxSize > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		}
+		if err := r.ParseForm(); err != nil {
+			if strings.Contains(err.Error(), "request body too large") {
+				bs.renderError(w, r, TokenInvalidRequest, "request body too large", "", http.StatusRequestEntityTooLarge)
+				return false
+			}
+			bs.renderError(w, r, TokenInvalidRequest, "malformed request body", "", http.StatusBadRequest)
+			return false
+		}
+	}
+	maxParams := bs.MaxFormParams
+	if maxParams == 0 {
+		maxParams = DefaultMaxFormParams
+	}
+	if maxParams > 0 {
+		n := 0
+		for _, values := range r.Form {
+			n += len(values)
+		}
+		if n > maxParams {
+			bs.renderError(w, r, TokenInvalidRequest, "too many request parameters", "", http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJSON decodes the JSON request body within the size limit,
+// rendering invalid_request and returning false when it is malformed or too large
+func (bs *BearerServer) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	maxSize := bs.MaxRequestBodySize
+	if maxSize == 0 {
+		maxSize = DefaultMaxRequestBodySize
+	}
+	if maxSize > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	}
+	if r.Body == nil {
+		bs.renderError(w, r, TokenInvalidRequest, "missing request body", "", http.StatusBadRequest)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			bs.renderError(w, r, TokenInvalidRequest, "request body too large", "", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		bs.renderError(w, r, TokenInvalidRequest, "malformed request body", "", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
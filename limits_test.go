@@ -0,0 +1,42 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestLimits(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.MaxRequestBodySize = 256
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		return w
+	}
+
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+	if w := post(form.Encode()); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	form.Set("padding", strings.Repeat("x", 512))
+	if w := post(form.Encode()); w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "invalid_request") {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	sut.MaxRequestBodySize = -1
+	form.Del("padding")
+	for i := 0; i < DefaultMaxFormParams; i++ {
+		form.Add(fmt.Sprintf("p%d", i), "v")
+	}
+	if w := post(form.Encode()); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
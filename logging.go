@@ -0,0 +1,193 @@
+package oauth
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the secret values in the request logs
+const Redacted = "[REDACTED]"
+
+// maxLoggedForm is the size of the request body read by LogRequests to log the form parameters
+const maxLoggedForm = 64 * 1024
+
+// RedactedParams are the form parameters whose values LogRequests redacts
+var RedactedParams = []string{
+	"password", "client_secret", "client_assertion", "assertion", "code", "code_verifier", "device_code", "refresh_token",
+	"access_token", "token", "subject_token", "actor_token", "id_token_hint",
+}
+
+// RedactedHeaders are the request headers whose values LogRequests redacts
+var RedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "DPoP", DefaultCSRFHeader}
+
+// RequestLogEntry describes a request served by the handlers wrapped by LogRequests, its Form and Header are redacted
+type RequestLogEntry struct {
+	Method    string
+	Path      string
+	Status    int
+	Duration  time.Duration
+	GrantType string
+	ClientID  string
+	RequestID string
+	Form      url.Values
+	Header    http.Header
+}
+
+// String formats the entry as a line of key=value pairs, without the headers
+func (e *RequestLogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "method=%s path=%q status=%d duration=%s", e.Method, e.Path, e.Status, e.Duration)
+	if e.GrantType != "" {
+		fmt.Fprintf(&b, " grant_type=%q", e.GrantType)
+	}
+	if e.ClientID != "" {
+		fmt.Fprintf(&b, " client_id=%q", e.ClientID)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " request_id=%q", e.RequestID)
+	}
+	if len(e.Form) > 0 {
+		fmt.Fprintf(&b, " form=%q", e.Form.Encode())
+	}
+	return b.String()
+}
+
+// LogRequests is the middleware calling log with the entry of each request once it is served: method, path, status,
+// duration, grant_type and client_id (form parameter or Basic authorization), the passwords, secrets, codes and tokens
+// of the form parameters and headers being redacted. The query string is not logged.
+//
+//	mux.Handle("/token", oauth.LogRequests(func(e *oauth.RequestLogEntry) { log.Println(e) })(http.HandlerFunc(bs.Token)))
+func LogRequests(log func(entry *RequestLogEntry)) func(next http.Handler) http.Handler {
+	return logRequests(log, nil)
+}
+
+// LogRequests is the LogRequests middleware also redacting the CSRFHeader of the RefreshCookie, when configured
+//
+//	mux.Handle("/token", bs.LogRequests(func(e *oauth.RequestLogEntry) { log.Println(e) })(http.HandlerFunc(bs.Token)))
+func (bs *BearerServer) LogRequests(log func(entry *RequestLogEntry)) func(next http.Handler) http.Handler {
+	var headers []string
+	if bs.RefreshCookie != nil {
+		headers = append(headers, bs.RefreshCookie.csrfHeader())
+	}
+	return logRequests(log, headers)
+}
+
+// logRequests returns the LogRequests middleware redacting the headers along the RedactedHeaders
+func logRequests(log func(entry *RequestLogEntry), headers []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			form := loggedForm(r)
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			entry := &RequestLogEntry{
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    sw.status,
+				Duration:  time.Since(start),
+				GrantType: form.Get("grant_type"),
+				ClientID:  form.Get("client_id"),
+				RequestID: w.Header().Get(RequestIDHeader),
+				Form:      redactForm(form),
+				Header:    redactHeader(r.Header, headers),
+			}
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			if clientID, _, err := GetBasicAuthentication(r); err == nil && clientID != "" {
+				entry.ClientID = clientID
+			}
+			log(entry)
+		})
+	}
+}
+
+// loggedForm reads the form-encoded body up to maxLoggedForm and puts it back in front of the rest of the body,
+// so the handlers still read and limit the whole body
+func loggedForm(r *http.Request) url.Values {
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return url.Values{}
+	}
+	prefix, err := ioutil.ReadAll(io.LimitReader(r.Body, maxLoggedForm))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+	if err != nil {
+		return url.Values{}
+	}
+	form, _ := url.ParseQuery(string(prefix))
+	return form
+}
+
+// redactForm redacts the RedactedParams of the form, their names are matched case-insensitively
+func redactForm(form url.Values) url.Values {
+	redacted := make(url.Values, len(form))
+	for name, values := range form {
+		redacted[name] = values
+		if containsFold(RedactedParams, name) {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+// redactHeader redacts the RedactedHeaders and the extra headers, their names are matched case-insensitively
+func redactHeader(h http.Header, extra []string) http.Header {
+	redacted := h.Clone()
+	for name := range redacted {
+		if containsFold(RedactedHeaders, name) || containsFold(extra, name) {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+// containsFold reports whether the names contain name under Unicode case-folding
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusWriter records the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush flushes the streamed responses
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
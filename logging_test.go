@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRequests(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.PropagateRequestIDs = true
+	var entry *RequestLogEntry
+	handler := LogRequests(func(e *RequestLogEntry) { entry = e })(http.HandlerFunc(sut.Token))
+
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}, "client_id": {"web"}}
+	req := httptest.NewRequest("POST", "/token?code=secret-code", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Cookie", "session=secret-session")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error the handler should read the whole form: StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if entry == nil || entry.Method != "POST" || entry.Path != "/token" || entry.Status != http.StatusOK || entry.GrantType != "password" ||
+		entry.ClientID != "web" || entry.RequestID == "" {
+		t.Fatalf("Error entry = %+v", entry)
+	}
+	if entry.Form.Get("password") != Redacted || entry.Form.Get("username") != "user111" || entry.Header.Get("Cookie") != Redacted {
+		t.Fatalf("Error entry = %+v", entry)
+	}
+	if line := entry.String(); strings.Contains(line, "password111") || strings.Contains(line, "secret-code") || !strings.Contains(line, `client_id="web"`) {
+		t.Fatalf("Error line = %s", line)
+	}
+
+	req = httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("abcdef", "forged-secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if entry.ClientID != "abcdef" || entry.Status != http.StatusUnauthorized || entry.Header.Get("Authorization") != Redacted {
+		t.Fatalf("Error entry = %+v", entry)
+	}
+
+	sut.RefreshCookie = &RefreshCookie{CSRFHeader: "X-XSRF-Token"}
+	handler = sut.LogRequests(func(e *RequestLogEntry) { entry = e })(http.HandlerFunc(sut.Token))
+	form = url.Values{"grant_type": {"password"}, "username": {"user111"}, "Password": {"password111"}}
+	req = httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-XSRF-Token", "secret-csrf")
+	req.Header["x-csrf-token"] = []string{"secret-default-csrf"}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if entry.Form.Get("Password") != Redacted || entry.Header.Get("X-XSRF-Token") != Redacted || entry.Header["x-csrf-token"][0] != Redacted {
+		t.Fatalf("Error entry = %+v", entry)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type contextKey string
@@ -15,12 +16,38 @@ const (
 	ScopeContext       contextKey = "oauth.scope"
 	TokenTypeContext   contextKey = "oauth.tokentype"
 	AccessTokenContext contextKey = "oauth.accesstoken"
+	// AnonymousContext is true in the context of the requests let through by AuthorizeOptional without a token
+	AnonymousContext contextKey = "oauth.anonymous"
+)
+
+var (
+	errInvalidAuthorizationHeader = errors.New("invalid bearer authorization header")
+	errInvalidToken               = errors.New("invalid token")
 )
 
 // BearerAuthentication middleware for go-chi
 type BearerAuthentication struct {
 	secretKey string
 	provider  *TokenProvider
+	// Audience, when set, must be contained in the "aud" claim of the accepted tokens
+	Audience string
+	// Denylist, when set, rejects the revoked tokens
+	Denylist *Denylist
+	// ReferenceTokens, when set, resolves the reference tokens issued in place of the oversized access tokens
+	ReferenceTokens ReferenceTokenStore
+	// UsageTracker, when set, records the last use of the accepted tokens
+	UsageTracker UsageTracker
+	// CloseExpiredStreams cancels the context of the AuthorizeStream connections at the token expiry or revocation
+	CloseExpiredStreams bool
+	// PolicyDecider, when set, takes the authorization decision of the requests with a valid token
+	PolicyDecider PolicyDecider
+	// Cache, when set, deduplicates and caches the token validations
+	Cache *ValidationCache
+	// TrustedIssuers, when set, accepts the JWT access tokens signed by the external issuers
+	TrustedIssuers []*TrustedIssuer
+	// OnPanic, when set, is called with the panics recovered while authenticating the requests, before the 500 response
+	// is rendered. The panics of the next handler are not recovered.
+	OnPanic func(r *http.Request, recovered interface{}, stack []byte)
 }
 
 // NewBearerAuthentication create a BearerAuthentication middleware
@@ -44,38 +71,131 @@ func Authorize(secretKey string, formatter TokenSecureFormatter) func(next http.
 // Authorization: Bearer {access_token}
 func (ba *BearerAuthentication) Authorize(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		token, err := ba.checkAuthorizationHeader(auth)
-		if err != nil {
-			renderJSON(w, "Not authorized: "+err.Error(), true, http.StatusUnauthorized)
-			return
+		if ctx := ba.authorize(w, r); ctx != nil {
+			next.ServeHTTP(w, r.WithContext(ctx))
 		}
+	})
+}
+
+// authorize returns the context of the request carrying its token, or renders the rejection and returns nil
+func (ba *BearerAuthentication) authorize(w http.ResponseWriter, r *http.Request) (ctx context.Context) {
+	w = trackResponse(w)
+	defer ba.recoverPanic(w, r)
+	auth := r.Header.Get("Authorization")
+	token, err := ba.checkAuthorizationHeader(r.Context(), auth)
+	if err != nil {
+		renderJSON(w, "Not authorized: "+err.Error(), true, http.StatusUnauthorized)
+		return nil
+	}
+	if !ba.decide(w, r, token) {
+		return nil
+	}
+	return ba.tokenContext(r.Context(), token, auth[7:])
+}
 
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, CredentialContext, token.Credential)
-		ctx = context.WithValue(ctx, ClaimsContext, token.Claims)
-		ctx = context.WithValue(ctx, ScopeContext, token.Scope)
-		ctx = context.WithValue(ctx, TokenTypeContext, token.TokenType)
-		ctx = context.WithValue(ctx, AccessTokenContext, auth[7:])
-		next.ServeHTTP(w, r.WithContext(ctx))
+// AuthorizeOptional is the Authorize middleware of the endpoints with mixed public and personalized behavior:
+// the requests without Authorization header are let through without CredentialContext, flagged by AnonymousContext,
+// the requests with an invalid token are still rejected.
+func (ba *BearerAuthentication) AuthorizeOptional(next http.Handler) http.Handler {
+	authorize := ba.Authorize(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			authorize.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), AnonymousContext, true)))
 	})
 }
 
+// IsAnonymous returns true when the request was let through by AuthorizeOptional without a token
+func IsAnonymous(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(AnonymousContext).(bool)
+	return anonymous
+}
+
+// tokenContext records the use of the accepted token and returns the context carrying it
+func (ba *BearerAuthentication) tokenContext(ctx context.Context, token *Token, raw string) context.Context {
+	if ba.UsageTracker != nil {
+		_ = ba.UsageTracker.TrackUsed(token.Credential, token.TokenType, time.Now().UTC())
+	}
+	ctx = context.WithValue(ctx, CredentialContext, token.Credential)
+	ctx = context.WithValue(ctx, ClaimsContext, token.Claims)
+	ctx = context.WithValue(ctx, ScopeContext, token.Scope)
+	ctx = context.WithValue(ctx, TokenTypeContext, token.TokenType)
+	return context.WithValue(ctx, AccessTokenContext, raw)
+}
+
 // Check header and token.
-func (ba *BearerAuthentication) checkAuthorizationHeader(auth string) (t *Token, err error) {
-	if len(auth) < 7 {
-		return nil, errors.New("invalid bearer authorization header")
+func (ba *BearerAuthentication) checkAuthorizationHeader(ctx context.Context, auth string) (t *Token, err error) {
+	if len(auth) < 7 || !strings.EqualFold(auth[:6], "bearer") {
+		return nil, errInvalidAuthorizationHeader
 	}
-	authType := strings.ToLower(auth[:6])
-	if authType != "bearer" {
-		return nil, errors.New("invalid bearer authorization header")
+	token, err := ba.ValidateTokenContext(ctx, auth[7:])
+	if errors.Is(err, ErrMalformedToken) {
+		return nil, errInvalidToken
+	}
+	return token, err
+}
+
+// ValidateToken decrypts the access token, or verifies the JWT of a TrustedIssuer, checking its expiration and audience.
+// ValidateToken is the supported entry point for validating tokens outside of an HTTP request,
+// the returned errors are ErrMalformedToken, ErrExpiredToken, ErrRevokedToken and ErrInvalidAudience,
+// or the ReferenceTokenStore errors.
+func (ba *BearerAuthentication) ValidateToken(raw string) (*Token, error) {
+	return ba.ValidateTokenContext(context.Background(), raw)
+}
+
+// ValidateTokenContext is ValidateToken with the context of the request, bounding the key resolution of the
+// TrustedIssuers tokens
+func (ba *BearerAuthentication) ValidateTokenContext(ctx context.Context, raw string) (*Token, error) {
+	validate := func(raw string) (*Token, error) {
+		return ba.validateToken(ctx, raw)
+	}
+	if ba.Cache == nil {
+		return validate(raw)
+	}
+	token, err := ba.Cache.validate(validationKey{owner: ba, audience: ba.Audience, raw: raw}, validate)
+	if err != nil {
+		return nil, err
+	}
+	if token.IsExpired() {
+		return nil, ErrExpiredToken
+	}
+	if ba.Denylist != nil && ba.Denylist.Contains(token.ID) {
+		return nil, ErrRevokedToken
+	}
+	return token, nil
+}
+
+// validateToken decrypts the access token checking its expiration, revocation and audience
+func (ba *BearerAuthentication) validateToken(ctx context.Context, raw string) (*Token, error) {
+	var token *Token
+	var err error
+	switch {
+	case ba.ReferenceTokens != nil && strings.HasPrefix(raw, ReferenceTokenPrefix):
+		var record *referenceRecord
+		if record, err = loadReference(ba.ReferenceTokens, raw); err == nil && record.Token == nil {
+			err = ErrMalformedToken
+		}
+		if err == nil {
+			token = record.Token
+		}
+	case len(ba.TrustedIssuers) > 0 && ba.trustedJWT(raw):
+		token, err = ba.validateJWT(ctx, raw)
+	default:
+		token, err = ba.provider.DecryptToken(raw)
 	}
-	token, err := ba.provider.DecryptToken(auth[7:])
 	if err != nil {
-		return nil, errors.New("invalid token")
+		return nil, err
 	}
 	if token.IsExpired() {
-		return nil, errors.New("token expired")
+		return nil, ErrExpiredToken
+	}
+	if ba.Denylist != nil && ba.Denylist.Contains(token.ID) {
+		return nil, ErrRevokedToken
+	}
+	if ba.Audience != "" && !token.HasAudience(ba.Audience) {
+		return nil, ErrInvalidAudience
 	}
 	return token, nil
 }
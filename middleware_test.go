@@ -1,7 +1,10 @@
 package oauth
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -22,7 +25,7 @@ func TestAuthorizationHeader(t *testing.T) {
 	t.Logf("Token response: %v", resp)
 
 	header := "Bearer " + resp.(*TokenResponse).Token
-	token, err := _mut.checkAuthorizationHeader(header)
+	token, err := _mut.checkAuthorizationHeader(context.Background(), header)
 	if err != nil {
 		t.Fatalf("Error %s", err.Error())
 	}
@@ -31,9 +34,87 @@ func TestAuthorizationHeader(t *testing.T) {
 
 func TestExpiredAuthorizationHeader(t *testing.T) {
 	header := `Bearer wMFZSkQ1kSTbQ9mkHufsfeHCnKo05TSEyLyjSiKOafAUQv7s0NClIgBQSDGKoRzeWfB2G0bKO7EE3P9MnaZNxkx2CtWVfTJkCXsIpo2eyF8Nw+ub5nr4Bxmj6JeOumQMrFogBHMnMT7Em7EhqQO+CICQ3cVX5suqsVkEZ/gkXfjKnnEH6qKYz3S3IN/ry3pVGaQc1wAn/cYqPA1SD+CAYqkriWgIGWJmYv3W9eRSoEWgfgigdM6kmZvlDxTlrACLOvzA/JCXK7qnP8TuFz4yAtNmBoNVw0PTjxIdBFJEC7RdZyQcO3SdgGykxgPqGhiW3Z4F7ZG3mzmy/SoSJIPnmmFIreDWt6+QOsUyeHkEu74G`
-	_, err := _mut.checkAuthorizationHeader(header)
+	_, err := _mut.checkAuthorizationHeader(context.Background(), header)
 	if err == nil {
 		t.Fatalf("Error should have occurred")
 	}
 	t.Logf("Error : %v", err)
 }
+
+func TestValidateToken(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	token, err := _mut.ValidateToken(resp.(*TokenResponse).Token)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if token.Credential != "user111" {
+		t.Fatalf("Error Credential = %s", token.Credential)
+	}
+
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.Audience = "orders"
+	if _, err = mut.ValidateToken(resp.(*TokenResponse).Token); !errors.Is(err, ErrInvalidAudience) {
+		t.Fatalf("Error should be ErrInvalidAudience: %v", err)
+	}
+	if _, err = mut.ValidateToken("garbage"); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error should be ErrMalformedToken: %v", err)
+	}
+}
+
+func TestTokenHasAudience(t *testing.T) {
+	token := &Token{Claims: Claims{"aud": []interface{}{"orders", "customers"}}}
+	if !token.HasAudience("customers") || token.HasAudience("payments") {
+		t.Fatalf("Error audience check")
+	}
+}
+
+func TestAuthorizeOptional(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	var credential interface{}
+	var anonymous bool
+	handler := _mut.AuthorizeOptional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential = r.Context().Value(CredentialContext)
+		anonymous = IsAnonymous(r.Context())
+	}))
+	serve := func(auth string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code = serve(""); code != http.StatusOK || credential != nil || !anonymous {
+		t.Fatalf("Error StatusCode = %d, credential = %v", code, credential)
+	}
+	if code = serve("Bearer " + resp.(*TokenResponse).Token); code != http.StatusOK || credential != "user111" || anonymous {
+		t.Fatalf("Error StatusCode = %d, credential = %v", code, credential)
+	}
+	if code = serve("Bearer garbage"); code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if IsAnonymous(context.Background()) {
+		t.Fatalf("Error request without AuthorizeOptional anonymous")
+	}
+}
+
+func BenchmarkAuthorizationHeader(b *testing.B) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		b.Fatalf("Error StatusCode = %d", code)
+	}
+	header := "Bearer " + resp.(*TokenResponse).Token
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := _mut.checkAuthorizationHeader(context.Background(), header); err != nil {
+			b.Fatalf("Error %s", err.Error())
+		}
+	}
+}
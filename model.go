@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"strings"
 	"time"
 )
 
@@ -8,6 +9,7 @@ type TokenType string
 
 const (
 	BearerToken TokenType = "Bearer"
+	DPoPToken   TokenType = "DPoP"
 	AuthToken   TokenType = "A"
 	UserToken   TokenType = "U"
 	ClientToken TokenType = "C"
@@ -19,11 +21,13 @@ type Properties map[string]string
 // TokenResponse is the authorization server response
 type TokenResponse struct {
 	Token                 string     `json:"access_token"`
-	RefreshToken          string     `json:"refresh_token"`
+	RefreshToken          string     `json:"refresh_token,omitempty"`
 	TokenType             TokenType  `json:"token_type"`               // bearer
 	ExpiresIn             int64      `json:"expires_in"`               // secs
 	RefreshTokenExpiresIn int64      `json:"refresh_token_expires_in"` // secs
 	Properties            Properties `json:"properties"`
+	// Extensions are marshaled at the top level of the response (e.g. id_token, issued_token_type)
+	Extensions map[string]interface{} `json:"-"`
 }
 
 // ExpirableToken is an interface for a token that has an expiration.
@@ -47,16 +51,65 @@ func (t *Token) IsExpired() bool {
 	return t.ExpiresIn > 0 && time.Now().UTC().After(t.CreationDate.Add(t.ExpiresIn))
 }
 
+// HasAudience returns true if the "aud" claim, either a string or a list of strings, contains the audience.
+func (t *Token) HasAudience(audience string) bool {
+	switch aud := t.Claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []string:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScopes returns true if the scope of the token contains all the scopes.
+func (t *Token) HasScopes(scopes ...string) bool {
+	return hasScopes(t.Scope, scopes...)
+}
+
+// hasScopes returns true if the space-delimited scope contains all the scopes
+func hasScopes(scope string, scopes ...string) bool {
+	granted := strings.Fields(scope)
+	for _, scope := range scopes {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // RefreshToken structure included in the authorization server response
 type RefreshToken struct {
 	ID           string        `json:"refresh_token_id"`
 	TokenID      string        `json:"token_id"`
 	CreationDate time.Time     `json:"date"`
+	AuthTime     time.Time     `json:"auth_time"`  // date of the original grant, kept across rotations
 	ExpiresIn    time.Duration `json:"expires_in"` // secs
 	Credential   string        `json:"credential"`
 	TokenType    TokenType     `json:"type"`
 	Scope        string        `json:"scope"`
 	Claims       Claims        `json:"claims"`
+	ParentID     string        `json:"parent_id,omitempty"` // refresh token rotated into this one
+	FamilyID     string        `json:"family_id,omitempty"` // refresh token of the original grant
+	ClientID     string        `json:"client_id,omitempty"` // authenticated client the token was issued to
+	DeviceID     string        `json:"device_id,omitempty"` // trusted device, kept out of the access token claims
 }
 
 // IsExpired checks the creation date to the expiry, if it's greater than 0, and returns true if the token is expired.
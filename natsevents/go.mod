@@ -0,0 +1,18 @@
+module github.com/jeffreydwalter/oauth-1/natsevents
+
+go 1.17
+
+require (
+	github.com/jeffreydwalter/oauth-1 v0.0.0
+	github.com/nats-io/nats.go v1.16.0
+)
+
+require (
+	github.com/gofrs/uuid v4.2.0+incompatible // indirect
+	github.com/nats-io/nkeys v0.3.0 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
+
+replace github.com/jeffreydwalter/oauth-1 => ../
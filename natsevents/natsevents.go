@@ -0,0 +1,57 @@
+// Package natsevents delivers the oauth token lifecycle events to NATS.
+//
+// The JSON events are published to the subject "<prefix>.<event type>", e.g. "oauth.token.issued",
+// so subscribers can filter them with wildcards.
+package natsevents
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+// DefaultSubjectPrefix is the prefix of the subjects when none is given
+const DefaultSubjectPrefix = "oauth"
+
+// Conn is the subset of the NATS connection used by the Sink, implemented by *nats.Conn.
+type Conn interface {
+	Publish(subject string, data []byte) error
+	Flush() error
+}
+
+// Sink is an oauth.EventSink publishing the events to NATS.
+type Sink struct {
+	conn   Conn
+	prefix string
+}
+
+// New creates a Sink over the connection, DefaultSubjectPrefix is used when prefix is empty.
+func New(conn Conn, prefix string) *Sink {
+	if prefix == "" {
+		prefix = DefaultSubjectPrefix
+	}
+	return &Sink{conn: conn, prefix: prefix}
+}
+
+// NewPublisher creates an oauth.AsyncPublisher buffering up to bufferSize events for the connection.
+func NewPublisher(conn Conn, prefix string, bufferSize int) *oauth.AsyncPublisher {
+	return oauth.NewAsyncPublisher(New(conn, prefix), bufferSize)
+}
+
+// PublishEvents publishes the events then flushes the connection so the delivery errors are reported
+func (s *Sink) PublishEvents(ctx context.Context, events []*oauth.Event) error {
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err = s.conn.Publish(s.prefix+"."+string(event.Type), data); err != nil {
+			return err
+		}
+	}
+	return s.conn.Flush()
+}
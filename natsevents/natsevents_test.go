@@ -0,0 +1,45 @@
+package natsevents
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jeffreydwalter/oauth-1"
+	"github.com/nats-io/nats.go"
+)
+
+var _ Conn = (*nats.Conn)(nil)
+
+type fakeConn struct {
+	subjects []string
+	data     [][]byte
+	flushes  int
+}
+
+func (c *fakeConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	c.data = append(c.data, data)
+	return nil
+}
+
+func (c *fakeConn) Flush() error {
+	c.flushes++
+	return nil
+}
+
+func TestPublishEvents(t *testing.T) {
+	conn := new(fakeConn)
+	sink := New(conn, "")
+	events := []*oauth.Event{{ID: "e1", Type: oauth.TokenIssuedEvent}, {ID: "e2", Type: oauth.TokenRevokedEvent}}
+	if err := sink.PublishEvents(context.Background(), events); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if len(conn.subjects) != 2 || conn.subjects[0] != "oauth.token.issued" || conn.subjects[1] != "oauth.token.revoked" || conn.flushes != 1 {
+		t.Fatalf("Error subjects = %v, flushes = %d", conn.subjects, conn.flushes)
+	}
+	event := new(oauth.Event)
+	if err := json.Unmarshal(conn.data[1], event); err != nil || event.ID != "e2" {
+		t.Fatalf("Error event = %+v, %v", event, err)
+	}
+}
@@ -0,0 +1,62 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+// CodeChallengeMethod is the PKCE code_challenge_method, see https://datatracker.ietf.org/doc/html/rfc7636
+type CodeChallengeMethod string
+
+const (
+	PlainCodeChallenge  CodeChallengeMethod = "plain"
+	S256CodeChallenge   CodeChallengeMethod = "S256"
+	minCodeVerifierSize                     = 43
+	maxCodeVerifierSize                     = 128
+)
+
+// PKCEVerifier defines the optional interface used to verify the code_verifier of the authorization code grant.
+// It is mandatory for public clients.
+type PKCEVerifier interface {
+	// CodeChallenge returns the code_challenge and code_challenge_method bound to the authorization code,
+	// an empty challenge means the authorization request did not use PKCE
+	CodeChallenge(clientID, code string, r *http.Request) (challenge string, method CodeChallengeMethod, err error)
+}
+
+// S256Challenge computes the S256 code_challenge of the code_verifier.
+func S256Challenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// VerifyCodeChallenge checks the code_verifier against the code_challenge using the method,
+// an empty method is interpreted as plain as stated by RFC 7636 §4.3.
+func VerifyCodeChallenge(challenge string, method CodeChallengeMethod, codeVerifier string) bool {
+	if !validCodeVerifier(codeVerifier) {
+		return false
+	}
+	switch method {
+	case S256CodeChallenge:
+		return subtle.ConstantTimeCompare([]byte(S256Challenge(codeVerifier)), []byte(challenge)) == 1
+	case PlainCodeChallenge, "":
+		return subtle.ConstantTimeCompare([]byte(codeVerifier), []byte(challenge)) == 1
+	default:
+		return false
+	}
+}
+
+// validCodeVerifier checks the code_verifier syntax: 43-128 characters of [A-Z] / [a-z] / [0-9] / "-" / "." / "_" / "~"
+func validCodeVerifier(v string) bool {
+	if len(v) < minCodeVerifierSize || len(v) > maxCodeVerifierSize {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~') {
+			return false
+		}
+	}
+	return true
+}
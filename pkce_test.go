@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testCodeVerifier provides authorization codes bound to a PKCE challenge for testing.
+type testCodeVerifier struct {
+	TestUserVerifier
+	challenge string
+	method    CodeChallengeMethod
+}
+
+func (testCodeVerifier) ValidateCode(clientID, clientSecret, code, redirectURI string, r *http.Request) (string, error) {
+	return "user111", nil
+}
+
+func (v testCodeVerifier) CodeChallenge(clientID, code string, r *http.Request) (string, CodeChallengeMethod, error) {
+	return v.challenge, v.method, nil
+}
+
+const testCodeVerifierValue = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	if S256Challenge(testCodeVerifierValue) != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("Error S256 challenge = %s", S256Challenge(testCodeVerifierValue))
+	}
+	if !VerifyCodeChallenge("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", S256CodeChallenge, testCodeVerifierValue) {
+		t.Fatalf("Error S256 challenge not verified")
+	}
+	if !VerifyCodeChallenge(testCodeVerifierValue, PlainCodeChallenge, testCodeVerifierValue) {
+		t.Fatalf("Error plain challenge not verified")
+	}
+	if VerifyCodeChallenge("short", PlainCodeChallenge, "short") {
+		t.Fatalf("Error invalid code_verifier accepted")
+	}
+}
+
+func TestPublicClientRequiresPKCE(t *testing.T) {
+	verifier := &testCodeVerifier{}
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, verifier, nil)
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "spa", Public: true, AllowedGrantTypes: []GrantType{AuthCodeGrant, ClientCredentialsGrant}})
+
+	r := &http.Request{Form: url.Values{}}
+	resp, code := sut.generateTokenResponse(AuthCodeGrant, "spa", "", "", "", "code", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidRequest {
+		t.Fatalf("Error response = %v", resp)
+	}
+
+	verifier.challenge, verifier.method = S256Challenge(testCodeVerifierValue), S256CodeChallenge
+	r = &http.Request{Form: url.Values{"code_verifier": {"wrong-" + testCodeVerifierValue}}}
+	resp, code = sut.generateTokenResponse(AuthCodeGrant, "spa", "", "", "", "code", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidGrant {
+		t.Fatalf("Error response = %v", resp)
+	}
+
+	r = &http.Request{Form: url.Values{"code_verifier": {testCodeVerifierValue}}}
+	resp, code = sut.generateTokenResponse(AuthCodeGrant, "spa", "", "", "", "code", "", r)
+	if code != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+
+	client, _ := sut.ClientStore.GetClient("spa")
+	if client.AllowsGrantType(ClientCredentialsGrant) {
+		t.Fatalf("Error public client allowed to use client_credentials")
+	}
+}
+
+func TestAuthorizationCodeVerifierClientSecret(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, &testCodeVerifier{}, nil)
+
+	// the ValidateCode of the verifier does not check the client secret
+	resp, code := sut.generateTokenResponse(AuthCodeGrant, "abcdef", "wrong", "", "", "code", "", &http.Request{Form: url.Values{}})
+	if code != http.StatusUnauthorized || resp.(ErrorResponse).Error != TokenInvalidClient {
+		t.Fatalf("Error response = %v", resp)
+	}
+	resp, code = sut.generateTokenResponse(AuthCodeGrant, "abcdef", "12345", "", "", "code", "", &http.Request{Form: url.Values{}})
+	if code != http.StatusOK {
+		t.Fatalf("Error response = %v", resp)
+	}
+}
@@ -0,0 +1,120 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PolicyInput is the authorization request submitted to the PolicyDecider after the token validation
+type PolicyInput struct {
+	Method  string   `json:"method"`
+	Path    string   `json:"path"`
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes"`
+	Claims  Claims   `json:"claims"`
+}
+
+// PolicyDecider takes the fine-grained authorization decisions of the BearerAuthentication middleware
+type PolicyDecider interface {
+	// Decide returns true when the request is allowed
+	Decide(ctx context.Context, input *PolicyInput) (bool, error)
+}
+
+// PolicyDeciderFunc is a function implementing PolicyDecider
+type PolicyDeciderFunc func(ctx context.Context, input *PolicyInput) (bool, error)
+
+// Decide calls f(ctx, input)
+func (f PolicyDeciderFunc) Decide(ctx context.Context, input *PolicyInput) (bool, error) {
+	return f(ctx, input)
+}
+
+// CasbinEnforcer is the subset of the Casbin enforcer used by the CasbinDecider, implemented by *casbin.Enforcer
+type CasbinEnforcer interface {
+	Enforce(rvals ...interface{}) (bool, error)
+}
+
+// CasbinDecider is the PolicyDecider enforcing the (subject, path, method) requests of the RESTful Casbin models
+type CasbinDecider struct {
+	Enforcer CasbinEnforcer
+}
+
+// Decide enforces the subject, path and method of the input
+func (d *CasbinDecider) Decide(_ context.Context, input *PolicyInput) (bool, error) {
+	return d.Enforcer.Enforce(input.Subject, input.Path, input.Method)
+}
+
+// OPADecider is the PolicyDecider querying an Open Policy Agent rule through the Data API,
+// e.g. http://localhost:8181/v1/data/httpapi/authz/allow. The rule receives the PolicyInput as input and
+// returns either a boolean or an object with an "allow" boolean, the undefined rule denies the request.
+type OPADecider struct {
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Decide POSTs the input to the rule
+func (d *OPADecider) Decide(ctx context.Context, input *PolicyInput) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("opa: unexpected status %d", resp.StatusCode)
+	}
+	var decision struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, err
+	}
+	if len(decision.Result) == 0 {
+		return false, nil
+	}
+	var allow bool
+	if json.Unmarshal(decision.Result, &allow) == nil {
+		return allow, nil
+	}
+	var result struct {
+		Allow bool `json:"allow"`
+	}
+	if err = json.Unmarshal(decision.Result, &result); err != nil {
+		return false, fmt.Errorf("opa: unexpected result %s", decision.Result)
+	}
+	return result.Allow, nil
+}
+
+// decide submits the request of the validated token to the PolicyDecider, rendering 403 when it is denied
+func (ba *BearerAuthentication) decide(w http.ResponseWriter, r *http.Request, token *Token) bool {
+	if ba.PolicyDecider == nil {
+		return true
+	}
+	input := &PolicyInput{Method: r.Method, Path: r.URL.Path, Subject: token.Credential, Scopes: strings.Fields(token.Scope), Claims: token.Claims}
+	allowed, err := ba.PolicyDecider.Decide(r.Context(), input)
+	if err != nil {
+		renderJSON(w, "Not authorized: policy decision failed", true, http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		renderJSON(w, "Not authorized: denied by policy", true, http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeEnforcer map[string]bool
+
+func (e fakeEnforcer) Enforce(rvals ...interface{}) (bool, error) {
+	return e[rvals[0].(string)+" "+rvals[2].(string)+" "+rvals[1].(string)], nil
+}
+
+func TestPolicyDecider(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.PolicyDecider = &CasbinDecider{Enforcer: fakeEnforcer{"user111 GET /orders": true}}
+	handler := mut.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+resp.(*TokenResponse).Token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code = get("/orders"); code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if code = get("/customers"); code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+}
+
+func TestOPADecider(t *testing.T) {
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input PolicyInput `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch body.Input.Path {
+		case "/bool":
+			_, _ = w.Write([]byte(`{"result": true}`))
+		case "/object":
+			_, _ = w.Write([]byte(`{"result": {"allow": true}}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer opa.Close()
+
+	d := &OPADecider{URL: opa.URL}
+	for path, expected := range map[string]bool{"/bool": true, "/object": true, "/undefined": false} {
+		allowed, err := d.Decide(context.Background(), &PolicyInput{Method: "GET", Path: path, Subject: "user111"})
+		if err != nil || allowed != expected {
+			t.Fatalf("Error %s allowed = %v, err = %v", path, allowed, err)
+		}
+	}
+}
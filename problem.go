@@ -0,0 +1,29 @@
+package oauth
+
+import "net/http"
+
+// ProblemMediaType is the media type of the error responses rendered with ProblemDetails, see RFC 7807
+const ProblemMediaType = "application/problem+json"
+
+// Problem is the RFC 7807 problem details document of an error response, the OAuth error fields (error,
+// error_description, error_uri, ...) are kept at the top level so the OAuth clients still parse it.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	ErrorResponse
+}
+
+// problem returns the problem details of the error response rendered with the status code. The problem type is the
+// error_uri, else the error code under the ProblemTypeBase, else "about:blank" titled with the status text.
+func (bs *BearerServer) problem(e ErrorResponse, statusCode int) *Problem {
+	p := &Problem{Type: e.URI, Title: string(e.Error), Status: statusCode, Detail: e.Description, ErrorResponse: e}
+	if p.Type == "" && bs.ProblemTypeBase != "" {
+		p.Type = bs.ProblemTypeBase + string(e.Error)
+	}
+	if p.Type == "" {
+		p.Type, p.Title = "about:blank", http.StatusText(statusCode)
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProblemDetails(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.ProblemDetails = true
+
+	token := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+	w := token(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"forged"}})
+	var p map[string]interface{}
+	if w.Code != http.StatusUnauthorized || json.Unmarshal(w.Body.Bytes(), &p) != nil {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != ProblemMediaType+"; charset=utf-8" {
+		t.Fatalf("Error Content-Type = %s", w.Header().Get("Content-Type"))
+	}
+	if p["type"] != "about:blank" || p["title"] != "Unauthorized" || p["status"] != float64(http.StatusUnauthorized) ||
+		p["detail"] != "invalid username or password" || p["error"] != string(TokenInvalidGrant) || p["error_description"] != p["detail"] {
+		t.Fatalf("Error problem = %v", p)
+	}
+
+	sut.ProblemTypeBase = "https://errors.example.com/oauth/"
+	w = token(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"forged"}})
+	if json.Unmarshal(w.Body.Bytes(), &p) != nil || p["type"] != "https://errors.example.com/oauth/invalid_grant" || p["title"] != "invalid_grant" {
+		t.Fatalf("Error problem = %v", p)
+	}
+
+	sut.ResponseFields = &ResponseFieldMapping{Names: map[string]string{"error_description": "message"}}
+	p = nil
+	w = token(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"forged"}})
+	if w.Header().Get("Content-Type") != ProblemMediaType+"; charset=utf-8" || json.Unmarshal(w.Body.Bytes(), &p) != nil {
+		t.Fatalf("Error Content-Type = %s, body = %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if _, ok := p["error_description"]; ok || p["message"] != "invalid username or password" || p["type"] == nil {
+		t.Fatalf("Error renamed problem = %v", p)
+	}
+	sut.ResponseFields = nil
+
+	w = token(url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("Error StatusCode = %d, Content-Type = %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,103 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrUserExists is returned by the UserProvisioner when the created user already exists.
+	ErrUserExists = errors.New("user already exists")
+	// ErrUserNotFound is returned by the UserProvisioner when the disabled user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// ProvisionedUser is the SCIM-like user representation of the provisioning endpoints.
+type ProvisionedUser struct {
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Active      bool   `json:"active"`
+}
+
+// UserProvisioner creates and disables the user accounts on behalf of the identity lifecycle system (HR, IdP, ...)
+type UserProvisioner interface {
+	// CreateUser creates the user account, ErrUserExists when the user name is taken
+	CreateUser(user *ProvisionedUser, r *http.Request) error
+	// DisableUser disables the user account, ErrUserNotFound when the user does not exist
+	DisableUser(userName string, r *http.Request) error
+}
+
+// CreateUser is the provisioning endpoint creating the user of the JSON body with the UserProvisioner,
+// it responds 201 with the created user. Protect it with Authorize, it is not mounted by RegisterHandlers.
+func (bs *BearerServer) CreateUser(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if bs.UserProvisioner == nil {
+		bs.renderError(w, r, TokenInvalidRequest, "user provisioning is not enabled", "", http.StatusNotFound)
+		return
+	}
+	var user ProvisionedUser
+	if !bs.decodeJSON(w, r, &user) {
+		return
+	}
+	if user.UserName == "" {
+		bs.renderError(w, r, TokenInvalidRequest, "userName is required", "", http.StatusBadRequest)
+		return
+	}
+	if err := bs.UserProvisioner.CreateUser(&user, r); err != nil {
+		if err == ErrUserExists {
+			bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusConflict)
+			return
+		}
+		bs.renderError(w, r, TokenServerError, "creating user failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, user, true, http.StatusCreated)
+}
+
+// DisableUser is the provisioning endpoint disabling the user named by the userName of the JSON body with the
+// UserProvisioner, then revoking all the tokens issued to the user with RevokeCredential. The TokenStore must implement
+// CredentialTokenStore, checked before the user is disabled.
+// Protect it with Authorize, it is not mounted by RegisterHandlers.
+func (bs *BearerServer) DisableUser(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if bs.UserProvisioner == nil {
+		bs.renderError(w, r, TokenInvalidRequest, "user provisioning is not enabled", "", http.StatusNotFound)
+		return
+	}
+	var user ProvisionedUser
+	if !bs.decodeJSON(w, r, &user) {
+		return
+	}
+	if user.UserName == "" {
+		bs.renderError(w, r, TokenInvalidRequest, "userName is required", "", http.StatusBadRequest)
+		return
+	}
+	if _, ok := bs.TokenStore.(CredentialTokenStore); !ok {
+		err := ErrTokenStoreRequired
+		if bs.TokenStore != nil {
+			err = ErrCredentialRevocationUnsupported
+		}
+		bs.renderError(w, r, TokenServerError, "user tokens cannot be revoked: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	if err := bs.UserProvisioner.DisableUser(user.UserName, r); err != nil {
+		if err == ErrUserNotFound {
+			bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusNotFound)
+			return
+		}
+		bs.renderError(w, r, TokenServerError, "disabling user failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	if _, err := bs.RevokeCredential(user.UserName); err != nil {
+		bs.renderError(w, r, TokenServerError, "revoking user tokens failed: "+err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, ProvisionedUser{UserName: user.UserName, Active: false}, true, http.StatusOK)
+}
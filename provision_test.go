@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryProvisioner map[string]*ProvisionedUser
+
+func (p memoryProvisioner) CreateUser(user *ProvisionedUser, r *http.Request) error {
+	if _, ok := p[user.UserName]; ok {
+		return ErrUserExists
+	}
+	user.Active = true
+	p[user.UserName] = user
+	return nil
+}
+
+func (p memoryProvisioner) DisableUser(userName string, r *http.Request) error {
+	user, ok := p[userName]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Active = false
+	return nil
+}
+
+func TestUserProvisioning(t *testing.T) {
+	users := memoryProvisioner{}
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.UserProvisioner = users
+	sut.TokenStore = NewMemoryTokenStore()
+	sut.Denylist = NewDenylist(10, 0.01)
+
+	call := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/users", strings.NewReader(body)))
+		return w
+	}
+	if w := call(sut.CreateUser, `{"userName":"user111","email":"user111@example.com"}`); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"active":true`) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := call(sut.CreateUser, `{"userName":"user111"}`); w.Code != http.StatusConflict {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w := call(sut.CreateUser, `{"email":"x"`); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+
+	resp, code := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token)
+
+	if w := call(sut.DisableUser, `{"userName":"user111"}`); w.Code != http.StatusOK || users["user111"].Active {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if !sut.Denylist.Contains(token.ID) {
+		t.Fatalf("Error access token of the disabled user not denied")
+	}
+	form := "grant_type=refresh_token&refresh_token=" + resp.(*TokenResponse).RefreshToken
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Error refresh of the disabled user StatusCode = %d", w.Code)
+	}
+	if w := call(sut.DisableUser, `{"userName":"user222"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+
+	sut.TokenStore = NewEncryptedStore(NewMemoryTokenStore(), sut.provider.secureFormatter)
+	if _, err := sut.RevokeCredential("user111"); err != ErrCredentialRevocationUnsupported {
+		t.Fatalf("Error RevokeCredential = %v", err)
+	}
+	users["user333"] = &ProvisionedUser{UserName: "user333", Active: true}
+	if w := call(sut.DisableUser, `{"userName":"user333"}`); w.Code != http.StatusInternalServerError || !users["user333"].Active {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	sut.TokenStore = nil
+	if w := call(sut.DisableUser, `{"userName":"user333"}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+}
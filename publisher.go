@@ -0,0 +1,120 @@
+package oauth
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncPublisher defaults
+const (
+	DefaultPublisherBatchSize       = 100
+	DefaultPublisherFlushInterval   = time.Second
+	DefaultPublisherShutdownTimeout = 5 * time.Second
+)
+
+// EventSink delivers batches of events to a message broker (see the kafkaevents and natsevents packages).
+type EventSink interface {
+	PublishEvents(ctx context.Context, events []*Event) error
+}
+
+// PublisherStats is the activity of an AsyncPublisher
+type PublisherStats struct {
+	Published int64 // events delivered to the sink
+	Dropped   int64 // events dropped because the buffer was full
+	Failed    int64 // events the sink failed to deliver
+}
+
+// AsyncPublisher is an EventPublisher buffering the events and delivering them in batches to the sink,
+// the events are dropped when the buffer is full so the token requests never wait for the broker.
+// Run must be started, e.g. with AddBackgroundTask.
+type AsyncPublisher struct {
+	Sink EventSink
+	// BatchSize is the maximum number of events per delivery, DefaultPublisherBatchSize when 0
+	BatchSize int
+	// FlushInterval is the maximum delay of a buffered event, DefaultPublisherFlushInterval when 0
+	FlushInterval time.Duration
+	// OnError, when set, is called with the events the sink failed to deliver
+	OnError func(events []*Event, err error)
+
+	buffer                     chan *Event
+	published, dropped, failed int64
+}
+
+// NewAsyncPublisher creates an AsyncPublisher buffering up to bufferSize events
+func NewAsyncPublisher(sink EventSink, bufferSize int) *AsyncPublisher {
+	return &AsyncPublisher{Sink: sink, buffer: make(chan *Event, bufferSize)}
+}
+
+// Publish buffers the event, dropping it when the buffer is full
+func (p *AsyncPublisher) Publish(event *Event) {
+	select {
+	case p.buffer <- event:
+	default:
+		atomic.AddInt64(&p.dropped, 1)
+	}
+}
+
+// Stats returns the activity of the publisher
+func (p *AsyncPublisher) Stats() PublisherStats {
+	return PublisherStats{
+		Published: atomic.LoadInt64(&p.published),
+		Dropped:   atomic.LoadInt64(&p.dropped),
+		Failed:    atomic.LoadInt64(&p.failed),
+	}
+}
+
+// Run delivers the buffered events until the context is done, then flushes the remaining events
+// within DefaultPublisherShutdownTimeout
+func (p *AsyncPublisher) Run(ctx context.Context) error {
+	size, interval := p.BatchSize, p.FlushInterval
+	if size <= 0 {
+		size = DefaultPublisherBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultPublisherFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]*Event, 0, size)
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), DefaultPublisherShutdownTimeout)
+			defer cancel()
+			for {
+				select {
+				case event := <-p.buffer:
+					if batch = append(batch, event); len(batch) == size {
+						batch = p.flush(flushCtx, batch)
+					}
+				default:
+					p.flush(flushCtx, batch)
+					return ctx.Err()
+				}
+			}
+		case event := <-p.buffer:
+			if batch = append(batch, event); len(batch) == size {
+				batch = p.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = p.flush(ctx, batch)
+		}
+	}
+}
+
+// flush delivers the batch returning it emptied
+func (p *AsyncPublisher) flush(ctx context.Context, batch []*Event) []*Event {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := p.Sink.PublishEvents(ctx, batch); err != nil {
+		atomic.AddInt64(&p.failed, int64(len(batch)))
+		if p.OnError != nil {
+			p.OnError(append([]*Event(nil), batch...), err)
+		}
+	} else {
+		atomic.AddInt64(&p.published, int64(len(batch)))
+	}
+	return batch[:0]
+}
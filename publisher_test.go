@@ -0,0 +1,53 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]*Event
+	err     error
+}
+
+func (s *recordingSink) PublishEvents(_ context.Context, events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]*Event(nil), events...))
+	return s.err
+}
+
+func TestAsyncPublisher(t *testing.T) {
+	sink := new(recordingSink)
+	p := NewAsyncPublisher(sink, 3)
+	p.BatchSize = 2
+	p.FlushInterval = time.Hour
+	for i := 0; i < 4; i++ {
+		p.Publish(&Event{Type: TokenIssuedEvent})
+	}
+	if stats := p.Stats(); stats.Dropped != 1 {
+		t.Fatalf("Error stats = %+v", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Run(ctx); err != context.Canceled {
+		t.Fatalf("Error %v", err)
+	}
+	if stats := p.Stats(); stats.Published != 3 || len(sink.batches) != 2 {
+		t.Fatalf("Error stats = %+v, batches = %d", stats, len(sink.batches))
+	}
+
+	sink.err = errors.New("broker unavailable")
+	var failed []*Event
+	p.OnError = func(events []*Event, err error) { failed = events }
+	p.Publish(&Event{Type: TokenRevokedEvent})
+	_ = p.Run(ctx)
+	if stats := p.Stats(); stats.Failed != 1 || len(failed) != 1 {
+		t.Fatalf("Error stats = %+v", stats)
+	}
+}
@@ -0,0 +1,118 @@
+package oauth
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles the token requests of each client.
+type RateLimiter interface {
+	// Allow reports whether the request identified by the key can proceed, otherwise how long to wait before retrying
+	Allow(key string) (bool, time.Duration)
+}
+
+// OverloadError is returned by the verifier methods to signal an overload of their backend,
+// the server renders temporarily_unavailable with the Retry-After header instead of a generic error.
+type OverloadError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *OverloadError) Error() string {
+	if e.Err == nil {
+		return "overloaded"
+	}
+	return "overloaded: " + e.Err.Error()
+}
+
+func (e *OverloadError) Unwrap() error {
+	return e.Err
+}
+
+// overloaded returns the temporarily_unavailable response when the error signals an overload
+func overloaded(err error) (ErrorResponse, bool) {
+	var oe *OverloadError
+	if !errors.As(err, &oe) {
+		return ErrorResponse{}, false
+	}
+	return ErrorResponse{Error: TokenTemporarilyUnavailable, Description: "the server is temporarily unavailable", URI: "", RetryAfter: oe.RetryAfter}, true
+}
+
+// rateLimited renders temporarily_unavailable when the RateLimiter denies the request. The requests are keyed by the
+// client address (resolved by the ClientIPResolver when set), never by the client_id or the credential chosen by the
+// caller, which runs before the client is authenticated.
+func (bs *BearerServer) rateLimited(next GrantHandler) GrantHandler {
+	return func(gc *GrantContext) (interface{}, int) {
+		var key string
+		if ip := gc.ClientIP; ip != nil {
+			key = ip.String()
+		} else if ip = parseHostIP(gc.RemoteAddr); ip != nil {
+			key = ip.String()
+		}
+		if ok, retryAfter := bs.RateLimiter.Allow(string(gc.GrantType) + "|" + key); !ok {
+			return ErrorResponse{Error: TokenTemporarilyUnavailable, Description: "too many requests", URI: "", RetryAfter: retryAfter}, http.StatusTooManyRequests
+		}
+		return next(gc)
+	}
+}
+
+// MemoryRateLimiter is an in-memory token bucket RateLimiter safe for concurrent use.
+type MemoryRateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimiter creates a MemoryRateLimiter allowing rate requests per second per key with bursts of burst requests.
+func NewMemoryRateLimiter(rate float64, burst int) *MemoryRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &MemoryRateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the bucket of the key, otherwise returns the time until the next token
+func (l *MemoryRateLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// PurgeExpired removes the buckets refilled before now, returning how many were removed
+func (l *MemoryRateLimiter) PurgeExpired(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for key, b := range l.buckets {
+		if l.rate > 0 && b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type overloadedVerifier struct {
+	TestUserVerifier
+}
+
+func (overloadedVerifier) ValidateClient(clientID, clientSecret, scope string, r *http.Request) error {
+	return &OverloadError{RetryAfter: 1500 * time.Millisecond, Err: errors.New("database pool exhausted")}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	l := NewMemoryRateLimiter(1, 2)
+	if ok, _ := l.Allow("abcdef"); !ok {
+		t.Fatalf("Error request denied")
+	}
+	if ok, _ := l.Allow("abcdef"); !ok {
+		t.Fatalf("Error burst denied")
+	}
+	ok, retryAfter := l.Allow("abcdef")
+	if ok || retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("Error allowed = %v, retryAfter = %s", ok, retryAfter)
+	}
+	if ok, _ = l.Allow("other"); !ok {
+		t.Fatalf("Error other key denied")
+	}
+}
+
+func TestRateLimitedGrant(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RateLimiter = NewMemoryRateLimiter(0.5, 1)
+
+	post := func() *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}}
+		req := httptest.NewRequest("POST", "/auth", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		sut.ClientCredentials(w, req)
+		return w
+	}
+	if w := post(); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	w := post()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" || !strings.Contains(w.Body.String(), "temporarily_unavailable") {
+		t.Fatalf("Error StatusCode = %d, Retry-After = %s, body = %s", w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+}
+
+func TestVerifierOverload(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(overloadedVerifier), nil)
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}}
+	req := httptest.NewRequest("POST", "/auth", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.ClientCredentials(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("Error StatusCode = %d, Retry-After = %s", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitedByAddress(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RateLimiter = NewMemoryRateLimiter(0.5, 1)
+
+	post := func(username, remoteAddr string) int {
+		form := url.Values{"grant_type": {"password"}, "username": {username}, "password": {"password111"}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		return w.Code
+	}
+	if code := post("user111", "192.0.2.1:1234"); code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if code := post("user222", "192.0.2.1:5678"); code != http.StatusTooManyRequests {
+		t.Fatalf("Error username rotation not throttled, StatusCode = %d", code)
+	}
+	if code := post("user111", "192.0.2.2:1234"); code == http.StatusTooManyRequests {
+		t.Fatalf("Error other address throttled")
+	}
+}
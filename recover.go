@@ -0,0 +1,47 @@
+package oauth
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanic, deferred by the handlers, renders the panics of the verifiers and the stores as a server_error response
+// and reports them to OnPanic. http.ErrAbortHandler is propagated to net/http.
+func (bs *BearerServer) recoverPanic(w http.ResponseWriter, r *http.Request) {
+	if reportPanic(recover(), w, r, bs.OnPanic) {
+		bs.renderError(w, r, TokenServerError, "internal server error", "", http.StatusInternalServerError)
+	}
+}
+
+// recoverPanic, deferred by the middlewares while they authenticate the request, renders the panics of the stores and
+// the PolicyDecider as a 500 response and reports them to OnPanic. http.ErrAbortHandler is propagated to net/http.
+func (ba *BearerAuthentication) recoverPanic(w http.ResponseWriter, r *http.Request) {
+	if reportPanic(recover(), w, r, ba.OnPanic) {
+		renderJSON(w, "Internal server error", true, http.StatusInternalServerError)
+	}
+}
+
+// reportPanic re-panics http.ErrAbortHandler, reports the other recovered values to onPanic and returns true when the
+// error response can be rendered, i.e. the response wrapped by trackResponse has not started.
+func reportPanic(recovered interface{}, w http.ResponseWriter, r *http.Request, onPanic func(r *http.Request, recovered interface{}, stack []byte)) bool {
+	if recovered == nil {
+		return false
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+	if onPanic != nil {
+		onPanic(r, recovered, debug.Stack())
+	}
+	sw, ok := w.(*statusWriter)
+	return !ok || sw.status == 0
+}
+
+// trackResponse wraps the ResponseWriter, unless already wrapped, so recoverPanic does not render an error in a
+// response already started
+func trackResponse(w http.ResponseWriter) http.ResponseWriter {
+	if _, ok := w.(*statusWriter); ok {
+		return w
+	}
+	return &statusWriter{ResponseWriter: w}
+}
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type panickingVerifier struct {
+	TestUserVerifier
+}
+
+func (panickingVerifier) ValidateUser(username, password, scope string, r *http.Request) error {
+	panic("nil map")
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var recovered interface{}
+	var stack []byte
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(panickingVerifier), nil)
+	sut.OnPanic = func(r *http.Request, rec interface{}, s []byte) { recovered, stack = rec, s }
+
+	form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.Token(w, req)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), string(TokenServerError)) || strings.Contains(w.Body.String(), "nil map") {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if recovered != "nil map" || len(stack) == 0 {
+		t.Fatalf("Error OnPanic recovered = %v", recovered)
+	}
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Fatalf("Error recovered = %v", rec)
+		}
+	}()
+	func() {
+		defer sut.recoverPanic(httptest.NewRecorder(), req)
+		panic(http.ErrAbortHandler)
+	}()
+}
+
+type panickingDecider struct{}
+
+func (panickingDecider) Decide(ctx context.Context, input *PolicyInput) (bool, error) {
+	panic("nil map")
+}
+
+func TestRecoverMiddlewarePanic(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	var recovered interface{}
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.PolicyDecider = panickingDecider{}
+	mut.OnPanic = func(r *http.Request, rec interface{}, s []byte) { recovered = rec }
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { t.Fatalf("Error next handler called") })
+	for name, handler := range map[string]http.Handler{
+		"Authorize":       mut.Authorize(next),
+		"AuthorizeStream": mut.AuthorizeStream(next),
+		"AuthorizeRoutes": mut.AuthorizeRoutes(RoutePolicy{})(next),
+	} {
+		recovered = nil
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+resp.(*TokenResponse).Token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError || recovered != "nil map" {
+			t.Fatalf("Error %s StatusCode = %d, recovered = %v", name, w.Code, recovered)
+		}
+	}
+}
+
+func TestRecoverPanicStartedResponse(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	func() {
+		tw := trackResponse(w)
+		defer sut.recoverPanic(tw, req)
+		tw.WriteHeader(http.StatusAccepted)
+		_, _ = tw.Write([]byte("partial"))
+		panic("nil map")
+	}()
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+}
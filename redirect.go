@@ -0,0 +1,152 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// RedirectURIClass is the kind of a redirect URI, see https://datatracker.ietf.org/doc/html/rfc8252#section-7
+type RedirectURIClass string
+
+const (
+	// HTTPSRedirect is a web or claimed https redirect URI (RFC 8252 §7.2)
+	HTTPSRedirect RedirectURIClass = "https"
+	// LoopbackRedirect is a http redirect URI on the loopback interface (RFC 8252 §7.3), any port is accepted
+	LoopbackRedirect RedirectURIClass = "loopback"
+	// CustomSchemeRedirect is a private-use URI scheme redirect URI (RFC 8252 §7.1)
+	CustomSchemeRedirect RedirectURIClass = "custom_scheme"
+)
+
+var (
+	// ErrInvalidRedirectURI is returned for the redirect URIs rejected by the RedirectURIPolicy.
+	ErrInvalidRedirectURI = errors.New("invalid redirect_uri")
+	// ErrRedirectURIMismatch is returned when the redirect URI is not registered for the client.
+	ErrRedirectURIMismatch = errors.New("redirect_uri is not registered for the client")
+)
+
+// RedirectURIPolicy decides which classes of redirect URIs can be registered.
+type RedirectURIPolicy struct {
+	AllowCustomSchemes bool
+	AllowLoopback      bool
+	// AllowLocalhost accepts the "localhost" loopback host, NOT RECOMMENDED by RFC 8252 §8.3
+	AllowLocalhost bool
+	// RequireReverseDomainScheme requires the custom schemes to be reverse domain names, e.g. "com.example.app"
+	RequireReverseDomainScheme bool
+}
+
+// DefaultRedirectURIPolicy accepts the https, loopback IP and reverse domain custom scheme redirect URIs
+var DefaultRedirectURIPolicy = RedirectURIPolicy{AllowCustomSchemes: true, AllowLoopback: true, RequireReverseDomainScheme: true}
+
+// forbiddenSchemes can execute code or read local content in the user agent
+var forbiddenSchemes = map[string]bool{"javascript": true, "data": true, "file": true, "vbscript": true, "blob": true, "about": true}
+
+// ClassifyRedirectURI returns the class of the redirect URI, rejecting the relative URIs, the fragments, the wildcards
+// and the plain http URIs outside of the loopback interface
+func ClassifyRedirectURI(uri string) (RedirectURIClass, error) {
+	if strings.Contains(uri, "*") {
+		return "", fmt.Errorf("%w: wildcards are not allowed", ErrInvalidRedirectURI)
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return "", fmt.Errorf("%w: not an absolute URI", ErrInvalidRedirectURI)
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return "", fmt.Errorf("%w: fragments are not allowed", ErrInvalidRedirectURI)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch {
+	case forbiddenSchemes[scheme]:
+		return "", fmt.Errorf("%w: scheme %s is not allowed", ErrInvalidRedirectURI, scheme)
+	case scheme == "https":
+		if u.Host == "" || u.User != nil {
+			return "", fmt.Errorf("%w: invalid host", ErrInvalidRedirectURI)
+		}
+		return HTTPSRedirect, nil
+	case scheme == "http":
+		if u.User != nil || !isLoopbackHost(u.Hostname()) {
+			return "", fmt.Errorf("%w: http is only allowed on the loopback interface", ErrInvalidRedirectURI)
+		}
+		return LoopbackRedirect, nil
+	}
+	return CustomSchemeRedirect, nil
+}
+
+// Validate checks that the redirect URI can be registered under the policy and returns its class
+func (p RedirectURIPolicy) Validate(uri string) (RedirectURIClass, error) {
+	class, err := ClassifyRedirectURI(uri)
+	if err != nil {
+		return "", err
+	}
+	switch class {
+	case LoopbackRedirect:
+		if !p.AllowLoopback {
+			return "", fmt.Errorf("%w: loopback redirect URIs are not allowed", ErrInvalidRedirectURI)
+		}
+		if u, _ := url.Parse(uri); !p.AllowLocalhost && strings.EqualFold(u.Hostname(), "localhost") {
+			return "", fmt.Errorf("%w: use the loopback IP literal instead of localhost", ErrInvalidRedirectURI)
+		}
+	case CustomSchemeRedirect:
+		if !p.AllowCustomSchemes {
+			return "", fmt.Errorf("%w: custom scheme redirect URIs are not allowed", ErrInvalidRedirectURI)
+		}
+		if u, _ := url.Parse(uri); p.RequireReverseDomainScheme && !strings.Contains(u.Scheme, ".") {
+			return "", fmt.Errorf("%w: custom schemes must be reverse domain names", ErrInvalidRedirectURI)
+		}
+	}
+	return class, nil
+}
+
+// ValidateClient checks all the redirect URIs of the client registration
+func (p RedirectURIPolicy) ValidateClient(c *Client) error {
+	for _, uri := range c.RedirectURIs {
+		if _, err := p.Validate(uri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchRedirectURI returns true if the redirect URI is registered for the client: the URIs are compared exactly,
+// except the port of the http redirect URIs on the 127.0.0.1 and [::1] loopback IP literals (RFC 8252 §7.3)
+func (c *Client) MatchRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if uri == registered {
+			return true
+		}
+	}
+	requested, ok := loopbackWithoutPort(uri)
+	if !ok {
+		return false
+	}
+	for _, registered := range c.RedirectURIs {
+		if r, ok := loopbackWithoutPort(registered); ok && r == requested {
+			return true
+		}
+	}
+	return false
+}
+
+// loopbackWithoutPort returns the loopback IP literal redirect URI without its port
+func loopbackWithoutPort(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" || u.User != nil || (u.Hostname() != "127.0.0.1" && u.Hostname() != "::1") {
+		return "", false
+	}
+	u.Host = u.Hostname()
+	if strings.Contains(u.Host, ":") {
+		u.Host = "[" + u.Host + "]"
+	}
+	return u.String(), true
+}
+
+// isLoopbackHost returns true for the loopback IP literals and localhost
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
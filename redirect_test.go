@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassifyRedirectURI(t *testing.T) {
+	valid := map[string]RedirectURIClass{
+		"https://app.example.com/cb":         HTTPSRedirect,
+		"http://127.0.0.1:8080/cb":           LoopbackRedirect,
+		"http://[::1]/cb":                    LoopbackRedirect,
+		"com.example.app:/oauth2redirect":    CustomSchemeRedirect,
+		"http://localhost:3000/callback?x=1": LoopbackRedirect,
+	}
+	for uri, want := range valid {
+		if class, err := ClassifyRedirectURI(uri); err != nil || class != want {
+			t.Fatalf("Error %s: class = %s, %v", uri, class, err)
+		}
+	}
+	for _, uri := range []string{"/cb", "https://*.example.com/cb", "https://app.example.com/cb#frag", "http://app.example.com/cb", "javascript:alert(1)", "https://user@app.example.com/cb"} {
+		if _, err := ClassifyRedirectURI(uri); !errors.Is(err, ErrInvalidRedirectURI) {
+			t.Fatalf("Error %s accepted: %v", uri, err)
+		}
+	}
+
+	policy := DefaultRedirectURIPolicy
+	for _, uri := range []string{"http://localhost:3000/cb", "myapp:/cb"} {
+		if _, err := policy.Validate(uri); err == nil {
+			t.Fatalf("Error %s accepted by the default policy", uri)
+		}
+	}
+	policy.AllowCustomSchemes = false
+	if err := policy.ValidateClient(&Client{RedirectURIs: []string{"https://app.example.com/cb", "com.example.app:/cb"}}); err == nil {
+		t.Fatalf("Error custom scheme accepted")
+	}
+}
+
+func TestMatchRedirectURI(t *testing.T) {
+	c := &Client{RedirectURIs: []string{"https://app.example.com/cb", "http://127.0.0.1/cb", "http://[::1]:9000/cb", "http://localhost/cb"}}
+	for uri, want := range map[string]bool{
+		"https://app.example.com/cb":      true,
+		"https://app.example.com/cb/":     false,
+		"https://app.example.com:8443/cb": false,
+		"http://127.0.0.1:51004/cb":       true,
+		"http://127.0.0.1:51004/other":    false,
+		"http://[::1]:4242/cb":            true,
+		"http://127.0.0.2/cb":             false,
+		"http://127.0.0.1:51004/cb?x=1":   false,
+		"http://localhost/cb":             true,
+		"http://localhost:8080/cb":        false,
+	} {
+		if got := c.MatchRedirectURI(uri); got != want {
+			t.Fatalf("Error %s: match = %v", uri, got)
+		}
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.StatelessAuthorizationCodes = true
+	sut.CodeReplayCache = NewMemoryReplayCache()
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{AuthCodeGrant}, RedirectURIs: []string{"https://client/cb"}})
+	if _, err := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://evil/cb", Credential: "user111"}); err != ErrRedirectURIMismatch {
+		t.Fatalf("Error %v", err)
+	}
+	if _, err := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111"}); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{AuthCodeGrant}})
+	if _, err := sut.IssueAuthorizationCode(&AuthorizationCode{ClientID: "abcdef", RedirectURI: "https://client/cb", Credential: "user111"}); err != ErrRedirectURIMismatch {
+		t.Fatalf("Error client without redirect URIs: %v", err)
+	}
+}
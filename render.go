@@ -3,7 +3,11 @@ package oauth
 import (
 	"bytes"
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 )
 
 // ErrorResponseType ...
@@ -87,27 +91,156 @@ type ErrorResponse struct {
 	Description string            `json:"error_description"`
 	URI         string            `json:"error_uri,omitempty"`
 	State       string            `json:"state,omitempty"`
+	RequestID   string            `json:"request_id,omitempty"`
+	// RetryAfter is rendered as the Retry-After header of the temporarily_unavailable errors
+	RetryAfter time.Duration `json:"-"`
 }
 
-func renderError(w http.ResponseWriter, error ErrorResponseType, description, uri string, statusCode int) {
-	renderJSON(w, ErrorResponse{Error: error, Description: description, URI: uri}, false, statusCode)
+// renderError renders the error response applying the StatusMapper
+func (bs *BearerServer) renderError(w http.ResponseWriter, r *http.Request, error ErrorResponseType, description, uri string, statusCode int) {
+	bs.renderResponse(w, r, ErrorResponse{Error: error, Description: description, URI: uri}, false, statusCode)
 }
 
+// renderResponse renders the token or error response applying the OnTokenResponse hook to the tokens not wrapped
+// and the StatusMapper (and ProblemDetails) to the errors, the fields of both being renamed by the ResponseFields. The responses are
+// encoded with the registered encoder of the media type negotiated with the Accept header, JSON by default.
+func (bs *BearerServer) renderResponse(w http.ResponseWriter, r *http.Request, resp interface{}, noStore bool, statusCode int) {
+	if r != nil && len(bs.DeprecatedGrants) > 0 {
+		bs.setDeprecationHeaders(w, r)
+	}
+	if t, ok := resp.(*TokenResponse); ok {
+		// the wrapped responses keep their refresh token instead of setting the RefreshCookie
+		if ttl, err := bs.wrapTTL(r); err != nil {
+			resp, statusCode = ErrorResponse{Error: TokenInvalidRequest, Description: err.Error()}, http.StatusBadRequest
+		} else {
+			if bs.RefreshCookie != nil && ttl == 0 {
+				bs.setRefreshCookie(w, t)
+			}
+			if bs.OnTokenResponse != nil && r != nil && ttl == 0 {
+				bs.OnTokenResponse(r.Context(), GrantType(r.FormValue("grant_type")), t, w, r)
+			}
+			if ttl > 0 {
+				resp, statusCode = bs.wrap(t, ttl)
+			}
+		}
+	}
+	if e, ok := resp.(ErrorResponse); ok {
+		if r != nil {
+			if e.RequestID == "" {
+				e.RequestID = RequestIDFromContext(r.Context())
+			}
+			e.Description = bs.translate(e.Description, r.Header.Get("Accept-Language"))
+		}
+		if e.URI == "" {
+			e.URI = bs.ErrorURIs.URI(e.Error)
+		}
+		resp = e
+		if e.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+		}
+		if bs.StatusMapper != nil {
+			statusCode = bs.StatusMapper(e.Error, statusCode)
+		}
+		if bs.ProblemDetails {
+			resp = bs.problem(e, statusCode)
+		}
+	}
+	bs.renderNegotiated(w, r, resp, noStore, statusCode)
+}
+
+// renderNegotiated renders the response with the field names of the ResponseFields in the media type negotiated
+// with the registered encoders
+func (bs *BearerServer) renderNegotiated(w http.ResponseWriter, r *http.Request, resp interface{}, noStore bool, statusCode int) {
+	_, problem := resp.(*Problem)
+	resp = bs.ResponseFields.rename(r, resp)
+	if len(bs.encoders) > 0 {
+		w.Header().Add("Vary", "Accept")
+		if mediaType, encoder := bs.negotiateEncoder(r); encoder != nil {
+			renderEncoded(w, mediaType, encoder, resp, noStore, statusCode)
+			return
+		}
+	}
+	if problem {
+		renderJSONAs(w, ProblemMediaType, resp, noStore, statusCode)
+		return
+	}
+	renderJSON(w, resp, noStore, statusCode)
+}
+
+// maxBufferedRender is the size up to which the responses of renderJSON are buffered and sent with their
+// Content-Length, the larger responses (introspection, JWKS, usage listings, ...) are encoded straight to the
+// ResponseWriter without Content-Length
+const maxBufferedRender = 64 * 1024
+
+// renderBuffers pools the buffers of renderJSON
+var renderBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
 // renderJSON marshals 'v' to JSON, automatically escaping HTML, setting the
 // Content-Type as application/json, and sending the status code header.
+// The responses up to maxBufferedRender carry their Content-Length, so the HEAD responses do too.
 func renderJSON(w http.ResponseWriter, v interface{}, noStore bool, statusCode int) {
-	buf := &bytes.Buffer{}
-	enc := json.NewEncoder(buf)
+	renderJSONAs(w, JSONMediaType, v, noStore, statusCode)
+}
+
+// renderJSONAs renders 'v' as JSON with the media type of a JSON format, e.g. application/problem+json
+func renderJSONAs(w http.ResponseWriter, mediaType string, v interface{}, noStore bool, statusCode int) {
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxBufferedRender {
+			renderBuffers.Put(buf)
+		}
+	}()
+
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	if noStore {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	rw := &renderWriter{w: w, buf: buf, statusCode: statusCode}
+	enc := json.NewEncoder(rw)
 	enc.SetEscapeHTML(true)
 	if err := enc.Encode(v); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if !rw.streaming {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if noStore {
-		w.Header().Set("Cache-Control", "no-store")
+	if rw.streaming {
+		return
 	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(statusCode)
 	_, _ = w.Write(buf.Bytes())
 }
+
+// renderWriter buffers the encoded response up to maxBufferedRender, the larger responses are written through to
+// the ResponseWriter after the status code
+type renderWriter struct {
+	w          http.ResponseWriter
+	buf        *bytes.Buffer
+	statusCode int
+	streaming  bool
+}
+
+func (rw *renderWriter) Write(p []byte) (int, error) {
+	if !rw.streaming && rw.buf.Len()+len(p) <= maxBufferedRender {
+		return rw.buf.Write(p)
+	}
+	if !rw.streaming {
+		rw.streaming = true
+		rw.w.WriteHeader(rw.statusCode)
+		if _, err := rw.w.Write(rw.buf.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return rw.w.Write(p)
+}
+
+// headResponseWriter discards the body of the HEAD responses, keeping their headers and Content-Length
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
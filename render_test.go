@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusMapper(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.StatusMapper = func(errorType ErrorResponseType, status int) int {
+		if status == http.StatusUnauthorized {
+			return http.StatusBadRequest
+		}
+		return status
+	}
+
+	req := httptest.NewRequest("POST", "/token", nil)
+	req.Header.Set("Authorization", "Basic !!!")
+	w := httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/token?grant_type=password&username=user111&password=password111", nil)
+	w = httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
+
+func TestOnTokenResponse(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.OnTokenResponse = func(ctx context.Context, grantType GrantType, resp *TokenResponse, w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "grant", Value: string(grantType), HttpOnly: true})
+		resp.Properties = Properties{"hooked": "true"}
+	}
+
+	req := httptest.NewRequest("POST", "/token?grant_type=password&username=user111&password=password111", nil)
+	w := httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Set-Cookie"), "grant=password") || !strings.Contains(w.Body.String(), `"hooked":"true"`) {
+		t.Fatalf("Error StatusCode = %d, headers = %v, body = %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/token?grant_type=password&username=user111&password=wrong", nil)
+	w = httptest.NewRecorder()
+	sut.UserCredentials(w, req)
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Fatalf("Error hook called for an error response")
+	}
+}
+
+func TestRenderJSONContentLength(t *testing.T) {
+	w := httptest.NewRecorder()
+	renderJSON(w, map[string]string{"status": "ok"}, true, http.StatusCreated)
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Error StatusCode = %d, headers = %v", w.Code, w.Header())
+	}
+
+	large := make([]string, 10000)
+	for i := range large {
+		large[i] = "key-" + strconv.Itoa(i)
+	}
+	w = httptest.NewRecorder()
+	renderJSON(w, large, false, http.StatusOK)
+	var decoded []string
+	if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil || len(decoded) != len(large) || w.Code != http.StatusOK || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("Error large response: %v, headers = %v", err, w.Header())
+	}
+}
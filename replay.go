@@ -0,0 +1,54 @@
+package oauth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrReplayed is returned by the ReplayCache when the identifier has already been consumed.
+var ErrReplayed = errors.New("identifier already consumed")
+
+// ReplayCache records single use identifiers (request object jti, request_uri, stateless authorization codes)
+// so the same request cannot be consumed twice. Implementations backed by shared storage protect clustered deployments.
+type ReplayCache interface {
+	// Consume records the identifier for the ttl, returning ErrReplayed if it is already recorded
+	Consume(id string, ttl time.Duration) error
+}
+
+// MemoryReplayCache is an in-process ReplayCache safe for concurrent use.
+type MemoryReplayCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// NewMemoryReplayCache creates an empty MemoryReplayCache.
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{ids: make(map[string]time.Time)}
+}
+
+// Consume records the identifier for the ttl, returning ErrReplayed if it is already recorded
+func (c *MemoryReplayCache) Consume(id string, ttl time.Duration) error {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if expiry, ok := c.ids[id]; ok && now.Before(expiry) {
+		return ErrReplayed
+	}
+	c.ids[id] = now.Add(ttl)
+	return nil
+}
+
+// PurgeExpired removes the identifiers expired before now, returning how many were removed
+func (c *MemoryReplayCache) PurgeExpired(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for id, expiry := range c.ids {
+		if !now.Before(expiry) {
+			delete(c.ids, id)
+			n++
+		}
+	}
+	return n
+}
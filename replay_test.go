@@ -0,0 +1,25 @@
+package oauth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayCache(t *testing.T) {
+	cache := NewMemoryReplayCache()
+	if err := cache.Consume("jti-1", time.Minute); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if err := cache.Consume("jti-1", time.Minute); err != ErrReplayed {
+		t.Fatalf("Error should be ErrReplayed: %v", err)
+	}
+	if err := cache.Consume("jti-2", -time.Second); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if n := cache.PurgeExpired(time.Now()); n != 1 {
+		t.Fatalf("Error purged = %d", n)
+	}
+	if err := cache.Consume("jti-2", time.Minute); err != nil {
+		t.Fatalf("Error expired identifier should be accepted: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+)
+
+// RequestIDHeader is the header carrying the request id.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDContext is the context key of the request id.
+const RequestIDContext contextKey = "oauth.requestid"
+
+// maxRequestIDLength bounds the incoming request ids
+const maxRequestIDLength = 128
+
+// RequestIDFromContext returns the request id of the context, empty if none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDContext).(string)
+	return id
+}
+
+// withRequestID honors the request id of the incoming request or generates one, sets it in the request context
+// and in the response header
+func (bs *BearerServer) withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	if !bs.PropagateRequestIDs {
+		return r
+	}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return r
+	}
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.Must(uuid.NewV4()).String()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), RequestIDContext, id))
+}
+
+// validRequestID accepts the printable ASCII ids, so they can be logged safely
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type requestIDVerifier struct {
+	TestUserVerifier
+	seen string
+}
+
+func (v *requestIDVerifier) ValidateUser(username, password, scope string, r *http.Request) error {
+	v.seen = RequestIDFromContext(r.Context())
+	return v.TestUserVerifier.ValidateUser(username, password, scope, r)
+}
+
+func TestRequestID(t *testing.T) {
+	verifier := new(requestIDVerifier)
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, verifier, nil)
+	sut.PropagateRequestIDs = true
+
+	post := func(password, requestID string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if requestID != "" {
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		return w
+	}
+
+	w := post("password111", "req-1")
+	if w.Code != http.StatusOK || w.Header().Get(RequestIDHeader) != "req-1" || verifier.seen != "req-1" {
+		t.Fatalf("Error StatusCode = %d, header = %s, seen = %s", w.Code, w.Header().Get(RequestIDHeader), verifier.seen)
+	}
+
+	w = post("wrong", "bad id\n")
+	id := w.Header().Get(RequestIDHeader)
+	if id == "" || id == "bad id\n" || verifier.seen != id {
+		t.Fatalf("Error request id = %q, seen = %q", id, verifier.seen)
+	}
+	if !strings.Contains(w.Body.String(), `"request_id":"`+id+`"`) {
+		t.Fatalf("Error body = %s", w.Body.String())
+	}
+
+	sut.PropagateRequestIDs = false
+	if w = post("password111", "req-2"); w.Header().Get(RequestIDHeader) != "" || verifier.seen != "" {
+		t.Fatalf("Error request id propagated while disabled")
+	}
+}
@@ -0,0 +1,154 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown is how long the circuit stays open when the RetryPolicy BreakerCooldown is zero.
+const DefaultBreakerCooldown = 30 * time.Second
+
+// RetryPolicy configures the retries and the circuit breaker of the ResilientVerifier.
+type RetryPolicy struct {
+	// Retries is the number of retries of the retryable errors, the backoff doubles after each retry
+	Retries int
+	// Backoff is the delay before the first retry, DefaultStoreBackoff when zero
+	Backoff time.Duration
+	// Jitter randomizes the backoff by this fraction, e.g. 0.2 for ±20%
+	Jitter float64
+	// BreakerThreshold consecutive failed calls open the circuit, 0 disables the circuit breaker
+	BreakerThreshold int
+	// BreakerCooldown is how long the open circuit fails the calls fast before letting one through, DefaultBreakerCooldown when zero
+	BreakerCooldown time.Duration
+	// Retryable reports whether the error is a datastore blip, by default the errors with a Temporary() or Timeout()
+	// method returning true and context.DeadlineExceeded. The other errors are returned as they are.
+	Retryable func(err error) bool
+}
+
+// ResilientVerifier decorates the StoreTokenID and ValidateTokenID methods of a verifier with retries, jittered backoff
+// and a circuit breaker. The open circuit fails the calls with an OverloadError, rendered as temporarily_unavailable.
+// The optional verifier interfaces (AuthorizationCodeVerifier, ExtensionsVerifier, ...) are looked up on the decorated verifier.
+type ResilientVerifier struct {
+	CredentialsVerifier
+	policy RetryPolicy
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewResilientVerifier decorates the verifier with the retry policy
+func NewResilientVerifier(verifier CredentialsVerifier, policy RetryPolicy) *ResilientVerifier {
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultStoreBackoff
+	}
+	if policy.BreakerCooldown <= 0 {
+		policy.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = isTemporary
+	}
+	return &ResilientVerifier{CredentialsVerifier: verifier, policy: policy}
+}
+
+// Unwrap returns the decorated verifier
+func (v *ResilientVerifier) Unwrap() CredentialsVerifier {
+	return v.CredentialsVerifier
+}
+
+// StoreTokenID calls the decorated StoreTokenID with the retry policy
+func (v *ResilientVerifier) StoreTokenID(tokenType TokenType, credential, tokenID, refreshTokenID string) error {
+	return v.call(func() error {
+		return v.CredentialsVerifier.StoreTokenID(tokenType, credential, tokenID, refreshTokenID)
+	})
+}
+
+// ValidateTokenID calls the decorated ValidateTokenID with the retry policy
+func (v *ResilientVerifier) ValidateTokenID(tokenType TokenType, credential, tokenID, refreshTokenID string) error {
+	return v.call(func() error {
+		return v.CredentialsVerifier.ValidateTokenID(tokenType, credential, tokenID, refreshTokenID)
+	})
+}
+
+// call runs fn through the circuit breaker, retrying its retryable errors
+func (v *ResilientVerifier) call(fn func() error) error {
+	if wait := v.open(); wait > 0 {
+		return &OverloadError{RetryAfter: wait, Err: errors.New("circuit open")}
+	}
+	backoff := v.policy.Backoff
+	err := fn()
+	for i := 0; err != nil && v.policy.Retryable(err) && i < v.policy.Retries; i++ {
+		time.Sleep(v.jittered(backoff))
+		backoff *= 2
+		err = fn()
+	}
+	v.record(err == nil || !v.policy.Retryable(err))
+	return err
+}
+
+// open returns the remaining cooldown of the open circuit, 0 when the call can proceed
+func (v *ResilientVerifier) open() time.Duration {
+	if v.policy.BreakerThreshold <= 0 {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if wait := time.Until(v.openUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// record counts the consecutive failures, opening the circuit at the threshold.
+// After the cooldown a single failure opens the circuit again (half-open).
+func (v *ResilientVerifier) record(success bool) {
+	if v.policy.BreakerThreshold <= 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if success {
+		v.failures = 0
+		return
+	}
+	v.failures++
+	if v.failures >= v.policy.BreakerThreshold {
+		v.openUntil = time.Now().Add(v.policy.BreakerCooldown)
+	}
+}
+
+// jittered returns the backoff randomized by the Jitter
+func (v *ResilientVerifier) jittered(backoff time.Duration) time.Duration {
+	if v.policy.Jitter > 0 {
+		delta := float64(backoff) * v.policy.Jitter
+		backoff += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	return backoff
+}
+
+// isTemporary reports whether the error is temporary or a timeout
+func isTemporary(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// optionalVerifier returns the verifier implementing the optional interfaces, unwrapping the ResilientVerifier
+func optionalVerifier(v CredentialsVerifier) CredentialsVerifier {
+	for {
+		w, ok := v.(interface{ Unwrap() CredentialsVerifier })
+		if !ok {
+			return v
+		}
+		v = w.Unwrap()
+	}
+}
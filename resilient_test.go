@@ -0,0 +1,62 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "connection reset" }
+func (temporaryError) Temporary() bool { return true }
+
+type blippingVerifier struct {
+	otpVerifier
+	err   error
+	calls int
+}
+
+func (v *blippingVerifier) StoreTokenID(tokenType TokenType, credential, tokenID, refreshTokenID string) error {
+	v.calls++
+	return v.err
+}
+
+func TestResilientVerifier(t *testing.T) {
+	inner := &blippingVerifier{err: temporaryError{}}
+	verifier := NewResilientVerifier(inner, RetryPolicy{Retries: 2, Backoff: time.Millisecond, Jitter: 0.5, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+
+	if err := verifier.StoreTokenID(UserToken, "user111", "1", "2"); err != (temporaryError{}) || inner.calls != 3 {
+		t.Fatalf("Error err = %v, calls = %d", err, inner.calls)
+	}
+	inner.calls, inner.err = 0, errors.New("duplicate token id")
+	if err := verifier.StoreTokenID(UserToken, "user111", "1", "2"); err == nil || inner.calls != 1 {
+		t.Fatalf("Error err = %v, calls = %d", err, inner.calls)
+	}
+
+	inner.calls, inner.err = 0, temporaryError{}
+	_ = verifier.StoreTokenID(UserToken, "user111", "1", "2")
+	_ = verifier.StoreTokenID(UserToken, "user111", "1", "2")
+	inner.calls = 0
+	var oe *OverloadError
+	if err := verifier.StoreTokenID(UserToken, "user111", "1", "2"); !errors.As(err, &oe) || oe.RetryAfter <= 0 || inner.calls != 0 {
+		t.Fatalf("Error err = %v, calls = %d", err, inner.calls)
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, verifier, nil)
+	if _, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request)); status != http.StatusServiceUnavailable {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+
+	inner.err = nil
+	sut = NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, NewResilientVerifier(inner, RetryPolicy{}), nil)
+	r, _ := http.NewRequest("POST", "/token?otp=123456", nil)
+	resp, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", r)
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if token, _ := sut.provider.DecryptToken(resp.(*TokenResponse).Token); token.Claims[AMRClaim] == nil {
+		t.Fatalf("Error optional verifier interface hidden by the decorator, claims = %v", token.Claims)
+	}
+}
@@ -0,0 +1,153 @@
+package oauth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DeviceClaim is the parameter and the token response field carrying the device identifier
+// or from the "device_id" parameter of the other grants
+const DeviceClaim = "device_id"
+
+// TokenMFARequired is returned when the RiskEvaluator requires a multi-factor authentication
+const TokenMFARequired ErrorResponseType = "mfa_required"
+
+// Velocity is the number of token requests seen in the VelocityCounter window, the current one included
+type Velocity struct {
+	Credential int
+	ClientIP   int
+}
+
+// RiskContext gathers the signals of a grant about to issue tokens.
+type RiskContext struct {
+	Grant *GrantContext
+	// GeoHint is the value of the server GeoHintHeader, set by the edge proxy or CDN
+	GeoHint string
+	// DeviceID is the device of the refreshed tokens or the "device_id" request parameter
+	DeviceID string
+	// TrustedDevice is set when the DeviceRegistry remembers the device, RequireMFA is then ignored
+	TrustedDevice bool
+	// Velocity is zero when the server has no VelocityCounter
+	Velocity Velocity
+}
+
+// RiskDecision is the outcome of the risk evaluation, the zero value allows the grant.
+type RiskDecision struct {
+	Deny       bool
+	RequireMFA bool
+	// MaxTTL, when positive, shortens the lifetime of the access and refresh tokens
+	MaxTTL time.Duration
+	// Reason is returned in the error description of the denied grants
+	Reason string
+}
+
+// RiskEvaluator scores the grants before the tokens generation, enabling adaptive authentication.
+type RiskEvaluator interface {
+	EvaluateRisk(rc *RiskContext) (RiskDecision, error)
+}
+
+// VelocityCounter counts the token requests per key over a sliding window.
+type VelocityCounter interface {
+	// Hit records a request of the key and returns the number of requests in the window
+	Hit(key string) (int, error)
+}
+
+// evaluateRisk calls the RiskEvaluator, the MaxTTL of the decision is kept in the grant context
+func (bs *BearerServer) evaluateRisk(gc *GrantContext) (interface{}, int) {
+	rc := &RiskContext{Grant: gc, DeviceID: gc.deviceID, TrustedDevice: gc.trustedDevice}
+	if r := gc.Request; r != nil {
+		if bs.GeoHintHeader != "" {
+			rc.GeoHint = r.Header.Get(bs.GeoHintHeader)
+		}
+		if rc.DeviceID == "" {
+			rc.DeviceID = r.FormValue(DeviceClaim)
+		}
+	}
+	if bs.VelocityCounter != nil {
+		var err error
+		if rc.Velocity.Credential, err = bs.VelocityCounter.Hit("credential:" + gc.Credential); err != nil {
+			return ErrorResponse{Error: TokenServerError, Description: "risk evaluation failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		}
+		if gc.ClientIP != nil {
+			if rc.Velocity.ClientIP, err = bs.VelocityCounter.Hit("ip:" + gc.ClientIP.String()); err != nil {
+				return ErrorResponse{Error: TokenServerError, Description: "risk evaluation failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+			}
+		}
+	}
+	decision, err := bs.RiskEvaluator.EvaluateRisk(rc)
+	if err != nil {
+		if resp, ok := overloaded(err); ok {
+			return resp, http.StatusServiceUnavailable
+		}
+		return ErrorResponse{Error: TokenServerError, Description: "risk evaluation failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	switch {
+	case decision.Deny:
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "grant denied: " + decision.Reason, URI: ""}, http.StatusBadRequest
+	case decision.RequireMFA && !rc.TrustedDevice:
+		return ErrorResponse{Error: TokenMFARequired, Description: "multi-factor authentication required: " + decision.Reason, URI: ""}, http.StatusForbidden
+	}
+	gc.maxTTL = decision.MaxTTL
+	return nil, 0
+}
+
+// capLifetimes shortens the lifetime of the tokens to max when positive
+func capLifetimes(token *Token, refresh *RefreshToken, max time.Duration) {
+	if max <= 0 {
+		return
+	}
+	if token.ExpiresIn <= 0 || token.ExpiresIn > max {
+		token.ExpiresIn = max
+	}
+	if refresh.ExpiresIn <= 0 || refresh.ExpiresIn > max {
+		refresh.ExpiresIn = max
+	}
+}
+
+// MemoryVelocityCounter is an in-memory sliding window VelocityCounter safe for concurrent use.
+type MemoryVelocityCounter struct {
+	mu     sync.Mutex
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+// NewMemoryVelocityCounter creates a MemoryVelocityCounter over the window
+func NewMemoryVelocityCounter(window time.Duration) *MemoryVelocityCounter {
+	return &MemoryVelocityCounter{window: window, hits: make(map[string][]time.Time)}
+}
+
+// Hit records a request of the key and returns the number of requests in the window
+func (c *MemoryVelocityCounter) Hit(key string) (int, error) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hits := append(c.recent(c.hits[key], now), now)
+	c.hits[key] = hits
+	return len(hits), nil
+}
+
+// PurgeExpired removes the keys without requests in the window and returns the number of keys removed
+func (c *MemoryVelocityCounter) PurgeExpired(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, hits := range c.hits {
+		if hits = c.recent(hits, now); len(hits) == 0 {
+			delete(c.hits, key)
+			n++
+		} else {
+			c.hits[key] = hits
+		}
+	}
+	return n
+}
+
+// recent drops the hits older than the window
+func (c *MemoryVelocityCounter) recent(hits []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) >= c.window {
+		i++
+	}
+	return hits[i:]
+}
@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRiskEvaluator struct {
+	last *RiskContext
+}
+
+func (e *testRiskEvaluator) EvaluateRisk(rc *RiskContext) (RiskDecision, error) {
+	e.last = rc
+	switch {
+	case rc.GeoHint == "XX":
+		return RiskDecision{Deny: true, Reason: "blocked country"}, nil
+	case rc.DeviceID == "":
+		return RiskDecision{RequireMFA: true, Reason: "unknown device"}, nil
+	case rc.Velocity.Credential > 2:
+		return RiskDecision{MaxTTL: time.Second * 5}, nil
+	}
+	return RiskDecision{}, nil
+}
+
+func TestRiskEvaluator(t *testing.T) {
+	evaluator := new(testRiskEvaluator)
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RiskEvaluator = evaluator
+	sut.VelocityCounter = NewMemoryVelocityCounter(time.Minute)
+	sut.GeoHintHeader = "CF-IPCountry"
+
+	post := func(device, country string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+		if device != "" {
+			form.Set(DeviceClaim, device)
+		}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("CF-IPCountry", country)
+		w := httptest.NewRecorder()
+		sut.UserCredentials(w, req)
+		return w
+	}
+
+	if w := post("device-1", "XX"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "blocked country") {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := post("", "FR"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), string(TokenMFARequired)) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := post("device-1", "FR"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"expires_in":5`) {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if evaluator.last.Velocity.Credential != 3 || evaluator.last.Velocity.ClientIP != 3 || evaluator.last.Grant.Credential != "user111" {
+		t.Fatalf("Error risk context = %+v", evaluator.last)
+	}
+}
+
+func TestMemoryVelocityCounterPurgeExpired(t *testing.T) {
+	c := NewMemoryVelocityCounter(time.Minute)
+	_, _ = c.Hit("a")
+	if n, _ := c.Hit("a"); n != 2 {
+		t.Fatalf("Error hits = %d", n)
+	}
+	if n := c.PurgeExpired(time.Now().Add(time.Minute)); n != 1 {
+		t.Fatalf("Error purged = %d", n)
+	}
+}
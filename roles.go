@@ -0,0 +1,119 @@
+package oauth
+
+import "net/http"
+
+// Role and permission claims, lists of strings
+const (
+	RolesClaim       = "roles"
+	PermissionsClaim = "permissions"
+)
+
+// RoleHierarchy resolves the roles granted by a role, e.g. "admin" grants "editor" which grants "viewer"
+type RoleHierarchy interface {
+	// ImpliedRoles returns the roles directly granted by the role
+	ImpliedRoles(role string) []string
+}
+
+// StaticRoleHierarchy is the RoleHierarchy mapping the roles to the roles they directly grant
+type StaticRoleHierarchy map[string][]string
+
+// ImpliedRoles returns the roles directly granted by the role
+func (h StaticRoleHierarchy) ImpliedRoles(role string) []string {
+	return h[role]
+}
+
+// Roles returns the roles claim
+func (c Claims) Roles() []string {
+	roles, _ := c.GetStrings(RolesClaim)
+	return roles
+}
+
+// Permissions returns the permissions claim
+func (c Claims) Permissions() []string {
+	permissions, _ := c.GetStrings(PermissionsClaim)
+	return permissions
+}
+
+// ExpandRoles returns the roles and all the roles they grant through the hierarchy, nil for no hierarchy
+func ExpandRoles(roles []string, hierarchy RoleHierarchy) []string {
+	expanded := make([]string, 0, len(roles))
+	seen := make(map[string]bool, len(roles))
+	pending := append([]string(nil), roles...)
+	for len(pending) > 0 {
+		role := pending[0]
+		pending = pending[1:]
+		if seen[role] {
+			continue
+		}
+		seen[role] = true
+		expanded = append(expanded, role)
+		if hierarchy != nil {
+			pending = append(pending, hierarchy.ImpliedRoles(role)...)
+		}
+	}
+	return expanded
+}
+
+// HasRole returns true when the roles claim, expanded through the hierarchy, contains one of the roles
+func (c Claims) HasRole(hierarchy RoleHierarchy, roles ...string) bool {
+	for _, granted := range ExpandRoles(c.Roles(), hierarchy) {
+		for _, role := range roles {
+			if granted == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPermissions returns true when the permissions claim contains all the permissions
+func (c Claims) HasPermissions(permissions ...string) bool {
+	granted := c.Permissions()
+	for _, permission := range permissions {
+		found := false
+		for _, g := range granted {
+			if g == permission {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireRole is the resource server middleware, after Authorize, rejecting with 403 the tokens without one of the roles
+func RequireRole(roles ...string) func(next http.Handler) http.Handler {
+	return RequireRoleIn(nil, roles...)
+}
+
+// RequireRoleIn is RequireRole granting the roles implied by the roles of the token through the hierarchy
+func RequireRoleIn(hierarchy RoleHierarchy, roles ...string) func(next http.Handler) http.Handler {
+	return requireClaims("insufficient role", func(claims Claims) bool {
+		return claims.HasRole(hierarchy, roles...)
+	})
+}
+
+// RequirePermission is the resource server middleware, after Authorize, rejecting with 403 the tokens without all the
+// permissions
+func RequirePermission(permissions ...string) func(next http.Handler) http.Handler {
+	return requireClaims("insufficient permissions", func(claims Claims) bool {
+		return claims.HasPermissions(permissions...)
+	})
+}
+
+// requireClaims is the middleware rejecting the tokens whose claims are not accepted
+func requireClaims(reason string, accept func(claims Claims) bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(ClaimsContext).(Claims)
+			if !accept(claims) {
+				renderJSON(w, "Not authorized: "+reason, true, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
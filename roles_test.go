@@ -0,0 +1,39 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	hierarchy := StaticRoleHierarchy{"admin": {"editor"}, "editor": {"viewer", "admin"}}
+	claims := Claims{RolesClaim: []interface{}{"admin"}, PermissionsClaim: []string{"orders:read", "orders:write"}}
+	if !claims.HasRole(hierarchy, "viewer") || claims.HasRole(nil, "viewer") || claims.HasRole(hierarchy, "owner") {
+		t.Fatalf("Error HasRole %v", ExpandRoles(claims.Roles(), hierarchy))
+	}
+	if !claims.HasPermissions("orders:read", "orders:write") || claims.HasPermissions("orders:read", "orders:delete") {
+		t.Fatalf("Error HasPermissions %v", claims.Permissions())
+	}
+
+	serve := func(middleware func(http.Handler) http.Handler) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ClaimsContext, claims))
+		w := httptest.NewRecorder()
+		middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := serve(RequireRole("admin")); code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if code := serve(RequireRole("viewer")); code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if code := serve(RequireRoleIn(hierarchy, "viewer")); code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if code := serve(RequirePermission("orders:delete")); code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+}
@@ -0,0 +1,114 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RoutePolicy declares the scopes required by the routes of a resource server, applied by a single AuthorizeRoutes
+// middleware instead of wrapping every handler. The patterns are "[METHOD ]/path": the path segments match path.Match
+// patterns (e.g. "/orders/*") and a trailing "/**" matches the whole subtree (e.g. "DELETE /admin/**").
+type RoutePolicy struct {
+	// Scopes maps the route patterns to the scopes all required by the matching requests. The pattern of the most
+	// specific path applies, the method only breaking the ties, and the requests matching no pattern only require
+	// a valid token.
+	Scopes map[string][]string
+	// Skip lists the route patterns of the public endpoints, let through without token
+	Skip []string
+}
+
+// AuthorizeRoutes is the Authorize middleware enforcing the policy: the skipped routes are public, the other requests
+// require a valid token with the scopes of their route, else get a 403 with the insufficient_scope challenge.
+func (ba *BearerAuthentication) AuthorizeRoutes(policy RoutePolicy) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authorize := ba.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if pattern, ok := policy.match(r); ok {
+				scopes := policy.Scopes[pattern]
+				if scope, _ := r.Context().Value(ScopeContext).(string); !hasScopes(scope, scopes...) {
+					renderInsufficientScope(w, scopes...)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		}))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, pattern := range policy.Skip {
+				if matchRoute(pattern, r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			authorize.ServeHTTP(w, r)
+		})
+	}
+}
+
+// match returns the most specific pattern of the Scopes matching the request: the most specific path, the method
+// patterns being preferred only between patterns of the same path specificity
+func (p RoutePolicy) match(r *http.Request) (string, bool) {
+	best := ""
+	found := false
+	for pattern := range p.Scopes {
+		if matchRoute(pattern, r) && (!found || moreSpecific(pattern, best)) {
+			best, found = pattern, true
+		}
+	}
+	return best, found
+}
+
+// moreSpecific ranks the paths of the patterns by their number of segments before a trailing "/**", the exact paths
+// before the subtrees and the longest paths first, then the method patterns before the others
+func moreSpecific(pattern, than string) bool {
+	p, method := routePath(pattern)
+	thanP, thanMethod := routePath(than)
+	subtree, thanSubtree := strings.HasSuffix(p, "/**"), strings.HasSuffix(thanP, "/**")
+	depth := strings.Count(strings.TrimSuffix(p, "/**"), "/")
+	thanDepth := strings.Count(strings.TrimSuffix(thanP, "/**"), "/")
+	switch {
+	case depth != thanDepth:
+		return depth > thanDepth
+	case subtree != thanSubtree:
+		return !subtree
+	case len(p) != len(thanP):
+		return len(p) > len(thanP)
+	case method != thanMethod:
+		return method
+	}
+	return pattern < than
+}
+
+// routePath returns the path of the "[METHOD ]/path" pattern and whether it has a method
+func routePath(pattern string) (string, bool) {
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		return strings.TrimSpace(pattern[i+1:]), true
+	}
+	return pattern, false
+}
+
+// matchRoute returns true when the request matches the "[METHOD ]/path" pattern
+func matchRoute(pattern string, r *http.Request) bool {
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		if !strings.EqualFold(pattern[:i], r.Method) {
+			return false
+		}
+		pattern = strings.TrimSpace(pattern[i+1:])
+	}
+	p := r.URL.Path
+	if prefix := strings.TrimSuffix(pattern, "/**"); prefix != pattern {
+		depth := strings.Count(prefix, "/")
+		if segments := strings.SplitN(p, "/", depth+2); len(segments) > depth+1 {
+			p = strings.Join(segments[:depth+1], "/")
+		}
+		pattern = prefix
+	}
+	matched, _ := path.Match(pattern, p)
+	return matched
+}
+
+// renderInsufficientScope renders the 403 with the RFC 6750 insufficient_scope challenge
+func renderInsufficientScope(w http.ResponseWriter, scopes ...string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopes, " ")))
+	renderJSON(w, "Not authorized: insufficient scope", true, http.StatusForbidden)
+}
@@ -0,0 +1,60 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizeRoutes(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "orders:read", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	policy := RoutePolicy{
+		Scopes: map[string][]string{
+			"/orders/**":      {"orders:read"},
+			"POST /orders/**": {"orders:write"},
+			"/admin/*":        {"oauth:admin"},
+		},
+		Skip: []string{"GET /health"},
+	}
+	handler := _mut.AuthorizeRoutes(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(method, path string, token bool) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token {
+			req.Header.Set("Authorization", "Bearer "+resp.(*TokenResponse).Token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	for _, c := range []struct {
+		method, path string
+		token        bool
+		expected     int
+	}{
+		{"GET", "/health", false, http.StatusOK},
+		{"POST", "/health", false, http.StatusUnauthorized},
+		{"GET", "/orders", true, http.StatusOK},
+		{"GET", "/orders/42/items", true, http.StatusOK},
+		{"GET", "/orders/42", false, http.StatusUnauthorized},
+		{"POST", "/orders/42", true, http.StatusForbidden},
+		{"GET", "/admin/clients", true, http.StatusForbidden},
+		{"GET", "/customers", true, http.StatusOK},
+	} {
+		if code = serve(c.method, c.path, c.token); code != c.expected {
+			t.Fatalf("Error %s %s StatusCode = %d", c.method, c.path, code)
+		}
+	}
+
+	// the method does not outrank a more specific path
+	policy = RoutePolicy{Scopes: map[string][]string{"/admin/**": {"oauth:admin"}, "GET /**": {"orders:read"}}}
+	handler = _mut.AuthorizeRoutes(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if code = serve("GET", "/admin/users", true); code != http.StatusForbidden {
+		t.Fatalf("Error GET /admin/users StatusCode = %d", code)
+	}
+	if code = serve("GET", "/orders", true); code != http.StatusOK {
+		t.Fatalf("Error GET /orders StatusCode = %d", code)
+	}
+}
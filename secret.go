@@ -0,0 +1,139 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SecretHashAlgorithm is the algorithm used to hash the client secrets.
+type SecretHashAlgorithm string
+
+const (
+	Argon2idSecretHash SecretHashAlgorithm = "argon2id"
+	BcryptSecretHash   SecretHashAlgorithm = "bcrypt"
+)
+
+var (
+	// ErrSecretMismatch is returned when the client secret does not match the hash.
+	ErrSecretMismatch = errors.New("client secret mismatch")
+	// ErrUnknownSecretHash is returned when the hash format or version is not recognized.
+	ErrUnknownSecretHash = errors.New("unknown client secret hash format")
+)
+
+// Argon2Params are the argon2id parameters used by HashClientSecret.
+type Argon2Params struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params follow the RFC 9106 second recommended option.
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Time: 3, Threads: 4, SaltLen: 16, KeyLen: 32}
+
+// Bounds of the argon2id parameters accepted by VerifyClientSecret, so a stored hash cannot exhaust the memory or the CPU
+const (
+	maxArgon2Memory = 1024 * 1024 // KiB
+	maxArgon2Time   = 32
+	maxArgon2KeyLen = 128
+)
+
+// HashClientSecret hashes the secret with argon2id using DefaultArgon2Params.
+// The result is self-describing (PHC string format) and can be stored in place of the plaintext secret.
+func HashClientSecret(secret string) (string, error) {
+	return HashClientSecretWith(Argon2idSecretHash, secret)
+}
+
+// HashClientSecretWith hashes the secret with the algorithm, the result carries a versioned prefix
+// ("$argon2id$v=19$..." or "$2a$...") used by VerifyClientSecret.
+func HashClientSecretWith(algorithm SecretHashAlgorithm, secret string) (string, error) {
+	switch algorithm {
+	case Argon2idSecretHash:
+		p := DefaultArgon2Params
+		salt := make([]byte, p.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(secret), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	case BcryptSecretHash:
+		hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+		return string(hash), err
+	default:
+		return "", fmt.Errorf("unsupported secret hash algorithm %q", algorithm)
+	}
+}
+
+// GenerateClientSecret returns a random client secret and its HashClientSecret hash
+func GenerateClientSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	hash, err = HashClientSecret(secret)
+	return secret, hash, err
+}
+
+// VerifyClientSecret checks the secret against a hash produced by HashClientSecret in constant time,
+// returning ErrSecretMismatch or ErrUnknownSecretHash.
+func VerifyClientSecret(hash, secret string) error {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2id(hash, secret)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return ErrSecretMismatch
+		}
+		return err
+	default:
+		return ErrUnknownSecretHash
+	}
+}
+
+func verifyArgon2id(hash, secret string) error {
+	// $argon2id$v=19$m=65536,t=3,p=4$salt$key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return ErrUnknownSecretHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return ErrUnknownSecretHash
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return ErrUnknownSecretHash
+	}
+	if p.Time < 1 || p.Time > maxArgon2Time || p.Threads < 1 || p.Memory < 8*uint32(p.Threads) || p.Memory > maxArgon2Memory {
+		return ErrUnknownSecretHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return ErrUnknownSecretHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > maxArgon2KeyLen {
+		return ErrUnknownSecretHash
+	}
+	computed := argon2.IDKey([]byte(secret), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrSecretMismatch
+	}
+	return nil
+}
+
+// constantTimeEqual compares the strings in constant time
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
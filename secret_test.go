@@ -0,0 +1,36 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashClientSecret(t *testing.T) {
+	for _, algorithm := range []SecretHashAlgorithm{Argon2idSecretHash, BcryptSecretHash} {
+		hash, err := HashClientSecretWith(algorithm, "12345")
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		if strings.Contains(hash, "12345") {
+			t.Fatalf("Error hash contains the secret = %s", hash)
+		}
+		if err = VerifyClientSecret(hash, "12345"); err != nil {
+			t.Fatalf("Error %s: %s", algorithm, err.Error())
+		}
+		if err = VerifyClientSecret(hash, "54321"); err != ErrSecretMismatch {
+			t.Fatalf("Error should be ErrSecretMismatch: %v", err)
+		}
+	}
+	if err := VerifyClientSecret("12345", "12345"); err != ErrUnknownSecretHash {
+		t.Fatalf("Error should be ErrUnknownSecretHash: %v", err)
+	}
+}
+
+func TestVerifyArgon2idParams(t *testing.T) {
+	for _, params := range []string{"m=65536,t=0,p=4", "m=65536,t=3,p=0", "m=65536,t=3,p=256", "m=4294967295,t=3,p=4", "m=8,t=3,p=4", "m=65536,t=1000,p=4"} {
+		hash := "$argon2id$v=19$" + params + "$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5"
+		if err := VerifyClientSecret(hash, "12345"); err != ErrUnknownSecretHash {
+			t.Fatalf("Error %s should be ErrUnknownSecretHash: %v", params, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// ReloadableSecret is the secret key of the server loaded from a SecretSource and reloaded at runtime, e.g. on SIGHUP.
+// It is the TokenSecureFormatter of the secret (SHA256RC4), keeping the formatter of the previous secret to decrypt
+// the tokens issued before the last reload.
+//
+//	secret, err := oauth.LoadSecret(ctx, oauth.FileSecret("/run/secrets/oauth"))
+//	bs := oauth.NewBearerServer("", time.Hour, 24*time.Hour, verifier, nil, oauth.WithSecret(secret))
+//	bs.AddBackgroundTask(secret.ReloadOnSignal)
+type ReloadableSecret struct {
+	Source SecretSource
+	// OnReload, when set, is called with the result of each reload
+	OnReload func(err error)
+
+	mu    sync.Mutex // serializes the reloads
+	state atomic.Value
+}
+
+type secretState struct {
+	secret         string
+	previousSecret string
+	current        TokenSecureFormatter
+	previous       TokenSecureFormatter
+}
+
+// LoadSecret loads the secret of the source
+func LoadSecret(ctx context.Context, source SecretSource) (*ReloadableSecret, error) {
+	s := &ReloadableSecret{Source: source}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Secret returns the current secret
+func (s *ReloadableSecret) Secret() string {
+	return s.load().secret
+}
+
+func (s *ReloadableSecret) load() *secretState {
+	state, _ := s.state.Load().(*secretState)
+	if state == nil {
+		return &secretState{}
+	}
+	return state
+}
+
+// Reload loads the secret of the source, the current secret is kept when it fails
+func (s *ReloadableSecret) Reload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret, err := s.Source.Secret(ctx)
+	if err == nil {
+		if state := s.load(); secret != state.secret {
+			s.state.Store(&secretState{
+				secret:         secret,
+				previousSecret: state.secret,
+				current:        NewSHA256RC4TokenSecurityProvider([]byte(secret)),
+				previous:       state.current,
+			})
+		}
+	}
+	if s.OnReload != nil {
+		s.OnReload(err)
+	}
+	return err
+}
+
+// ReloadOnSignal reloads the secret at each SIGHUP until the context is done, returning the context error.
+// It is a BackgroundTask, the failed reloads are reported to OnReload.
+func (s *ReloadableSecret) ReloadOnSignal(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signals:
+			_ = s.Reload(ctx)
+		}
+	}
+}
+
+// CryptToken crypts the token with the current secret
+func (s *ReloadableSecret) CryptToken(source []byte) ([]byte, error) {
+	state := s.load()
+	if state.current == nil {
+		return nil, ErrEmptySecret
+	}
+	return state.current.CryptToken(source)
+}
+
+// DecryptToken decrypts the token with the current secret, then with the previous one
+func (s *ReloadableSecret) DecryptToken(source []byte) ([]byte, error) {
+	state := s.load()
+	if state.current == nil {
+		return nil, ErrEmptySecret
+	}
+	b, err := state.current.DecryptToken(source)
+	if err != nil && state.previous != nil {
+		return state.previous.DecryptToken(source)
+	}
+	return b, err
+}
+
+// WithSecret makes the server crypt the tokens with the reloadable secret and derive its other keys (CSRF tokens,
+// idempotency fingerprints) from the current secret, the secretKey passed to NewBearerServer is ignored.
+// The secret does not replace the formatter passed to NewBearerServer, which must be nil: Validate reports the
+// custom formatters, whose tokens are not crypted with the reloadable secret.
+func WithSecret(secret *ReloadableSecret) ServerOption {
+	return func(bs *BearerServer) {
+		bs.secrets = secret
+	}
+}
+
+// secret returns the current secret key of the server
+func (bs *BearerServer) secret() string {
+	if bs.secrets != nil {
+		return bs.secrets.Secret()
+	}
+	return bs.secretKey
+}
+
+// verificationSecrets returns the secret keys accepted for the values signed by the server: the current one, then
+// the previous one of the WithSecret secret until its next reload
+func (bs *BearerServer) verificationSecrets() []string {
+	if bs.secrets == nil {
+		return []string{bs.secretKey}
+	}
+	state := bs.secrets.load()
+	if state.previousSecret == "" {
+		return []string{state.secret}
+	}
+	return []string{state.secret, state.previousSecret}
+}
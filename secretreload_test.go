@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadableSecret(t *testing.T) {
+	secret := "first-secret"
+	source := SecretSourceFunc(func(context.Context) (string, error) {
+		if secret == "" {
+			return "", errors.New("backend down")
+		}
+		return secret, nil
+	})
+	reloadable, err := LoadSecret(context.Background(), source)
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	sut := NewBearerServer("", time.Second*10, time.Second*60, new(TestUserVerifier), nil, WithSecret(reloadable))
+	mut := NewBearerAuthentication(reloadable.Secret(), reloadable)
+
+	before, err := sut.IssueToken(context.Background(), UserToken, "user111", "read", nil)
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	secret = "second-secret"
+	if err = reloadable.Reload(context.Background()); err != nil || reloadable.Secret() != "second-secret" || sut.secret() != "second-secret" {
+		t.Fatalf("Error Reload = %v, secret = %s", err, reloadable.Secret())
+	}
+	after, _ := sut.IssueToken(context.Background(), UserToken, "user111", "read", nil)
+	for _, token := range []string{before.Token, after.Token} {
+		if _, err = mut.ValidateToken(token); err != nil {
+			t.Fatalf("Error the tokens of the current and previous secrets should be valid: %v", err)
+		}
+	}
+	if _, err = NewBearerAuthentication("second-secret", nil).ValidateToken(after.Token); err != nil {
+		t.Fatalf("Error the token should be crypted with the new secret: %v", err)
+	}
+
+	secret = ""
+	if err = reloadable.Reload(context.Background()); err == nil || reloadable.Secret() != "second-secret" {
+		t.Fatalf("Error the failed reload should keep the secret: %v", err)
+	}
+	secret = "third-secret"
+	reloadable.Reload(context.Background())
+	if _, err = mut.ValidateToken(before.Token); err == nil {
+		t.Fatalf("Error the tokens of the secret before the previous one should be rejected")
+	}
+
+	custom := NewBearerServer("", time.Second*10, time.Second*60, new(TestUserVerifier), NewRC4TokenSecurityProvider([]byte("custom")), WithSecret(reloadable))
+	if err = custom.Validate(); err == nil || !strings.Contains(err.Error(), "WithSecret") {
+		t.Fatalf("Error the custom formatter should be reported: %v", err)
+	}
+	if err = sut.Validate(); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+}
+
+func TestReloadableSecretCSRF(t *testing.T) {
+	secret := "first-secret"
+	reloadable, _ := LoadSecret(context.Background(), SecretSourceFunc(func(context.Context) (string, error) { return secret, nil }))
+	sut := NewBearerServer("", time.Second*10, time.Second*60, new(TestUserVerifier), nil, WithSecret(reloadable))
+	sut.RefreshCookie = &RefreshCookie{}
+	csrf := csrfToken(reloadable.Secret(), "refresh-token")
+	refresh := func() (string, error) {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader("grant_type=refresh_token"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(DefaultCSRFHeader, csrf)
+		req.AddCookie(&http.Cookie{Name: DefaultRefreshCookieName, Value: "refresh-token"})
+		return sut.refreshTokenParam(req)
+	}
+
+	secret = "second-secret"
+	reloadable.Reload(context.Background())
+	if token, err := refresh(); err != nil || token != "refresh-token" {
+		t.Fatalf("Error the CSRF token of the previous secret should be accepted: %s, %v", token, err)
+	}
+	secret = "third-secret"
+	reloadable.Reload(context.Background())
+	if _, err := refresh(); err != errCSRFMismatch {
+		t.Fatalf("Error the CSRF token of the secret before the previous one should be rejected: %v", err)
+	}
+}
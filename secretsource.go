@@ -0,0 +1,251 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrEmptySecret is returned by the SecretSource when the secret is empty.
+var ErrEmptySecret = errors.New("empty secret")
+
+// SecretSource loads the secret key of the server (see LoadSecret), from the environment, a file, Vault or AWS Secrets Manager.
+type SecretSource interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// SecretSourceFunc is an adapter to use ordinary functions as SecretSource.
+type SecretSourceFunc func(ctx context.Context) (string, error)
+
+// Secret calls f(ctx).
+func (f SecretSourceFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// EnvSecret is the SecretSource reading the secret from the environment variable of this name.
+type EnvSecret string
+
+// Secret returns the value of the environment variable
+func (e EnvSecret) Secret(_ context.Context) (string, error) {
+	secret := os.Getenv(string(e))
+	if secret == "" {
+		return "", fmt.Errorf("%w: environment variable %s", ErrEmptySecret, string(e))
+	}
+	return secret, nil
+}
+
+// FileSecret is the SecretSource reading the secret from the file of this path, e.g. a mounted Kubernetes or Docker secret.
+// The trailing new lines are trimmed.
+type FileSecret string
+
+// Secret reads the file
+func (f FileSecret) Secret(_ context.Context) (string, error) {
+	b, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(string(b), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%w: file %s", ErrEmptySecret, string(f))
+	}
+	return secret, nil
+}
+
+// VaultSecret is the SecretSource reading the secret from a field of a HashiCorp Vault KV secret (version 1 or 2).
+type VaultSecret struct {
+	// Address is the Vault address, e.g. "https://vault.example.com:8200"
+	Address string
+	// Token authenticates the requests, the VAULT_TOKEN environment variable when empty
+	Token string
+	// Path is the API path of the secret under /v1, e.g. "secret/data/oauth" for the KV version 2 "secret" mount
+	Path string
+	// Field is the field of the secret data holding the secret key
+	Field string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Secret reads the secret field
+func (v *VaultSecret) Secret(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(v.Address, "/")+"/v1/"+strings.TrimPrefix(v.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	token := v.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	req.Header.Set("X-Vault-Token", token)
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = fetchSecretJSON(v.Client, req, &resp, "vault"); err != nil {
+		return "", err
+	}
+	data := resp.Data
+	// the KV version 2 secrets nest the data and its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	secret, _ := data[v.Field].(string)
+	if secret == "" {
+		return "", fmt.Errorf("%w: vault field %s of %s", ErrEmptySecret, v.Field, v.Path)
+	}
+	return secret, nil
+}
+
+// AWSCredentials are the credentials signing the requests of the AWSSecretsManagerSecret
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// EnvAWSCredentials returns the credentials of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables
+func EnvAWSCredentials(_ context.Context) (AWSCredentials, error) {
+	c := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, errors.New("aws: missing AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY")
+	}
+	return c, nil
+}
+
+// AWSSecretsManagerSecret is the SecretSource reading the SecretString of an AWS Secrets Manager secret.
+type AWSSecretsManagerSecret struct {
+	// SecretID is the name or ARN of the secret
+	SecretID string
+	// Region is the region of the secret, the AWS_REGION environment variable when empty
+	Region string
+	// Credentials returns the credentials signing the requests, EnvAWSCredentials when nil. Plug the credentials
+	// provider of the AWS SDK for the instance profiles and the IAM roles of the service accounts.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	// Endpoint overrides the "https://secretsmanager.<region>.amazonaws.com" endpoint, e.g. for a VPC endpoint
+	Endpoint string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Secret calls the GetSecretValue action signed with SigV4
+func (a *AWSSecretsManagerSecret) Secret(ctx context.Context) (string, error) {
+	region := a.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	credentials := a.Credentials
+	if credentials == nil {
+		credentials = EnvAWSCredentials
+	}
+	creds, err := credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": a.SecretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signSigV4(req, body, creds, region, "secretsmanager", time.Now())
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err = fetchSecretJSON(a.Client, req, &resp, "secretsmanager"); err != nil {
+		return "", err
+	}
+	if resp.SecretString == "" {
+		return "", fmt.Errorf("%w: secretsmanager secret %s", ErrEmptySecret, a.SecretID)
+	}
+	return resp.SecretString, nil
+}
+
+// fetchSecretJSON sends the request and decodes the JSON response of the secret backend
+func fetchSecretJSON(client *http.Client, req *http.Request, v interface{}, backend string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", backend, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", backend, resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", backend, err)
+	}
+	return nil
+}
+
+// signSigV4 signs the request and its headers with the AWS Signature Version 4
+func signSigV4(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.Query().Encode(), canonicalHeaders.String(), signedHeaders,
+		hex.EncodeToString(bodyHash[:])}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+
+		", Signature="+hex.EncodeToString(key))
+}
@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvAndFileSecret(t *testing.T) {
+	os.Setenv("OAUTH_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("OAUTH_TEST_SECRET")
+	if secret, err := EnvSecret("OAUTH_TEST_SECRET").Secret(context.Background()); err != nil || secret != "env-secret" {
+		t.Fatalf("Error Secret = %s, %v", secret, err)
+	}
+	if _, err := EnvSecret("OAUTH_TEST_MISSING").Secret(context.Background()); err == nil {
+		t.Fatalf("Error the missing variable should fail")
+	}
+	path := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(path, []byte("file-secret\n"), 0600)
+	if secret, err := FileSecret(path).Secret(context.Background()); err != nil || secret != "file-secret" {
+		t.Fatalf("Error Secret = %q, %v", secret, err)
+	}
+}
+
+func TestVaultSecret(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/oauth" || r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"key":"vault-secret"},"metadata":{"version":3}}}`))
+	}))
+	defer vault.Close()
+	source := &VaultSecret{Address: vault.URL, Token: "s.token", Path: "secret/data/oauth", Field: "key"}
+	if secret, err := source.Secret(context.Background()); err != nil || secret != "vault-secret" {
+		t.Fatalf("Error Secret = %s, %v", secret, err)
+	}
+	source.Token = "forged"
+	if _, err := source.Secret(context.Background()); err == nil {
+		t.Fatalf("Error the forbidden request should fail")
+	}
+}
+
+func TestSignSigV4(t *testing.T) {
+	// get-vanilla of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signSigV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Fatalf("Error Authorization = %s", auth)
+	}
+}
+
+func TestAWSSecretsManagerSecret(t *testing.T) {
+	sm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || body["SecretId"] != "oauth/key" ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"Name":"oauth/key","SecretString":"aws-secret"}`))
+	}))
+	defer sm.Close()
+	source := &AWSSecretsManagerSecret{SecretID: "oauth/key", Region: "eu-west-1", Endpoint: sm.URL,
+		Credentials: func(context.Context) (AWSCredentials, error) {
+			return AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}, nil
+		}}
+	if secret, err := source.Secret(context.Background()); err != nil || secret != "aws-secret" {
+		t.Fatalf("Error Secret = %s, %v", secret, err)
+	}
+}
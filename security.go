@@ -3,25 +3,94 @@ package oauth
 import (
 	"crypto/rc4"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 )
 
+// DefaultMaxTokenSize is the default maximum length of an encoded token accepted by the TokenProvider.
+const DefaultMaxTokenSize = 16 * 1024
+
+var (
+	// ErrMalformedToken is returned when a token cannot be decoded, decrypted or deserialized.
+	ErrMalformedToken = errors.New("malformed token")
+	// ErrExpiredToken is returned when a well formed token is expired.
+	ErrExpiredToken = errors.New("token expired")
+	// ErrInvalidAudience is returned when the token is not intended for the expected audience.
+	ErrInvalidAudience = errors.New("invalid token audience")
+	// ErrRevokedToken is returned when the token id is in the Denylist.
+	ErrRevokedToken = errors.New("token revoked")
+	// ErrTokenTooLarge is returned when a crypted token exceeds the MaxTokenSize, so it would not be decrypted.
+	ErrTokenTooLarge = errors.New("token too large")
+)
+
+// TokenSizeError is the ErrTokenTooLarge error of an encoded token exceeding the MaxTokenSize of the TokenProvider
+type TokenSizeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *TokenSizeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes exceed the MaxTokenSize of %d bytes", ErrTokenTooLarge, e.Size, e.Limit)
+}
+
+// Unwrap returns ErrTokenTooLarge
+func (e *TokenSizeError) Unwrap() error {
+	return ErrTokenTooLarge
+}
+
+// TokenSecureFormatter crypts and decrypts the serialized tokens.
+// The methods are called concurrently: keep no per-call state in the formatter, e.g. instantiate the stateful
+// stream ciphers on each call.
 type TokenSecureFormatter interface {
 	CryptToken(source []byte) ([]byte, error)
 	DecryptToken(source []byte) ([]byte, error)
 }
 
+// CompactTokenFormatter is implemented by the TokenSecureFormatters whose crypted tokens are printable URL-safe strings,
+// such as the compact JWS of the signing formatters: the TokenProvider emits and reads them as is instead of wrapping
+// them in base64.
+type CompactTokenFormatter interface {
+	TokenSecureFormatter
+	// CompactTokens reports whether the crypted tokens are emitted as is
+	CompactTokens() bool
+}
+
+// AccessTokenFormatter is implemented by the TokenSecureFormatters emitting the access tokens in a format of their own,
+// such as the RFC 9068 JWTs of the Ed25519TokenSecureFormatter: the TokenProvider crypts and decrypts the access tokens
+// with these methods, the refresh tokens and the authorization codes with CryptToken and DecryptToken.
+type AccessTokenFormatter interface {
+	TokenSecureFormatter
+	CryptAccessToken(t *Token) ([]byte, error)
+	DecryptAccessToken(source []byte) (*Token, error)
+}
+
+// TokenProvider serializes and crypts tokens using a TokenSecureFormatter.
 type TokenProvider struct {
 	secureFormatter TokenSecureFormatter
+	// MaxTokenSize is the maximum length of the encoded tokens crypted and accepted for decryption, 0 disables the check.
+	MaxTokenSize int
 }
 
+// NewTokenProvider creates a TokenProvider using the formatter.
 func NewTokenProvider(formatter TokenSecureFormatter) *TokenProvider {
-	return &TokenProvider{secureFormatter: formatter}
+	return &TokenProvider{secureFormatter: formatter, MaxTokenSize: DefaultMaxTokenSize}
 }
 
+// CryptToken serializes and crypts the access token.
+// The serialization is canonical: encoding/json sorts the keys of the claims maps, nested ones included, so the same
+// token always gives the same payload bytes and the signing formatters produce stable tokens.
 func (tp *TokenProvider) CryptToken(t *Token) (token string, err error) {
+	if f, ok := tp.secureFormatter.(AccessTokenFormatter); ok {
+		ctoken, err := f.CryptAccessToken(t)
+		if err != nil {
+			return "", err
+		}
+		return tp.encode(ctoken)
+	}
 	bToken, err := json.Marshal(t)
 	if err != nil {
 		return "", err
@@ -29,6 +98,7 @@ func (tp *TokenProvider) CryptToken(t *Token) (token string, err error) {
 	return tp.crypt(bToken)
 }
 
+// CryptRefreshToken serializes and crypts the refresh token.
 func (tp *TokenProvider) CryptRefreshToken(t *RefreshToken) (token string, err error) {
 	bToken, err := json.Marshal(t)
 	if err != nil {
@@ -37,44 +107,150 @@ func (tp *TokenProvider) CryptRefreshToken(t *RefreshToken) (token string, err e
 	return tp.crypt(bToken)
 }
 
+// DecryptToken decrypts the access token, errors wrap ErrMalformedToken.
 func (tp *TokenProvider) DecryptToken(token string) (t *Token, err error) {
-	bToken, err := tp.decrypt(token)
-	if err != nil {
-		return nil, err
+	if f, ok := tp.secureFormatter.(AccessTokenFormatter); ok {
+		b, err := tp.decode(token)
+		if err != nil {
+			return nil, err
+		}
+		if t, err = f.DecryptAccessToken(b); err != nil {
+			if errors.Is(err, ErrMalformedToken) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+		}
+		return t, nil
 	}
-	err = json.Unmarshal(bToken, &t)
-	if err != nil {
+	if err = tp.decryptJSON(token, &t); err != nil {
 		return nil, err
 	}
+	if t == nil {
+		return nil, ErrMalformedToken
+	}
 	return t, nil
 }
 
+// DecryptRefreshTokens decrypts the refresh token, errors wrap ErrMalformedToken.
 func (tp *TokenProvider) DecryptRefreshTokens(refreshToken string) (refresh *RefreshToken, err error) {
-	bRefresh, err := tp.decrypt(refreshToken)
-	if err != nil {
+	if err = tp.decryptJSON(refreshToken, &refresh); err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(bRefresh, &refresh)
-	if err != nil {
-		return nil, err
+	if refresh == nil {
+		return nil, ErrMalformedToken
 	}
 	return refresh, nil
 }
 
+// compact reports whether the formatter tokens are emitted without the base64 wrapping
+func (tp *TokenProvider) compact() bool {
+	f, ok := tp.secureFormatter.(CompactTokenFormatter)
+	return ok && f.CompactTokens()
+}
+
+// crypt crypts and encodes the token, failing with a *TokenSizeError when it exceeds the MaxTokenSize
 func (tp *TokenProvider) crypt(token []byte) (string, error) {
 	ctoken, err := tp.secureFormatter.CryptToken(token)
 	if err != nil {
 		return "", err
 	}
-	return base64.StdEncoding.EncodeToString(ctoken), nil
+	return tp.encode(ctoken)
+}
+
+// encode encodes the crypted token, failing with a *TokenSizeError when it exceeds the MaxTokenSize
+func (tp *TokenProvider) encode(ctoken []byte) (string, error) {
+	encoded := string(ctoken)
+	if !tp.compact() {
+		encoded = base64.StdEncoding.EncodeToString(ctoken)
+	}
+	if tp.MaxTokenSize > 0 && len(encoded) > tp.MaxTokenSize {
+		return "", &TokenSizeError{Size: len(encoded), Limit: tp.MaxTokenSize}
+	}
+	return encoded, nil
 }
 
 func (tp *TokenProvider) decrypt(token string) ([]byte, error) {
-	b, err := base64.StdEncoding.DecodeString(token)
+	b, err := tp.decode(token)
 	if err != nil {
 		return nil, err
 	}
-	return tp.secureFormatter.DecryptToken(b)
+	return tp.decryptBytes(b)
+}
+
+// decode checks the MaxTokenSize and decodes the crypted token, errors wrap ErrMalformedToken
+func (tp *TokenProvider) decode(token string) ([]byte, error) {
+	if tp.MaxTokenSize > 0 && len(token) > tp.MaxTokenSize {
+		return nil, fmt.Errorf("%w: token exceeds %d bytes", ErrMalformedToken, tp.MaxTokenSize)
+	}
+	if tp.compact() {
+		return []byte(token), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return b, nil
+}
+
+// decodeBuffers pools the buffers of the base64 decoding of decryptJSON
+var decodeBuffers = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// pooledDecoding reports whether the tokens can be decoded in the pooled buffers: only the builtin RC4 formatters are
+// known not to retain the source of DecryptToken, the other formatters get a buffer of their own
+func (tp *TokenProvider) pooledDecoding() bool {
+	switch tp.secureFormatter.(type) {
+	case *SHA256RC4TokenSecureFormatter, *RC4TokenSecureFormatter:
+		return true
+	}
+	return false
+}
+
+// decryptJSON decrypts the token and deserializes it into v, decoding the token in a pooled buffer when pooledDecoding
+func (tp *TokenProvider) decryptJSON(token string, v interface{}) error {
+	if !tp.pooledDecoding() {
+		b, err := tp.decrypt(token)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+		}
+		return nil
+	}
+	if tp.MaxTokenSize > 0 && len(token) > tp.MaxTokenSize {
+		return fmt.Errorf("%w: token exceeds %d bytes", ErrMalformedToken, tp.MaxTokenSize)
+	}
+	buf := decodeBuffers.Get().(*[]byte)
+	defer decodeBuffers.Put(buf)
+	size := len(token) + base64.StdEncoding.DecodedLen(len(token))
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	src := append((*buf)[:0], token...)
+	n, err := base64.StdEncoding.Decode((*buf)[len(token):size], src)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	b, err := tp.decryptBytes((*buf)[len(token) : len(token)+n])
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return nil
+}
+
+// decryptBytes decrypts the decoded token, errors wrap ErrMalformedToken
+func (tp *TokenProvider) decryptBytes(b []byte) ([]byte, error) {
+	dest, err := tp.secureFormatter.DecryptToken(b)
+	if err != nil {
+		if errors.Is(err, ErrMalformedToken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return dest, nil
 }
 
 type RC4TokenSecureFormatter struct {
@@ -84,12 +260,13 @@ type RC4TokenSecureFormatter struct {
 
 func NewRC4TokenSecurityProvider(key []byte) *RC4TokenSecureFormatter {
 	var sc = &RC4TokenSecureFormatter{key: key}
+	sc.cipher, _ = rc4.NewCipher(key)
 	return sc
 }
 
 func (sc *RC4TokenSecureFormatter) CryptToken(source []byte) ([]byte, error) {
 	dest := make([]byte, len(source))
-	cipher, err := rc4.NewCipher(sc.key)
+	cipher, err := newRC4Cipher(sc.cipher, sc.key)
 	if err != nil {
 		return nil, err
 	}
@@ -99,9 +276,9 @@ func (sc *RC4TokenSecureFormatter) CryptToken(source []byte) ([]byte, error) {
 
 func (sc *RC4TokenSecureFormatter) DecryptToken(source []byte) ([]byte, error) {
 	dest := make([]byte, len(source))
-	cipher, err := rc4.NewCipher(sc.key)
+	cipher, err := newRC4Cipher(sc.cipher, sc.key)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	cipher.XORKeyStream(dest, source)
 	return dest, nil
@@ -114,16 +291,30 @@ type SHA256RC4TokenSecureFormatter struct {
 
 func NewSHA256RC4TokenSecurityProvider(key []byte) *SHA256RC4TokenSecureFormatter {
 	var sc = &SHA256RC4TokenSecureFormatter{key: key}
+	sc.cipher, _ = rc4.NewCipher(key)
 	return sc
 }
 
+// newRC4Cipher returns a copy of the initial cipher, so the concurrent calls do not share the stream state
+// and skip the key schedule, or a new cipher of the key when the formatter was not created by its constructor
+func newRC4Cipher(initial *rc4.Cipher, key []byte) (rc4.Cipher, error) {
+	if initial != nil {
+		return *initial, nil
+	}
+	cipher, err := rc4.NewCipher(key)
+	if err != nil {
+		return rc4.Cipher{}, err
+	}
+	return *cipher, nil
+}
+
 func (sc *SHA256RC4TokenSecureFormatter) CryptToken(source []byte) ([]byte, error) {
 	hasher := sha256.New()
 	hasher.Write(source)
 	hash := hasher.Sum(nil)
 	newSource := append(hash, source...)
 	dest := make([]byte, len(newSource))
-	cipher, err := rc4.NewCipher(sc.key)
+	cipher, err := newRC4Cipher(sc.cipher, sc.key)
 	if err != nil {
 		return nil, err
 	}
@@ -133,21 +324,17 @@ func (sc *SHA256RC4TokenSecureFormatter) CryptToken(source []byte) ([]byte, erro
 
 func (sc *SHA256RC4TokenSecureFormatter) DecryptToken(source []byte) ([]byte, error) {
 	if len(source) < 32 {
-		return nil, errors.New("Invalid token")
+		return nil, ErrMalformedToken
 	}
 	dest := make([]byte, len(source))
-	cipher, err := rc4.NewCipher(sc.key)
+	cipher, err := newRC4Cipher(sc.cipher, sc.key)
 	if err != nil {
 		return nil, err
 	}
 	cipher.XORKeyStream(dest, source)
-	hasher := sha256.New()
-	hasher.Write(dest[32:])
-	hash := hasher.Sum(nil)
-	for i, b := range hash {
-		if b != dest[i] {
-			return nil, errors.New("Invalid token")
-		}
+	hash := sha256.Sum256(dest[32:])
+	if subtle.ConstantTimeCompare(hash[:], dest[:32]) != 1 {
+		return nil, ErrMalformedToken
 	}
 	return dest[32:], nil
 }
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func fuzzSeeds(f *testing.F) {
+	r := new(http.Request)
+	token, refresh, err := _sut.generateTokens(UserToken, "user111", "", "", r)
+	if err != nil {
+		f.Fatalf("Error %s", err.Error())
+	}
+	resp, err := _sut.cryptTokens(token, refresh, r)
+	if err != nil {
+		f.Fatalf("Error %s", err.Error())
+	}
+	f.Add(resp.Token)
+	f.Add(resp.RefreshToken)
+	f.Add("")
+	f.Add("bnVsbA==")
+	f.Add("!!!")
+}
+
+func FuzzDecryptToken(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, raw string) {
+		token, err := _sut.provider.DecryptToken(raw)
+		if err != nil && !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("Error should wrap ErrMalformedToken: %v", err)
+		}
+		if err == nil {
+			_ = token.IsExpired()
+		}
+	})
+}
+
+func FuzzDecryptRefreshTokens(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, raw string) {
+		refresh, err := _sut.provider.DecryptRefreshTokens(raw)
+		if err != nil && !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("Error should wrap ErrMalformedToken: %v", err)
+		}
+		if err == nil {
+			_ = refresh.IsExpired()
+		}
+	})
+}
+
+func FuzzRC4DecryptToken(f *testing.F) {
+	f.Add([]byte("testkey"), []byte("source"))
+	f.Add([]byte{}, []byte("source"))
+	f.Fuzz(func(t *testing.T, key, source []byte) {
+		_, _ = NewRC4TokenSecurityProvider(key).DecryptToken(source)
+	})
+}
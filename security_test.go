@@ -1,7 +1,14 @@
 package oauth
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var _sutRC4, _sutSHA256 *TokenProvider
@@ -95,3 +102,138 @@ func TestDecryptSHA256_LongKey(t *testing.T) {
 		}
 	}
 }
+
+func TestDecryptMalformedToken(t *testing.T) {
+	for _, token := range []string{"!!!", "bnVsbA==", ""} {
+		if _, err := _sutSHA256.DecryptRefreshTokens(token); !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("Error should be ErrMalformedToken for %q: %v", token, err)
+		}
+	}
+	if _, err := NewTokenProvider(NewRC4TokenSecurityProvider(nil)).DecryptToken("bnVsbA=="); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error should be ErrMalformedToken: %v", err)
+	}
+}
+
+func TestDecryptOversizedToken(t *testing.T) {
+	token := strings.Repeat("A", DefaultMaxTokenSize+4)
+	if _, err := _sutSHA256.DecryptToken(token); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error should be ErrMalformedToken: %v", err)
+	}
+}
+
+func TestCryptOversizedToken(t *testing.T) {
+	claims := Claims{"groups": strings.Repeat("g", 12*1024)}
+	if _, err := _sutSHA256.CryptToken(&Token{ID: "id", Claims: claims}); !errors.Is(err, ErrTokenTooLarge) {
+		t.Fatalf("Error should be ErrTokenTooLarge: %v", err)
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	_, err := sut.IssueToken(context.Background(), UserToken, "user111", "", claims)
+	var issueErr *IssueError
+	if !errors.As(err, &issueErr) || issueErr.Response.Error != TokenServerError || !strings.Contains(issueErr.Response.Description, "token too large") {
+		t.Fatalf("Error the oversized token should not be issued: %v", err)
+	}
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "", Claims{"groups": strings.Repeat("g", 1024)})
+	if err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if _, err = sut.provider.DecryptRefreshTokens(resp.RefreshToken); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+}
+
+func concurrentProviders(t testing.TB) map[string]*TokenProvider {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	return map[string]*TokenProvider{
+		"rc4":        _sutRC4,
+		"sha256rc4":  _sutSHA256,
+		"ed25519":    NewTokenProvider(NewEd25519TokenSecurityProvider(private)),
+		"compressed": NewTokenProvider(NewCompressingFormatter(NewSHA256RC4TokenSecurityProvider([]byte("testkey")), GzipCompression)),
+	}
+}
+
+func TestTokenProviderConcurrent(t *testing.T) {
+	for name, provider := range concurrentProviders(t) {
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					credential := fmt.Sprintf("user-%d-%d", i, j)
+					raw, err := provider.CryptToken(&Token{ID: credential, Credential: credential, CreationDate: time.Now().UTC(), Claims: Claims{"n": j}})
+					if err != nil {
+						t.Errorf("Error %s: %s", name, err.Error())
+						return
+					}
+					token, err := provider.DecryptToken(raw)
+					if err != nil || token.Credential != credential {
+						t.Errorf("Error %s: garbled token %v, err = %v", name, token, err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkTokenProviderParallel(b *testing.B) {
+	for name, provider := range concurrentProviders(b) {
+		raw, err := provider.CryptToken(&Token{ID: "id", Credential: "user111", CreationDate: time.Now().UTC()})
+		if err != nil {
+			b.Fatalf("Error %s", err.Error())
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := provider.DecryptToken(raw); err != nil {
+						b.Fatalf("Error %s", err.Error())
+					}
+				}
+			})
+		})
+	}
+}
+
+// retainingFormatter is a plain formatter keeping the sources of DecryptToken
+type retainingFormatter struct {
+	mu       sync.Mutex
+	retained [][]byte
+}
+
+func (f *retainingFormatter) CryptToken(source []byte) ([]byte, error) {
+	return append([]byte(nil), source...), nil
+}
+
+func (f *retainingFormatter) DecryptToken(source []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.retained = append(f.retained, source)
+	return append([]byte(nil), source...), nil
+}
+
+func TestDecryptRetainingFormatter(t *testing.T) {
+	formatter := new(retainingFormatter)
+	provider := NewTokenProvider(formatter)
+	var raws []string
+	for _, credential := range []string{"user111", "user222"} {
+		raw, err := provider.CryptToken(&Token{ID: credential, Credential: credential, CreationDate: time.Now().UTC(), ExpiresIn: time.Hour})
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		raws = append(raws, raw)
+	}
+	for _, raw := range raws {
+		if _, err := provider.DecryptToken(raw); err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+	}
+	if !strings.Contains(string(formatter.retained[0]), `"user111"`) {
+		t.Fatalf("Error retained source overwritten: %s", formatter.retained[0])
+	}
+}
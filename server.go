@@ -1,7 +1,12 @@
 package oauth
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -38,36 +43,214 @@ type AuthorizationCodeVerifier interface {
 	ValidateCode(clientID, clientSecret, code, redirectURI string, r *http.Request) (string, error)
 }
 
+// VerifierSelector returns the CredentialsVerifier validating the grants of the client,
+// nil selects the verifier of the server
+type VerifierSelector func(clientID string) CredentialsVerifier
+
+// TokenTypeFunc derives the token_type of the response from the access token
+type TokenTypeFunc func(token *Token, r *http.Request) TokenType
+
+// RefreshTokenLifetimeVerifier defines the optional interface providing per credential and per client refresh token lifetimes
+type RefreshTokenLifetimeVerifier interface {
+	// RefreshTokenLifetime returns the idle and absolute lifetimes of the refresh tokens issued to the credential through
+	// the client, clientID is empty when the client is not authenticated. Zero values fall back to the server
+	// RefreshTokenTTL and RefreshTokenMaxLifetime
+	RefreshTokenLifetime(tokenType TokenType, credential, clientID string) (idle, absolute time.Duration)
+}
+
+var (
+	errRefreshLifetimeExceeded = errors.New("refresh token absolute lifetime exceeded")
+	errClientSecretInBody      = errors.New("client credentials in the request body are not allowed")
+)
+
+// StatusMapper maps the error type of a response to the HTTP status code, status is the default one
+type StatusMapper func(errorType ErrorResponseType, status int) int
+
+// TokenResponseHook is called with the successful token responses before they are rendered
+type TokenResponseHook func(ctx context.Context, grantType GrantType, resp *TokenResponse, w http.ResponseWriter, r *http.Request)
+
 // BearerServer is the OAuth 2 bearer server implementation.
 type BearerServer struct {
 	secretKey       string
 	TokenTTL        time.Duration
-	RefreshTokenTTL time.Duration
+	RefreshTokenTTL time.Duration // idle lifetime, reset by each refresh
+	// RefreshTokenMaxLifetime is the absolute lifetime of the original grant, after which refresh is impossible, 0 means unlimited
+	RefreshTokenMaxLifetime time.Duration
+	// ClientStore, when set, restricts each client to its registered grant types
+	ClientStore ClientStore
+	// ClientAuthFormEncoded decodes the client credentials of the Basic authorization header as required by RFC 6749 §2.3.1
+	ClientAuthFormEncoded bool
+	// RequireClientAuthHeader rejects the client_secret sent in the request body, only the Basic authorization header is accepted
+	RequireClientAuthHeader bool
+	// RefreshClaims calls the verifier AddClaims during the refresh so the claims reflect the current user state,
+	// otherwise the claims of the original grant are carried over. The claims set by the server (act, acr, amr) are kept in both cases
+	RefreshClaims bool
+	// StatelessAuthorizationCodes exchanges the self-contained codes sealed by IssueAuthorizationCode
+	// instead of calling the AuthorizationCodeVerifier
+	StatelessAuthorizationCodes bool
+	// CodeTTL is the lifetime of the stateless authorization codes, DefaultCodeTTL when 0
+	CodeTTL time.Duration
+	// CodeReplayCache makes the stateless authorization codes single use, it is required by StatelessAuthorizationCodes
+	CodeReplayCache ReplayCache
+	// AuthCodeStore, when set, persists the authorization codes issued by IssueAuthorizationCode
+	// instead of calling the AuthorizationCodeVerifier
+	AuthCodeStore AuthCodeStore
+	// SupportedClaims, when set, restricts the claims requested by the "claims" parameter of the authorization codes
+	SupportedClaims []string
+	// StatusMapper, when set, adjusts the HTTP status of the error responses
+	StatusMapper StatusMapper
+	// VerifierSelector, when set, routes the validation of each authenticated client to its own verifier, the server
+	// verifier still validates the other requests
+	VerifierSelector VerifierSelector
+	// TokenStore, when set, records the issued tokens and their rotation lineage, revoked refresh tokens are rejected
+	TokenStore TokenStore
+	// Denylist, when set, receives the access token ids of the revoked refresh tokens, share it with BearerAuthentication
+	Denylist *Denylist
+	// ResponseTokenType is the token_type of the responses, BearerToken when empty (e.g. "bearer" for legacy clients)
+	ResponseTokenType TokenType
+	// TokenTypeFunc, when set, derives the token_type of each response
+	TokenTypeFunc TokenTypeFunc
+	// MaxRequestBodySize limits the size of the token requests body, DefaultMaxRequestBodySize when 0, unlimited when negative
+	MaxRequestBodySize int64
+	// MaxFormParams limits the number of parameters of the token requests, DefaultMaxFormParams when 0, unlimited when negative
+	MaxFormParams int
+	// ClientIPResolver resolves the client address behind the trusted proxies, the peer address is used when nil
+	ClientIPResolver *ClientIPResolver
+	// RequireHTTPS rejects the token requests not received over TLS, the ClientIPResolver proxies can forward the scheme
+	RequireHTTPS bool
+	// PropagateRequestIDs honors the X-Request-ID header of the requests, or generates one, exposing it in the verifier
+	// requests context (RequestIDFromContext), the response header and the error responses
+	PropagateRequestIDs bool
+	// Translator, when set, localizes the error descriptions to the Accept-Language of the requests,
+	// the catalogs registered with RegisterCatalog are used otherwise
+	Translator Translator
+	// OnOversizedToken, when set, is called with the diagnostics of the access and refresh tokens exceeding the
+	// MaxTokenSize of the server (DefaultMaxTokenSize, see WithMaxTokenSize)
+	OnOversizedToken func(report TokenSizeReport)
+	// ReferenceTokens, when set, stores the records of the oversized access and refresh tokens issued as references
+	ReferenceTokens ReferenceTokenStore
+	// ScopeTTLPolicy caps the lifetime of the access tokens carrying sensitive scopes (e.g. "payments:write": 5 * time.Minute)
+	ScopeTTLPolicy ScopeTTLPolicy
+	// RiskEvaluator, when set, scores the grants before the tokens generation and can deny them, require MFA or shorten the TTL
+	RiskEvaluator RiskEvaluator
+	// VelocityCounter, when set, provides the request counts per credential and client address to the RiskEvaluator
+	VelocityCounter VelocityCounter
+	// GeoHintHeader is the request header carrying the geolocation hint of the RiskContext, e.g. "CF-IPCountry"
+	GeoHintHeader string
+	// DeviceRegistry, when set, remembers the devices of the multi-factor password grants so that MFA is skipped on them
+	DeviceRegistry DeviceRegistry
+	// TrustedDeviceTTL is how long a device stays trusted, forever when zero
+	TrustedDeviceTTL time.Duration
+	// UserProvisioner, when set, serves the CreateUser and DisableUser provisioning endpoints
+	UserProvisioner UserProvisioner
+	// UsageTracker, when set, records the tokens issued to each client
+	UsageTracker UsageTracker
+	// AdminScope is the scope of the access tokens accepted by the AdminHandler, DefaultAdminScope when empty
+	AdminScope string
+	// RateLimiter, when set, throttles the token requests of each client
+	RateLimiter RateLimiter
+	// IdempotencyCache, when set, replays the token response to the retries of a request sent with the same Idempotency-Key
+	IdempotencyCache IdempotencyCache
+	// IdempotencyWindow is how long the responses are replayed, DefaultIdempotencyWindow when 0
+	IdempotencyWindow time.Duration
+	// Janitor, when set, is run by Start until Shutdown
+	Janitor *Janitor
+	// Events, when set, receives the token issuance, refresh and revocation events
+	Events EventPublisher
+	// RefreshCookie, when set, delivers the refresh tokens in a Secure HttpOnly cookie read back by the refresh_token grant
+	RefreshCookie *RefreshCookie
+	// OnTokenResponse, when set, is called before rendering the successful token responses, it can add headers
+	// (e.g. cookies) or edit the response. It is not called for the wrapped responses, whose tokens must only reach
+	// the recipient unwrapping them.
+	OnTokenResponse TokenResponseHook
+	// OnPanic, when set, is called with the panics recovered by the handlers before the server_error response is rendered,
+	// the response is left as is when it has already started
+	OnPanic func(r *http.Request, recovered interface{}, stack []byte)
+	// StoreTokenIDPolicy sets the retries of the failed verifier StoreTokenID calls and whether the tokens are then issued anyway
+	StoreTokenIDPolicy StorePolicy
+	// DisabledGrants answer unsupported_grant_type before any processing, see WithDisabledGrants
+	DisabledGrants []GrantType
+	// DeprecatedGrants add the Deprecation and Sunset headers to the responses of the grant types, see WithDeprecatedGrant
+	DeprecatedGrants map[GrantType]GrantDeprecation
+	// TrustedIssuers are the external issuers whose JWTs are translated by TranslateToken
+	TrustedIssuers []*TrustedIssuer
+	// FederationMapper, when set, maps the identities of the external tokens translated by TranslateToken
+	FederationMapper FederationMapper
+	// TranslationReplayCache makes the external tokens single use, it is required by TranslateToken
+	TranslationReplayCache ReplayCache
+	// SPIFFEResolver, when set, authenticates the client_credentials grant of the clients presenting an X.509 SVID
+	// without client secret
+	SPIFFEResolver SPIFFEResolver
+	// WrapStore, when set, enables the response wrapping: the token requests sent with the WrapTTLHeader get a single-use
+	// wrapping token exchanged for the token response at the Unwrap endpoint
+	WrapStore WrapStore
+	// MaxWrapTTL bounds the lifetime of the wrapping tokens, DefaultMaxWrapTTL when 0
+	MaxWrapTTL time.Duration
+	// ResponseFields, when set, renames the fields of the token and error responses for the legacy clients
+	ResponseFields *ResponseFieldMapping
+	// ProblemDetails renders the error responses as RFC 7807 application/problem+json documents keeping the OAuth error fields
+	ProblemDetails bool
+	// ProblemTypeBase, when set, prefixes the error codes to form the problem types of the errors without error_uri,
+	// e.g. "https://errors.example.com/oauth/". The problem type is "about:blank" when empty.
+	ProblemTypeBase string
+	// ErrorURIs, when set, sets the error_uri of the error responses to the documentation of their error code
+	ErrorURIs *ErrorURICatalog
+	// SecurityHeaders override the DefaultSecurityHeaders set on the responses of the handlers,
+	// an empty value removes the header
+	SecurityHeaders http.Header
+	// CORSOrigins are the origins of the browser clients allowed by the CORS middleware, "*" allows any origin
+	CORSOrigins []string
+	// OnConfigReload, when set, is called with the result of each Reload and the errors of WatchConfigFile
+	OnConfigReload func(cfg *ServerConfig, err error)
+
 	verifier        CredentialsVerifier
 	provider        *TokenProvider
+	assertionGrants map[GrantType]AssertionGrantHandler
+	encoders        map[string]ResponseEncoder
+	secrets         *ReloadableSecret
+	middlewares     []GrantMiddleware
+	catalogs        map[string]MessageCatalog
+	lifecycle       lifecycle
+	config          atomic.Value
+	configMu        sync.Mutex // serializes the reloads
 }
 
 // NewBearerServer creates new OAuth 2 bearer server
-func NewBearerServer(secretKey string, ttl, refreshTTL time.Duration, verifier CredentialsVerifier, formatter TokenSecureFormatter) *BearerServer {
-	if formatter == nil {
-		formatter = NewSHA256RC4TokenSecurityProvider([]byte(secretKey))
-	}
-	return &BearerServer{
+func NewBearerServer(secretKey string, ttl, refreshTTL time.Duration, verifier CredentialsVerifier, formatter TokenSecureFormatter, opts ...ServerOption) *BearerServer {
+	bs := &BearerServer{
 		secretKey:       secretKey,
 		TokenTTL:        ttl,
 		RefreshTokenTTL: refreshTTL,
 		verifier:        verifier,
 		provider:        NewTokenProvider(formatter)}
+	for _, opt := range opts {
+		opt(bs)
+	}
+	switch {
+	case formatter != nil:
+	case bs.secrets != nil:
+		bs.provider.secureFormatter = bs.secrets
+	default:
+		bs.provider.secureFormatter = NewSHA256RC4TokenSecurityProvider([]byte(secretKey))
+	}
+	return bs
 }
 
 // UserCredentials manages password grant type requests
 func (bs *BearerServer) UserCredentials(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if !bs.checkHTTPS(w, r) || !bs.parseForm(w, r) || !bs.checkWrapTTL(w, r) {
+		return
+	}
 	grantType := r.FormValue("grant_type")
 	scope := r.FormValue("scope")
 	// get username and password from basic authorization header
 	username, password, err := GetBasicAuthentication(r)
 	if err != nil {
-		renderError(w, TokenInvalidClient, "invalid username or password", "", http.StatusUnauthorized)
+		bs.renderError(w, r, TokenInvalidClient, "invalid username or password", "", http.StatusUnauthorized)
 		return
 	}
 
@@ -79,185 +262,600 @@ func (bs *BearerServer) UserCredentials(w http.ResponseWriter, r *http.Request)
 		password = r.FormValue("password")
 	}
 
-	refreshToken := r.FormValue("refresh_token")
+	refreshToken, err := bs.refreshTokenParam(r)
+	if err != nil {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusForbidden)
+		return
+	}
 	resp, statusCode := bs.generateTokenResponse(GrantType(grantType), username, password, refreshToken, scope, "", "", r)
-	renderJSON(w, resp, GrantType(grantType) == RefreshTokenGrant, statusCode)
+	bs.renderResponse(w, r, resp, GrantType(grantType) == RefreshTokenGrant, statusCode)
 }
 
 // ClientCredentials manages client credentials grant type requests
 func (bs *BearerServer) ClientCredentials(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if !bs.checkHTTPS(w, r) || !bs.parseForm(w, r) || !bs.checkWrapTTL(w, r) {
+		return
+	}
 	grantType := r.FormValue("grant_type")
 	// grant_type client_credentials variables
-	clientID := r.FormValue("client_id")
-	clientSecret := r.FormValue("client_secret")
-	if clientID == "" || clientSecret == "" {
-		// get clientID and secret from basic authorization header
-		var err error
-		clientID, clientSecret, err = GetBasicAuthentication(r)
-		if err != nil {
-			renderError(w, TokenInvalidClient, "invalid client id or secret", "", http.StatusUnauthorized)
-			return
-		}
+	clientID, clientSecret, err := bs.clientCredentials(r)
+	if err == errClientSecretInBody {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		clientID, r, err = bs.authenticateSVID(r, clientID, clientSecret)
+	}
+	if err != nil {
+		bs.renderError(w, r, TokenInvalidClient, "invalid client id or secret", "", http.StatusUnauthorized)
+		return
 	}
 	scope := r.FormValue("scope")
-	refreshToken := r.FormValue("refresh_token")
+	refreshToken, err := bs.refreshTokenParam(r)
+	if err != nil {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusForbidden)
+		return
+	}
 	resp, statusCode := bs.generateTokenResponse(GrantType(grantType), clientID, clientSecret, refreshToken, scope, "", "", r)
-	renderJSON(w, resp, GrantType(grantType) == RefreshTokenGrant, statusCode)
+	bs.renderResponse(w, r, resp, GrantType(grantType) == RefreshTokenGrant, statusCode)
 }
 
 // AuthorizationCode manages authorization code grant type requests for the phase two of the authorization process
 func (bs *BearerServer) AuthorizationCode(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if !bs.checkHTTPS(w, r) || !bs.parseForm(w, r) || !bs.checkWrapTTL(w, r) {
+		return
+	}
 	grantType := r.FormValue("grant_type")
 	// grant_type client_credentials variables
-	clientID := r.FormValue("client_id")
-	clientSecret := r.FormValue("client_secret") // not mandatory
+	clientID, clientSecret, err := bs.clientCredentials(r) // secret not mandatory for public clients
+	if err == errClientSecretInBody {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		bs.renderError(w, r, TokenInvalidClient, "invalid client id or secret", "", http.StatusUnauthorized)
+		return
+	}
 	code := r.FormValue("code")
 	redirectURI := r.FormValue("redirect_uri") // not mandatory
 	scope := r.FormValue("scope")              // not mandatory
-	if clientID == "" {
-		var err error
+	resp, status := bs.generateTokenResponse(GrantType(grantType), clientID, clientSecret, "", scope, code, redirectURI, r)
+	bs.renderResponse(w, r, resp, GrantType(grantType) == RefreshTokenGrant, status)
+}
+
+// clientCredentials gets the client credentials from the Basic authorization header or, when allowed, from the request body
+func (bs *BearerServer) clientCredentials(r *http.Request) (clientID, clientSecret string, err error) {
+	if bs.ClientAuthFormEncoded {
+		clientID, clientSecret, err = GetClientBasicAuthentication(r)
+	} else {
 		clientID, clientSecret, err = GetBasicAuthentication(r)
-		if err != nil {
-			renderError(w, TokenInvalidClient, "invalid client id or secret", "", http.StatusUnauthorized)
-			return
-		}
 	}
-	resp, status := bs.generateTokenResponse(GrantType(grantType), clientID, clientSecret, "", scope, code, redirectURI, r)
-	renderJSON(w, resp, GrantType(grantType) == RefreshTokenGrant, status)
+	if err != nil || clientID != "" {
+		return clientID, clientSecret, err
+	}
+	// Including the client credentials in the request-body using the two
+	// parameters is NOT RECOMMENDED
+	clientID, clientSecret = r.FormValue("client_id"), r.FormValue("client_secret")
+	if clientSecret != "" && bs.RequireClientAuthHeader {
+		return "", "", errClientSecretInBody
+	}
+	return clientID, clientSecret, nil
 }
 
 // Generate token response
 func (bs *BearerServer) generateTokenResponse(grantType GrantType, credential string, secret string, refreshToken string, scope string, code string, redirectURI string, r *http.Request) (interface{}, int) {
-	var resp *TokenResponse
+	if bs.grantDisabled(grantType) {
+		return ErrorResponse{Error: TokenUnsupportedGrantType, Description: "grant type is unsupported", URI: ""}, http.StatusBadRequest
+	}
+	gc := newGrantContext(grantType, credential, secret, refreshToken, scope, code, redirectURI, r)
+	if r != nil {
+		gc.ClientIP = bs.ClientIPResolver.ClientIP(r)
+		gc.Request = r.WithContext(context.WithValue(r.Context(), clientIPContext, gc.ClientIP))
+	}
+	return bs.grantHandler()(gc)
+}
+
+func (bs *BearerServer) grant(gc *GrantContext) (interface{}, int) {
+	grantType, credential, secret, scope := gc.GrantType, gc.Credential, gc.secret, gc.Scope
+	if grantType == ClientCredentialsGrant || grantType == AuthCodeGrant {
+		// the client is authenticated by the grant with its secret or its authorization code
+		gc.ClientID = credential
+		bs.selectVerifier(gc, credential)
+	}
+	r := gc.Request
 	switch grantType {
 	case PasswordGrant:
-		if err := bs.verifier.ValidateUser(credential, secret, scope, r); err != nil {
+		// the Basic authorization of the password grant carries the user credentials, the verifier of the client is
+		// selected for the client authenticated by its client_secret
+		clientID, clientSecret := r.FormValue("client_id"), r.FormValue("client_secret")
+		selected := clientSecret != "" && bs.selectVerifier(gc, clientID)
+		r = gc.Request
+		if bs.ClientStore != nil {
+			if _, resp, status := bs.authenticateClient(gc, clientID, clientSecret); resp != nil {
+				return resp, status
+			}
+		} else if selected {
+			if resp, status := bs.validateClient(gc, nil, clientID, clientSecret); resp != nil {
+				return resp, status
+			}
+		}
+		if err := bs.verifierFor(r).ValidateUser(credential, secret, scope, r); err != nil {
+			if resp, ok := overloaded(err); ok {
+				return resp, http.StatusServiceUnavailable
+			}
 			return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid username or password", URI: ""}, http.StatusUnauthorized
 		}
-
-		token, refresh, err := bs.generateTokens(UserToken, credential, scope, r)
-		if err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check claims: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		if v, ok := optionalVerifier(bs.verifierFor(r)).(AuthenticationContextVerifier); ok {
+			gc.acr, gc.amr = v.AuthenticationContext(credential, r)
 		}
-
-		if err = bs.verifier.StoreTokenID(token.TokenType, credential, token.ID, refresh.ID); err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "storing Token id failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		if resp, status := bs.checkDevice(gc); resp != nil {
+			return resp, status
 		}
 
-		if resp, err = bs.cryptTokens(token, refresh, r); err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check security provider: " + err.Error(), URI: ""}, http.StatusInternalServerError
-		}
+		return bs.issueTokens(gc, UserToken, credential)
 	case ClientCredentialsGrant:
-		if err := bs.verifier.ValidateClient(credential, secret, scope, r); err != nil {
-			return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid username or password", URI: ""}, http.StatusUnauthorized
+		var client *Client
+		if bs.ClientStore != nil {
+			var err error
+			if client, err = bs.ClientStore.GetClient(credential); err != nil {
+				return clientGrantError(err)
+			}
 		}
-
-		token, refresh, err := bs.generateTokens(ClientToken, credential, scope, r)
-		if err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check claims: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		// the clients authenticated by their SVID have no secret to validate
+		if r == nil || SPIFFEIDFromContext(r.Context()) == "" {
+			if err := bs.checkClientSecret(client, credential, secret, scope, r); err != nil {
+				if resp, ok := overloaded(err); ok {
+					return resp, http.StatusServiceUnavailable
+				}
+				return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid username or password", URI: ""}, http.StatusUnauthorized
+			}
 		}
 
-		if err = bs.verifier.StoreTokenID(token.TokenType, credential, token.ID, refresh.ID); err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "storing Token id failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		if client != nil && !client.AllowsGrantType(grantType) {
+			return clientGrantError(ErrGrantTypeNotAllowed)
 		}
+		gc.ClientAuthenticated = true
 
-		if resp, err = bs.cryptTokens(token, refresh, r); err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check security provider: " + err.Error(), URI: ""}, http.StatusInternalServerError
-		}
+		return bs.issueTokens(gc, ClientToken, credential)
 	case AuthCodeGrant:
-		codeVerifier, ok := bs.verifier.(AuthorizationCodeVerifier)
+		if bs.StatelessAuthorizationCodes || bs.AuthCodeStore != nil {
+			return bs.codeGrant(gc)
+		}
+
+		codeVerifier, ok := optionalVerifier(bs.verifierFor(r)).(AuthorizationCodeVerifier)
 		if !ok {
 			return ErrorResponse{Error: TokenUnsupportedGrantType, Description: "grant type is unsupported", URI: ""}, http.StatusBadRequest
 		}
 
-		user, err := codeVerifier.ValidateCode(credential, secret, code, redirectURI, r)
+		client, err := bs.checkClientGrant(credential, grantType)
 		if err != nil {
-			return ErrorResponse{Error: TokenInvalidRequest, Description: "invalid username or password", URI: ""}, http.StatusBadRequest
+			return clientGrantError(err)
+		}
+		if client == nil || !client.Public {
+			if secret == "" {
+				return ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+			}
+			if err = bs.checkClientSecret(client, credential, secret, "", r); err != nil {
+				if resp, ok := overloaded(err); ok {
+					return resp, http.StatusServiceUnavailable
+				}
+				return ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+			}
 		}
 
-		token, refresh, err := bs.generateTokens(AuthToken, user, scope, r)
-		if err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check claims: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		if resp, status := bs.verifyPKCE(client, credential, gc.code, r); resp != nil {
+			return resp, status
 		}
 
-		err = bs.verifier.StoreTokenID(token.TokenType, user, token.ID, refresh.ID)
+		user, err := codeVerifier.ValidateCode(credential, secret, gc.code, gc.RedirectURI, r)
 		if err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "storing Token id failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+			if resp, ok := overloaded(err); ok {
+				return resp, http.StatusServiceUnavailable
+			}
+			return ErrorResponse{Error: TokenInvalidRequest, Description: "invalid username or password", URI: ""}, http.StatusBadRequest
 		}
+		gc.ClientAuthenticated = true
 
-		if resp, err = bs.cryptTokens(token, refresh, r); err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check security provider: " + err.Error(), URI: ""}, http.StatusInternalServerError
-		}
+		return bs.issueTokens(gc, AuthToken, user)
 	case RefreshTokenGrant:
-		refresh, err := bs.provider.DecryptRefreshTokens(refreshToken)
+		refresh, err := bs.decryptRefreshToken(gc.refreshToken)
 		if err != nil || refresh.IsExpired() {
 			return ErrorResponse{Error: TokenInvalidRequest, Description: "refresh token is invalid or expired", URI: ""}, http.StatusBadRequest
 		}
 
-		if err = bs.verifier.ValidateTokenID(refresh.TokenType, refresh.Credential, refresh.TokenID, refresh.ID); err != nil {
+		if owner := refreshTokenClient(refresh); owner != "" {
+			bs.selectVerifier(gc, owner)
+			r = gc.Request
+		}
+		if err = bs.verifierFor(r).ValidateTokenID(refresh.TokenType, refresh.Credential, refresh.TokenID, refresh.ID); err != nil {
+			if resp, ok := overloaded(err); ok {
+				return resp, http.StatusServiceUnavailable
+			}
 			return ErrorResponse{Error: TokenInvalidRequest, Description: "refresh token is invalid or expired", URI: ""}, http.StatusBadRequest
 		}
+		var parent *TokenRecord
+		if bs.TokenStore != nil {
+			if parent, err = bs.TokenStore.GetToken(refresh.ID); err != nil && err != ErrTokenNotFound {
+				return ErrorResponse{Error: TokenServerError, Description: "loading Token failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+			}
+			if err == ErrTokenNotFound {
+				return ErrorResponse{Error: TokenInvalidRequest, Description: "refresh token is invalid or expired", URI: ""}, http.StatusBadRequest
+			}
+			if parent.Revoked {
+				// a rotated refresh token is presented again: the token family is compromised
+				bs.revokeFamily(parent)
+				return ErrorResponse{Error: TokenInvalidRequest, Description: "refresh token is invalid or expired", URI: ""}, http.StatusBadRequest
+			}
+		}
 
-		token, refresh, err := bs.refreshTokens(refresh.TokenType, refresh.Credential, refresh.Scope, refresh.Claims)
-		if err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		if bs.ClientStore != nil {
+			// the refresh requests authenticate the client with the Basic authorization or the client_id and client_secret
+			clientID, clientSecret := credential, secret
+			if clientID == "" && r != nil {
+				clientID, clientSecret = r.FormValue("client_id"), r.FormValue("client_secret")
+			}
+			if _, resp, status := bs.authenticateClient(gc, clientID, clientSecret); resp != nil {
+				return resp, status
+			}
+			if owner := refreshTokenClient(refresh); owner != "" && owner != gc.ClientID {
+				return ErrorResponse{Error: TokenInvalidGrant, Description: "refresh token was issued to another client", URI: ""}, http.StatusBadRequest
+			}
 		}
 
-		err = bs.verifier.StoreTokenID(token.TokenType, refresh.Credential, token.ID, refresh.ID)
+		gc.Credential, gc.Scope = refresh.Credential, refresh.Scope
+		gc.deviceID = refresh.DeviceID
+		if resp, status := bs.validateGrant(gc); resp != nil {
+			return resp, status
+		}
+
+		token, refresh, err := bs.refreshTokens(refresh, r)
+		if err == errRefreshLifetimeExceeded {
+			return ErrorResponse{Error: TokenInvalidRequest, Description: "refresh token is invalid or expired", URI: ""}, http.StatusBadRequest
+		}
 		if err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "storing Token id failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+			return ErrorResponse{Error: TokenServerError, Description: "token generation failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
 		}
+		capLifetimes(token, refresh, gc.maxTTL)
 
-		if resp, err = bs.cryptTokens(token, refresh, r); err != nil {
-			return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check security provider: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		resp, status := bs.storeAndCryptTokens(token, refresh, r, parent)
+		if status == http.StatusOK {
+			bs.trackUsage(gc, refresh, true)
 		}
+		return resp, status
 	default:
+		if handler, ok := bs.assertionGrants[grantType]; ok {
+			return bs.assertionGrant(gc, handler)
+		}
 		return ErrorResponse{Error: TokenUnsupportedGrantType, Description: "grant type is unsupported", URI: ""}, http.StatusBadRequest
 	}
+}
+
+// issueTokens validates the grant context, then generates, stores and crypts the tokens issued to the credential
+func (bs *BearerServer) issueTokens(gc *GrantContext, tokenType TokenType, credential string) (interface{}, int) {
+	gc.Credential = credential
+	if resp, status := bs.validateGrant(gc); resp != nil {
+		return resp, status
+	}
+	token, refresh, err := bs.generateTokens(tokenType, credential, gc.authenticatedClient(), gc.Scope, gc.Request)
+	if err != nil {
+		if resp, ok := overloaded(err); ok {
+			return resp, http.StatusServiceUnavailable
+		}
+		return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check claims: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	if !gc.authTime.IsZero() {
+		if refresh.ExpiresIn, err = bs.refreshTokenTTL(tokenType, credential, gc.authenticatedClient(), gc.authTime, gc.Request); err != nil {
+			return ErrorResponse{Error: TokenInvalidGrant, Description: "authentication is too old", URI: ""}, http.StatusBadRequest
+		}
+		refresh.AuthTime = gc.authTime
+	}
+	capLifetimes(token, refresh, gc.maxTTL)
+	setAuthenticationContext(token, refresh, gc.acr, gc.amr)
+	if gc.ClientAuthenticated {
+		refresh.ClientID = gc.ClientID
+	}
+	if err = bs.rememberDevice(gc, refresh); err != nil {
+		return ErrorResponse{Error: TokenServerError, Description: "storing trusted device failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	resp, status := bs.storeAndCryptTokens(token, refresh, gc.Request, nil)
+	if tr, ok := resp.(*TokenResponse); ok {
+		if gc.trustedDevice {
+			_ = tr.SetExtension(DeviceClaim, gc.deviceID)
+		}
+		bs.trackUsage(gc, refresh, false)
+	}
+	return resp, status
+}
+
+// storeAndCryptTokens stores and crypts the tokens, then revokes the rotated refresh token, if any, once the new
+// tokens are issued so that a failed rotation can be retried with it. The refresh token rotated concurrently by
+// another request is reused: its family is revoked.
+func (bs *BearerServer) storeAndCryptTokens(token *Token, refresh *RefreshToken, r *http.Request, rotated *TokenRecord) (interface{}, int) {
+	bs.applyScopeTTL(token)
+	if err := bs.storeTokenID(token, refresh, r); err != nil {
+		if resp, ok := overloaded(err); ok {
+			return resp, http.StatusServiceUnavailable
+		}
+		return ErrorResponse{Error: TokenServerError, Description: "storing Token id failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	if bs.TokenStore != nil {
+		if err := bs.TokenStore.SaveToken(tokenRecord(token, refresh)); err != nil {
+			return ErrorResponse{Error: TokenServerError, Description: "storing Token failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		}
+	}
 
+	resp, err := bs.cryptTokens(token, refresh, r)
+	if errors.Is(err, ErrTokenTooLarge) {
+		return ErrorResponse{Error: TokenServerError, Description: "token generation failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	if err != nil {
+		return ErrorResponse{Error: TokenServerError, Description: "token generation failed, check security provider: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+	if rotated != nil {
+		if err = bs.TokenStore.RotateToken(rotated.ID); err == ErrTokenRotated {
+			bs.revokeFamily(rotated)
+			return ErrorResponse{Error: TokenInvalidRequest, Description: "refresh token is invalid or expired", URI: ""}, http.StatusBadRequest
+		}
+		if err != nil {
+			if resp, ok := overloaded(err); ok {
+				return resp, http.StatusServiceUnavailable
+			}
+			return ErrorResponse{Error: TokenServerError, Description: "revoking Token failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+		}
+	}
+	bs.publishTokens(token, refresh, r)
 	return resp, http.StatusOK
 }
 
-func (bs *BearerServer) refreshTokens(tokenType TokenType, username, scope string, claims Claims) (*Token, *RefreshToken, error) {
-	token := &Token{ID: uuid.Must(uuid.NewV4()).String(), Credential: username, ExpiresIn: bs.TokenTTL, CreationDate: time.Now().UTC(), TokenType: tokenType, Scope: scope, Claims: claims}
-	refreshToken := &RefreshToken{ID: uuid.Must(uuid.NewV4()).String(), TokenID: token.ID, Credential: username, ExpiresIn: bs.RefreshTokenTTL, CreationDate: time.Now().UTC(), TokenType: tokenType, Scope: scope, Claims: claims}
+// checkClientGrant verifies that the client is registered for the grant type when a ClientStore is configured,
+// the returned client is nil when there is no ClientStore
+func (bs *BearerServer) checkClientGrant(clientID string, grantType GrantType) (*Client, error) {
+	if bs.ClientStore == nil {
+		return nil, nil
+	}
+	client, err := bs.ClientStore.GetClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrantType(grantType) {
+		return nil, ErrGrantTypeNotAllowed
+	}
+	return client, nil
+}
+
+// authenticateClient requires the client of the grant request to be registered in the ClientStore for the grant type:
+// the public clients are identified by their client_id, the confidential clients are authenticated with checkClientSecret.
+// The client is then the authenticated client of the grant context.
+func (bs *BearerServer) authenticateClient(gc *GrantContext, clientID, secret string) (*Client, interface{}, int) {
+	if clientID == "" {
+		return nil, ErrorResponse{Error: TokenInvalidClient, Description: "client authentication required", URI: ""}, http.StatusUnauthorized
+	}
+	client, err := bs.ClientStore.GetClient(clientID)
+	if err != nil {
+		resp, status := clientGrantError(err)
+		return nil, resp, status
+	}
+	// the public clients have no secret, a secret selected the verifier of a confidential client
+	if client.Public != (secret == "") {
+		return nil, ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+	}
+	if !client.Public {
+		if resp, status := bs.validateClient(gc, client, clientID, secret); resp != nil {
+			return nil, resp, status
+		}
+	}
+	if !client.AllowsGrantType(gc.GrantType) {
+		resp, status := clientGrantError(ErrGrantTypeNotAllowed)
+		return nil, resp, status
+	}
+	gc.ClientID, gc.ClientAuthenticated = clientID, true
+	return client, nil, 0
+}
+
+// validateClient authenticates the client with checkClientSecret, the client is nil without ClientStore
+func (bs *BearerServer) validateClient(gc *GrantContext, client *Client, clientID, secret string) (interface{}, int) {
+	if err := bs.checkClientSecret(client, clientID, secret, gc.Scope, gc.Request); err != nil {
+		if resp, ok := overloaded(err); ok {
+			return resp, http.StatusServiceUnavailable
+		}
+		return ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+	}
+	gc.ClientID, gc.ClientAuthenticated = clientID, true
+	return nil, 0
+}
+
+// checkClientSecret authenticates the confidential client: the secret is verified against the SecretHash of the
+// registered client when set, otherwise by the ValidateClient of the verifier selected for the request
+func (bs *BearerServer) checkClientSecret(client *Client, clientID, secret, scope string, r *http.Request) error {
+	if client != nil && client.SecretHash != "" {
+		return VerifyClientSecret(client.SecretHash, secret)
+	}
+	return bs.verifierFor(r).ValidateClient(clientID, secret, scope, r)
+}
+
+// refreshTokenClient returns the client the refresh token was issued to, empty when unknown
+func refreshTokenClient(refresh *RefreshToken) string {
+	if refresh.TokenType == ClientToken {
+		return refresh.Credential
+	}
+	return refresh.ClientID
+}
+
+// verifyPKCE checks the code_verifier when the code is bound to a code_challenge, PKCE is mandatory for public clients
+func (bs *BearerServer) verifyPKCE(client *Client, clientID, code string, r *http.Request) (interface{}, int) {
+	public := client != nil && client.Public
+	pkceVerifier, ok := optionalVerifier(bs.verifierFor(r)).(PKCEVerifier)
+	if !ok {
+		if public {
+			return ErrorResponse{Error: TokenInvalidRequest, Description: "PKCE is required for public clients", URI: ""}, http.StatusBadRequest
+		}
+		return nil, 0
+	}
+	challenge, method, err := pkceVerifier.CodeChallenge(clientID, code, r)
+	if err != nil {
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid authorization code", URI: ""}, http.StatusBadRequest
+	}
+	if challenge == "" {
+		if public {
+			return ErrorResponse{Error: TokenInvalidRequest, Description: "PKCE is required for public clients", URI: ""}, http.StatusBadRequest
+		}
+		return nil, 0
+	}
+	if !VerifyCodeChallenge(challenge, method, r.FormValue("code_verifier")) {
+		return ErrorResponse{Error: TokenInvalidGrant, Description: "invalid code_verifier", URI: ""}, http.StatusBadRequest
+	}
+	return nil, 0
+}
+
+func clientGrantError(err error) (interface{}, int) {
+	switch err {
+	case ErrGrantTypeNotAllowed:
+		return ErrorResponse{Error: TokenUnauthorizedClient, Description: "client is not authorized to use this grant type", URI: ""}, http.StatusBadRequest
+	case ErrClientNotFound:
+		return ErrorResponse{Error: TokenInvalidClient, Description: "invalid client id or secret", URI: ""}, http.StatusUnauthorized
+	default:
+		return ErrorResponse{Error: TokenServerError, Description: "client lookup failed: " + err.Error(), URI: ""}, http.StatusInternalServerError
+	}
+}
+
+func (bs *BearerServer) refreshTokens(old *RefreshToken, r *http.Request) (*Token, *RefreshToken, error) {
+	authTime := old.AuthTime
+	if authTime.IsZero() {
+		authTime = old.CreationDate
+	}
+	refreshTTL, err := bs.refreshTokenTTL(old.TokenType, old.Credential, refreshTokenClient(old), authTime, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	token := &Token{ID: uuid.Must(uuid.NewV4()).String(), Credential: old.Credential, ExpiresIn: bs.tokenTTL(), CreationDate: time.Now().UTC(), TokenType: old.TokenType, Scope: old.Scope, Claims: old.Claims}
+	if bs.RefreshClaims && bs.verifierFor(r) != nil {
+		if token.Claims, err = bs.verifierFor(r).AddClaims(token.TokenType, token.Credential, token.ID, token.Scope, r); err != nil {
+			return nil, nil, err
+		}
+		token.Claims = keepServerClaims(token.Claims, old.Claims)
+	}
+	familyID := old.FamilyID
+	if familyID == "" {
+		familyID = old.ID
+	}
+	refreshToken := &RefreshToken{ID: uuid.Must(uuid.NewV4()).String(), TokenID: token.ID, Credential: old.Credential, ExpiresIn: refreshTTL, CreationDate: token.CreationDate, AuthTime: authTime, TokenType: old.TokenType, Scope: old.Scope, Claims: token.Claims, ParentID: old.ID, FamilyID: familyID, ClientID: old.ClientID, DeviceID: old.DeviceID}
 	return token, refreshToken, nil
 }
 
-func (bs *BearerServer) generateTokens(tokenType TokenType, username, scope string, r *http.Request) (*Token, *RefreshToken, error) {
-	token := &Token{ID: uuid.Must(uuid.NewV4()).String(), Credential: username, ExpiresIn: bs.TokenTTL, CreationDate: time.Now().UTC(), TokenType: tokenType, Scope: scope}
+// refreshTokenTTL returns the idle lifetime of the refresh token issued through the authenticated client bounded by the
+// absolute lifetime started at authTime
+func (bs *BearerServer) refreshTokenTTL(tokenType TokenType, credential, clientID string, authTime time.Time, r *http.Request) (time.Duration, error) {
+	_, idle, absolute := bs.ttls(bs.Config())
+	if v, ok := optionalVerifier(bs.verifierFor(r)).(RefreshTokenLifetimeVerifier); ok {
+		i, a := v.RefreshTokenLifetime(tokenType, credential, clientID)
+		if i > 0 {
+			idle = i
+		}
+		if a > 0 {
+			absolute = a
+		}
+	}
+	if absolute > 0 {
+		remaining := time.Until(authTime.Add(absolute))
+		if remaining <= 0 {
+			return 0, errRefreshLifetimeExceeded
+		}
+		if idle <= 0 || remaining < idle {
+			idle = remaining
+		}
+	}
+	return idle, nil
+}
+
+func (bs *BearerServer) generateTokens(tokenType TokenType, username, clientID, scope string, r *http.Request) (*Token, *RefreshToken, error) {
+	token := &Token{ID: uuid.Must(uuid.NewV4()).String(), Credential: username, ExpiresIn: bs.tokenTTL(), CreationDate: time.Now().UTC(), TokenType: tokenType, Scope: scope}
 	var claims Claims
 	var err error
-	if bs.verifier != nil {
-		claims, err = bs.verifier.AddClaims(token.TokenType, username, token.ID, token.Scope, r)
+	if bs.verifierFor(r) != nil {
+		claims, err = bs.verifierFor(r).AddClaims(token.TokenType, username, token.ID, token.Scope, r)
 		if err != nil {
 			return nil, nil, err
 		}
 		token.Claims = claims
 	}
 
-	refreshToken := &RefreshToken{ID: uuid.Must(uuid.NewV4()).String(), TokenID: token.ID, Credential: username, ExpiresIn: bs.RefreshTokenTTL, CreationDate: time.Now().UTC(), TokenType: tokenType, Scope: scope, Claims: claims}
+	refreshTTL, err := bs.refreshTokenTTL(tokenType, username, clientID, token.CreationDate, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken := &RefreshToken{ID: uuid.Must(uuid.NewV4()).String(), TokenID: token.ID, Credential: username, ExpiresIn: refreshTTL, CreationDate: token.CreationDate, AuthTime: token.CreationDate, TokenType: tokenType, Scope: scope, Claims: claims}
 	return token, refreshToken, nil
 }
 
 func (bs *BearerServer) cryptTokens(token *Token, refresh *RefreshToken, r *http.Request) (*TokenResponse, error) {
 	cToken, err := bs.provider.CryptToken(token)
+	if errors.Is(err, ErrTokenTooLarge) {
+		cToken, err = bs.referenceToken(err, token.ID, token.Credential, token.Claims, &referenceRecord{Token: token}, expiresAt(token.CreationDate, token.ExpiresIn))
+	}
 	if err != nil {
 		return nil, err
 	}
 	cRefreshToken, err := bs.provider.CryptRefreshToken(refresh)
+	if errors.Is(err, ErrTokenTooLarge) {
+		cRefreshToken, err = bs.referenceToken(err, refresh.ID, refresh.Credential, refresh.Claims, &referenceRecord{Refresh: refresh}, expiresAt(refresh.CreationDate, refresh.ExpiresIn))
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	tokenResponse := &TokenResponse{Token: cToken, RefreshToken: cRefreshToken, TokenType: BearerToken, ExpiresIn: (int64)(bs.TokenTTL.Seconds()), RefreshTokenExpiresIn: (int64)(bs.RefreshTokenTTL.Seconds())}
+	tokenResponse := &TokenResponse{Token: cToken, RefreshToken: cRefreshToken, TokenType: bs.responseTokenType(token, r), ExpiresIn: expiresIn(token.CreationDate, token.ExpiresIn), RefreshTokenExpiresIn: expiresIn(refresh.CreationDate, refresh.ExpiresIn)}
 
-	if bs.verifier != nil {
-		props, err := bs.verifier.AddProperties(token.TokenType, token.Credential, token.ID, token.Scope, r)
+	if bs.verifierFor(r) != nil {
+		props, err := bs.verifierFor(r).AddProperties(token.TokenType, token.Credential, token.ID, token.Scope, r)
 		if err != nil {
 			return nil, err
 		}
 		tokenResponse.Properties = props
 	}
+	if extVerifier, ok := optionalVerifier(bs.verifierFor(r)).(ExtensionsVerifier); ok {
+		extensions, err := extVerifier.AddExtensions(token.TokenType, token.Credential, token.ID, token.Scope, r)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range extensions {
+			if err = tokenResponse.SetExtension(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return tokenResponse, nil
 }
+
+// responseTokenType returns the token_type of the response: the TokenTypeFunc result, DPoPToken for the tokens bound
+// to a DPoP key (cnf.jkt claim), otherwise ResponseTokenType or BearerToken
+func (bs *BearerServer) responseTokenType(token *Token, r *http.Request) TokenType {
+	if bs.TokenTypeFunc != nil {
+		if t := bs.TokenTypeFunc(token, r); t != "" {
+			return t
+		}
+	}
+	if jkt, ok := token.Claims.Lookup("cnf.jkt"); ok && jkt != "" {
+		return DPoPToken
+	}
+	if bs.ResponseTokenType != "" {
+		return bs.ResponseTokenType
+	}
+	return BearerToken
+}
+
+// expiresIn returns the seconds remaining before the expiry of the token
+func expiresIn(creationDate time.Time, ttl time.Duration) int64 {
+	remaining := time.Until(creationDate.Add(ttl))
+	if remaining < 0 {
+		return 0
+	}
+	return int64(math.Round(remaining.Seconds()))
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -92,7 +95,7 @@ func (TestUserVerifier) StoreTokenID(tokenType TokenType, credential, tokenID, r
 
 func TestGenerateTokensByUsername(t *testing.T) {
 	r := new(http.Request)
-	token, refresh, err := _sut.generateTokens(UserToken, "user111", "", r)
+	token, refresh, err := _sut.generateTokens(UserToken, "user111", "", "", r)
 	if err == nil {
 		t.Logf("Token: %v", token)
 		t.Logf("Refresh Token: %v", refresh)
@@ -103,7 +106,7 @@ func TestGenerateTokensByUsername(t *testing.T) {
 
 func TestCryptTokens(t *testing.T) {
 	r := new(http.Request)
-	token, refresh, err := _sut.generateTokens(UserToken, "user222", "", r)
+	token, refresh, err := _sut.generateTokens(UserToken, "user222", "", "", r)
 	if err == nil {
 		t.Logf("Token: %v", token)
 		t.Logf("Refresh Token: %v", refresh)
@@ -121,7 +124,7 @@ func TestCryptTokens(t *testing.T) {
 
 func TestDecryptRefreshTokens(t *testing.T) {
 	r := new(http.Request)
-	token, refresh, err := _sut.generateTokens(UserToken, "user333", "", r)
+	token, refresh, err := _sut.generateTokens(UserToken, "user333", "", "", r)
 	if err == nil {
 		t.Logf("Token: %v", token)
 		t.Logf("Refresh Token: %v", refresh)
@@ -191,3 +194,197 @@ func TestRefreshToken4ClientCredentials(t *testing.T) {
 	}
 	t.Logf("New Token Response: %v", resp2)
 }
+
+func TestRefreshTokenAbsoluteLifetime(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Hour, new(TestUserVerifier), nil)
+	sut.RefreshTokenMaxLifetime = time.Hour * 2
+	r := new(http.Request)
+
+	old := &RefreshToken{ID: "r1", TokenID: "t1", Credential: "abcdef", TokenType: ClientToken, ExpiresIn: time.Hour, CreationDate: time.Now().UTC(), AuthTime: time.Now().UTC().Add(-time.Hour - time.Minute*30)}
+	_, refresh, err := sut.refreshTokens(old, r)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if refresh.ExpiresIn > time.Minute*30 || !refresh.AuthTime.Equal(old.AuthTime) {
+		t.Fatalf("Error refresh token not bounded by absolute lifetime: %v", refresh)
+	}
+
+	old.AuthTime = time.Now().UTC().Add(-time.Hour * 3)
+	cRefresh, err := sut.provider.CryptRefreshToken(old)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	_, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", cRefresh, "", "", "", r)
+	if code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+}
+
+type clientLifetimeVerifier struct {
+	TestUserVerifier
+}
+
+func (clientLifetimeVerifier) RefreshTokenLifetime(tokenType TokenType, credential, clientID string) (time.Duration, time.Duration) {
+	if clientID == "abcdef" {
+		return time.Minute * 5, 0
+	}
+	return 0, 0
+}
+
+func TestRefreshTokenLifetimePerClient(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Hour, new(clientLifetimeVerifier), nil)
+	r := new(http.Request)
+
+	resp, code := sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", "12345", "", "", "", "", r)
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	refresh, _ := sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	if refresh.ExpiresIn != time.Minute*5 {
+		t.Fatalf("Error client refresh token lifetime = %s", refresh.ExpiresIn)
+	}
+	resp, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", resp.(*TokenResponse).RefreshToken, "", "", "", r)
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if refresh, _ = sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken); refresh.ExpiresIn != time.Minute*5 {
+		t.Fatalf("Error rotated refresh token lifetime = %s", refresh.ExpiresIn)
+	}
+
+	resp, code = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", r)
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if refresh, _ = sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken); refresh.ExpiresIn != time.Hour {
+		t.Fatalf("Error refresh token lifetime without client = %s", refresh.ExpiresIn)
+	}
+}
+
+func TestClientGrantTypeAllowList(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{ClientCredentialsGrant}})
+	r := new(http.Request)
+
+	resp, code := sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", "12345", "", "", "", "", r)
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	refreshToken := resp.(*TokenResponse).RefreshToken
+	if _, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", refreshToken, "", "", "", r); code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	resp, code = sut.generateTokenResponse(RefreshTokenGrant, "abcdef", "12345", refreshToken, "", "", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenUnauthorizedClient {
+		t.Fatalf("Error response = %v", resp)
+	}
+
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{ClientCredentialsGrant, RefreshTokenGrant}},
+		&Client{ID: "spa", Public: true, AllowedGrantTypes: []GrantType{RefreshTokenGrant}})
+	r, _ = http.NewRequest("POST", "/token?client_id=spa", nil)
+	resp, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", refreshToken, "", "", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenInvalidGrant {
+		t.Fatalf("Error refresh token of another client: response = %v", resp)
+	}
+
+	// the password grant requires an authenticated client registered for the grant type
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{PasswordGrant}})
+	for query, expected := range map[string]int{"": 401, "?client_id=abcdef": 401, "?client_id=abcdef&client_secret=wrong": 401, "?client_id=abcdef&client_secret=12345": 200} {
+		r, _ := http.NewRequest("POST", "/token"+query, nil)
+		if resp, code = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", r); code != expected {
+			t.Fatalf("Error %s: response = %v", query, resp)
+		}
+	}
+
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{PasswordGrant}})
+	resp, code = sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", "12345", "", "", "", "", r)
+	if code != http.StatusBadRequest || resp.(ErrorResponse).Error != TokenUnauthorizedClient {
+		t.Fatalf("Error response = %v", resp)
+	}
+}
+
+func TestRequireClientAuthHeader(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.RequireClientAuthHeader = true
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}, "client_secret": {"12345"}}
+	req := httptest.NewRequest("POST", "/auth", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.ClientCredentials(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/auth", strings.NewReader("grant_type=client_credentials"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("abcdef", "12345")
+	w = httptest.NewRecorder()
+	sut.ClientCredentials(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRefreshClaims(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	old := &RefreshToken{ID: "r1", TokenID: "t1", Credential: "user111", TokenType: UserToken, ExpiresIn: time.Hour, CreationDate: time.Now().UTC(), Claims: Claims{"role": "admin"}}
+
+	token, _, err := sut.refreshTokens(old, new(http.Request))
+	if err != nil || token.Claims["role"] != "admin" {
+		t.Fatalf("Error claims = %v, %v", token.Claims, err)
+	}
+
+	sut.RefreshClaims = true
+	token, refresh, err := sut.refreshTokens(old, new(http.Request))
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if _, ok := token.Claims["role"]; ok || token.Claims["customer_id"] != "1001" || refresh.Claims["customer_id"] != "1001" {
+		t.Fatalf("Error claims not refreshed = %v", token.Claims)
+	}
+
+	old.Claims[ActorClaim] = map[string]interface{}{"sub": "support01"}
+	token, refresh, err = sut.refreshTokens(old, new(http.Request))
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if actor, _ := token.Claims.Lookup("act.sub"); actor != "support01" || refresh.Claims[ActorClaim] == nil || token.Claims["customer_id"] != "1001" {
+		t.Fatalf("Error actor not kept = %v", token.Claims)
+	}
+}
+
+func TestResponseTokenType(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	token := &Token{Claims: Claims{}}
+	if tt := sut.responseTokenType(token, nil); tt != BearerToken {
+		t.Fatalf("Error token_type = %s", tt)
+	}
+	sut.ResponseTokenType = "bearer"
+	if tt := sut.responseTokenType(token, nil); tt != "bearer" {
+		t.Fatalf("Error token_type = %s", tt)
+	}
+	token.Claims["cnf"] = map[string]interface{}{"jkt": "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"}
+	if tt := sut.responseTokenType(token, nil); tt != DPoPToken {
+		t.Fatalf("Error token_type = %s", tt)
+	}
+	sut.TokenTypeFunc = func(token *Token, r *http.Request) TokenType { return "MAC" }
+	if tt := sut.responseTokenType(token, nil); tt != "MAC" {
+		t.Fatalf("Error token_type = %s", tt)
+	}
+}
+
+func TestExpiresInFromTokens(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Hour, new(TestUserVerifier), nil)
+	sut.RefreshTokenMaxLifetime = time.Hour * 2
+	old := &RefreshToken{ID: "r1", TokenID: "t1", Credential: "abcdef", TokenType: ClientToken, ExpiresIn: time.Hour, CreationDate: time.Now().UTC(), AuthTime: time.Now().UTC().Add(-time.Hour - time.Minute*30)}
+	cRefresh, _ := sut.provider.CryptRefreshToken(old)
+
+	resp, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", cRefresh, "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	tr := resp.(*TokenResponse)
+	if tr.ExpiresIn != 10 || tr.RefreshTokenExpiresIn > 30*60 || tr.RefreshTokenExpiresIn < 30*60-5 {
+		t.Fatalf("Error expires_in = %d, refresh_token_expires_in = %d", tr.ExpiresIn, tr.RefreshTokenExpiresIn)
+	}
+}
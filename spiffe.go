@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+const spiffeIDContext contextKey = "oauth.spiffeid"
+
+// ErrUnknownSPIFFEID is returned by the SPIFFEResolver when the SPIFFE ID is not mapped to a client.
+var ErrUnknownSPIFFEID = errors.New("unknown SPIFFE ID")
+
+// SPIFFEResolver maps the SPIFFE IDs of the workload X.509 SVIDs authenticating the client_credentials grant
+// to the client ids, so the workloads of a service mesh need no static client secret.
+type SPIFFEResolver interface {
+	// ResolveSPIFFEID returns the client id of the SPIFFE ID, e.g. spiffe://example.org/ns/prod/sa/billing
+	ResolveSPIFFEID(spiffeID string, r *http.Request) (clientID string, err error)
+}
+
+// StaticSPIFFEResolver is the SPIFFEResolver mapping the SPIFFE IDs to the client ids
+type StaticSPIFFEResolver map[string]string
+
+// ResolveSPIFFEID returns the client id of the SPIFFE ID, ErrUnknownSPIFFEID when it is not mapped
+func (m StaticSPIFFEResolver) ResolveSPIFFEID(spiffeID string, _ *http.Request) (string, error) {
+	if clientID, ok := m[spiffeID]; ok {
+		return clientID, nil
+	}
+	return "", ErrUnknownSPIFFEID
+}
+
+// SPIFFEID returns the SPIFFE ID of the X.509 SVID presented by the client: the single spiffe URI SAN of the leaf
+// certificate of the verified chain. The TLS server must verify the client certificates against the SPIFFE trust
+// bundle (tls.Config ClientAuth and ClientCAs).
+func SPIFFEID(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	if len(leaf.URIs) != 1 || leaf.URIs[0].Scheme != "spiffe" || leaf.URIs[0].Host == "" {
+		return "", false
+	}
+	return leaf.URIs[0].String(), true
+}
+
+// SPIFFEIDFromContext returns the SPIFFE ID that authenticated the client_credentials grant, empty otherwise
+func SPIFFEIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(spiffeIDContext).(string)
+	return id
+}
+
+// authenticateSVID resolves the client id of the SVID of the request without client secret, returning the request
+// carrying the SPIFFE ID. The client id, when sent, must match the resolved one.
+func (bs *BearerServer) authenticateSVID(r *http.Request, clientID, clientSecret string) (string, *http.Request, error) {
+	if bs.SPIFFEResolver == nil || clientSecret != "" || GrantType(r.FormValue("grant_type")) != ClientCredentialsGrant {
+		return clientID, r, nil
+	}
+	spiffeID, ok := SPIFFEID(r)
+	if !ok {
+		return clientID, r, nil
+	}
+	resolved, err := bs.SPIFFEResolver.ResolveSPIFFEID(spiffeID, r)
+	if err != nil {
+		return "", r, err
+	}
+	if clientID != "" && clientID != resolved {
+		return "", r, ErrUnknownSPIFFEID
+	}
+	return resolved, r.WithContext(context.WithValue(r.Context(), spiffeIDContext, spiffeID)), nil
+}
@@ -0,0 +1,42 @@
+package oauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSPIFFEClientAuthentication(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.SPIFFEResolver = StaticSPIFFEResolver{"spiffe://example.org/ns/prod/sa/billing": "abcdef"}
+
+	post := func(spiffeID string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if spiffeID != "" {
+			id, _ := url.Parse(spiffeID)
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{URIs: []*url.URL{id}}}}}
+		}
+		w := httptest.NewRecorder()
+		sut.ClientCredentials(w, req)
+		return w
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if w := post("spiffe://example.org/ns/prod/sa/billing", form); w.Code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := post("spiffe://example.org/ns/prod/sa/other", form); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w := post("spiffe://example.org/ns/prod/sa/billing", url.Values{"grant_type": {"client_credentials"}, "client_id": {"other"}}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w := post("", url.Values{"grant_type": {"client_credentials"}, "client_id": {"abcdef"}}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Error the client without SVID should need its secret: StatusCode = %d", w.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+var (
+	_ oauth.ClientStore      = (*Store)(nil)
+	_ oauth.ClientAdminStore = (*Store)(nil)
+)
+
+const clientSelect = "SELECT id, grant_types, public, redirect_uris, secret_hash FROM oauth_clients"
+
+// GetClient returns the client registration or oauth.ErrClientNotFound
+func (s *Store) GetClient(clientID string) (*oauth.Client, error) {
+	row, err := s.queryRow(context.Background(), nil, clientSelect+" WHERE id = ?", clientID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := scanClient(row)
+	if err == sql.ErrNoRows {
+		return nil, oauth.ErrClientNotFound
+	}
+	return c, err
+}
+
+// ListClients returns the client registrations sorted by id
+func (s *Store) ListClients() ([]*oauth.Client, error) {
+	rows, err := s.query(context.Background(), nil, clientSelect+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var clients []*oauth.Client
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+// scanClient scans a row of clientSelect
+func scanClient(row interface{ Scan(...interface{}) error }) (*oauth.Client, error) {
+	var c oauth.Client
+	var grantTypes, redirectURIs string
+	if err := row.Scan(&c.ID, &grantTypes, &c.Public, &redirectURIs, &c.SecretHash); err != nil {
+		return nil, err
+	}
+	for _, g := range strings.Fields(grantTypes) {
+		c.AllowedGrantTypes = append(c.AllowedGrantTypes, oauth.GrantType(g))
+	}
+	c.RedirectURIs = strings.Fields(redirectURIs)
+	return &c, nil
+}
+
+// SaveClient creates or replaces the client registration
+func (s *Store) SaveClient(c *oauth.Client) error {
+	grantTypes := make([]string, len(c.AllowedGrantTypes))
+	for i, g := range c.AllowedGrantTypes {
+		grantTypes[i] = string(g)
+	}
+	_, err := s.exec(context.Background(), nil, "INSERT INTO oauth_clients (id, grant_types, public, redirect_uris, secret_hash) VALUES (?, ?, ?, ?, ?)"+s.dialect.upsert("id", []string{"grant_types", "public", "redirect_uris", "secret_hash"}),
+		c.ID, strings.Join(grantTypes, " "), c.Public, strings.Join(c.RedirectURIs, " "), c.SecretHash)
+	return err
+}
+
+// DeleteClient removes the client registration
+func (s *Store) DeleteClient(clientID string) error {
+	_, err := s.exec(context.Background(), nil, "DELETE FROM oauth_clients WHERE id = ?", clientID)
+	return err
+}
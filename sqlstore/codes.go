@@ -0,0 +1,91 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+var _ oauth.AuthCodeStore = (*Store)(nil)
+
+// SaveCode records the authorization code
+func (s *Store) SaveCode(c *oauth.AuthorizationCode) error {
+	claims, err := encodeClaimsRequest(c.Claims)
+	if err != nil {
+		return err
+	}
+	authTime := sql.NullTime{Time: c.AuthTime.UTC(), Valid: !c.AuthTime.IsZero()}
+	_, err = s.exec(context.Background(), nil, "INSERT INTO oauth_codes (id, client_id, redirect_uri, credential, scope, code_challenge, code_challenge_method, acr, amr, claims_request, nonce, auth_time, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		c.ID, c.ClientID, c.RedirectURI, c.Credential, c.Scope, c.CodeChallenge, string(c.CodeChallengeMethod), c.ACR, strings.Join(c.AMR, " "), claims, c.Nonce, authTime, c.CreationDate.UTC(), c.CreationDate.Add(c.ExpiresIn).UTC())
+	return err
+}
+
+// ConsumeCode returns and removes the authorization code, oauth.ErrCodeNotFound if unknown or already consumed.
+// Concurrent exchanges of the same code are resolved by the delete: only one of them removes the row.
+func (s *Store) ConsumeCode(id string) (*oauth.AuthorizationCode, error) {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	row, err := s.queryRow(ctx, tx, "SELECT id, client_id, redirect_uri, credential, scope, code_challenge, code_challenge_method, acr, amr, claims_request, nonce, auth_time, created_at, expires_at FROM oauth_codes WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	var c oauth.AuthorizationCode
+	var method, amr, claims string
+	var authTime sql.NullTime
+	var expiresAt time.Time
+	err = row.Scan(&c.ID, &c.ClientID, &c.RedirectURI, &c.Credential, &c.Scope, &c.CodeChallenge, &method, &c.ACR, &amr, &claims, &c.Nonce, &authTime, &c.CreationDate, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, oauth.ErrCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.CodeChallengeMethod = oauth.CodeChallengeMethod(method)
+	c.AMR = strings.Fields(amr)
+	if authTime.Valid {
+		c.AuthTime = authTime.Time
+	}
+	if c.Claims, err = decodeClaimsRequest(claims); err != nil {
+		return nil, err
+	}
+	c.ExpiresIn = expiresAt.Sub(c.CreationDate)
+
+	res, err := s.exec(ctx, tx, "DELETE FROM oauth_codes WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, oauth.ErrCodeNotFound
+	}
+	return &c, tx.Commit()
+}
+
+// encodeClaimsRequest returns the JSON of the claims request, empty when nil
+func encodeClaimsRequest(cr *oauth.ClaimsRequest) (string, error) {
+	if cr == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(cr)
+	return string(b), err
+}
+
+// decodeClaimsRequest parses the JSON claims request, nil when empty
+func decodeClaimsRequest(s string) (*oauth.ClaimsRequest, error) {
+	if s == "" {
+		return nil, nil
+	}
+	cr := new(oauth.ClaimsRequest)
+	if err := json.Unmarshal([]byte(s), cr); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
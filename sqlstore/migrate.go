@@ -0,0 +1,174 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migration is a schema version and its statements
+type migration struct {
+	Version    int
+	Statements []string
+}
+
+// migrations returns the migrations of the dialect sorted by version,
+// the files are named <version>_<description>.sql
+func (d Dialect) migrations() ([]migration, error) {
+	dir := path.Join("migrations", d.Name)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: no migrations for dialect %s", d.Name)
+	}
+	var ms []migration
+	for _, e := range entries {
+		prefix := strings.SplitN(e.Name(), "_", 2)[0]
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("sqlstore: invalid migration file name %s", e.Name())
+		}
+		b, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := migration{Version: version}
+		for _, stmt := range strings.Split(string(b), ";") {
+			if stmt = strings.TrimSpace(stmt); stmt != "" {
+				m.Statements = append(m.Statements, stmt)
+			}
+		}
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+// migrationLock is the name of the MySQL lock serializing the Migrate calls of the servers starting concurrently
+const migrationLock = "oauth_schema_migrations"
+
+// migrationLockTimeout is the number of seconds Migrate waits for the MySQL migration lock
+const migrationLockTimeout = 60
+
+// Migrate creates or upgrades the schema, the applied versions are recorded in the oauth_schema_migrations table.
+// On MySQL, whose DDL statements commit implicitly, the migrations run under the GET_LOCK migration lock and each
+// applied statement is recorded in the oauth_schema_migration_steps table so a failed migration resumes after its last
+// applied statement.
+func (s *Store) Migrate(ctx context.Context) error {
+	ms, err := s.dialect.migrations()
+	if err != nil {
+		return err
+	}
+	if s.dialect.Name == MySQL.Name {
+		return s.migrateMySQL(ctx, ms)
+	}
+	if _, err = s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS oauth_schema_migrations (version INTEGER PRIMARY KEY, applied_at "+s.timestampType()+" NOT NULL)"); err != nil {
+		return err
+	}
+	var current int
+	if err = s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM oauth_schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if m.Version <= current {
+			continue
+		}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range m.Statements {
+			if _, err = tx.ExecContext(ctx, stmt); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("sqlstore: migration %d: %w", m.Version, err)
+			}
+		}
+		if _, err = tx.ExecContext(ctx, s.dialect.rebind("INSERT INTO oauth_schema_migrations (version, applied_at) VALUES (?, ?)"), m.Version, time.Now().UTC()); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMySQL applies the migrations on a single connection holding the migration lock, the lock is released with
+// the connection
+func (s *Store) migrateMySQL(ctx context.Context, ms []migration) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var locked sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLock, migrationLockTimeout).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("sqlstore: migration lock %s not acquired", migrationLock)
+	}
+	defer func() {
+		var released sql.NullInt64
+		_ = conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLock).Scan(&released)
+	}()
+	if _, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS oauth_schema_migrations (version INTEGER PRIMARY KEY, applied_at "+s.timestampType()+" NOT NULL)"); err != nil {
+		return err
+	}
+	if _, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS oauth_schema_migration_steps (version INTEGER NOT NULL, statement INTEGER NOT NULL, PRIMARY KEY (version, statement))"); err != nil {
+		return err
+	}
+	var current int
+	if err = conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM oauth_schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if m.Version <= current {
+			continue
+		}
+		var applied int
+		if err = conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(statement), 0) FROM oauth_schema_migration_steps WHERE version = ?", m.Version).Scan(&applied); err != nil {
+			return err
+		}
+		for i := applied; i < len(m.Statements); i++ {
+			if _, err = conn.ExecContext(ctx, m.Statements[i]); err != nil {
+				return fmt.Errorf("sqlstore: migration %d statement %d: %w", m.Version, i+1, err)
+			}
+			if _, err = conn.ExecContext(ctx, "INSERT INTO oauth_schema_migration_steps (version, statement) VALUES (?, ?)", m.Version, i+1); err != nil {
+				return err
+			}
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, "INSERT INTO oauth_schema_migrations (version, applied_at) VALUES (?, ?)", m.Version, time.Now().UTC()); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM oauth_schema_migration_steps WHERE version = ?", m.Version); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) timestampType() string {
+	if s.dialect.Name == MySQL.Name {
+		return "DATETIME(6)"
+	}
+	return "TIMESTAMP WITH TIME ZONE"
+}
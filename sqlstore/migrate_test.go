@@ -0,0 +1,156 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is the state of the database of the fakeDriver, recording the executed statements
+type fakeDB struct {
+	mu       sync.Mutex
+	executed []string
+	locked   bool
+	version  int64
+	steps    map[int64]int64
+	failOn   string
+}
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{db: d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if s.query == db.failOn {
+		return nil, errors.New("statement failed")
+	}
+	db.executed = append(db.executed, s.query)
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO oauth_schema_migration_steps"):
+		db.steps[args[0].(int64)] = args[1].(int64)
+	case strings.HasPrefix(s.query, "DELETE FROM oauth_schema_migration_steps"):
+		delete(db.steps, args[0].(int64))
+	case strings.HasPrefix(s.query, "INSERT INTO oauth_schema_migrations"):
+		db.version = args[0].(int64)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var v int64
+	switch {
+	case strings.HasPrefix(s.query, "SELECT GET_LOCK"):
+		if !db.locked {
+			db.locked, v = true, 1
+		}
+	case strings.HasPrefix(s.query, "SELECT RELEASE_LOCK"):
+		db.locked, v = false, 1
+	case strings.Contains(s.query, "MAX(version)"):
+		v = db.version
+	case strings.Contains(s.query, "MAX(statement)"):
+		v = db.steps[args[0].(int64)]
+	}
+	return &fakeRows{v: v}, nil
+}
+
+type fakeRows struct {
+	v    int64
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"v"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.v
+	return nil
+}
+
+func TestMigrateMySQL(t *testing.T) {
+	ms, err := MySQL.migrations()
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	var failed migration
+	for _, m := range ms {
+		if len(m.Statements) > 1 {
+			failed = m
+			break
+		}
+	}
+	if failed.Version == 0 {
+		t.Fatalf("Error no migration with several statements")
+	}
+	fake := &fakeDB{steps: make(map[int64]int64), failOn: failed.Statements[1]}
+	sql.Register("sqlstore-fake", fakeDriver{db: fake})
+	db, err := sql.Open("sqlstore-fake", "")
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	defer db.Close()
+	store := New(db, MySQL)
+
+	if err = store.Migrate(context.Background()); err == nil {
+		t.Fatalf("Error failed statement accepted")
+	}
+	if fake.locked {
+		t.Fatalf("Error migration lock not released")
+	}
+	if fake.version != int64(failed.Version-1) || fake.steps[int64(failed.Version)] != 1 {
+		t.Fatalf("Error progress version = %d steps = %v", fake.version, fake.steps)
+	}
+
+	fake.failOn, fake.executed = "", nil
+	if err = store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	for _, stmt := range fake.executed {
+		if stmt == failed.Statements[0] {
+			t.Fatalf("Error applied statement replayed: %s", stmt)
+		}
+	}
+	if fake.version != int64(ms[len(ms)-1].Version) || len(fake.steps) != 0 {
+		t.Fatalf("Error progress version = %d steps = %v", fake.version, fake.steps)
+	}
+
+	fake.locked = true
+	if err = store.Migrate(context.Background()); err == nil || !strings.Contains(err.Error(), "lock") {
+		t.Fatalf("Error migration without the lock: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+CREATE TABLE oauth_tokens (
+    id          VARCHAR(64) PRIMARY KEY,
+    token_id    VARCHAR(64) NOT NULL,
+    parent_id   VARCHAR(64) NOT NULL DEFAULT '',
+    family_id   VARCHAR(64) NOT NULL,
+    token_type  VARCHAR(16) NOT NULL,
+    credential  VARCHAR(255) NOT NULL,
+    scope       TEXT NOT NULL,
+    created_at  DATETIME(6) NOT NULL,
+    expires_at  DATETIME(6) NOT NULL,
+    revoked     BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX oauth_tokens_parent_id (parent_id),
+    INDEX oauth_tokens_expires_at (expires_at)
+);
+
+CREATE TABLE oauth_clients (
+    id          VARCHAR(255) PRIMARY KEY,
+    grant_types TEXT NOT NULL,
+    public      BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE oauth_codes (
+    id                    VARCHAR(64) PRIMARY KEY,
+    client_id             VARCHAR(255) NOT NULL,
+    redirect_uri          TEXT NOT NULL,
+    credential            VARCHAR(255) NOT NULL,
+    scope                 TEXT NOT NULL,
+    code_challenge        VARCHAR(128) NOT NULL,
+    code_challenge_method VARCHAR(16) NOT NULL,
+    created_at            DATETIME(6) NOT NULL,
+    expires_at            DATETIME(6) NOT NULL,
+    INDEX oauth_codes_expires_at (expires_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE oauth_codes ADD COLUMN claims_request TEXT NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE oauth_clients ADD COLUMN redirect_uris TEXT NOT NULL;
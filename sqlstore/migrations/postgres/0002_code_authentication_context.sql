@@ -0,0 +1,2 @@
+ALTER TABLE oauth_codes ADD COLUMN acr VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE oauth_codes ADD COLUMN amr VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE oauth_codes ADD COLUMN nonce VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE oauth_codes ADD COLUMN auth_time TIMESTAMP WITH TIME ZONE NULL;
@@ -0,0 +1 @@
+CREATE INDEX oauth_tokens_credential ON oauth_tokens (credential);
@@ -0,0 +1 @@
+ALTER TABLE oauth_clients ADD COLUMN secret_hash VARCHAR(255) NOT NULL DEFAULT '';
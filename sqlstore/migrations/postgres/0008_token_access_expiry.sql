@@ -0,0 +1 @@
+ALTER TABLE oauth_tokens ADD COLUMN token_expires_at TIMESTAMP WITH TIME ZONE NULL;
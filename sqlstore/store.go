@@ -0,0 +1,146 @@
+// Package sqlstore implements the oauth TokenStore, ClientStore and AuthCodeStore over database/sql,
+// for PostgreSQL and MySQL. The schema is created by Migrate, MySQL connections need the parseTime=true DSN parameter.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect adapts the queries to the database.
+type Dialect struct {
+	Name string
+	// placeholder returns the nth (1-based) bind parameter
+	placeholder func(n int) string
+	// upsert returns the clause updating the columns when the primary key already exists
+	upsert func(key string, columns []string) string
+}
+
+var (
+	// Postgres is the dialect of PostgreSQL.
+	Postgres = Dialect{
+		Name:        "postgres",
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		upsert: func(key string, columns []string) string {
+			set := make([]string, len(columns))
+			for i, c := range columns {
+				set[i] = c + " = EXCLUDED." + c
+			}
+			return " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(set, ", ")
+		},
+	}
+	// MySQL is the dialect of MySQL and MariaDB.
+	MySQL = Dialect{
+		Name:        "mysql",
+		placeholder: func(int) string { return "?" },
+		upsert: func(key string, columns []string) string {
+			set := make([]string, len(columns))
+			for i, c := range columns {
+				set[i] = c + " = VALUES(" + c + ")"
+			}
+			return " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+		},
+	}
+)
+
+// rebind replaces the ? bind parameters of the query with the dialect placeholders
+func (d Dialect) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString(d.placeholder(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Store is the SQL store, safe for concurrent use. The prepared statements are reused across the calls.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// New creates a Store over the database.
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect, stmts: make(map[string]*sql.Stmt)}
+}
+
+// Close closes the prepared statements, the database is not closed
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first error
+	for query, stmt := range s.stmts {
+		if err := stmt.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(s.stmts, query)
+	}
+	return first
+}
+
+// stmt returns the prepared statement of the query written with ? bind parameters
+func (s *Store) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.db.PrepareContext(ctx, s.dialect.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+// exec executes the prepared query, within the transaction when tx is not nil
+func (s *Store) exec(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// query runs the prepared query, within the transaction when tx is not nil
+func (s *Store) query(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// queryRow runs the prepared query returning at most one row, within the transaction when tx is not nil
+func (s *Store) queryRow(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Row, error) {
+	stmt, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	return stmt.QueryRowContext(ctx, args...), nil
+}
+
+// Ping checks the connectivity of the database
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
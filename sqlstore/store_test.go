@@ -0,0 +1,49 @@
+package sqlstore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM oauth_tokens WHERE id = ? AND revoked = ?"
+	if got := Postgres.rebind(query); got != "SELECT id FROM oauth_tokens WHERE id = $1 AND revoked = $2" {
+		t.Fatalf("Error query = %s", got)
+	}
+	if got := MySQL.rebind(query); got != query {
+		t.Fatalf("Error query = %s", got)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	if got := Postgres.upsert("id", []string{"grant_types", "public"}); got != " ON CONFLICT (id) DO UPDATE SET grant_types = EXCLUDED.grant_types, public = EXCLUDED.public" {
+		t.Fatalf("Error upsert = %s", got)
+	}
+	if got := MySQL.upsert("id", []string{"grant_types", "public"}); got != " ON DUPLICATE KEY UPDATE grant_types = VALUES(grant_types), public = VALUES(public)" {
+		t.Fatalf("Error upsert = %s", got)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for _, d := range []Dialect{Postgres, MySQL} {
+		ms, err := d.migrations()
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		if len(ms) == 0 || ms[0].Version != 1 {
+			t.Fatalf("Error %s migrations = %v", d.Name, ms)
+		}
+		tables := 0
+		for _, stmt := range ms[0].Statements {
+			if strings.HasPrefix(stmt, "CREATE TABLE") {
+				tables++
+			}
+		}
+		if tables != 3 {
+			t.Fatalf("Error %s tables = %d", d.Name, tables)
+		}
+	}
+	if _, err := (Dialect{Name: "oracle"}).migrations(); err == nil {
+		t.Fatalf("Error unknown dialect accepted")
+	}
+}
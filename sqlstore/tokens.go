@@ -0,0 +1,179 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jeffreydwalter/oauth-1"
+)
+
+var (
+	_ oauth.TokenStore           = (*Store)(nil)
+	_ oauth.CredentialTokenStore = (*Store)(nil)
+)
+
+// noExpiry bounds the expiries purged by PurgeExpiredContext, the zero time of the records without expiry is before it
+var noExpiry = time.Unix(0, 0).UTC()
+
+var tokenColumns = []string{"token_id", "parent_id", "family_id", "token_type", "credential", "scope", "created_at", "expires_at", "token_expires_at", "revoked"}
+
+// SaveToken records the tokens, the access token expiry is NULL when it does not expire
+func (s *Store) SaveToken(rec *oauth.TokenRecord) error {
+	ctx := context.Background()
+	tokenExpiresAt := sql.NullTime{Time: rec.TokenExpiresAt.UTC(), Valid: !rec.TokenExpiresAt.IsZero()}
+	_, err := s.exec(ctx, nil, "INSERT INTO oauth_tokens (id, token_id, parent_id, family_id, token_type, credential, scope, created_at, expires_at, token_expires_at, revoked) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"+s.dialect.upsert("id", tokenColumns),
+		rec.ID, rec.TokenID, rec.ParentID, rec.FamilyID, string(rec.TokenType), rec.Credential, rec.Scope, rec.CreationDate.UTC(), rec.ExpiresAt.UTC(), tokenExpiresAt, rec.Revoked)
+	return err
+}
+
+// GetToken returns the record of the refresh token or oauth.ErrTokenNotFound
+func (s *Store) GetToken(refreshTokenID string) (*oauth.TokenRecord, error) {
+	row, err := s.queryRow(context.Background(), nil, "SELECT id, token_id, parent_id, family_id, token_type, credential, scope, created_at, expires_at, token_expires_at, revoked FROM oauth_tokens WHERE id = ?", refreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	var rec oauth.TokenRecord
+	var tokenType string
+	var tokenExpiresAt sql.NullTime
+	err = row.Scan(&rec.ID, &rec.TokenID, &rec.ParentID, &rec.FamilyID, &tokenType, &rec.Credential, &rec.Scope, &rec.CreationDate, &rec.ExpiresAt, &tokenExpiresAt, &rec.Revoked)
+	if err == sql.ErrNoRows {
+		return nil, oauth.ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec.TokenType = oauth.TokenType(tokenType)
+	if tokenExpiresAt.Valid {
+		rec.TokenExpiresAt = tokenExpiresAt.Time
+	}
+	return &rec, nil
+}
+
+// RotateToken revokes the rotated refresh token unless it is already revoked, in a single conditional update
+func (s *Store) RotateToken(refreshTokenID string) error {
+	ctx := context.Background()
+	res, err := s.exec(ctx, nil, "UPDATE oauth_tokens SET revoked = ? WHERE id = ? AND revoked = ?", true, refreshTokenID, false)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err = s.GetToken(refreshTokenID); err != nil {
+		return err
+	}
+	return oauth.ErrTokenRotated
+}
+
+// RevokeFamily revokes the refresh token and all the refresh tokens rotated from it in a transaction
+func (s *Store) RevokeFamily(refreshTokenID string) ([]string, error) {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	row, err := s.queryRow(ctx, tx, "SELECT id FROM oauth_tokens WHERE id = ?", refreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	if err = row.Scan(&id); err == sql.ErrNoRows {
+		return nil, oauth.ErrTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var revoked []string
+	pending := []string{refreshTokenID}
+	for len(pending) > 0 {
+		id, pending = pending[0], pending[1:]
+		res, err := s.exec(ctx, tx, "UPDATE oauth_tokens SET revoked = ? WHERE id = ? AND revoked = ?", true, id, false)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			revoked = append(revoked, id)
+		}
+		children, err := s.childTokens(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, children...)
+	}
+	return revoked, tx.Commit()
+}
+
+// RevokeCredential revokes all the refresh tokens issued to the credential in a transaction
+func (s *Store) RevokeCredential(credential string) ([]string, error) {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := s.query(ctx, tx, "SELECT id FROM oauth_tokens WHERE credential = ? AND revoked = ?", credential, false)
+	if err != nil {
+		return nil, err
+	}
+	var revoked []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		revoked = append(revoked, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err = s.exec(ctx, tx, "UPDATE oauth_tokens SET revoked = ? WHERE credential = ? AND revoked = ?", true, credential, false); err != nil {
+		return nil, err
+	}
+	return revoked, tx.Commit()
+}
+
+// childTokens returns the refresh tokens rotated from the refresh token
+func (s *Store) childTokens(ctx context.Context, tx *sql.Tx, parentID string) ([]string, error) {
+	rows, err := s.query(ctx, tx, "SELECT id FROM oauth_tokens WHERE parent_id = ?", parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeExpired removes the tokens and codes expired before now, returning how many were removed.
+// Errors are ignored, use PurgeExpiredContext to get them.
+func (s *Store) PurgeExpired(now time.Time) int {
+	n, _ := s.PurgeExpiredContext(context.Background(), now)
+	return n
+}
+
+// PurgeExpiredContext removes the tokens and codes expired before now, returning how many were removed. The records
+// without expiry (NULL or the zero time of the non-expiring refresh tokens) are kept.
+func (s *Store) PurgeExpiredContext(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+	for _, query := range []string{"DELETE FROM oauth_tokens WHERE expires_at > ? AND expires_at <= ?", "DELETE FROM oauth_codes WHERE expires_at > ? AND expires_at <= ?"} {
+		res, err := s.exec(ctx, nil, query, noExpiry, now.UTC())
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += int(n)
+	}
+	return total, nil
+}
@@ -0,0 +1,65 @@
+package oauth
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultStoreBackoff is the delay before the first retry of the StorePolicy when its Backoff is zero.
+const DefaultStoreBackoff = 100 * time.Millisecond
+
+// StorePolicy decides how the grants react to the failures of the verifier StoreTokenID,
+// the zero value fails the grant immediately (fail-closed).
+type StorePolicy struct {
+	// Retries is the number of retries of the failed calls, the backoff doubles after each retry
+	Retries int
+	// Backoff is the delay before the first retry, DefaultStoreBackoff when zero
+	Backoff time.Duration
+	// FailOpen issues the tokens when the call still fails after the retries, publishing a TokenStoreFailedEvent.
+	// It requires the server Events, without them the grant fails, and never applies to an OverloadError.
+	// The token id is then not stored: the refresh of these tokens fails when ValidateTokenID checks the stored ids.
+	FailOpen bool
+}
+
+// storeTokenID calls the verifier StoreTokenID applying the StoreTokenIDPolicy,
+// the returned error is nil when the failure is tolerated by FailOpen and published to the Events
+func (bs *BearerServer) storeTokenID(token *Token, refresh *RefreshToken, r *http.Request) error {
+	policy := bs.StoreTokenIDPolicy
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = DefaultStoreBackoff
+	}
+	err := bs.verifierFor(r).StoreTokenID(token.TokenType, token.Credential, token.ID, refresh.ID)
+	for i := 0; err != nil && i < policy.Retries; i++ {
+		if _, ok := overloaded(err); ok {
+			break
+		}
+		if r != nil {
+			select {
+			case <-r.Context().Done():
+				return err
+			case <-time.After(backoff):
+			}
+		} else {
+			time.Sleep(backoff)
+		}
+		backoff *= 2
+		err = bs.verifierFor(r).StoreTokenID(token.TokenType, token.Credential, token.ID, refresh.ID)
+	}
+	if err == nil || !policy.FailOpen || bs.Events == nil {
+		return err
+	}
+	if _, ok := overloaded(err); ok {
+		return err
+	}
+	bs.publish(&Event{
+		Type:           TokenStoreFailedEvent,
+		TokenID:        token.ID,
+		RefreshTokenID: refresh.ID,
+		TokenType:      token.TokenType,
+		Credential:     token.Credential,
+		Scope:          token.Scope,
+		Error:          err.Error(),
+	}, r)
+	return nil
+}
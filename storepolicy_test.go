@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type flakyVerifier struct {
+	TestUserVerifier
+	failures int
+	calls    int
+}
+
+func (v *flakyVerifier) StoreTokenID(tokenType TokenType, credential, tokenID, refreshTokenID string) error {
+	v.calls++
+	if v.calls <= v.failures {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func TestStoreTokenIDPolicy(t *testing.T) {
+	verifier := &flakyVerifier{failures: 2}
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, verifier, nil)
+	if _, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request)); status != http.StatusInternalServerError || verifier.calls != 1 {
+		t.Fatalf("Error fail-closed StatusCode = %d, calls = %d", status, verifier.calls)
+	}
+
+	verifier.calls = 0
+	sut.StoreTokenIDPolicy = StorePolicy{Retries: 2, Backoff: time.Millisecond}
+	if _, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request)); status != http.StatusOK || verifier.calls != 3 {
+		t.Fatalf("Error retry StatusCode = %d, calls = %d", status, verifier.calls)
+	}
+
+	verifier.calls, verifier.failures = 0, 10
+	sut.StoreTokenIDPolicy = StorePolicy{Retries: 1, Backoff: time.Millisecond, FailOpen: true}
+	if err := sut.Validate(); err == nil {
+		t.Fatalf("Error FailOpen without Events accepted")
+	}
+	if _, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request)); status != http.StatusInternalServerError {
+		t.Fatalf("Error fail-open without Events StatusCode = %d", status)
+	}
+
+	verifier.calls = 0
+	events := new(recordingPublisher)
+	sut.Events = events
+	if _, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request)); status != http.StatusOK || verifier.calls != 2 {
+		t.Fatalf("Error fail-open StatusCode = %d, calls = %d", status, verifier.calls)
+	}
+	if len(events.events) != 2 || events.events[0].Type != TokenStoreFailedEvent || events.events[0].Error != "connection reset" || events.events[1].Type != TokenIssuedEvent {
+		t.Fatalf("Error events = %+v", events.events)
+	}
+}
+
+type overloadedStoreVerifier struct {
+	TestUserVerifier
+}
+
+func (overloadedStoreVerifier) StoreTokenID(tokenType TokenType, credential, tokenID, refreshTokenID string) error {
+	return &OverloadError{RetryAfter: time.Second, Err: errors.New("pool exhausted")}
+}
+
+func TestStoreTokenIDPolicyOverload(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(overloadedStoreVerifier), nil)
+	sut.Events = new(recordingPublisher)
+	sut.StoreTokenIDPolicy = StorePolicy{Retries: 1, Backoff: time.Millisecond, FailOpen: true}
+	if _, status := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request)); status != http.StatusServiceUnavailable {
+		t.Fatalf("Error overload failed open, StatusCode = %d", status)
+	}
+}
@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// WebSocketProtocol is the subprotocol selected by the WebSocket upgrader when the browser sends the access token in
+	// the Sec-WebSocket-Protocol header: "bearer, bearer.{base64url(access_token)}".
+	WebSocketProtocol = "bearer"
+	// AccessTokenParam is the query parameter carrying the access token of the connections unable to set the
+	// Authorization header, such as EventSource (RFC 6750 §2.3)
+	AccessTokenParam = "access_token"
+)
+
+// StreamToken returns the access token of a WebSocket upgrade or a server-sent events request, read from the
+// Authorization header, the bearer subprotocol of the Sec-WebSocket-Protocol header or the access_token query parameter.
+func StreamToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return auth[7:]
+	}
+	for _, protocol := range strings.Split(strings.Join(r.Header.Values("Sec-WebSocket-Protocol"), ","), ",") {
+		protocol = strings.TrimSpace(protocol)
+		if strings.HasPrefix(protocol, WebSocketProtocol+".") {
+			if token, err := base64.RawURLEncoding.DecodeString(protocol[len(WebSocketProtocol)+1:]); err == nil {
+				return string(token)
+			}
+		}
+	}
+	return r.URL.Query().Get(AccessTokenParam)
+}
+
+// AuthenticateStream validates the access token of a WebSocket upgrade or a server-sent events request (see StreamToken)
+func (ba *BearerAuthentication) AuthenticateStream(r *http.Request) (*Token, error) {
+	raw := StreamToken(r)
+	if raw == "" {
+		return nil, errors.New("missing access token")
+	}
+	token, err := ba.ValidateTokenContext(r.Context(), raw)
+	if errors.Is(err, ErrMalformedToken) {
+		return nil, errors.New("invalid token")
+	}
+	return token, err
+}
+
+// AuthorizeStream is the Authorize middleware of the WebSocket and the server-sent events endpoints, reading the token
+// with StreamToken. The claims remain in the request context for the lifetime of the connection and, when
+// CloseExpiredStreams is set, the context is cancelled at the expiry or the revocation (Denylist) of the token so the
+// handler can close the connection, TokenLapse reports which.
+// The WebSocket upgrader must select the WebSocketProtocol when the token is sent in the Sec-WebSocket-Protocol header.
+func (ba *BearerAuthentication) AuthorizeStream(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ctx := ba.authorizeStream(w, r)
+		if token == nil {
+			return
+		}
+		if ba.CloseExpiredStreams {
+			var cancel context.CancelFunc
+			ctx, cancel = NewTokenWatcher(ba.Denylist).Watch(ctx, token)
+			defer cancel()
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authorizeStream returns the token of the stream request and the context carrying it, or renders the rejection and
+// returns a nil token
+func (ba *BearerAuthentication) authorizeStream(w http.ResponseWriter, r *http.Request) (token *Token, ctx context.Context) {
+	w = trackResponse(w)
+	defer ba.recoverPanic(w, r)
+	t, err := ba.AuthenticateStream(r)
+	if err != nil {
+		renderJSON(w, "Not authorized: "+err.Error(), true, http.StatusUnauthorized)
+		return nil, nil
+	}
+	if !ba.decide(w, r, t) {
+		return nil, nil
+	}
+	return t, ba.tokenContext(r.Context(), t, StreamToken(r))
+}
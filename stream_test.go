@@ -0,0 +1,46 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAuthorizeStream(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	raw := resp.(*TokenResponse).Token
+
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.CloseExpiredStreams = true
+	var deadline time.Time
+	handler := mut.AuthorizeStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(CredentialContext) != "user111" || r.Context().Value(AccessTokenContext) != raw {
+			t.Fatalf("Error context = %v", r.Context())
+		}
+		deadline, _ = r.Context().Deadline()
+	}))
+
+	websocket := httptest.NewRequest("GET", "/ws", nil)
+	websocket.Header.Set("Sec-WebSocket-Protocol", WebSocketProtocol+", "+WebSocketProtocol+"."+base64.RawURLEncoding.EncodeToString([]byte(raw)))
+	sse := httptest.NewRequest("GET", "/events?"+AccessTokenParam+"="+url.QueryEscape(raw), nil)
+	for _, req := range []*http.Request{websocket, sse} {
+		deadline = time.Time{}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || deadline.IsZero() || time.Until(deadline) > time.Duration(resp.(*TokenResponse).ExpiresIn)*time.Second {
+			t.Fatalf("Error StatusCode = %d, deadline = %v", w.Code, deadline)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+)
+
+// ID token claims binding the ID token to the access token and the authorization code, see OIDC Core §3.3.2.11
+const (
+	AccessTokenHashClaim = "at_hash"
+	CodeHashClaim        = "c_hash"
+)
+
+// TokenHash returns the left-most half of the hash of the token, base64url encoded, the hash being the one of the
+// JWS algorithm of the ID token (SHA-256 for RS256, ES256, PS256 and HS256, SHA-512 for EdDSA)
+func TokenHash(token, alg string) (string, error) {
+	h, err := jwsHash(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// SetTokenHashes sets the at_hash and c_hash claims of the access token and code, when not empty
+func SetTokenHashes(claims Claims, alg, accessToken, code string) error {
+	for name, value := range map[string]string{AccessTokenHashClaim: accessToken, CodeHashClaim: code} {
+		if value == "" {
+			continue
+		}
+		h, err := TokenHash(value, alg)
+		if err != nil {
+			return err
+		}
+		claims[name] = h
+	}
+	return nil
+}
+
+// jwsHash returns the hash function of the JWS algorithm
+func jwsHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case "RS256", "ES256", "PS256", "HS256":
+		return sha256.New(), nil
+	case "RS384", "ES384", "PS384", "HS384":
+		return sha512.New384(), nil
+	case "RS512", "ES512", "PS512", "HS512", EdDSA:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported JWS algorithm %q", alg)
+}
@@ -0,0 +1,27 @@
+package oauth
+
+import "testing"
+
+func TestTokenHash(t *testing.T) {
+	// OIDC Core Appendix A.3 and A.4
+	if h, err := TokenHash("jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y", "RS256"); err != nil || h != "77QmUPtjPfzWtF2AnpK9RQ" {
+		t.Fatalf("Error at_hash = %s, %v", h, err)
+	}
+	if h, err := TokenHash("Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk", "RS256"); err != nil || h != "LDktKdoQak3Pk0cnXxCltA" {
+		t.Fatalf("Error c_hash = %s, %v", h, err)
+	}
+	if h, _ := TokenHash("token", EdDSA); len(h) != 43 {
+		t.Fatalf("Error EdDSA hash = %s", h)
+	}
+	if _, err := TokenHash("token", "none"); err == nil {
+		t.Fatalf("Error unsupported algorithm accepted")
+	}
+
+	claims := Claims{}
+	if err := SetTokenHashes(claims, "RS256", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y", ""); err != nil {
+		t.Fatalf("Error %v", err)
+	}
+	if claims[AccessTokenHashClaim] != "77QmUPtjPfzWtF2AnpK9RQ" || claims[CodeHashClaim] != nil {
+		t.Fatalf("Error claims = %v", claims)
+	}
+}
@@ -0,0 +1,171 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// ReferenceTokenPrefix prefixes the reference tokens issued in place of the oversized access and refresh tokens
+const ReferenceTokenPrefix = "ref."
+
+// ErrReferenceNotFound is returned by the ReferenceTokenStore when the reference is unknown or expired.
+var ErrReferenceNotFound = errors.New("reference token not found")
+
+// TokenSizeReport describes an access or refresh token exceeding the MaxTokenSize
+type TokenSizeReport struct {
+	TokenID    string
+	Credential string
+	Size       int
+	Limit      int
+	// Claims is the JSON encoded size of each claim, to find the ones inflating the token
+	Claims map[string]int
+}
+
+// ReferenceTokenStore keeps the records of the oversized tokens, the clients receive a short reference resolved by
+// the BearerAuthentication middleware for the access tokens and by the refresh_token grant for the refresh tokens.
+type ReferenceTokenStore interface {
+	// SaveReference stores the JSON record of the token under the reference id until expiresAt, zero meaning no expiry
+	SaveReference(id, record string, expiresAt time.Time) error
+	// LoadReference returns the JSON record of the reference or ErrReferenceNotFound
+	LoadReference(id string) (string, error)
+}
+
+// WithMaxTokenSize sets the maximum size of the encoded tokens crypted and decrypted by the server, DefaultMaxTokenSize
+// by default and 0 disabling the limit. The larger tokens are reported to OnOversizedToken and replaced by a
+// reference when the server has ReferenceTokens, their issuance fails otherwise. The BearerAuthentication middlewares
+// accept the tokens up to DefaultMaxTokenSize.
+func WithMaxTokenSize(size int) ServerOption {
+	return func(bs *BearerServer) {
+		bs.provider.MaxTokenSize = size
+	}
+}
+
+// referenceRecord is the record of an oversized token kept by the ReferenceTokenStore, in place of the crypted token
+// the TokenProvider would not decrypt
+type referenceRecord struct {
+	Token   *Token        `json:"access_token,omitempty"`
+	Refresh *RefreshToken `json:"refresh_token,omitempty"`
+}
+
+// referenceToken reports the token whose encoding failed with the *TokenSizeError and replaces it by a reference to
+// its record when the server has ReferenceTokens, the error is returned otherwise
+func (bs *BearerServer) referenceToken(sizeErr error, id, credential string, claims Claims, record *referenceRecord, expiresAt time.Time) (string, error) {
+	if bs.OnOversizedToken != nil {
+		report := TokenSizeReport{TokenID: id, Credential: credential, Claims: make(map[string]int, len(claims))}
+		var e *TokenSizeError
+		if errors.As(sizeErr, &e) {
+			report.Size, report.Limit = e.Size, e.Limit
+		}
+		for name, value := range claims {
+			b, _ := json.Marshal(value)
+			report.Claims[name] = len(b)
+		}
+		bs.OnOversizedToken(report)
+	}
+	if bs.ReferenceTokens == nil {
+		return "", sizeErr
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	ref := uuid.Must(uuid.NewV4()).String()
+	if err = bs.ReferenceTokens.SaveReference(ref, string(b), expiresAt); err != nil {
+		return "", err
+	}
+	return ReferenceTokenPrefix + ref, nil
+}
+
+// loadReference returns the record of the reference token, errors wrap ErrMalformedToken or are the store errors
+func loadReference(store ReferenceTokenStore, raw string) (*referenceRecord, error) {
+	b, err := store.LoadReference(raw[len(ReferenceTokenPrefix):])
+	if err == ErrReferenceNotFound {
+		return nil, ErrMalformedToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := new(referenceRecord)
+	if err = json.Unmarshal([]byte(b), record); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return record, nil
+}
+
+// decryptRefreshToken decrypts the refresh token, or loads the record of the reference issued in its place
+func (bs *BearerServer) decryptRefreshToken(raw string) (*RefreshToken, error) {
+	if bs.ReferenceTokens == nil || !strings.HasPrefix(raw, ReferenceTokenPrefix) {
+		return bs.provider.DecryptRefreshTokens(raw)
+	}
+	record, err := loadReference(bs.ReferenceTokens, raw)
+	if err != nil {
+		return nil, err
+	}
+	if record.Refresh == nil {
+		return nil, ErrMalformedToken
+	}
+	return record.Refresh, nil
+}
+
+// expiresAt returns the expiry of the token lifetime, zero when the token does not expire
+func expiresAt(creationDate time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return creationDate.Add(ttl)
+}
+
+// MemoryReferenceTokenStore is an in-memory ReferenceTokenStore safe for concurrent use.
+type MemoryReferenceTokenStore struct {
+	mu         sync.RWMutex
+	references map[string]referenceToken
+}
+
+type referenceToken struct {
+	record    string
+	expiresAt time.Time
+}
+
+// NewMemoryReferenceTokenStore creates an empty MemoryReferenceTokenStore.
+func NewMemoryReferenceTokenStore() *MemoryReferenceTokenStore {
+	return &MemoryReferenceTokenStore{references: make(map[string]referenceToken)}
+}
+
+// SaveReference stores the record under the reference id
+func (s *MemoryReferenceTokenStore) SaveReference(id, record string, expiresAt time.Time) error {
+	s.mu.Lock()
+	s.references[id] = referenceToken{record: record, expiresAt: expiresAt}
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadReference returns the record of the reference
+func (s *MemoryReferenceTokenStore) LoadReference(id string) (string, error) {
+	s.mu.RLock()
+	ref, ok := s.references[id]
+	s.mu.RUnlock()
+	if !ok || (!ref.expiresAt.IsZero() && !time.Now().Before(ref.expiresAt)) {
+		return "", ErrReferenceNotFound
+	}
+	return ref.record, nil
+}
+
+// PurgeExpired removes the expired references and returns the number of references removed
+func (s *MemoryReferenceTokenStore) PurgeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, ref := range s.references {
+		if !ref.expiresAt.IsZero() && !now.Before(ref.expiresAt) {
+			delete(s.references, id)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOversizedTokenReference(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil, WithMaxTokenSize(400))
+	var reports []TokenSizeReport
+	sut.OnOversizedToken = func(r TokenSizeReport) { reports = append(reports, r) }
+	claims := Claims{"groups": strings.Repeat("g", 300)}
+
+	if _, err := sut.IssueToken(context.Background(), UserToken, "user111", "", claims); err == nil || !strings.Contains(err.Error(), ErrTokenTooLarge.Error()) {
+		t.Fatalf("Error the oversized token should not be issued: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Size <= 400 || reports[0].Limit != 400 || reports[0].Claims["groups"] != 302 {
+		t.Fatalf("Error reports = %+v", reports)
+	}
+
+	store := NewMemoryReferenceTokenStore()
+	sut.ReferenceTokens = store
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "", claims)
+	if err != nil || !strings.HasPrefix(resp.Token, ReferenceTokenPrefix) || !strings.HasPrefix(resp.RefreshToken, ReferenceTokenPrefix) {
+		t.Fatalf("Error response = %+v, %v", resp, err)
+	}
+
+	ba := NewBearerAuthentication("mySecretKey-10101", nil)
+	if _, err = ba.ValidateToken(resp.Token); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error %v", err)
+	}
+	ba.ReferenceTokens = store
+	token, err := ba.ValidateToken(resp.Token)
+	if err != nil || token.Credential != "user111" || token.Claims["groups"] != claims["groups"] {
+		t.Fatalf("Error token = %+v, %v", token, err)
+	}
+	if _, err = ba.ValidateToken(resp.RefreshToken); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("Error the refresh token reference should not be accepted as an access token: %v", err)
+	}
+	if _, err = ba.ValidateToken(ReferenceTokenPrefix + "unknown"); err != ErrMalformedToken {
+		t.Fatalf("Error %v", err)
+	}
+	if n := store.PurgeExpired(time.Now().Add(30 * time.Second)); n != 1 {
+		t.Fatalf("Error purged = %d", n)
+	}
+}
+
+func TestOversizedTokenRefresh(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	store := NewMemoryReferenceTokenStore()
+	sut.ReferenceTokens = store
+	claims := Claims{"groups": strings.Repeat("g", DefaultMaxTokenSize)}
+
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "read", claims)
+	if err != nil || !strings.HasPrefix(resp.Token, ReferenceTokenPrefix) || !strings.HasPrefix(resp.RefreshToken, ReferenceTokenPrefix) {
+		t.Fatalf("Error response = %+v, %v", resp, err)
+	}
+	ba := NewBearerAuthentication("mySecretKey-10101", nil)
+	ba.ReferenceTokens = store
+	if token, err := ba.ValidateToken(resp.Token); err != nil || token.Claims["groups"] != claims["groups"] {
+		t.Fatalf("Error token = %+v, %v", token, err)
+	}
+
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {resp.RefreshToken}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	sut.Token(w, req)
+	var refreshed TokenResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &refreshed) != nil {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if token, err := ba.ValidateToken(refreshed.Token); err != nil || token.Credential != "user111" || token.Scope != "read" {
+		t.Fatalf("Error token = %+v, %v", token, err)
+	}
+}
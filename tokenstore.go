@@ -0,0 +1,181 @@
+package oauth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTokenNotFound is returned by the TokenStore when the refresh token is not recorded.
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenRotated is returned by RotateToken when the refresh token is already rotated or revoked.
+	ErrTokenRotated = errors.New("token already rotated")
+)
+
+// TokenRecord is the persisted state of a refresh token and of the access token issued with it.
+type TokenRecord struct {
+	ID      string `json:"refresh_token_id"`
+	TokenID string `json:"token_id"`
+	// ParentID is the refresh token rotated into this one, empty for the tokens of the original grant
+	ParentID string `json:"parent_id,omitempty"`
+	// FamilyID is the refresh token of the original grant, shared by all its rotations
+	FamilyID     string    `json:"family_id"`
+	TokenType    TokenType `json:"type"`
+	Credential   string    `json:"credential"`
+	Scope        string    `json:"scope"`
+	CreationDate time.Time `json:"date"`
+	// ExpiresAt is the expiry of the refresh token, zero when it does not expire
+	ExpiresAt time.Time `json:"expires_at"`
+	// TokenExpiresAt is the expiry of the access token, zero when it does not expire
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+	Revoked        bool      `json:"revoked"`
+}
+
+// IsExpired returns true if the refresh token is expired
+func (rec *TokenRecord) IsExpired(now time.Time) bool {
+	return !rec.ExpiresAt.IsZero() && !now.Before(rec.ExpiresAt)
+}
+
+// TokenStore persists the issued tokens and their rotation lineage.
+type TokenStore interface {
+	// SaveToken records the tokens, called after the verifier StoreTokenID, or replaces the record of the refresh token,
+	// e.g. revoked by its rotation
+	SaveToken(rec *TokenRecord) error
+	// GetToken returns the record of the refresh token or ErrTokenNotFound
+	GetToken(refreshTokenID string) (*TokenRecord, error)
+	// RotateToken atomically revokes the refresh token rotated into a new one unless it is already revoked, returning
+	// ErrTokenRotated then so that only one of the concurrent rotations succeeds, or ErrTokenNotFound
+	RotateToken(refreshTokenID string) error
+	// RevokeFamily revokes the refresh token and all the refresh tokens rotated from it,
+	// returning the revoked refresh token ids
+	RevokeFamily(refreshTokenID string) ([]string, error)
+}
+
+// CredentialTokenStore is implemented by the TokenStores able to revoke all the tokens of a credential.
+type CredentialTokenStore interface {
+	// RevokeCredential revokes all the refresh tokens issued to the credential, returning the revoked refresh token ids
+	RevokeCredential(credential string) ([]string, error)
+}
+
+// MemoryTokenStore is an in-memory TokenStore safe for concurrent use.
+type MemoryTokenStore struct {
+	mu       sync.RWMutex
+	records  map[string]*TokenRecord
+	children map[string][]string
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{records: make(map[string]*TokenRecord), children: make(map[string][]string)}
+}
+
+// SaveToken records the tokens
+func (s *MemoryTokenStore) SaveToken(rec *TokenRecord) error {
+	c := *rec
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[c.ID]; !ok && c.ParentID != "" {
+		s.children[c.ParentID] = append(s.children[c.ParentID], c.ID)
+	}
+	s.records[c.ID] = &c
+	return nil
+}
+
+// GetToken returns the record of the refresh token or ErrTokenNotFound
+func (s *MemoryTokenStore) GetToken(refreshTokenID string) (*TokenRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[refreshTokenID]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	c := *rec
+	return &c, nil
+}
+
+// RotateToken revokes the rotated refresh token unless it is already revoked
+func (s *MemoryTokenStore) RotateToken(refreshTokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[refreshTokenID]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	if rec.Revoked {
+		return ErrTokenRotated
+	}
+	rec.Revoked = true
+	return nil
+}
+
+// RevokeFamily revokes the refresh token and all the refresh tokens rotated from it
+func (s *MemoryTokenStore) RevokeFamily(refreshTokenID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[refreshTokenID]; !ok {
+		return nil, ErrTokenNotFound
+	}
+	var revoked []string
+	pending := []string{refreshTokenID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if rec, ok := s.records[id]; ok && !rec.Revoked {
+			rec.Revoked = true
+			revoked = append(revoked, id)
+		}
+		pending = append(pending, s.children[id]...)
+	}
+	return revoked, nil
+}
+
+// RevokeCredential revokes all the refresh tokens issued to the credential
+func (s *MemoryTokenStore) RevokeCredential(credential string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var revoked []string
+	for id, rec := range s.records {
+		if rec.Credential == credential && !rec.Revoked {
+			rec.Revoked = true
+			revoked = append(revoked, id)
+		}
+	}
+	return revoked, nil
+}
+
+// PurgeExpired removes the records expired before now, returning how many were removed
+func (s *MemoryTokenStore) PurgeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, rec := range s.records {
+		if rec.IsExpired(now) {
+			delete(s.records, id)
+			delete(s.children, id)
+			n++
+		}
+	}
+	return n
+}
+
+// tokenRecord returns the TokenRecord of the tokens, the records of the non-expiring refresh tokens have no ExpiresAt.
+// The access token expiry is recorded as issued, the TTL of the server may change before a revocation.
+func tokenRecord(token *Token, refresh *RefreshToken) *TokenRecord {
+	familyID := refresh.FamilyID
+	if familyID == "" {
+		familyID = refresh.ID
+	}
+	return &TokenRecord{
+		ID:             refresh.ID,
+		TokenID:        token.ID,
+		ParentID:       refresh.ParentID,
+		FamilyID:       familyID,
+		TokenType:      refresh.TokenType,
+		Credential:     refresh.Credential,
+		Scope:          refresh.Scope,
+		CreationDate:   refresh.CreationDate,
+		ExpiresAt:      expiresAt(refresh.CreationDate, refresh.ExpiresIn),
+		TokenExpiresAt: expiresAt(token.CreationDate, token.ExpiresIn),
+	}
+}
@@ -0,0 +1,161 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRevokeFamily(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	store := NewMemoryTokenStore()
+	sut.TokenStore = store
+
+	resp, code := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	first := resp.(*TokenResponse).RefreshToken
+	resp, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", first, "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	second := resp.(*TokenResponse).RefreshToken
+
+	firstRefresh, _ := sut.provider.DecryptRefreshTokens(first)
+	secondRefresh, _ := sut.provider.DecryptRefreshTokens(second)
+	rec, err := store.GetToken(secondRefresh.ID)
+	if err != nil || rec.ParentID != firstRefresh.ID || rec.FamilyID != firstRefresh.ID {
+		t.Fatalf("Error lineage = %+v, %v", rec, err)
+	}
+	if rec, err = store.GetToken(firstRefresh.ID); err != nil || !rec.Revoked {
+		t.Fatalf("Error rotated refresh token not revoked = %+v, %v", rec, err)
+	}
+
+	// the rotated refresh token was revoked by the rotation
+	revoked, err := store.RevokeFamily(firstRefresh.ID)
+	if err != nil || len(revoked) != 1 || revoked[0] != secondRefresh.ID {
+		t.Fatalf("Error revoked = %v, %v", revoked, err)
+	}
+	if _, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", second, "", "", "", new(http.Request)); code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	if _, err = store.RevokeFamily("unknown"); err != ErrTokenNotFound {
+		t.Fatalf("Error %v", err)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	store := NewMemoryTokenStore()
+	sut.TokenStore = store
+
+	resp, _ := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	first := resp.(*TokenResponse).RefreshToken
+	resp, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", first, "", "", "", new(http.Request))
+	if code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	second := resp.(*TokenResponse).RefreshToken
+
+	// the replay of the rotated refresh token revokes the whole family
+	if _, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", first, "", "", "", new(http.Request)); code != http.StatusBadRequest {
+		t.Fatalf("Error replayed refresh token StatusCode = %d", code)
+	}
+	secondRefresh, _ := sut.provider.DecryptRefreshTokens(second)
+	if rec, err := store.GetToken(secondRefresh.ID); err != nil || !rec.Revoked {
+		t.Fatalf("Error family not revoked = %+v, %v", rec, err)
+	}
+	if _, code = sut.generateTokenResponse(RefreshTokenGrant, "", "", second, "", "", "", new(http.Request)); code != http.StatusBadRequest {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+}
+
+// failingTokenStore fails the SaveToken calls while fail is set
+type failingTokenStore struct {
+	*MemoryTokenStore
+	fail bool
+}
+
+func (s *failingTokenStore) SaveToken(rec *TokenRecord) error {
+	if s.fail {
+		return errors.New("store down")
+	}
+	return s.MemoryTokenStore.SaveToken(rec)
+}
+
+func TestRefreshTokenRotationRetry(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	store := &failingTokenStore{MemoryTokenStore: NewMemoryTokenStore()}
+	sut.TokenStore = store
+
+	resp, _ := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	first := resp.(*TokenResponse).RefreshToken
+	store.fail = true
+	if _, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", first, "", "", "", new(http.Request)); code != http.StatusInternalServerError {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	store.fail = false
+	if _, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", first, "", "", "", new(http.Request)); code != http.StatusOK {
+		t.Fatalf("Error the retry of the failed rotation should succeed: StatusCode = %d", code)
+	}
+}
+
+func TestRefreshTokenConcurrentRotation(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.TokenStore = NewMemoryTokenStore()
+
+	resp, _ := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	first := resp.(*TokenResponse).RefreshToken
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", first, "", "", "", new(http.Request)); code == http.StatusOK {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("Error %d concurrent rotations of the refresh token succeeded", succeeded)
+	}
+}
+
+func TestNonExpiringTokenRecord(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, 0, new(TestUserVerifier), nil)
+	store := NewMemoryTokenStore()
+	sut.TokenStore = store
+
+	resp, _ := sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	refresh, _ := sut.provider.DecryptRefreshTokens(resp.(*TokenResponse).RefreshToken)
+	if rec, err := store.GetToken(refresh.ID); err != nil || !rec.ExpiresAt.IsZero() {
+		t.Fatalf("Error record = %+v, %v", rec, err)
+	}
+	if n := store.PurgeExpired(time.Now().Add(time.Hour)); n != 0 {
+		t.Fatalf("Error the non-expiring refresh token was purged")
+	}
+	if _, code := sut.generateTokenResponse(RefreshTokenGrant, "", "", resp.(*TokenResponse).RefreshToken, "", "", "", new(http.Request)); code != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+}
+
+func TestMemoryTokenStorePurgeExpired(t *testing.T) {
+	store := NewMemoryTokenStore()
+	now := time.Now()
+	_ = store.SaveToken(&TokenRecord{ID: "old", ExpiresAt: now.Add(-time.Minute)})
+	_ = store.SaveToken(&TokenRecord{ID: "new", ExpiresAt: now.Add(time.Minute)})
+	if n := store.PurgeExpired(now); n != 1 {
+		t.Fatalf("Error purged = %d", n)
+	}
+	if _, err := store.GetToken("old"); err != ErrTokenNotFound {
+		t.Fatalf("Error %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FederatedIdentity is the internal identity of an external token, minted by TranslateToken
+type FederatedIdentity struct {
+	// TokenType is UserToken when empty
+	TokenType  TokenType
+	Credential string
+	Scope      string
+	// Claims are merged over the claims of the verifier AddClaims
+	Claims Claims
+}
+
+// FederationMapper maps the identities of the external tokens to the internal ones
+type FederationMapper interface {
+	// MapIdentity returns the internal identity of the validated external token, whose credential is the
+	// ExternalCredential of its "iss" and "sub" claims, or an error to deny the translation. A nil identity denies it too
+	MapIdentity(external *Token, r *http.Request) (*FederatedIdentity, error)
+}
+
+// ErrTranslationReplayCacheRequired is rendered by TranslateToken without TranslationReplayCache: the external tokens
+// could be translated repeatedly until they expire.
+var ErrTranslationReplayCacheRequired = errors.New("token translation requires a TranslationReplayCache")
+
+// TranslateToken is the gateway endpoint exchanging the external JWT of a TrustedIssuer, sent as bearer token,
+// for the tokens of this server with the identity mapped by the FederationMapper ("exchange at the edge").
+// The external tokens must have the Audience of their TrustedIssuer and a "jti" claim, consumed in the
+// TranslationReplayCache once the identity is mapped so each one is translated once. It is not mounted by RegisterHandlers.
+func (bs *BearerServer) TranslateToken(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if !bs.checkHTTPS(w, r) || !bs.checkWrapTTL(w, r) {
+		return
+	}
+	if bs.FederationMapper == nil || len(bs.TrustedIssuers) == 0 {
+		bs.renderError(w, r, TokenInvalidRequest, "token translation is not enabled", "", http.StatusNotFound)
+		return
+	}
+	if bs.TranslationReplayCache == nil {
+		bs.renderError(w, r, TokenServerError, ErrTranslationReplayCacheRequired.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") || strings.Count(auth[7:], ".") != 2 {
+		bs.renderError(w, r, TokenInvalidRequest, "missing external bearer token", "", http.StatusBadRequest)
+		return
+	}
+	ba := &BearerAuthentication{TrustedIssuers: bs.TrustedIssuers}
+	external, err := ba.validateJWT(r.Context(), auth[7:])
+	if err == nil && bs.Denylist != nil && bs.Denylist.Contains(external.ID) {
+		err = ErrRevokedToken
+	}
+	if err == nil && external.ID == "" {
+		err = errors.New("missing jti claim")
+	}
+	if err != nil {
+		bs.renderError(w, r, TokenInvalidGrant, "invalid external token: "+err.Error(), "", http.StatusBadRequest)
+		return
+	}
+	identity, err := bs.FederationMapper.MapIdentity(external, r)
+	if err != nil {
+		if resp, ok := overloaded(err); ok {
+			bs.renderResponse(w, r, resp, false, http.StatusServiceUnavailable)
+			return
+		}
+		bs.renderError(w, r, AuthorizationCodeGrantAccessDenied, err.Error(), "", http.StatusForbidden)
+		return
+	}
+	if identity == nil {
+		bs.renderError(w, r, AuthorizationCodeGrantAccessDenied, "no identity mapped for the external token", "", http.StatusForbidden)
+		return
+	}
+	// the external token is consumed once mapped, so a temporary failure of the mapping can be retried with it
+	iss, _ := external.Claims.GetString("iss")
+	if err = bs.TranslationReplayCache.Consume(ExternalCredential(iss, external.ID), time.Until(external.CreationDate.Add(external.ExpiresIn))); err != nil {
+		if resp, ok := overloaded(err); ok {
+			bs.renderResponse(w, r, resp, false, http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, ErrReplayed) {
+			bs.renderError(w, r, TokenInvalidGrant, "external token already translated", "", http.StatusBadRequest)
+			return
+		}
+		bs.renderError(w, r, TokenServerError, "token translation failed", "", http.StatusInternalServerError)
+		return
+	}
+	if identity.TokenType == "" {
+		identity.TokenType = UserToken
+	}
+	resp, err := bs.mintTokens(r, identity.TokenType, identity.Credential, identity.Scope, func(c Claims) error {
+		return c.Merge(identity.Claims)
+	})
+	var ie *IssueError
+	if errors.As(err, &ie) {
+		bs.renderResponse(w, r, ie.Response, false, ie.StatusCode)
+		return
+	}
+	if err != nil {
+		if resp, ok := overloaded(err); ok {
+			bs.renderResponse(w, r, resp, false, http.StatusServiceUnavailable)
+			return
+		}
+		bs.renderError(w, r, TokenServerError, err.Error(), "", http.StatusInternalServerError)
+		return
+	}
+	bs.renderResponse(w, r, resp, true, http.StatusOK)
+}
@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testFederationMapper struct{}
+
+func (testFederationMapper) MapIdentity(external *Token, r *http.Request) (*FederatedIdentity, error) {
+	if external.Credential == ExternalCredential("https://idp", "busy@idp") {
+		return nil, &OverloadError{RetryAfter: time.Second, Err: errors.New("directory pool exhausted")}
+	}
+	if external.Credential == ExternalCredential("https://idp", "nobody@idp") {
+		return nil, nil
+	}
+	if external.Credential != ExternalCredential("https://idp", "alice@idp") {
+		return nil, errors.New("unknown federated user")
+	}
+	return &FederatedIdentity{Credential: "user111", Scope: "read", Claims: Claims{"federated": external.Credential}}, nil
+}
+
+func TestTranslateToken(t *testing.T) {
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.TrustedIssuers = []*TrustedIssuer{{Issuer: "https://idp", Keys: StaticKeys{"k": edPub}, Audience: "gateway"}}
+	sut.FederationMapper = testFederationMapper{}
+	sut.TranslationReplayCache = NewMemoryReplayCache()
+
+	sign := func(sub, jti string) string {
+		return signJWT(t, EdDSA, "k", edKey, Claims{"iss": "https://idp", "sub": sub, "aud": "gateway", "jti": jti, "exp": time.Now().Add(time.Minute).Unix()})
+	}
+	send := func(raw string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/translate", nil)
+		req.Header.Set("Authorization", "Bearer "+raw)
+		w := httptest.NewRecorder()
+		sut.TranslateToken(w, req)
+		return w
+	}
+	translate := func(sub string) *httptest.ResponseRecorder {
+		return send(sign(sub, sub+"-jti"))
+	}
+	alice := sign("alice@idp", "alice-1")
+	w := send(alice)
+	var resp TokenResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	token, err := NewBearerAuthentication("mySecretKey-10101", nil).ValidateToken(resp.Token)
+	if err != nil || token.Credential != "user111" || token.Claims["federated"] != "https://idp#alice@idp" {
+		t.Fatalf("Error token = %+v, %v", token, err)
+	}
+	if w = translate("mallory@idp"); w.Code != http.StatusForbidden {
+		t.Fatalf("Error StatusCode = %d", w.Code)
+	}
+	if w = send(alice); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error the replayed external token should be rejected: StatusCode = %d", w.Code)
+	}
+	if w = send(sign("alice@idp", "")); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error the external token without jti should be rejected: StatusCode = %d", w.Code)
+	}
+	if w = translate("nobody@idp"); w.Code != http.StatusForbidden {
+		t.Fatalf("Error the external token without identity should be denied: StatusCode = %d", w.Code)
+	}
+	// the temporary failures of the mapping do not consume the external token
+	for i := 0; i < 2; i++ {
+		if w = translate("busy@idp"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Fatalf("Error StatusCode = %d, headers = %v", w.Code, w.Header())
+		}
+	}
+	wrongAudience := signJWT(t, EdDSA, "k", edKey, Claims{"iss": "https://idp", "sub": "alice@idp", "aud": "billing", "jti": "alice-2", "exp": time.Now().Add(time.Minute).Unix()})
+	if w = send(wrongAudience); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error the external token of another audience should be rejected: StatusCode = %d", w.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/translate", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	sut.TranslateToken(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Error the internal token should be rejected: StatusCode = %d", w.Code)
+	}
+}
@@ -0,0 +1,27 @@
+package oauth
+
+import (
+	"strings"
+	"time"
+)
+
+// ScopeTTLPolicy maps the sensitive scopes to the maximum lifetime of the access tokens carrying them.
+type ScopeTTLPolicy map[string]time.Duration
+
+// MaxTTL returns the shortest lifetime of the scopes of the space-delimited scope, 0 when none is capped
+func (p ScopeTTLPolicy) MaxTTL(scope string) time.Duration {
+	var max time.Duration
+	for _, s := range strings.Fields(scope) {
+		if ttl, ok := p[s]; ok && ttl > 0 && (max == 0 || ttl < max) {
+			max = ttl
+		}
+	}
+	return max
+}
+
+// applyScopeTTL caps the access token lifetime to the ScopeTTLPolicy, after the verifier and risk decisions
+func (bs *BearerServer) applyScopeTTL(token *Token) {
+	if max := bs.ScopeTTLPolicy.MaxTTL(token.Scope); max > 0 && (token.ExpiresIn <= 0 || token.ExpiresIn > max) {
+		token.ExpiresIn = max
+	}
+}
@@ -0,0 +1,27 @@
+package oauth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScopeTTLPolicy(t *testing.T) {
+	policy := ScopeTTLPolicy{"payments:write": time.Minute, "admin": time.Second * 30, "read": 0}
+	if ttl := policy.MaxTTL("read payments:write admin"); ttl != time.Second*30 {
+		t.Fatalf("Error ttl = %s", ttl)
+	}
+	if ttl := policy.MaxTTL("read"); ttl != 0 {
+		t.Fatalf("Error ttl = %s", ttl)
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Hour, time.Hour*2, new(TestUserVerifier), nil)
+	sut.ScopeTTLPolicy = policy
+	resp, err := sut.IssueToken(context.Background(), UserToken, "user111", "read payments:write", nil)
+	if err != nil || resp.ExpiresIn != 60 || resp.RefreshTokenExpiresIn != 7200 {
+		t.Fatalf("Error response = %+v, %v", resp, err)
+	}
+	if resp, err = sut.IssueToken(context.Background(), UserToken, "user111", "read", nil); err != nil || resp.ExpiresIn != 3600 {
+		t.Fatalf("Error response = %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,276 @@
+package oauth
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UsageKind selects the client or the user counters of the UsageTracker.
+type UsageKind string
+
+const (
+	ClientUsage UsageKind = "clients"
+	UserUsage   UsageKind = "users"
+)
+
+// ErrUsageTrackerRequired is returned by DormantClients when the server has no UsageTracker.
+var ErrUsageTrackerRequired = errors.New("usage analytics require a UsageTracker")
+
+// Usage are the token counters of a client or a user.
+type Usage struct {
+	Issued     int64     `json:"issued"`
+	Refreshed  int64     `json:"refreshed"`
+	LastIssued time.Time `json:"last_issued,omitempty"`
+	// LastUsed is the last access token validation by a BearerAuthentication sharing the UsageTracker
+	LastUsed time.Time `json:"last_used,omitempty"`
+}
+
+// UsageTracker records the tokens issued to each client and user and their last use.
+type UsageTracker interface {
+	// TrackIssued records tokens issued by the client to the credential, refreshed for the refresh_token grant.
+	// clientID is empty when the client is not authenticated
+	TrackIssued(clientID, credential string, tokenType TokenType, refreshed bool, at time.Time) error
+	// TrackUsed records the use of an access token issued to the credential
+	TrackUsed(credential string, tokenType TokenType, at time.Time) error
+	// Usage returns the counters of the client or the user, the zero Usage when it has no tokens
+	Usage(kind UsageKind, id string) (Usage, error)
+	// ListUsage returns the page of the counters of the clients or the users sorted by id following the after id,
+	// limit is DefaultUsagePageSize when not positive
+	ListUsage(kind UsageKind, after string, limit int) (*UsagePage, error)
+}
+
+// DefaultUsagePageSize is the number of counters listed by ListUsage when the limit is not positive.
+const DefaultUsagePageSize = 100
+
+// UsagePage is a page of the counters listed by ListUsage.
+type UsagePage struct {
+	Usage map[string]Usage `json:"usage"`
+	// Next is the after id of the next page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// trackUsage records the issued tokens in the UsageTracker under the client the refresh token is issued to: the client
+// authenticated by the grant, the credential of the client credentials tokens or the client of the rotated refresh token.
+// The client_id parameter of the unauthenticated requests is not counted.
+func (bs *BearerServer) trackUsage(gc *GrantContext, refresh *RefreshToken, refreshed bool) {
+	if bs.UsageTracker == nil {
+		return
+	}
+	_ = bs.UsageTracker.TrackIssued(refreshTokenClient(refresh), gc.Credential, refresh.TokenType, refreshed, time.Now().UTC())
+}
+
+// DormantClients returns the ids of the registered clients without tokens issued since the date, sorted.
+// The ClientStore must implement ClientAdminStore.
+func (bs *BearerServer) DormantClients(since time.Time) ([]string, error) {
+	if bs.UsageTracker == nil {
+		return nil, ErrUsageTrackerRequired
+	}
+	store, ok := bs.ClientStore.(ClientAdminStore)
+	if !ok {
+		return nil, errors.New("dormant clients require a ClientAdminStore")
+	}
+	clients, err := store.ListClients()
+	if err != nil {
+		return nil, err
+	}
+	var dormant []string
+	for _, c := range clients {
+		u, err := bs.UsageTracker.Usage(ClientUsage, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		if u.LastIssued.Before(since) {
+			dormant = append(dormant, c.ID)
+		}
+	}
+	sort.Strings(dormant)
+	return dormant, nil
+}
+
+// usageShards is the number of independently locked shards of the MemoryUsageTracker
+const usageShards = 16
+
+// DefaultMaxUsageEntries bounds the counters of each kind kept by the MemoryUsageTracker when MaxEntries is 0.
+const DefaultMaxUsageEntries = 100000
+
+// MemoryUsageTracker is an in-memory UsageTracker safe for concurrent use. The counters are spread over locked
+// shards by id, the least recently active counters are evicted beyond MaxEntries and PurgeExpired removes the counters
+// inactive for Retention.
+type MemoryUsageTracker struct {
+	// MaxEntries bounds the counters of each kind, DefaultMaxUsageEntries when 0
+	MaxEntries int
+	// Retention is the inactivity after which PurgeExpired removes the counters, never when 0
+	Retention time.Duration
+
+	shards [usageShards]usageShard
+}
+
+type usageShard struct {
+	mu    sync.RWMutex
+	usage map[UsageKind]map[string]*Usage
+}
+
+// NewMemoryUsageTracker creates an empty MemoryUsageTracker
+func NewMemoryUsageTracker() *MemoryUsageTracker {
+	m := new(MemoryUsageTracker)
+	for i := range m.shards {
+		m.shards[i].usage = map[UsageKind]map[string]*Usage{ClientUsage: {}, UserUsage: {}}
+	}
+	return m
+}
+
+// shard returns the shard of the id
+func (m *MemoryUsageTracker) shard(id string) *usageShard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &m.shards[h.Sum32()%usageShards]
+}
+
+// TrackIssued records tokens issued by the client to the credential
+func (m *MemoryUsageTracker) TrackIssued(clientID, credential string, tokenType TokenType, refreshed bool, at time.Time) error {
+	if clientID != "" {
+		m.update(ClientUsage, clientID, func(u *Usage) { u.issued(refreshed, at) })
+	}
+	if tokenType != ClientToken && credential != "" {
+		m.update(UserUsage, credential, func(u *Usage) { u.issued(refreshed, at) })
+	}
+	return nil
+}
+
+// TrackUsed records the use of an access token issued to the credential
+func (m *MemoryUsageTracker) TrackUsed(credential string, tokenType TokenType, at time.Time) error {
+	kind := UserUsage
+	if tokenType == ClientToken {
+		kind = ClientUsage
+	}
+	m.update(kind, credential, func(u *Usage) {
+		if at.After(u.LastUsed) {
+			u.LastUsed = at
+		}
+	})
+	return nil
+}
+
+// Usage returns the counters of the client or the user
+func (m *MemoryUsageTracker) Usage(kind UsageKind, id string) (Usage, error) {
+	s := m.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if u, ok := s.usage[kind][id]; ok {
+		return *u, nil
+	}
+	return Usage{}, nil
+}
+
+// ListUsage returns a page of the counters of the clients or the users sorted by id
+func (m *MemoryUsageTracker) ListUsage(kind UsageKind, after string, limit int) (*UsagePage, error) {
+	if limit <= 0 {
+		limit = DefaultUsagePageSize
+	}
+	var ids []string
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for id := range s.usage[kind] {
+			if id > after {
+				ids = append(ids, id)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	sort.Strings(ids)
+	page := &UsagePage{Usage: make(map[string]Usage, limit)}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		page.Next = ids[limit-1]
+	}
+	for _, id := range ids {
+		s := m.shard(id)
+		s.mu.RLock()
+		if u, ok := s.usage[kind][id]; ok {
+			page.Usage[id] = *u
+		}
+		s.mu.RUnlock()
+	}
+	return page, nil
+}
+
+// PurgeExpired removes the counters inactive for Retention before now, returning how many were removed
+func (m *MemoryUsageTracker) PurgeExpired(now time.Time) int {
+	if m.Retention <= 0 {
+		return 0
+	}
+	before := now.Add(-m.Retention)
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		for _, usage := range s.usage {
+			for id, u := range usage {
+				if u.lastActivity().Before(before) {
+					delete(usage, id)
+					n++
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// update applies the change to the counters of the id, created when missing, evicting the least recently active
+// counters of the shard beyond its share of MaxEntries
+func (m *MemoryUsageTracker) update(kind UsageKind, id string, change func(u *Usage)) {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := s.usage[kind]
+	u, ok := usage[id]
+	if !ok {
+		max := m.MaxEntries
+		if max <= 0 {
+			max = DefaultMaxUsageEntries
+		}
+		if max = (max + usageShards - 1) / usageShards; len(usage) >= max {
+			evictLeastActive(usage)
+		}
+		u = new(Usage)
+		usage[id] = u
+	}
+	change(u)
+}
+
+// evictLeastActive removes the least recently active counters
+func evictLeastActive(usage map[string]*Usage) {
+	var oldest string
+	var at time.Time
+	for id, u := range usage {
+		if last := u.lastActivity(); oldest == "" || last.Before(at) {
+			oldest, at = id, last
+		}
+	}
+	delete(usage, oldest)
+}
+
+// lastActivity returns the date of the last issuance or use
+func (u *Usage) lastActivity() time.Time {
+	if u.LastUsed.After(u.LastIssued) {
+		return u.LastUsed
+	}
+	return u.LastIssued
+}
+
+// issued increments the counters of the issued tokens
+func (u *Usage) issued(refreshed bool, at time.Time) {
+	if refreshed {
+		u.Refreshed++
+	} else {
+		u.Issued++
+	}
+	if at.After(u.LastIssued) {
+		u.LastIssued = at
+	}
+}
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUsageTracker(t *testing.T) {
+	tracker := NewMemoryUsageTracker()
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(adminVerifier), nil)
+	sut.UsageTracker = tracker
+	sut.ClientStore = NewMemoryClientStore(&Client{ID: "abcdef", AllowedGrantTypes: []GrantType{ClientCredentialsGrant, RefreshTokenGrant}}, &Client{ID: "dormant"},
+		&Client{ID: "web", Public: true, AllowedGrantTypes: []GrantType{PasswordGrant}})
+	start := time.Now().UTC()
+
+	resp, status := sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", "12345", "", "", "", "", new(http.Request))
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if _, status = sut.generateTokenResponse(RefreshTokenGrant, "abcdef", "12345", resp.(*TokenResponse).RefreshToken, "", "", "", new(http.Request)); status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if _, status = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", httptest.NewRequest("POST", "/token?client_id=web", nil)); status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+
+	if u, _ := tracker.Usage(ClientUsage, "abcdef"); u.Issued != 1 || u.Refreshed != 1 || u.LastIssued.Before(start) {
+		t.Fatalf("Error client usage = %+v", u)
+	}
+	if u, _ := tracker.Usage(UserUsage, "user111"); u.Issued != 1 || !u.LastUsed.IsZero() {
+		t.Fatalf("Error user usage = %+v", u)
+	}
+	if dormant, err := sut.DormantClients(start); err != nil || len(dormant) != 1 || dormant[0] != "dormant" {
+		t.Fatalf("Error dormant clients = %v, %v", dormant, err)
+	}
+
+	ba := NewBearerAuthentication("mySecretKey-10101", nil)
+	ba.UsageTracker = tracker
+	token, _ := sut.IssueToken(context.Background(), UserToken, "user111", "", nil)
+	req := httptest.NewRequest("GET", "/resource", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	ba.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	if u, _ := tracker.Usage(UserUsage, "user111"); u.LastUsed.Before(start) {
+		t.Fatalf("Error user usage = %+v", u)
+	}
+
+	admin, _ := sut.IssueToken(context.Background(), UserToken, "admin", DefaultAdminScope, nil)
+	req = httptest.NewRequest("GET", "/usage/users", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.Token)
+	w := httptest.NewRecorder()
+	sut.AdminHandler().ServeHTTP(w, req)
+	var users UsagePage
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil || users.Usage["user111"].Issued != 1 {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	// without ClientStore the client_id parameter of the password grant is not authenticated
+	sut = NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(adminVerifier), nil)
+	sut.UsageTracker = tracker
+	resp, status = sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", httptest.NewRequest("POST", "/token?client_id=spoofed", nil))
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if u, _ := tracker.Usage(ClientUsage, "spoofed"); u.Issued != 0 {
+		t.Fatalf("Error unauthenticated client usage = %+v", u)
+	}
+	resp, status = sut.generateTokenResponse(ClientCredentialsGrant, "abcdef", "12345", "", "", "", "", new(http.Request))
+	if status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if _, status = sut.generateTokenResponse(RefreshTokenGrant, "", "", resp.(*TokenResponse).RefreshToken, "", "", "", httptest.NewRequest("POST", "/token?client_id=spoofed", nil)); status != http.StatusOK {
+		t.Fatalf("Error StatusCode = %d", status)
+	}
+	if u, _ := tracker.Usage(ClientUsage, "abcdef"); u.Issued != 2 || u.Refreshed != 2 {
+		t.Fatalf("Error client usage = %+v", u)
+	}
+	if u, _ := tracker.Usage(ClientUsage, "spoofed"); u.Issued != 0 || u.Refreshed != 0 {
+		t.Fatalf("Error unauthenticated client usage = %+v", u)
+	}
+}
+
+func TestMemoryUsageTrackerBounds(t *testing.T) {
+	tracker := NewMemoryUsageTracker()
+	tracker.MaxEntries = usageShards
+	tracker.Retention = time.Hour
+	now := time.Now().UTC()
+	for i := 0; i < 100; i++ {
+		_ = tracker.TrackIssued(fmt.Sprintf("client%03d", i), "", ClientToken, false, now.Add(time.Duration(i)*time.Second))
+	}
+	var ids []string
+	for after := ""; ; {
+		page, err := tracker.ListUsage(ClientUsage, after, 3)
+		if err != nil || len(page.Usage) > 3 {
+			t.Fatalf("Error page = %+v, %v", page, err)
+		}
+		for id := range page.Usage {
+			ids = append(ids, id)
+		}
+		if after = page.Next; after == "" {
+			break
+		}
+	}
+	if len(ids) == 0 || len(ids) > 2*usageShards {
+		t.Fatalf("Error %d counters kept", len(ids))
+	}
+	if u, _ := tracker.Usage(ClientUsage, "client099"); u.Issued != 1 {
+		t.Fatalf("Error latest counter evicted")
+	}
+
+	_ = tracker.TrackIssued("stale", "", ClientToken, false, now.Add(-2*time.Hour))
+	if n := tracker.PurgeExpired(now); n != 1 {
+		t.Fatalf("Error purged = %d", n)
+	}
+}
@@ -0,0 +1,92 @@
+package oauth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate checks the server configuration returning a descriptive error for each problem found:
+// the verifier must implement the interfaces required by the grants served, the formatter must round-trip
+// the tokens and the TTLs must be consistent. Call it at startup to fail fast instead of serving 400/500 responses.
+func (bs *BearerServer) Validate(grants ...GrantType) error {
+	var problems []string
+	if bs.verifier == nil {
+		// the VerifierSelector is not called for the requests without authenticated client, nor for its nil verifiers
+		problems = append(problems, "the credentials verifier is nil")
+	}
+	for _, grantType := range grants {
+		if err := bs.supportsGrant(grantType); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if err := bs.checkFormatter(); err != nil {
+		problems = append(problems, "the token formatter does not round-trip: "+err.Error())
+	}
+	if bs.secrets != nil && bs.provider.secureFormatter != TokenSecureFormatter(bs.secrets) {
+		problems = append(problems, "WithSecret requires a nil TokenSecureFormatter, the custom one does not crypt the tokens with the reloadable secret")
+	}
+	if bs.TokenTTL < 0 || bs.RefreshTokenTTL < 0 || bs.RefreshTokenMaxLifetime < 0 {
+		problems = append(problems, "token lifetimes cannot be negative")
+	}
+	if bs.TokenTTL > 0 && bs.RefreshTokenTTL > 0 && bs.TokenTTL > bs.RefreshTokenTTL {
+		problems = append(problems, fmt.Sprintf("TokenTTL (%s) exceeds RefreshTokenTTL (%s)", bs.TokenTTL, bs.RefreshTokenTTL))
+	}
+	if bs.RefreshTokenMaxLifetime > 0 && bs.RefreshTokenTTL > bs.RefreshTokenMaxLifetime {
+		problems = append(problems, fmt.Sprintf("RefreshTokenTTL (%s) exceeds RefreshTokenMaxLifetime (%s)", bs.RefreshTokenTTL, bs.RefreshTokenMaxLifetime))
+	}
+	if bs.StoreTokenIDPolicy.FailOpen && bs.Events == nil {
+		problems = append(problems, "StoreTokenIDPolicy.FailOpen requires Events to audit the unstored tokens")
+	}
+	for _, issuer := range bs.TrustedIssuers {
+		if issuer != nil && issuer.Audience == "" {
+			problems = append(problems, fmt.Sprintf("the TrustedIssuer %q has no Audience", issuer.Issuer))
+		}
+	}
+	if bs.FederationMapper != nil && bs.TranslationReplayCache == nil {
+		problems = append(problems, ErrTranslationReplayCacheRequired.Error())
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid server configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// supportsGrant checks that the configuration can serve the grant type
+func (bs *BearerServer) supportsGrant(grantType GrantType) error {
+	switch grantType {
+	case PasswordGrant, ClientCredentialsGrant, RefreshTokenGrant:
+		return nil
+	case AuthCodeGrant:
+		if _, ok := optionalVerifier(bs.verifier).(AuthorizationCodeVerifier); !ok && !bs.StatelessAuthorizationCodes && bs.AuthCodeStore == nil {
+			return fmt.Errorf("grant %s requires the verifier to implement AuthorizationCodeVerifier", grantType)
+		}
+		if bs.StatelessAuthorizationCodes && bs.CodeReplayCache == nil {
+			return fmt.Errorf("grant %s: %w", grantType, ErrCodeReplayCacheRequired)
+		}
+		return nil
+	default:
+		if handler, ok := bs.assertionGrants[grantType]; ok && handler != nil {
+			return nil
+		}
+		return fmt.Errorf("grant %s has no registered AssertionGrantHandler", grantType)
+	}
+}
+
+// checkFormatter crypts and decrypts a sample payload
+func (bs *BearerServer) checkFormatter() error {
+	sample := []byte(`{"token_id":"self-test"}`)
+	token, err := bs.provider.crypt(sample)
+	if err != nil {
+		return err
+	}
+	decrypted, err := bs.provider.decrypt(token)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sample, decrypted) {
+		return errors.New("decrypted payload differs from the original")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// brokenFormatter corrupts the tokens for testing.
+type brokenFormatter struct{}
+
+func (brokenFormatter) CryptToken(source []byte) ([]byte, error)   { return source, nil }
+func (brokenFormatter) DecryptToken(source []byte) ([]byte, error) { return source[1:], nil }
+
+func TestValidate(t *testing.T) {
+	if err := _sut.Validate(PasswordGrant, ClientCredentialsGrant, RefreshTokenGrant); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+
+	sut := NewBearerServer("mySecretKey-10101", time.Minute, time.Second, new(TestUserVerifier), brokenFormatter{})
+	sut.TrustedIssuers = []*TrustedIssuer{{Issuer: "https://idp", Keys: StaticKeys{}}}
+	sut.FederationMapper = testFederationMapper{}
+	err := sut.Validate(AuthCodeGrant, JWTBearerGrant)
+	if err == nil {
+		t.Fatalf("Error should have occurred")
+	}
+	for _, problem := range []string{"AuthorizationCodeVerifier", "AssertionGrantHandler", "round-trip", "exceeds RefreshTokenTTL", "has no Audience", "TranslationReplayCache"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("Error %q should report %s", err.Error(), problem)
+		}
+	}
+
+	sut = NewBearerServer("mySecretKey-10101", time.Second, time.Minute, nil, nil)
+	sut.VerifierSelector = func(clientID string) CredentialsVerifier { return new(TestUserVerifier) }
+	if err = sut.Validate(PasswordGrant); err == nil || !strings.Contains(err.Error(), "the credentials verifier is nil") {
+		t.Fatalf("Error the nil verifier should be reported with a VerifierSelector: %v", err)
+	}
+}
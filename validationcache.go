@@ -0,0 +1,132 @@
+package oauth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ValidationCache defaults
+const (
+	DefaultValidationTTL         = 30 * time.Second
+	DefaultNegativeValidationTTL = 5 * time.Second
+	DefaultValidationCacheSize   = 10000
+)
+
+// ValidationCache caches the results of the token validations of the BearerAuthentication middleware, so the bursts
+// of requests with the same token decrypt it once: the concurrent validations of a token are deduplicated
+// (singleflight), the valid tokens are cached up to TTL and the rejected ones up to NegativeTTL.
+// The results are cached per middleware and Audience, so a cache shared by middlewares with different keys, audiences
+// or TrustedIssuers does not let the tokens of one through the other. The expiry and the Denylist are checked again on
+// each cache hit, the other changes are only seen when the entry expires: a deleted reference token, a key removed
+// from a TrustedIssuer or a changed TrustedIssuers list keep accepting the cached tokens up to TTL, and the tokens
+// rejected before the change stay rejected up to NegativeTTL. The cached tokens are shared, do not modify them.
+type ValidationCache struct {
+	// TTL caches the valid tokens, up to their expiry
+	TTL time.Duration
+	// NegativeTTL caches the malformed, expired, revoked and wrong audience tokens
+	NegativeTTL time.Duration
+	// MaxEntries bounds the cache, the expired entries are pruned when it is full and the cache cleared if still full
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[validationKey]validationEntry
+	calls   map[validationKey]*validationCall
+}
+
+// validationKey identifies a cached validation: the token and the middleware with the audience it was validated for
+type validationKey struct {
+	owner    *BearerAuthentication
+	audience string
+	raw      string
+}
+
+// validationEntry is a cached validation result
+type validationEntry struct {
+	token     *Token
+	err       error
+	expiresAt time.Time
+}
+
+// validationCall is an in-flight validation the concurrent callers wait for
+type validationCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// NewValidationCache creates a ValidationCache with the default TTLs and size
+func NewValidationCache() *ValidationCache {
+	return &ValidationCache{TTL: DefaultValidationTTL, NegativeTTL: DefaultNegativeValidationTTL, MaxEntries: DefaultValidationCacheSize}
+}
+
+// Len returns the number of cached results, expired ones included until pruned
+func (c *ValidationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// validate returns the cached result of the token, or calls validate once for all the concurrent callers
+func (c *ValidationCache) validate(key validationKey, validate func(raw string) (*Token, error)) (*Token, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && now.Before(e.expiresAt) {
+		c.mu.Unlock()
+		return e.token, e.err
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+	if c.calls == nil {
+		c.calls = make(map[validationKey]*validationCall)
+	}
+	call := &validationCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.store(key, call.token, call.err, now)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.token, call.err = validate(key.raw)
+	return call.token, call.err
+}
+
+// store caches the validation result, the transient errors (e.g. of the ReferenceTokenStore) are not cached
+func (c *ValidationCache) store(key validationKey, token *Token, err error, now time.Time) {
+	var expiresAt time.Time
+	switch {
+	case err == nil:
+		expiresAt = now.Add(c.TTL)
+		if token.ExpiresIn > 0 && token.CreationDate.Add(token.ExpiresIn).Before(expiresAt) {
+			expiresAt = token.CreationDate.Add(token.ExpiresIn)
+		}
+	case errors.Is(err, ErrMalformedToken), errors.Is(err, ErrExpiredToken), errors.Is(err, ErrRevokedToken), errors.Is(err, ErrInvalidAudience):
+		expiresAt = now.Add(c.NegativeTTL)
+	default:
+		return
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[validationKey]validationEntry)
+	}
+	if c.MaxEntries > 0 && len(c.entries) >= c.MaxEntries {
+		for key, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= c.MaxEntries {
+			c.entries = make(map[validationKey]validationEntry)
+		}
+	}
+	c.entries[key] = validationEntry{token: token, err: err, expiresAt: expiresAt}
+}
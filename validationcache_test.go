@@ -0,0 +1,110 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidationCache(t *testing.T) {
+	cache := NewValidationCache()
+	var calls int32
+	release := make(chan struct{})
+	validate := func(raw string) (*Token, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		if raw == "garbage" {
+			return nil, ErrMalformedToken
+		}
+		return &Token{ID: raw, CreationDate: time.Now().UTC(), ExpiresIn: time.Hour}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := cache.validate(validationKey{raw: "token"}, validate); err != nil || token.ID != "token" {
+				t.Errorf("Error token = %v, err = %v", token, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if _, err := cache.validate(validationKey{raw: "token"}, validate); err != nil || calls != 1 {
+		t.Fatalf("Error calls = %d, err = %v", calls, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.validate(validationKey{raw: "garbage"}, validate); !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("Error should be ErrMalformedToken: %v", err)
+		}
+	}
+	if calls != 2 || cache.Len() != 2 {
+		t.Fatalf("Error calls = %d, len = %d", calls, cache.Len())
+	}
+
+	transient := errors.New("reference store down")
+	if _, err := cache.validate(validationKey{raw: "reference"}, func(string) (*Token, error) { return nil, transient }); err != transient || cache.Len() != 2 {
+		t.Fatalf("Error the transient error should not be cached: %v", err)
+	}
+}
+
+func TestValidateTokenCached(t *testing.T) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		t.Fatalf("Error StatusCode = %d", code)
+	}
+	mut := NewBearerAuthentication("mySecretKey-10101", nil)
+	mut.Cache = NewValidationCache()
+	mut.Denylist = NewDenylist(100, 0.01)
+	token, err := mut.ValidateToken(resp.(*TokenResponse).Token)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	mut.Denylist.Add(token.ID, time.Now().Add(time.Hour))
+	if _, err = mut.ValidateToken(resp.(*TokenResponse).Token); !errors.Is(err, ErrRevokedToken) {
+		t.Fatalf("Error the cached token should be revoked: %v", err)
+	}
+
+	mut.Denylist = nil
+	other := NewBearerAuthentication("mySecretKey-10101", nil)
+	other.Cache, other.Audience = mut.Cache, "https://billing.example.com"
+	if _, err = other.ValidateToken(resp.(*TokenResponse).Token); !errors.Is(err, ErrInvalidAudience) {
+		t.Fatalf("Error the cached token should be checked against the audience: %v", err)
+	}
+	other.Audience = ""
+	if _, err = other.ValidateToken(resp.(*TokenResponse).Token); err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	foreign := NewBearerAuthentication("otherSecretKey-20202", nil)
+	foreign.Cache = mut.Cache
+	if _, err = foreign.ValidateToken(resp.(*TokenResponse).Token); err == nil {
+		t.Fatalf("Error the cached token accepted by the middleware of another key")
+	}
+}
+
+func BenchmarkValidateToken(b *testing.B) {
+	resp, code := _sut.generateTokenResponse(PasswordGrant, "user111", "password111", "", "", "", "", new(http.Request))
+	if code != 200 {
+		b.Fatalf("Error StatusCode = %d", code)
+	}
+	raw := resp.(*TokenResponse).Token
+	for name, cache := range map[string]*ValidationCache{"uncached": nil, "cached": NewValidationCache()} {
+		mut := NewBearerAuthentication("mySecretKey-10101", nil)
+		mut.Cache = cache
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := mut.ValidateToken(raw); err != nil {
+						b.Fatalf("Error %s", err.Error())
+					}
+				}
+			})
+		})
+	}
+}
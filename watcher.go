@@ -0,0 +1,92 @@
+package oauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often the TokenWatcher polls the Denylist when its Interval is zero.
+const DefaultWatchInterval = 5 * time.Second
+
+type watchKey struct{}
+
+// watchState is the lapse of the watched token
+type watchState struct {
+	mu      sync.Mutex
+	err     error
+	expires time.Time
+}
+
+// TokenWatcher signals the expiry and the revocation of the tokens of the long-lived connections (WebSocket,
+// server-sent events, gRPC streams), so the streaming handlers can terminate the sessions whose credentials lapsed.
+type TokenWatcher struct {
+	// Denylist, when set, is polled for the revocation of the watched tokens
+	Denylist *Denylist
+	// Interval is the Denylist polling interval, DefaultWatchInterval when zero
+	Interval time.Duration
+}
+
+// NewTokenWatcher creates a TokenWatcher polling the denylist, nil to only watch the expiry
+func NewTokenWatcher(denylist *Denylist) *TokenWatcher {
+	return &TokenWatcher{Denylist: denylist}
+}
+
+// Watch returns a copy of the context cancelled when the token expires (its deadline) or is revoked, TokenLapse
+// reports which. Call the cancel function when the connection ends to release the watch.
+func (tw *TokenWatcher) Watch(ctx context.Context, token *Token) (context.Context, context.CancelFunc) {
+	state := new(watchState)
+	ctx = context.WithValue(ctx, watchKey{}, state)
+	var cancel context.CancelFunc
+	if token.ExpiresIn > 0 {
+		state.expires = token.CreationDate.Add(token.ExpiresIn)
+		ctx, cancel = context.WithDeadline(ctx, state.expires)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	if tw.Denylist != nil {
+		go tw.poll(ctx, token, func() {
+			state.mu.Lock()
+			state.err = ErrRevokedToken
+			state.mu.Unlock()
+			cancel()
+		})
+	}
+	return ctx, cancel
+}
+
+// poll calls revoked when the token is added to the Denylist, and returns when the context is done
+func (tw *TokenWatcher) poll(ctx context.Context, token *Token, revoked func()) {
+	interval := tw.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if tw.Denylist.Contains(token.ID) {
+				revoked()
+				return
+			}
+		}
+	}
+}
+
+// TokenLapse returns ErrExpiredToken or ErrRevokedToken when the context of Watch was cancelled by the lapse of its
+// token, nil otherwise
+func TokenLapse(ctx context.Context) error {
+	state, ok := ctx.Value(watchKey{}).(*watchState)
+	if !ok {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.err == nil && ctx.Err() == context.DeadlineExceeded && !state.expires.IsZero() && !time.Now().Before(state.expires) {
+		return ErrExpiredToken
+	}
+	return state.err
+}
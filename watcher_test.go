@@ -0,0 +1,43 @@
+package oauth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenWatcher(t *testing.T) {
+	expiring := &Token{ID: "expiring", CreationDate: time.Now().UTC(), ExpiresIn: 50 * time.Millisecond}
+	ctx, cancel := NewTokenWatcher(nil).Watch(context.Background(), expiring)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Error the expired token was not signaled")
+	}
+	if err := TokenLapse(ctx); err != ErrExpiredToken {
+		t.Fatalf("Error TokenLapse = %v", err)
+	}
+
+	denylist := NewDenylist(100, 0.01)
+	watcher := &TokenWatcher{Denylist: denylist, Interval: 10 * time.Millisecond}
+	revoked := &Token{ID: "revoked", CreationDate: time.Now().UTC(), ExpiresIn: time.Hour}
+	ctx, cancel = watcher.Watch(context.Background(), revoked)
+	defer cancel()
+	denylist.Add(revoked.ID, time.Now().Add(time.Hour))
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Error the revoked token was not signaled")
+	}
+	if err := TokenLapse(ctx); err != ErrRevokedToken {
+		t.Fatalf("Error TokenLapse = %v", err)
+	}
+
+	ctx, cancel = watcher.Watch(context.Background(), &Token{ID: "valid", CreationDate: time.Now().UTC(), ExpiresIn: time.Hour})
+	cancel()
+	<-ctx.Done()
+	if err := TokenLapse(ctx); err != nil {
+		t.Fatalf("Error TokenLapse = %v", err)
+	}
+}
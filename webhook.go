@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Webhook headers, the signature is the hex HMAC-SHA256 of "<timestamp>.<body>" prefixed with "sha256="
+const (
+	WebhookSignatureHeader = "X-OAuth-Signature"
+	WebhookTimestampHeader = "X-OAuth-Timestamp"
+	WebhookEventHeader     = "X-OAuth-Event"
+)
+
+// WebhookNotifier defaults
+const (
+	DefaultWebhookWorkers    = 4
+	DefaultWebhookQueueSize  = 1024
+	DefaultWebhookMaxRetries = 3
+	DefaultWebhookBackoff    = time.Second
+	DefaultWebhookTimeout    = 10 * time.Second
+)
+
+// ErrWebhookNotifierUninitialized is returned by Run and reported to OnError by Publish when the WebhookNotifier
+// was not created by NewWebhookNotifier or NewWebhookNotifierSize.
+var ErrWebhookNotifierUninitialized = errors.New("WebhookNotifier must be created by NewWebhookNotifier")
+
+// WebhookNotifier is an EventPublisher POSTing the signed JSON events to the URLs from a pool of workers,
+// failed deliveries are retried with exponential backoff. It must be created by NewWebhookNotifier or
+// NewWebhookNotifierSize, and Run must be started, e.g. with AddBackgroundTask.
+type WebhookNotifier struct {
+	URLs   []string
+	Secret []byte
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Timeout bounds each delivery attempt, DefaultWebhookTimeout when 0, so an unresponsive URL does not hold a worker
+	Timeout time.Duration
+	// Workers is the number of concurrent deliveries, DefaultWebhookWorkers when 0
+	Workers int
+	// MaxRetries is the number of retries of a failed delivery, DefaultWebhookMaxRetries when 0, none when negative
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled at each retry, DefaultWebhookBackoff when 0
+	Backoff time.Duration
+	// OnError, when set, is called when a delivery is abandoned
+	OnError func(url string, event *Event, err error)
+
+	queue   chan webhookDelivery
+	dropped int64
+}
+
+// webhookDelivery is an event to deliver to an URL
+type webhookDelivery struct {
+	url   string
+	event *Event
+}
+
+// NewWebhookNotifier creates a WebhookNotifier queuing up to DefaultWebhookQueueSize deliveries
+func NewWebhookNotifier(secret []byte, urls ...string) *WebhookNotifier {
+	return NewWebhookNotifierSize(DefaultWebhookQueueSize, secret, urls...)
+}
+
+// NewWebhookNotifierSize creates a WebhookNotifier queuing up to queueSize deliveries
+func NewWebhookNotifierSize(queueSize int, secret []byte, urls ...string) *WebhookNotifier {
+	return &WebhookNotifier{URLs: urls, Secret: secret, queue: make(chan webhookDelivery, queueSize)}
+}
+
+// Publish queues the event for each URL, deliveries are dropped when the queue is full
+func (n *WebhookNotifier) Publish(event *Event) {
+	for _, url := range n.URLs {
+		if n.queue == nil {
+			atomic.AddInt64(&n.dropped, 1)
+			if n.OnError != nil {
+				n.OnError(url, event, ErrWebhookNotifierUninitialized)
+			}
+			continue
+		}
+		select {
+		case n.queue <- webhookDelivery{url: url, event: event}:
+		default:
+			atomic.AddInt64(&n.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of deliveries dropped because the queue was full
+func (n *WebhookNotifier) Dropped() int64 {
+	return atomic.LoadInt64(&n.dropped)
+}
+
+// Run delivers the queued events until the context is done
+func (n *WebhookNotifier) Run(ctx context.Context) error {
+	if n.queue == nil {
+		return ErrWebhookNotifierUninitialized
+	}
+	workers := n.Workers
+	if workers <= 0 {
+		workers = DefaultWebhookWorkers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-n.queue:
+					if err := n.deliver(ctx, d); err != nil && n.OnError != nil {
+						n.OnError(d.url, d.event, err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// deliver POSTs the event, retrying the network errors, 429 and 5xx responses
+func (n *WebhookNotifier) deliver(ctx context.Context, d webhookDelivery) error {
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		return err
+	}
+	retries, backoff := n.MaxRetries, n.Backoff
+	if retries == 0 {
+		retries = DefaultWebhookMaxRetries
+	}
+	if backoff <= 0 {
+		backoff = DefaultWebhookBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, d, body)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+		timer := time.NewTimer(backoff << uint(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// post sends the signed request returning whether the failure can be retried
+func (n *WebhookNotifier) post(ctx context.Context, d webhookDelivery, body []byte) (bool, error) {
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(d.event.Type))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(n.Secret, timestamp, body))
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook %s responded %d", d.url, resp.StatusCode)
+}
+
+// SignWebhook returns the signature of the webhook body sent at timestamp
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature of a received webhook in constant time
+func VerifyWebhook(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,100 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := []byte("webhook-secret")
+	var calls int32
+	received := make(chan *Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !VerifyWebhook(secret, r.Header.Get(WebhookTimestampHeader), body, r.Header.Get(WebhookSignatureHeader)) {
+			t.Errorf("Error invalid signature %s", r.Header.Get(WebhookSignatureHeader))
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		event := new(Event)
+		_ = json.Unmarshal(body, event)
+		received <- event
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(secret, srv.URL)
+	n.Backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- n.Run(ctx) }()
+
+	n.Publish(&Event{ID: "e1", Type: TokenIssuedEvent, Credential: "user111"})
+	select {
+	case event := <-received:
+		if event.ID != "e1" || event.Type != TokenIssuedEvent {
+			t.Fatalf("Error event = %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error webhook not delivered")
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Error calls = %d", calls)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Error %v", err)
+	}
+
+	full := NewWebhookNotifierSize(1, secret, srv.URL)
+	full.Publish(&Event{ID: "e2"})
+	full.Publish(&Event{ID: "e3"})
+	if full.Dropped() != 1 {
+		t.Fatalf("Error dropped = %d", full.Dropped())
+	}
+
+	var reported error
+	zero := &WebhookNotifier{URLs: []string{srv.URL}, OnError: func(url string, event *Event, err error) { reported = err }}
+	zero.Publish(&Event{ID: "e4"})
+	if reported != ErrWebhookNotifierUninitialized || zero.Dropped() != 1 {
+		t.Fatalf("Error zero value Publish = %v, dropped = %d", reported, zero.Dropped())
+	}
+	if err := zero.Run(context.Background()); err != ErrWebhookNotifierUninitialized {
+		t.Fatalf("Error zero value Run = %v", err)
+	}
+}
+
+func TestWebhookNotifierTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	n := NewWebhookNotifier([]byte("webhook-secret"), srv.URL)
+	n.Timeout, n.MaxRetries = 50*time.Millisecond, -1
+	abandoned := make(chan error, 1)
+	n.OnError = func(url string, event *Event, err error) { abandoned <- err }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = n.Run(ctx) }()
+
+	n.Publish(&Event{ID: "e1", Type: TokenIssuedEvent})
+	select {
+	case err := <-abandoned:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error the delivery to the unresponsive URL did not time out")
+	}
+}
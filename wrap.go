@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// WrapTTLHeader is the token request header asking for the response wrapping, its value is the lifetime of the
+	// wrapping token as a duration ("60s") or a number of seconds.
+	WrapTTLHeader = "X-Wrap-TTL"
+	// DefaultMaxWrapTTL bounds the requested wrapping lifetimes when BearerServer.MaxWrapTTL is 0.
+	DefaultMaxWrapTTL = 5 * time.Minute
+)
+
+// ErrWrapNotFound is returned by the WrapStore when the wrapping token is unknown, expired or already unwrapped.
+var ErrWrapNotFound = errors.New("wrapping token not found")
+
+// WrapInfo describes the single-use wrapping token returned instead of the token response.
+type WrapInfo struct {
+	Token        string    `json:"token"`
+	TTL          int64     `json:"ttl"`
+	CreationTime time.Time `json:"creation_time"`
+}
+
+// WrappedResponse is the token response replaced by its wrapping token.
+type WrappedResponse struct {
+	WrapInfo WrapInfo `json:"wrap_info"`
+}
+
+// WrapStore stores the wrapped token responses until they are unwrapped or expire.
+type WrapStore interface {
+	// Put stores the response under the wrapping token until expiresAt
+	Put(token string, resp *TokenResponse, expiresAt time.Time) error
+	// Take removes and returns the response of the wrapping token, ErrWrapNotFound when it is unknown or expired.
+	// It must be atomic so the response is unwrapped only once.
+	Take(token string) (*TokenResponse, error)
+}
+
+// MemoryWrapStore is an in-memory WrapStore safe for concurrent use.
+type MemoryWrapStore struct {
+	mu      sync.Mutex
+	entries map[string]wrapEntry
+}
+
+type wrapEntry struct {
+	resp      *TokenResponse
+	expiresAt time.Time
+}
+
+// NewMemoryWrapStore creates an empty MemoryWrapStore.
+func NewMemoryWrapStore() *MemoryWrapStore {
+	return &MemoryWrapStore{entries: make(map[string]wrapEntry)}
+}
+
+// Put stores the response under the wrapping token until expiresAt
+func (s *MemoryWrapStore) Put(token string, resp *TokenResponse, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[token] = wrapEntry{resp: resp, expiresAt: expiresAt}
+	return nil
+}
+
+// Take removes and returns the response of the wrapping token
+func (s *MemoryWrapStore) Take(token string) (*TokenResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[token]
+	if !ok {
+		return nil, ErrWrapNotFound
+	}
+	delete(s.entries, token)
+	if !time.Now().Before(e.expiresAt) {
+		return nil, ErrWrapNotFound
+	}
+	return e.resp, nil
+}
+
+// PurgeExpired removes the responses expired before now, returning how many were removed
+func (s *MemoryWrapStore) PurgeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for token, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, token)
+			n++
+		}
+	}
+	return n
+}
+
+// wrapTTL returns the wrapping lifetime requested in the WrapTTLHeader bounded by MaxWrapTTL, 0 when not requested
+func (bs *BearerServer) wrapTTL(r *http.Request) (time.Duration, error) {
+	if r == nil || bs.WrapStore == nil {
+		return 0, nil
+	}
+	value := strings.TrimSpace(r.Header.Get(WrapTTLHeader))
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, errors.New("invalid " + WrapTTLHeader)
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl <= 0 {
+		return 0, errors.New("invalid " + WrapTTLHeader)
+	}
+	maxTTL := bs.MaxWrapTTL
+	if maxTTL <= 0 {
+		maxTTL = DefaultMaxWrapTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl, nil
+}
+
+// checkWrapTTL renders invalid_request when the WrapTTLHeader is invalid, before the grant issues the tokens
+func (bs *BearerServer) checkWrapTTL(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := bs.wrapTTL(r); err != nil {
+		bs.renderError(w, r, TokenInvalidRequest, err.Error(), "", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// wrap stores the token response in the WrapStore and returns its wrapping token response
+func (bs *BearerServer) wrap(resp *TokenResponse, ttl time.Duration) (interface{}, int) {
+	now := time.Now().UTC()
+	info := WrapInfo{Token: uuid.Must(uuid.NewV4()).String(), TTL: int64(ttl / time.Second), CreationTime: now}
+	if err := bs.WrapStore.Put(info.Token, resp, now.Add(ttl)); err != nil {
+		return ErrorResponse{Error: TokenServerError, Description: "wrapping response failed"}, http.StatusInternalServerError
+	}
+	return &WrappedResponse{WrapInfo: info}, http.StatusOK
+}
+
+// Unwrap is the endpoint returning the token response of the single-use wrapping token sent as bearer token or in the
+// token form parameter. It is not mounted by RegisterHandlers.
+func (bs *BearerServer) Unwrap(w http.ResponseWriter, r *http.Request) {
+	r = bs.withRequestID(w, r)
+	bs.setSecurityHeaders(w)
+	w = trackResponse(w)
+	defer bs.recoverPanic(w, r)
+	if bs.WrapStore == nil {
+		bs.renderError(w, r, TokenInvalidRequest, "response wrapping is not enabled", "", http.StatusNotFound)
+		return
+	}
+	if !bs.checkHTTPS(w, r) || !bs.parseForm(w, r) {
+		return
+	}
+	token := r.FormValue("token")
+	if auth := r.Header.Get("Authorization"); token == "" && len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		token = auth[7:]
+	}
+	if token == "" {
+		bs.renderError(w, r, TokenInvalidRequest, "wrapping token is required", "", http.StatusBadRequest)
+		return
+	}
+	resp, err := bs.WrapStore.Take(token)
+	if err == ErrWrapNotFound {
+		bs.renderError(w, r, TokenInvalidGrant, "invalid wrapping token", "", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		bs.renderError(w, r, TokenServerError, "unwrapping response failed", "", http.StatusInternalServerError)
+		return
+	}
+	bs.renderNegotiated(w, r, resp, true, http.StatusOK)
+}
@@ -0,0 +1,93 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseWrapping(t *testing.T) {
+	sut := NewBearerServer("mySecretKey-10101", time.Second*10, time.Second*60, new(TestUserVerifier), nil)
+	sut.WrapStore = NewMemoryWrapStore()
+	sut.MaxWrapTTL = time.Minute
+	events := new(recordingPublisher)
+	sut.Events = events
+	hooked := 0
+	sut.OnTokenResponse = func(ctx context.Context, grantType GrantType, resp *TokenResponse, w http.ResponseWriter, r *http.Request) {
+		hooked++
+	}
+
+	token := func(wrapTTL string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"password"}, "username": {"user111"}, "password": {"password111"}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if wrapTTL != "" {
+			req.Header.Set(WrapTTLHeader, wrapTTL)
+		}
+		w := httptest.NewRecorder()
+		sut.Token(w, req)
+		return w
+	}
+	unwrap := func(wrappingToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/unwrap", nil)
+		req.Header.Set("Authorization", "Bearer "+wrappingToken)
+		w := httptest.NewRecorder()
+		sut.Unwrap(w, req)
+		return w
+	}
+
+	w := token("10m")
+	var wrapped WrappedResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &wrapped) != nil || strings.Contains(w.Body.String(), "access_token") {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if wrapped.WrapInfo.Token == "" || wrapped.WrapInfo.TTL != 60 {
+		t.Fatalf("Error wrap_info = %+v", wrapped.WrapInfo)
+	}
+	if hooked != 0 {
+		t.Fatalf("Error OnTokenResponse called with the wrapped response")
+	}
+	w = unwrap(wrapped.WrapInfo.Token)
+	var resp TokenResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Error Cache-Control = %s", w.Header().Get("Cache-Control"))
+	}
+	if w = unwrap(wrapped.WrapInfo.Token); w.Code != http.StatusBadRequest {
+		t.Fatalf("Error the wrapping token should be single use: StatusCode = %d", w.Code)
+	}
+
+	issued := len(events.events)
+	if issued == 0 {
+		t.Fatalf("Error no issuance event")
+	}
+	if w = token("forever"); w.Code != http.StatusBadRequest || len(events.events) != issued {
+		t.Fatalf("Error StatusCode = %d, the grant should not run: events = %d", w.Code, len(events.events)-issued)
+	}
+	if w = token(""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "access_token") || hooked != 1 {
+		t.Fatalf("Error StatusCode = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMemoryWrapStoreExpiry(t *testing.T) {
+	store := NewMemoryWrapStore()
+	store.Put("expired", &TokenResponse{Token: "t"}, time.Now().Add(-time.Second))
+	store.Put("live", &TokenResponse{Token: "t"}, time.Now().Add(time.Minute))
+	if _, err := store.Take("expired"); err != ErrWrapNotFound {
+		t.Fatalf("Error Take = %v", err)
+	}
+	store.Put("expired", &TokenResponse{Token: "t"}, time.Now().Add(-time.Second))
+	if n := store.PurgeExpired(time.Now()); n != 1 {
+		t.Fatalf("Error PurgeExpired = %d", n)
+	}
+	if resp, err := store.Take("live"); err != nil || resp.Token != "t" {
+		t.Fatalf("Error Take = %v, %v", resp, err)
+	}
+}